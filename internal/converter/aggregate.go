@@ -0,0 +1,510 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// groupsField holds the group of a query without GROUP BY inside the $facet that outputs it
+const groupsField = "groups"
+
+// accumulatorOperators maps SQL aggregate functions to $group accumulators
+var accumulatorOperators = map[string]string{
+	"COUNT": "$sum",
+	"SUM":   "$sum",
+	"AVG":   "$avg",
+	"MIN":   "$min",
+	"MAX":   "$max",
+}
+
+// groupStage builds the $group stage of a query that aggregates rows
+type groupStage struct {
+	id           interface{}
+	accumulators mongo.Document
+	// finalizers turn accumulated values into the aggregate result, such as the
+	// size of the set collected for a COUNT(DISTINCT x)
+	finalizers mongo.Document
+	// input resolves the columns of the documents being grouped
+	input *scope
+	// output resolves aggregates and group keys to fields of the grouped documents
+	output *scope
+	// groupingSets tells for each grouping set of a GROUP BY with GROUPING SETS, ROLLUP or CUBE
+	// which keys it groups by, and is nil for a plain GROUP BY
+	groupingSets [][]bool
+	// groupings holds the GROUPING calls of a query with grouping sets
+	groupings []groupingCall
+	// warnings explain accumulators that only approximate their aggregate
+	warnings []string
+	// counts holds the accumulators that count rows, which are 0 rather than null over no rows
+	counts map[string]bool
+}
+
+// groupKey is an expression of the GROUP BY clause and the name it is stored under
+type groupKey struct {
+	name string
+	expr sql.Expr
+}
+
+// newGroupStage returns a group stage that collapses all input documents into one
+func newGroupStage(input *scope) *groupStage {
+	output := newScope()
+	output.grouped = true
+	output.options = input.options
+	return &groupStage{input: input, output: output, counts: map[string]bool{}}
+}
+
+// setKeys groups documents by the given keys, storing a single key directly in _id
+// and several keys, or the keys of grouping sets, as fields of an _id document
+func (g *groupStage) setKeys(keys []groupKey) error {
+	if len(keys) == 1 && g.groupingSets == nil {
+		value, err := convertExpression(keys[0].expr, g.input)
+		if err != nil {
+			return err
+		}
+		g.id = value
+		g.output.fields[keys[0].expr.String()] = "_id"
+		return nil
+	}
+	var id mongo.Document
+	for _, key := range keys {
+		value, err := convertExpression(key.expr, g.input)
+		if err != nil {
+			return err
+		}
+		name := fieldName(key.name)
+		for i := 2; indexOf(id, name) != -1; i++ {
+			name = fmt.Sprintf("%s_%d", fieldName(key.name), i)
+		}
+		id = append(id, mongo.Element{Key: name, Value: value})
+		g.output.fields[key.expr.String()] = "_id." + name
+	}
+	g.id = id
+	return nil
+}
+
+// accumulate adds an accumulator for an aggregate call unless an equal one exists,
+// and returns the field that holds its value
+func (g *groupStage) accumulate(call *sql.FuncCall, name string) (string, error) {
+	if isGrouping(call) {
+		return g.grouping(call)
+	}
+	if field, ok := g.output.fields[call.String()]; ok {
+		return field, nil
+	}
+	field := g.uniqueField(fieldName(name))
+	position := len(g.accumulators)
+	accumulator, finalizer, err := g.convertAggregate(call, field)
+	if err != nil {
+		return "", err
+	}
+	// the accumulators a call adds for itself, such as the sums a CORR is computed from, follow its own
+	helpers := append(mongo.Document{}, g.accumulators[position:]...)
+	g.accumulators = append(append(g.accumulators[:position], mongo.Element{Key: field, Value: accumulator}), helpers...)
+	if finalizer != nil {
+		g.finalizers = append(g.finalizers, mongo.Element{Key: field, Value: finalizer})
+	}
+	g.output.fields[call.String()] = field
+	return field, nil
+}
+
+// accumulateAll adds accumulators for every aggregate call inside an expression
+func (g *groupStage) accumulateAll(expr sql.Expr) error {
+	var err error
+	sql.Walk(expr, func(e sql.Expr) bool {
+		if err != nil {
+			return false
+		}
+		if call, ok := e.(*sql.FuncCall); ok && sql.IsAggregate(call) {
+			_, err = g.accumulate(call, call.String())
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+// uniqueField returns name, suffixed if a field with that name is already accumulated
+func (g *groupStage) uniqueField(name string) string {
+	field := name
+	for i := 2; ; i++ {
+		if _, exists := g.accumulators.Get(field); !exists && field != "_id" {
+			return field
+		}
+		field = fmt.Sprintf("%s_%d", name, i)
+	}
+}
+
+// convertAggregate converts an aggregate call to a $group accumulator, and to a
+// finalizing expression when the accumulator does not hold the result directly. The
+// argument of a call with a FILTER is null for the rows the filter does not hold for,
+// which every accumulator skips
+func (g *groupStage) convertAggregate(call *sql.FuncCall, field string) (interface{}, interface{}, error) {
+	name := strings.ToUpper(call.Name)
+	if percentileFunctions[name] {
+		return g.convertPercentile(call, field)
+	}
+	if _, ok := stringFunctions[name]; ok || arrayFunctions[name] {
+		return g.convertCollect(call, field)
+	}
+	if _, ok := deviationFunctions[name]; ok {
+		return g.convertDeviation(call, field)
+	}
+	if _, ok := bivariateFunctions[name]; ok {
+		return g.convertBivariate(call, field)
+	}
+	op, ok := accumulatorOperators[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported aggregate function: %s", call.Name)
+	}
+	if len(call.Args) != 1 {
+		return nil, nil, fmt.Errorf("%s expects one argument", call.Name)
+	}
+	if len(call.OrderBy) > 0 {
+		return nil, nil, fmt.Errorf("%s does not accept ORDER BY or WITHIN GROUP", call.Name)
+	}
+	filter, err := g.convertAggregateFilter(call)
+	if err != nil {
+		return nil, nil, err
+	}
+	if name == "COUNT" && !call.Distinct && countsRows(call.Args[0]) {
+		g.counts[field] = true
+		if filter != nil {
+			return mongo.Document{{Key: "$sum", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{filter, int64(1), int64(0)}}}}}, nil, nil
+		}
+		return mongo.Document{{Key: "$sum", Value: int64(1)}}, nil, nil
+	}
+	if _, ok := call.Args[0].(*sql.StarExpr); ok {
+		return nil, nil, fmt.Errorf("%s does not accept *", call)
+	}
+	arg, err := g.convertAggregateArg(call.Args[0], filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if call.Distinct {
+		accumulator := mongo.Document{{Key: "$addToSet", Value: arg}}
+		distinct := mongo.Document{{Key: "$setDifference", Value: mongo.Array{"$" + field, mongo.Array{nil}}}}
+		if name == "COUNT" {
+			return accumulator, mongo.Document{{Key: "$size", Value: distinct}}, nil
+		}
+		finalizer := mongo.Document{{Key: op, Value: "$" + field}}
+		if name == "SUM" && filter != nil {
+			empty := mongo.Document{{Key: "$eq", Value: mongo.Array{mongo.Document{{Key: "$size", Value: distinct}}, int64(0)}}}
+			finalizer = mongo.Document{{Key: "$cond", Value: mongo.Array{empty, nil, finalizer}}}
+		}
+		return accumulator, finalizer, nil
+	}
+	if name == "COUNT" {
+		g.counts[field] = true
+		return mongo.Document{{Key: "$sum", Value: countNonNull(arg)}}, nil, nil
+	}
+	if name == "SUM" && filter != nil {
+		// $sum is 0 when the filter holds for no row with a value, where SUM is null, so the values
+		// are counted next to it
+		count := g.uniqueField(field + "_n")
+		g.counts[count] = true
+		g.accumulators = append(g.accumulators, mongo.Element{Key: count, Value: mongo.Document{{Key: "$sum", Value: countNonNull(arg)}}})
+		empty := mongo.Document{{Key: "$eq", Value: mongo.Array{"$" + count, int64(0)}}}
+		return mongo.Document{{Key: op, Value: arg}}, mongo.Document{{Key: "$cond", Value: mongo.Array{empty, nil, "$" + field}}}, nil
+	}
+	return mongo.Document{{Key: op, Value: arg}}, nil, nil
+}
+
+// convertAggregateFilter converts the FILTER of an aggregate call, returning nil when it has none
+func (g *groupStage) convertAggregateFilter(call *sql.FuncCall) (interface{}, error) {
+	if call.Filter == nil {
+		return nil, nil
+	}
+	if sql.ContainsAggregate(call.Filter) || sql.ContainsWindow(call.Filter) {
+		return nil, fmt.Errorf("FILTER can not contain aggregate or window functions: %s", call)
+	}
+	return convertExpression(call.Filter, g.input)
+}
+
+// convertAggregateArg converts the argument of an aggregate call, which is null for the rows its
+// converted FILTER does not hold for
+func (g *groupStage) convertAggregateArg(arg sql.Expr, filter interface{}) (interface{}, error) {
+	value, err := convertExpression(arg, g.input)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		value = mongo.Document{{Key: "$cond", Value: mongo.Array{filter, value, nil}}}
+	}
+	return value, nil
+}
+
+// countNonNull returns an expression that is 1 when a value is not null or missing and 0 otherwise
+func countNonNull(value interface{}) mongo.Document {
+	isNull := mongo.Document{{Key: "$eq", Value: mongo.Array{mongo.Document{{Key: "$ifNull", Value: mongo.Array{value, nil}}}, nil}}}
+	return mongo.Document{{Key: "$cond", Value: mongo.Array{isNull, int64(0), int64(1)}}}
+}
+
+// stages returns the $group stage, followed by an $addFields stage if any accumulator needs finalizing,
+// or the $facet that groups by each grouping set
+func (g *groupStage) stages() []mongo.Document {
+	if g.groupingSets != nil {
+		return g.facetStages()
+	}
+	group := append(mongo.Document{{Key: "_id", Value: g.id}}, g.accumulators...)
+	stages := []mongo.Document{{{Key: "$group", Value: group}}}
+	if g.id == nil {
+		stages = g.emptyGroupStages(stages[0])
+	}
+	if len(g.finalizers) > 0 {
+		stages = append(stages, mongo.Document{{Key: "$addFields", Value: g.finalizers}})
+	}
+	return stages
+}
+
+// emptyGroupStages wraps the $group of a query without GROUP BY in a $facet that outputs a document
+// even when there are no rows, as SQL still returns a row of aggregates then, and replaces a missing
+// group by the values of its accumulators over no rows: 0 for a count, no values for the
+// accumulators that collect them, and null for the others
+func (g *groupStage) emptyGroupStages(group mongo.Document) []mongo.Document {
+	empty := mongo.Document{{Key: "_id", Value: nil}}
+	for _, accumulator := range g.accumulators {
+		var value interface{}
+		if g.counts[accumulator.Key] {
+			value = int64(0)
+		} else if document, ok := accumulator.Value.(mongo.Document); ok && len(document) == 1 &&
+			(document[0].Key == "$push" || document[0].Key == "$addToSet") {
+			value = mongo.Array{}
+		}
+		empty = append(empty, mongo.Element{Key: accumulator.Key, Value: value})
+	}
+	first := mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$" + groupsField, int64(0)}}}
+	return []mongo.Document{
+		{{Key: "$facet", Value: mongo.Document{{Key: groupsField, Value: mongo.Array{group}}}}},
+		{{Key: "$replaceWith", Value: mongo.Document{{Key: "$ifNull", Value: mongo.Array{first, empty}}}}},
+	}
+}
+
+// rewriteDistinct rewrites a SELECT DISTINCT to group its rows by the columns it selects, which
+// leaves one row for each distinct combination of their values. A DISTINCT query that computes
+// aggregates without GROUP BY returns a single row, which is distinct already
+func rewriteDistinct(query sql.Query) (sql.Query, error) {
+	if !query.Distinct {
+		return query, nil
+	}
+	query.Distinct = false
+	if len(query.GroupBy) > 0 || query.GroupingSets != nil || query.Having != nil {
+		return sql.Query{}, fmt.Errorf("SELECT DISTINCT is not supported with GROUP BY or HAVING")
+	}
+	for _, projection := range query.Projections {
+		if sql.ContainsAggregate(projection.Expr) {
+			return query, nil
+		}
+	}
+	for _, projection := range query.Projections {
+		if _, ok := projection.Expr.(*sql.StarExpr); ok {
+			return sql.Query{}, fmt.Errorf("SELECT DISTINCT %s is not supported, as it would compare whole documents", projection.Expr)
+		}
+		if sql.ContainsWindow(projection.Expr) {
+			return sql.Query{}, fmt.Errorf("SELECT DISTINCT is not supported with window functions: %s", projection.Expr)
+		}
+		query.GroupBy = append(query.GroupBy, projection.Expr)
+	}
+	return query, nil
+}
+
+// groupKeys resolves the GROUP BY clause of a query, where a key may also be the
+// position or alias of a SELECT list entry
+func groupKeys(query sql.Query) ([]groupKey, error) {
+	var keys []groupKey
+	for _, expr := range query.GroupBy {
+		key := groupKey{expr: expr}
+		if literal, ok := expr.(*sql.Literal); ok && literal.Kind == sql.NumberLiteral {
+			position, err := strconv.Atoi(literal.Value)
+			if err != nil || position < 1 || position > len(query.Projections) {
+				return nil, fmt.Errorf("GROUP BY position %s is not in the SELECT list", literal.Value)
+			}
+			key.expr = query.Projections[position-1].Expr
+		} else if column, ok := expr.(*sql.ColumnRef); ok && column.Table == "" {
+			for _, projection := range query.Projections {
+				if projection.Alias == column.Name {
+					key.expr = projection.Expr
+				}
+			}
+		}
+		if sql.ContainsAggregate(key.expr) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in GROUP BY: %s", key.expr)
+		}
+
+		key.name = key.expr.String()
+		if column, ok := key.expr.(*sql.ColumnRef); ok {
+			key.name = column.Name
+		}
+		for _, projection := range query.Projections {
+			if projection.Alias != "" && projection.Expr.String() == key.expr.String() {
+				key.name = projection.Alias
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// isCountQuery checks if a query only counts the documents matching its filter, or those where a
+// field is not null
+func isCountQuery(query sql.Query, s *scope) bool {
+	if len(query.Projections) != 1 || len(query.GroupBy) > 0 || query.GroupingSets != nil || query.Having != nil || query.Sample != nil {
+		return false
+	}
+	call, ok := query.Projections[0].Expr.(*sql.FuncCall)
+	if !ok || !strings.EqualFold(call.Name, "COUNT") || call.Distinct || call.Filter != nil || len(call.Args) != 1 {
+		return false
+	}
+	_, isField := s.fieldPath(call.Args[0])
+	return countsRows(call.Args[0]) || isField
+}
+
+// countsRows checks if the argument of a COUNT is * or a constant that is not null, which counts
+// every row
+func countsRows(arg sql.Expr) bool {
+	if _, ok := arg.(*sql.StarExpr); ok {
+		return true
+	}
+	literal, ok := arg.(*sql.Literal)
+	return ok && literal.Kind != sql.NullLiteral
+}
+
+// convertCountQuery converts a query that only counts documents to a countDocuments command
+func convertCountQuery(query sql.Query, result mongo.Query, src source) (mongo.Query, error) {
+	result.Command = mongo.MongoCount
+	match := src.match
+	if match == nil {
+		match = mongo.Document{}
+	}
+	call := query.Projections[0].Expr.(*sql.FuncCall)
+	if !countsRows(call.Args[0]) {
+		field, _ := src.scope.fieldPath(call.Args[0])
+		notNull := mongo.Document{{Key: field, Value: mongo.Document{{Key: "$ne", Value: nil}}}}
+		if len(match) == 0 {
+			match = notNull
+		} else {
+			match = mergeFilters([]mongo.Document{match, notNull})
+		}
+	}
+	result.Match = match
+	return result, nil
+}
+
+// convertAggregateQuery converts a query that groups rows or computes aggregates to an aggregation pipeline
+func convertAggregateQuery(query sql.Query, result mongo.Query, src source) (mongo.Query, error) {
+	if isCountQuery(query, src.scope) && len(src.stages) == 0 {
+		return convertCountQuery(query, result, src)
+	}
+
+	group := newGroupStage(src.scope)
+	keys, err := groupKeys(query)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	if query.GroupingSets != nil {
+		group.groupingSets = groupingSets(query)
+		result.Warnings = append(result.Warnings, facetWarning)
+	}
+	if len(keys) > 0 {
+		if err := group.setKeys(keys); err != nil {
+			return mongo.Query{}, err
+		}
+	}
+	for _, projection := range query.Projections {
+		if _, ok := projection.Expr.(*sql.StarExpr); ok {
+			return mongo.Query{}, fmt.Errorf("%s can not be combined with aggregate functions", projection.Expr)
+		}
+		if call, ok := projection.Expr.(*sql.FuncCall); ok && sql.IsAggregate(call) {
+			if _, err := group.accumulate(call, projection.Name()); err != nil {
+				return mongo.Query{}, err
+			}
+			continue
+		}
+		if err := group.accumulateAll(projection.Expr); err != nil {
+			return mongo.Query{}, err
+		}
+	}
+
+	var having mongo.Document
+	if query.Having != nil {
+		for _, projection := range query.Projections {
+			if field, ok := group.output.fieldPath(projection.Expr); ok && projection.Alias != "" {
+				if _, exists := group.output.fields[projection.Alias]; !exists {
+					group.output.fields[projection.Alias] = field
+				}
+			}
+		}
+		if err := group.accumulateAll(query.Having); err != nil {
+			return mongo.Query{}, err
+		}
+		if having, err = convertFilter(query.Having, group.output); err != nil {
+			return mongo.Query{}, err
+		}
+	}
+
+	order, err := resolveOrderBy(query.OrderBy, query.Projections)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	sample := query.Limit != nil && isRandomOrder(order)
+	if sample {
+		order = nil
+	}
+	for _, item := range order {
+		if err := group.accumulateAll(item.Expr); err != nil {
+			return mongo.Query{}, err
+		}
+	}
+
+	windows, err := convertWindows(query.Projections, group.output)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	sortKeys, sort, err := convertOrderBy(order, group.output)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+
+	var project mongo.Document
+	for _, projection := range query.Projections {
+		name := fieldName(projection.Name())
+		if field, ok := group.output.fieldPath(projection.Expr); ok && field == name {
+			project = append(project, mongo.Element{Key: name, Value: int64(1)})
+			continue
+		}
+		value, err := convertExpression(projection.Expr, group.output)
+		if err != nil {
+			return mongo.Query{}, err
+		}
+		project = append(project, mongo.Element{Key: name, Value: projectedValue(value)})
+	}
+
+	pipeline := append(src.pipeline(), group.stages()...)
+	if having != nil {
+		pipeline = append(pipeline, mongo.Document{{Key: "$match", Value: having}})
+	}
+	pipeline = append(pipeline, windows.stages()...)
+	pipeline = append(pipeline, sortStages(sortKeys, sort)...)
+	pipeline = append(pipeline, limitStages(query.Limit, sample)...)
+	pipeline = append(pipeline, mongo.Document{{Key: "$project", Value: excludeID(project)}})
+
+	result.Command = mongo.MongoAggregate
+	result.Pipeline = pipeline
+	result.Warnings = append(result.Warnings, group.warnings...)
+	result.Warnings = append(result.Warnings, windows.warnings...)
+	return result, nil
+}
+
+// projectedValue wraps constants that $project would otherwise read as inclusion or exclusion flags
+func projectedValue(value interface{}) interface{} {
+	switch value.(type) {
+	case int64, float64, bool:
+		return mongo.Document{{Key: "$literal", Value: value}}
+	default:
+		return value
+	}
+}
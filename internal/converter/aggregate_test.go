@@ -0,0 +1,358 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertAggregateQuery(t *testing.T) {
+	countStar := &sql.FuncCall{Name: "COUNT", Args: []sql.Expr{&sql.StarExpr{}}}
+	tests := []struct {
+		name    string
+		sql     sql.Query
+		want    mongo.Query
+		wantErr bool
+	}{
+		{
+			name: "count documents",
+			sql: sql.Query{
+				Command:     sql.SQLSelect,
+				Table:       "users",
+				Columns:     []string{"COUNT(*)"},
+				Filter:      "active=true",
+				Projections: []sql.Projection{{Expr: countStar}},
+				Where:       &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Name: "active"}, Right: &sql.Literal{Kind: sql.BooleanLiteral, Value: "true"}},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoCount,
+				Collections: "users",
+				Field:       []string{"COUNT(*)"},
+				Filter:      "active=true",
+				Match:       mongo.Document{{Key: "active", Value: true}},
+			},
+		},
+		{
+			name: "count column",
+			sql: sql.Query{
+				Command:     sql.SQLSelect,
+				Table:       "users",
+				Columns:     []string{"COUNT(email)"},
+				Projections: []sql.Projection{{Expr: &sql.FuncCall{Name: "COUNT", Args: []sql.Expr{&sql.ColumnRef{Name: "email"}}}}},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoCount,
+				Collections: "users",
+				Field:       []string{"COUNT(email)"},
+				Match:       mongo.Document{{Key: "email", Value: mongo.Document{{Key: "$ne", Value: nil}}}},
+			},
+		},
+		{
+			name: "count a constant",
+			sql: sql.Query{
+				Command:     sql.SQLSelect,
+				Table:       "users",
+				Columns:     []string{"COUNT(1)"},
+				Projections: []sql.Projection{{Expr: &sql.FuncCall{Name: "COUNT", Args: []sql.Expr{&sql.Literal{Kind: sql.NumberLiteral, Value: "1"}}}}},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoCount,
+				Collections: "users",
+				Field:       []string{"COUNT(1)"},
+				Match:       mongo.Document{},
+			},
+		},
+		{
+			name: "count an expression",
+			sql: sql.Query{
+				Command:     sql.SQLSelect,
+				Table:       "users",
+				Columns:     []string{"n"},
+				Projections: []sql.Projection{{Expr: &sql.FuncCall{Name: "COUNT", Args: []sql.Expr{&sql.FuncCall{Name: "UPPER", Args: []sql.Expr{&sql.ColumnRef{Name: "name"}}}}}, Alias: "n"}},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoAggregate,
+				Collections: "users",
+				Field:       []string{"n"},
+				Pipeline: []mongo.Document{
+					groupFacet(mongo.Document{
+						{Key: "_id", Value: nil},
+						{Key: "n", Value: mongo.Document{{Key: "$sum", Value: countNonNull(mongo.Document{{Key: "$toUpper", Value: "$name"}})}}},
+					}),
+					emptyGroup(mongo.Document{{Key: "_id", Value: nil}, {Key: "n", Value: int64(0)}}),
+					{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "n", Value: int64(1)}}}},
+				},
+			},
+		},
+		{
+			name: "select distinct",
+			sql: sql.Query{
+				Command:     sql.SQLSelect,
+				Table:       "users",
+				Columns:     []string{"city"},
+				Projections: []sql.Projection{{Expr: &sql.ColumnRef{Name: "city"}}},
+				Distinct:    true,
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoAggregate,
+				Collections: "users",
+				Field:       []string{"city"},
+				Pipeline: []mongo.Document{
+					{{Key: "$group", Value: mongo.Document{{Key: "_id", Value: "$city"}}}},
+					{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "city", Value: "$_id"}}}},
+				},
+			},
+		},
+		{
+			name: "select distinct star",
+			sql: sql.Query{
+				Command:     sql.SQLSelect,
+				Table:       "users",
+				Columns:     []string{"*"},
+				Projections: []sql.Projection{{Expr: &sql.StarExpr{}}},
+				Distinct:    true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "group everything",
+			sql: sql.Query{
+				Command: sql.SQLSelect,
+				Table:   "t",
+				Columns: []string{"MAX(age)", "n"},
+				Projections: []sql.Projection{
+					{Expr: &sql.FuncCall{Name: "MAX", Args: []sql.Expr{&sql.ColumnRef{Name: "age"}}}},
+					{Expr: &sql.FuncCall{Name: "COUNT", Args: []sql.Expr{&sql.ColumnRef{Name: "tag"}}, Distinct: true}, Alias: "n"},
+				},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoAggregate,
+				Collections: "t",
+				Field:       []string{"MAX(age)", "n"},
+				Pipeline: []mongo.Document{
+					groupFacet(mongo.Document{
+						{Key: "_id", Value: nil},
+						{Key: "MAX(age)", Value: mongo.Document{{Key: "$max", Value: "$age"}}},
+						{Key: "n", Value: mongo.Document{{Key: "$addToSet", Value: "$tag"}}},
+					}),
+					emptyGroup(mongo.Document{{Key: "_id", Value: nil}, {Key: "MAX(age)", Value: nil}, {Key: "n", Value: mongo.Array{}}}),
+					{{Key: "$addFields", Value: mongo.Document{
+						{Key: "n", Value: mongo.Document{{Key: "$size", Value: mongo.Document{{Key: "$setDifference", Value: mongo.Array{"$n", mongo.Array{nil}}}}}}},
+					}}},
+					{{Key: "$project", Value: mongo.Document{
+						{Key: "_id", Value: int64(0)},
+						{Key: "MAX(age)", Value: int64(1)},
+						{Key: "n", Value: int64(1)},
+					}}},
+				},
+			},
+		},
+		{
+			name: "group by keys",
+			sql: sql.Query{
+				Command: sql.SQLSelect,
+				Table:   "orders",
+				Columns: []string{"c", "city", "total"},
+				Projections: []sql.Projection{
+					{Expr: &sql.ColumnRef{Name: "country"}, Alias: "c"},
+					{Expr: &sql.ColumnRef{Name: "city"}},
+					{Expr: &sql.FuncCall{Name: "SUM", Args: []sql.Expr{&sql.ColumnRef{Name: "total"}}}, Alias: "total"},
+				},
+				GroupBy: []sql.Expr{&sql.Literal{Kind: sql.NumberLiteral, Value: "1"}, &sql.ColumnRef{Name: "city"}},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoAggregate,
+				Collections: "orders",
+				Field:       []string{"c", "city", "total"},
+				Pipeline: []mongo.Document{
+					{{Key: "$group", Value: mongo.Document{
+						{Key: "_id", Value: mongo.Document{{Key: "c", Value: "$country"}, {Key: "city", Value: "$city"}}},
+						{Key: "total", Value: mongo.Document{{Key: "$sum", Value: "$total"}}},
+					}}},
+					{{Key: "$project", Value: mongo.Document{
+						{Key: "_id", Value: int64(0)},
+						{Key: "c", Value: "$_id.c"},
+						{Key: "city", Value: "$_id.city"},
+						{Key: "total", Value: int64(1)},
+					}}},
+				},
+			},
+		},
+		{
+			name: "having with hidden accumulator",
+			sql: sql.Query{
+				Command:     sql.SQLSelect,
+				Table:       "orders",
+				Columns:     []string{"country", "n"},
+				Projections: []sql.Projection{{Expr: &sql.ColumnRef{Name: "country"}}, {Expr: countStar, Alias: "n"}},
+				GroupBy:     []sql.Expr{&sql.ColumnRef{Name: "country"}},
+				Having: &sql.BinaryExpr{
+					Op:    "AND",
+					Left:  &sql.BinaryExpr{Op: ">", Left: countStar, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "5"}},
+					Right: &sql.BinaryExpr{Op: ">=", Left: &sql.FuncCall{Name: "SUM", Args: []sql.Expr{&sql.ColumnRef{Name: "total"}}}, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "100"}},
+				},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoAggregate,
+				Collections: "orders",
+				Field:       []string{"country", "n"},
+				Pipeline: []mongo.Document{
+					{{Key: "$group", Value: mongo.Document{
+						{Key: "_id", Value: "$country"},
+						{Key: "n", Value: mongo.Document{{Key: "$sum", Value: int64(1)}}},
+						{Key: "SUM(total)", Value: mongo.Document{{Key: "$sum", Value: "$total"}}},
+					}}},
+					{{Key: "$match", Value: mongo.Document{
+						{Key: "n", Value: mongo.Document{{Key: "$gt", Value: int64(5)}}},
+						{Key: "SUM(total)", Value: mongo.Document{{Key: "$gte", Value: int64(100)}}},
+					}}},
+					{{Key: "$project", Value: mongo.Document{
+						{Key: "_id", Value: int64(0)},
+						{Key: "country", Value: "$_id"},
+						{Key: "n", Value: int64(1)},
+					}}},
+				},
+			},
+		},
+		{
+			name: "filtered count",
+			sql: sql.Query{
+				Command: sql.SQLSelect,
+				Table:   "orders",
+				Columns: []string{"open"},
+				Projections: []sql.Projection{{
+					Expr:  &sql.FuncCall{Name: "COUNT", Args: []sql.Expr{&sql.StarExpr{}}, Filter: &sql.ColumnRef{Name: "open"}},
+					Alias: "open",
+				}},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoAggregate,
+				Collections: "orders",
+				Field:       []string{"open"},
+				Pipeline: []mongo.Document{
+					groupFacet(mongo.Document{
+						{Key: "_id", Value: nil},
+						{Key: "open", Value: mongo.Document{{Key: "$sum", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{"$open", int64(1), int64(0)}}}}}},
+					}),
+					emptyGroup(mongo.Document{{Key: "_id", Value: nil}, {Key: "open", Value: int64(0)}}),
+					{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "open", Value: int64(1)}}}},
+				},
+			},
+		},
+		{
+			name: "filtered sum",
+			sql: sql.Query{
+				Command: sql.SQLSelect,
+				Table:   "orders",
+				Columns: []string{"open"},
+				Projections: []sql.Projection{{
+					Expr:  &sql.FuncCall{Name: "SUM", Args: []sql.Expr{&sql.ColumnRef{Name: "total"}}, Filter: &sql.ColumnRef{Name: "open"}},
+					Alias: "open",
+				}},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoAggregate,
+				Collections: "orders",
+				Field:       []string{"open"},
+				Pipeline: []mongo.Document{
+					groupFacet(mongo.Document{
+						{Key: "_id", Value: nil},
+						{Key: "open", Value: mongo.Document{{Key: "$sum", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{"$open", "$total", nil}}}}}},
+						{Key: "open_n", Value: mongo.Document{{Key: "$sum", Value: countNonNull(mongo.Document{{Key: "$cond", Value: mongo.Array{"$open", "$total", nil}}})}}},
+					}),
+					emptyGroup(mongo.Document{{Key: "_id", Value: nil}, {Key: "open", Value: nil}, {Key: "open_n", Value: int64(0)}}),
+					{{Key: "$addFields", Value: mongo.Document{
+						{Key: "open", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{mongo.Document{{Key: "$eq", Value: mongo.Array{"$open_n", int64(0)}}}, nil, "$open"}}}},
+					}}},
+					{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "open", Value: int64(1)}}}},
+				},
+			},
+		},
+		{
+			name: "filtered distinct count",
+			sql: sql.Query{
+				Command: sql.SQLSelect,
+				Table:   "orders",
+				Columns: []string{"n"},
+				Projections: []sql.Projection{{
+					Expr: &sql.FuncCall{Name: "COUNT", Args: []sql.Expr{&sql.ColumnRef{Name: "user"}}, Distinct: true,
+						Filter: &sql.BinaryExpr{Op: ">", Left: &sql.ColumnRef{Name: "total"}, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "10"}}},
+					Alias: "n",
+				}},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoAggregate,
+				Collections: "orders",
+				Field:       []string{"n"},
+				Pipeline: []mongo.Document{
+					groupFacet(mongo.Document{
+						{Key: "_id", Value: nil},
+						{Key: "n", Value: mongo.Document{{Key: "$addToSet", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{
+							mongo.Document{{Key: "$gt", Value: mongo.Array{"$total", int64(10)}}},
+							"$user",
+							nil,
+						}}}}}},
+					}),
+					emptyGroup(mongo.Document{{Key: "_id", Value: nil}, {Key: "n", Value: mongo.Array{}}}),
+					{{Key: "$addFields", Value: mongo.Document{{Key: "n", Value: mongo.Document{{Key: "$size", Value: mongo.Document{{Key: "$setDifference", Value: mongo.Array{"$n", mongo.Array{nil}}}}}}}}}},
+					{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "n", Value: int64(1)}}}},
+				},
+			},
+		},
+		{
+			name: "aggregate in filter",
+			sql: sql.Query{
+				Command:     sql.SQLSelect,
+				Table:       "orders",
+				Projections: []sql.Projection{{Expr: &sql.FuncCall{Name: "SUM", Args: []sql.Expr{&sql.ColumnRef{Name: "total"}}, Filter: countStar}}},
+			},
+			want:    mongo.Query{},
+			wantErr: true,
+		},
+		{
+			name: "aggregate in group by",
+			sql: sql.Query{
+				Command:     sql.SQLSelect,
+				Table:       "orders",
+				Projections: []sql.Projection{{Expr: countStar}},
+				GroupBy:     []sql.Expr{countStar},
+			},
+			want:    mongo.Query{},
+			wantErr: true,
+		},
+		{
+			name: "ungrouped column",
+			sql: sql.Query{
+				Command:     sql.SQLSelect,
+				Table:       "users",
+				Projections: []sql.Projection{{Expr: &sql.ColumnRef{Name: "name"}}, {Expr: countStar}},
+			},
+			want:    mongo.Query{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertSQLQueryToMongoQuery(tt.sql)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// groupFacet returns the $facet stage that outputs the group of a query without GROUP BY
+func groupFacet(group mongo.Document) mongo.Document {
+	return mongo.Document{{Key: "$facet", Value: mongo.Document{{Key: "groups", Value: mongo.Array{mongo.Document{{Key: "$group", Value: group}}}}}}}
+}
+
+// emptyGroup returns the stage that replaces the missing group of a query without GROUP BY by the given values
+func emptyGroup(values mongo.Document) mongo.Document {
+	first := mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$groups", int64(0)}}}
+	return mongo.Document{{Key: "$replaceWith", Value: mongo.Document{{Key: "$ifNull", Value: mongo.Array{first, values}}}}}
+}
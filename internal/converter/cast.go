@@ -0,0 +1,148 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// castTypes maps SQL type names to the BSON type $convert converts to
+var castTypes = map[string]string{
+	"INT":                         "int",
+	"INTEGER":                     "int",
+	"SMALLINT":                    "int",
+	"TINYINT":                     "int",
+	"MEDIUMINT":                   "int",
+	"INT2":                        "int",
+	"INT4":                        "int",
+	"BIGINT":                      "long",
+	"INT8":                        "long",
+	"SIGNED":                      "long",
+	"UNSIGNED":                    "long",
+	"FLOAT":                       "double",
+	"FLOAT4":                      "double",
+	"FLOAT8":                      "double",
+	"REAL":                        "double",
+	"DOUBLE":                      "double",
+	"DOUBLE PRECISION":            "double",
+	"NUMERIC":                     "decimal",
+	"DECIMAL":                     "decimal",
+	"VARCHAR":                     "string",
+	"NVARCHAR":                    "string",
+	"CHAR":                        "string",
+	"NCHAR":                       "string",
+	"CHARACTER":                   "string",
+	"CHARACTER VARYING":           "string",
+	"TEXT":                        "string",
+	"STRING":                      "string",
+	"BOOLEAN":                     "bool",
+	"BOOL":                        "bool",
+	"BIT":                         "bool",
+	"DATE":                        "date",
+	"DATETIME":                    "date",
+	"DATETIME2":                   "date",
+	"TIMESTAMP":                   "date",
+	"TIMESTAMPTZ":                 "date",
+	"TIMESTAMP WITH TIME ZONE":    "date",
+	"TIMESTAMP WITHOUT TIME ZONE": "date",
+	"OBJECTID":                    "objectId",
+}
+
+// castOperators maps BSON types to the operator that converts to them, failing on values that can not be converted
+var castOperators = map[string]string{
+	"int":      "$toInt",
+	"long":     "$toLong",
+	"double":   "$toDouble",
+	"decimal":  "$toDecimal",
+	"string":   "$toString",
+	"bool":     "$toBool",
+	"date":     "$toDate",
+	"objectId": "$toObjectId",
+}
+
+// castKinds maps BSON types to the kind of value they hold
+var castKinds = map[string]valueKind{
+	"int":     numberValue,
+	"long":    numberValue,
+	"double":  numberValue,
+	"decimal": numberValue,
+	"string":  stringValue,
+	"bool":    booleanValue,
+	"date":    dateValue,
+}
+
+// castType returns the BSON type a SQL type converts to, ignoring its length or precision
+func castType(typ string) (string, bool) {
+	if i := strings.Index(typ, "("); i != -1 {
+		typ = typ[:i]
+	}
+	to, ok := castTypes[typ]
+	return to, ok
+}
+
+// isDateCast checks if a conversion is to DATE, which drops the time of day
+func isDateCast(cast *sql.CastExpr) bool {
+	return cast.Type == "DATE"
+}
+
+// nullOnCastError checks if the conversions of a scope return null for values that can not be converted
+func (s *scope) nullOnCastError() bool {
+	return s != nil && s.options.NullOnCastError
+}
+
+// convertCast converts a CAST to the operator for its target type, or to $convert with an onError
+// of null when the options ask for bad values to become null instead of failing the command
+func convertCast(cast *sql.CastExpr, s *scope) (interface{}, error) {
+	to, ok := castType(cast.Type)
+	if !ok {
+		return nil, fmt.Errorf("unsupported CAST type: %s", cast.Type)
+	}
+	if value, constant, err := constantCast(cast, s); err != nil || constant {
+		return value, err
+	}
+	input, err := convertExpression(cast.Expr, s)
+	if err != nil {
+		return nil, err
+	}
+	var converted interface{} = mongo.Document{{Key: castOperators[to], Value: input}}
+	if s.nullOnCastError() {
+		converted = mongo.Document{{Key: "$convert", Value: mongo.Document{
+			{Key: "input", Value: input},
+			{Key: "to", Value: to},
+			{Key: "onError", Value: nil},
+		}}}
+	}
+	if isDateCast(cast) {
+		trunc := mongo.Document{{Key: "date", Value: converted}, {Key: "unit", Value: "day"}}
+		converted = mongo.Document{{Key: "$dateTrunc", Value: withTimeZone(trunc, s.timeZone())}}
+	}
+	return converted, nil
+}
+
+// constantCast converts a string constant cast to a date or timestamp to the date it names, so that
+// a filter comparing a field to it can use an index
+func constantCast(cast *sql.CastExpr, s *scope) (interface{}, bool, error) {
+	literal, ok := cast.Expr.(*sql.Literal)
+	if to, _ := castType(cast.Type); !ok || literal.Kind != sql.StringLiteral || to != "date" {
+		return nil, false, nil
+	}
+	value, err := parseDateLiteral(&sql.Literal{Kind: sql.TimestampLiteral, Value: literal.Value}, s)
+	if err != nil {
+		if s.nullOnCastError() {
+			return nil, true, nil
+		}
+		return nil, false, fmt.Errorf("can not cast %s to %s", literal, cast.Type)
+	}
+	if isDateCast(cast) {
+		location, err := timeZoneLocation(s.timeZone())
+		if err != nil {
+			return nil, false, err
+		}
+		t := value.(time.Time).In(location)
+		value = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+	}
+	return value, true, nil
+}
@@ -0,0 +1,99 @@
+package converter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertCast(t *testing.T) {
+	qty := &sql.ColumnRef{Name: "qty"}
+	str := func(value string) sql.Expr { return &sql.Literal{Kind: sql.StringLiteral, Value: value} }
+	tests := []struct {
+		name    string
+		cast    *sql.CastExpr
+		options Options
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "shorthand",
+			cast: &sql.CastExpr{Expr: qty, Type: "BIGINT"},
+			want: mongo.Document{{Key: "$toLong", Value: "$qty"}},
+		},
+		{
+			name: "type with a length",
+			cast: &sql.CastExpr{Expr: qty, Type: "VARCHAR(20)"},
+			want: mongo.Document{{Key: "$toString", Value: "$qty"}},
+		},
+		{
+			name:    "null on error",
+			cast:    &sql.CastExpr{Expr: qty, Type: "DOUBLE PRECISION"},
+			options: Options{NullOnCastError: true},
+			want: mongo.Document{{Key: "$convert", Value: mongo.Document{
+				{Key: "input", Value: "$qty"},
+				{Key: "to", Value: "double"},
+				{Key: "onError", Value: nil},
+			}}},
+		},
+		{
+			name:    "date drops the time of day",
+			cast:    &sql.CastExpr{Expr: &sql.ColumnRef{Name: "created"}, Type: "DATE"},
+			options: Options{TimeZone: "Europe/Paris"},
+			want: mongo.Document{{Key: "$dateTrunc", Value: mongo.Document{
+				{Key: "date", Value: mongo.Document{{Key: "$toDate", Value: "$created"}}},
+				{Key: "unit", Value: "day"},
+				{Key: "timezone", Value: "Europe/Paris"},
+			}}},
+		},
+		{
+			name: "constant timestamp",
+			cast: &sql.CastExpr{Expr: str("2024-05-01 10:30:00"), Type: "TIMESTAMP"},
+			want: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name:    "constant date in the default time zone",
+			cast:    &sql.CastExpr{Expr: str("2024-05-01 10:30:00"), Type: "DATE"},
+			options: Options{TimeZone: "+02:00"},
+			want:    time.Date(2024, 5, 1, 0, 0, 0, 0, time.FixedZone("+02:00", 7200)),
+		},
+		{
+			name:    "invalid constant date",
+			cast:    &sql.CastExpr{Expr: str("soon"), Type: "DATE"},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "invalid constant date with null on error",
+			cast:    &sql.CastExpr{Expr: str("soon"), Type: "DATE"},
+			options: Options{NullOnCastError: true},
+			want:    nil,
+		},
+		{
+			name:    "unsupported type",
+			cast:    &sql.CastExpr{Expr: qty, Type: "INTERVAL"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScope()
+			s.options = tt.options
+			got, err := convertCast(tt.cast, s)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			if want, ok := tt.want.(time.Time); ok {
+				require.True(t, want.Equal(got.(time.Time)), "got %v", got)
+				return
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+)
+
+// defaultLocale is the locale of the collations that follow the root collation order, which English
+// uses without tailoring it
+const defaultLocale = "en"
+
+// mysqlCollation matches a MySQL collation name: a character set, an optional language, a Unicode
+// Collation Algorithm version and the accent and case sensitivity suffixes, such as utf8mb4_0900_ai_ci
+var mysqlCollation = regexp.MustCompile(`^(?:utf8mb4|utf8mb3|utf8|utf16|utf16le|utf32|ucs2|latin1|latin2|ascii)_(.+)$`)
+
+// mysqlLanguages maps the language names of MySQL collations to their locales
+var mysqlLanguages = map[string]string{
+	"general": defaultLocale, "unicode": defaultLocale, "croatian": "hr", "czech": "cs",
+	"danish": "da", "esperanto": "eo", "estonian": "et", "german1": "de", "german2": "de",
+	"hungarian": "hu", "icelandic": "is", "latvian": "lv", "lithuanian": "lt", "persian": "fa",
+	"polish": "pl", "romanian": "ro", "sinhala": "si", "slovak": "sk", "slovenian": "sl",
+	"spanish": "es", "spanish2": "es", "swedish": "sv", "turkish": "tr", "vietnamese": "vi",
+}
+
+// icuStrengths maps the values of the ks keyword of an ICU locale to collation strengths
+var icuStrengths = map[string]int64{"level1": 1, "level2": 2, "level3": 3, "level4": 4, "identic": 5}
+
+// mongoLocales holds the languages MongoDB has collations for
+var mongoLocales = map[string]bool{
+	"af": true, "am": true, "ar": true, "as": true, "az": true, "be": true, "bg": true, "bn": true,
+	"bo": true, "bs": true, "ca": true, "chr": true, "cs": true, "cy": true, "da": true, "de": true,
+	"dsb": true, "dz": true, "ee": true, "el": true, "en": true, "eo": true, "es": true, "et": true,
+	"fa": true, "fi": true, "fil": true, "fo": true, "fr": true, "ga": true, "gl": true, "gu": true,
+	"ha": true, "haw": true, "he": true, "hi": true, "hr": true, "hsb": true, "hu": true, "hy": true,
+	"id": true, "ig": true, "is": true, "it": true, "ja": true, "ka": true, "kk": true, "kl": true,
+	"km": true, "kn": true, "ko": true, "kok": true, "ky": true, "lb": true, "lkt": true, "ln": true,
+	"lo": true, "lt": true, "lv": true, "mk": true, "ml": true, "mn": true, "mr": true, "ms": true,
+	"mt": true, "my": true, "nb": true, "ne": true, "nl": true, "nn": true, "om": true, "or": true,
+	"pa": true, "pl": true, "ps": true, "pt": true, "ro": true, "ru": true, "se": true, "si": true,
+	"sk": true, "sl": true, "smn": true, "sq": true, "sr": true, "sv": true, "sw": true, "ta": true,
+	"te": true, "th": true, "to": true, "tr": true, "ug": true, "uk": true, "ur": true, "vi": true,
+	"wae": true, "yi": true, "yo": true, "zh": true, "zu": true,
+}
+
+// sqlServerCollation matches a SQL Server collation name: an optional SQL_ prefix, a language, the
+// case and accent sensitivity suffixes and any further options, such as SQL_Latin1_General_CP1_CI_AS
+var sqlServerCollation = regexp.MustCompile(`^(?:sql_)?([a-z][a-z0-9_]*?)_(ci|cs)_(ai|as)((?:_[a-z0-9]+)*)$`)
+
+// sqlServerLanguages maps the languages of SQL Server collations, without their code pages and
+// versions, to their locales
+var sqlServerLanguages = map[string]string{
+	"latin1_general": defaultLocale, "arabic": "ar", "chinese_prc": "zh", "chinese_taiwan_stroke": "zh",
+	"croatian": "hr", "cyrillic_general": "ru", "czech": "cs", "danish_norwegian": "da",
+	"estonian": "et", "finnish_swedish": "fi", "french": "fr", "german_phonebook": "de", "greek": "el",
+	"hebrew": "he", "hungarian": "hu", "icelandic": "is", "japanese": "ja", "korean_wansung": "ko",
+	"latvian": "lv", "lithuanian": "lt", "modern_spanish": "es", "polish": "pl", "romanian": "ro",
+	"slovak": "sk", "slovenian": "sl", "thai": "th", "traditional_spanish": "es", "turkish": "tr",
+	"ukrainian": "uk", "vietnamese": "vi",
+}
+
+// sqlServerVersion matches the code page or version part of a SQL Server collation language
+var sqlServerVersion = regexp.MustCompile(`^(?:cp)?[0-9]+$`)
+
+// convertCollations converts the collations named by the COLLATE clauses of a statement to the one
+// collation of the command, returning an error if they differ
+func convertCollations(names []string) (mongo.Document, error) {
+	var collation mongo.Document
+	var first string
+	for _, name := range names {
+		converted, err := convertCollation(name)
+		if err != nil {
+			return nil, err
+		}
+		if converted == nil {
+			continue
+		}
+		if collation != nil && collation.String() != converted.String() {
+			return nil, fmt.Errorf("conflicting collations %s and %s, as MongoDB only allows one collation per command", first, name)
+		}
+		collation, first = converted, name
+	}
+	return collation, nil
+}
+
+// convertCollation converts a collation name to a MongoDB collation: an ICU locale such as
+// de-u-ks-level1 or en-US-x-icu, a libc locale such as de_DE.utf8, a MySQL collation such as
+// utf8mb4_0900_ai_ci or a SQL Server collation such as Latin1_General_CI_AS, whose accent and case
+// sensitivity give the strength. The default collation
+// converts to nil, leaving the collation of the collection in place
+func convertCollation(name string) (mongo.Document, error) {
+	lower := strings.ToLower(name)
+	base := strings.SplitN(lower, ".", 2)[0]
+	switch {
+	case lower == "default":
+		return nil, nil
+	case base == "c" || base == "posix" || lower == "ucs_basic" || lower == "binary" || strings.HasSuffix(lower, "_bin") || strings.HasSuffix(lower, "_bin2"):
+		return mongo.Document{{Key: "locale", Value: "simple"}}, nil
+	}
+	// SQL Server names put the case sensitivity before the accent sensitivity, unlike MySQL names,
+	// some of which start with the same character set
+	if match := sqlServerCollation.FindStringSubmatch(lower); match != nil {
+		return convertSQLServerCollation(name, match)
+	}
+	if match := mysqlCollation.FindStringSubmatch(lower); match != nil {
+		return convertMySQLCollation(name, strings.Split(match[1], "_"))
+	}
+	return convertLocaleCollation(name, lower)
+}
+
+// convertMySQLCollation converts the parts of a MySQL collation name that follow its character set.
+// A case insensitive collation that does not state its accent sensitivity ignores accents too
+func convertMySQLCollation(name string, parts []string) (mongo.Document, error) {
+	strength := int64(0)
+	switch parts[len(parts)-1] {
+	case "ci":
+		strength = 1
+		if len(parts) > 1 && parts[len(parts)-2] == "as" {
+			strength = 2
+		}
+	case "cs":
+		strength = 3
+	}
+	locale, ok := mysqlLanguages[parts[0]]
+	if !ok && mongoLocales[parts[0]] {
+		locale, ok = parts[0], true
+	}
+	if !ok && strings.Trim(parts[0], "0123456789") == "" {
+		locale, ok = defaultLocale, true
+	}
+	if !ok || strength == 0 {
+		return nil, fmt.Errorf("unsupported collation %s", name)
+	}
+	return mongo.Document{{Key: "locale", Value: locale}, {Key: "strength", Value: strength}}, nil
+}
+
+// convertSQLServerCollation converts the parts of a SQL Server collation name matched by
+// sqlServerCollation. A case sensitive collation that ignores accents compares case at a level of
+// its own, and the Pref collations sort upper case first
+func convertSQLServerCollation(name string, match []string) (mongo.Document, error) {
+	var words []string
+	upperFirst := false
+	for _, word := range strings.Split(match[1], "_") {
+		switch {
+		case word == "pref":
+			upperFirst = true
+		case !sqlServerVersion.MatchString(word):
+			words = append(words, word)
+		}
+	}
+	locale, ok := sqlServerLanguages[strings.Join(words, "_")]
+	if !ok {
+		return nil, fmt.Errorf("unsupported collation %s", name)
+	}
+	for _, option := range strings.Split(strings.TrimPrefix(match[4], "_"), "_") {
+		// Kana and width sensitivity have no setting of their own in a MongoDB collation
+		if option != "" && option != "sc" && option != "utf8" && option != "vss" {
+			return nil, fmt.Errorf("unsupported setting %s of collation %s", option, name)
+		}
+	}
+	collation := mongo.Document{{Key: "locale", Value: locale}}
+	switch {
+	case match[2] == "cs" && match[3] == "as":
+		collation = append(collation, mongo.Element{Key: "strength", Value: int64(3)})
+	case match[2] == "cs":
+		collation = append(collation, mongo.Element{Key: "strength", Value: int64(1)}, mongo.Element{Key: "caseLevel", Value: true})
+	case match[3] == "as":
+		collation = append(collation, mongo.Element{Key: "strength", Value: int64(2)})
+	default:
+		collation = append(collation, mongo.Element{Key: "strength", Value: int64(1)})
+	}
+	if upperFirst {
+		collation = append(collation, mongo.Element{Key: "caseFirst", Value: "upper"})
+	}
+	return collation, nil
+}
+
+// convertLocaleCollation converts an ICU or libc locale name to a collation of its language, reading
+// the strength, numeric ordering, case level and case first settings from its Unicode extension
+func convertLocaleCollation(name, lower string) (mongo.Document, error) {
+	lower = strings.TrimSuffix(strings.SplitN(lower, ".", 2)[0], "-x-icu")
+	tags := strings.FieldsFunc(lower, func(r rune) bool { return r == '-' || r == '_' })
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("unsupported collation %s", name)
+	}
+	locale := tags[0]
+	if locale == "und" || locale == "root" {
+		locale = defaultLocale
+	}
+	if !mongoLocales[locale] {
+		return nil, fmt.Errorf("unsupported collation %s", name)
+	}
+	collation := mongo.Document{{Key: "locale", Value: locale}, {Key: "strength", Value: int64(3)}}
+	extension := -1
+	for i, tag := range tags {
+		if tag == "u" {
+			extension = i + 1
+			break
+		}
+	}
+	if extension < 0 {
+		return collation, nil
+	}
+	keywords := tags[extension:]
+	for i := 0; i < len(keywords); i++ {
+		key, value := keywords[i], ""
+		if i+1 < len(keywords) && len(keywords[i+1]) > 2 {
+			value = keywords[i+1]
+			i++
+		}
+		switch {
+		case key == "ks" && icuStrengths[value] > 0:
+			collation[1].Value = icuStrengths[value]
+		case key == "kn" && (value == "" || value == "true" || value == "false"):
+			collation = append(collation, mongo.Element{Key: "numericOrdering", Value: value != "false"})
+		case key == "kc" && (value == "" || value == "true" || value == "false"):
+			collation = append(collation, mongo.Element{Key: "caseLevel", Value: value != "false"})
+		case key == "kf" && (value == "upper" || value == "lower" || value == "false"):
+			if value == "false" {
+				value = "off"
+			}
+			collation = append(collation, mongo.Element{Key: "caseFirst", Value: value})
+		default:
+			return nil, fmt.Errorf("unsupported setting %s of collation %s", key, name)
+		}
+	}
+	return collation, nil
+}
@@ -0,0 +1,118 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertCollations(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    mongo.Document
+		wantErr bool
+	}{
+		{
+			name:  "icu locale with strength",
+			names: []string{"de-u-ks-level1"},
+			want:  mongo.Document{{Key: "locale", Value: "de"}, {Key: "strength", Value: int64(1)}},
+		},
+		{
+			name:  "icu locale with numeric ordering",
+			names: []string{"en-US-u-kn-true-x-icu"},
+			want:  mongo.Document{{Key: "locale", Value: "en"}, {Key: "strength", Value: int64(3)}, {Key: "numericOrdering", Value: true}},
+		},
+		{
+			name:  "libc locale",
+			names: []string{"fr_FR.UTF-8"},
+			want:  mongo.Document{{Key: "locale", Value: "fr"}, {Key: "strength", Value: int64(3)}},
+		},
+		{
+			name:  "binary collation",
+			names: []string{"C"},
+			want:  mongo.Document{{Key: "locale", Value: "simple"}},
+		},
+		{
+			name:  "mysql accent and case insensitive",
+			names: []string{"utf8mb4_0900_ai_ci"},
+			want:  mongo.Document{{Key: "locale", Value: "en"}, {Key: "strength", Value: int64(1)}},
+		},
+		{
+			name:  "mysql accent sensitive",
+			names: []string{"utf8mb4_de_pb_0900_as_ci"},
+			want:  mongo.Document{{Key: "locale", Value: "de"}, {Key: "strength", Value: int64(2)}},
+		},
+		{
+			name:  "mysql language name",
+			names: []string{"latin1_swedish_ci"},
+			want:  mongo.Document{{Key: "locale", Value: "sv"}, {Key: "strength", Value: int64(1)}},
+		},
+		{
+			name:  "sql server accent sensitive",
+			names: []string{"SQL_Latin1_General_CP1_CI_AS"},
+			want:  mongo.Document{{Key: "locale", Value: "en"}, {Key: "strength", Value: int64(2)}},
+		},
+		{
+			name:  "sql server case sensitive and accent insensitive",
+			names: []string{"French_100_CS_AI_SC_UTF8"},
+			want:  mongo.Document{{Key: "locale", Value: "fr"}, {Key: "strength", Value: int64(1)}, {Key: "caseLevel", Value: true}},
+		},
+		{
+			name:  "sql server binary collation",
+			names: []string{"Latin1_General_BIN2"},
+			want:  mongo.Document{{Key: "locale", Value: "simple"}},
+		},
+		{
+			name:  "equivalent collations",
+			names: []string{"utf8mb4_general_ci", "utf8mb4_unicode_ci", "default"},
+			want:  mongo.Document{{Key: "locale", Value: "en"}, {Key: "strength", Value: int64(1)}},
+		},
+		{
+			name:  "default collation",
+			names: []string{"default"},
+		},
+		{
+			name:    "conflicting collations",
+			names:   []string{"utf8mb4_0900_ai_ci", "utf8mb4_0900_as_cs"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported setting",
+			names:   []string{"de-u-co-phonebk"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown collation",
+			names:   []string{"utf8mb4_klingon_ci"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown locale",
+			names:   []string{"xx-bogus"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown sql server language",
+			names:   []string{"Klingon_CI_AS"},
+			wantErr: true,
+		},
+		{
+			name:    "sql server kana sensitivity",
+			names:   []string{"Japanese_CI_AS_KS"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertCollations(tt.names)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
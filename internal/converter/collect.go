@@ -0,0 +1,143 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// arrayFunctions lists the aggregate functions that collect the values of a group into an array
+var arrayFunctions = map[string]bool{"ARRAY_AGG": true, "JSON_AGG": true, "JSONB_AGG": true, "JSON_ARRAYAGG": true}
+
+// stringFunctions maps the aggregate functions that join the values of a group into a string to
+// the separator they join them with when they are given none
+var stringFunctions = map[string]string{"STRING_AGG": ",", "GROUP_CONCAT": ",", "LISTAGG": ""}
+
+// collectedValue is the field of a collected element that holds the value, next to its sort keys
+const collectedValue = "value"
+
+// convertCollect converts an array or string aggregate to a $push of its values, or an $addToSet
+// for DISTINCT, and a finalizer that sorts the values by the ORDER BY of the call and, for a
+// string aggregate, joins those that are not null with its separator. A sorted aggregate pushes
+// each value with its sort keys, unless it is sorted by the value itself
+func (g *groupStage) convertCollect(call *sql.FuncCall, field string) (interface{}, interface{}, error) {
+	name := strings.ToUpper(call.Name)
+	separator, joined := stringFunctions[name]
+	if joined {
+		if len(call.Args) < 1 || len(call.Args) > 2 {
+			return nil, nil, fmt.Errorf("%s expects a value and an optional separator", name)
+		}
+		if len(call.Args) == 2 {
+			literal, ok := call.Args[1].(*sql.Literal)
+			if !ok || literal.Kind != sql.StringLiteral {
+				return nil, nil, fmt.Errorf("%s expects a constant separator but got %s", name, call.Args[1])
+			}
+			separator = literal.Value
+		}
+	} else if len(call.Args) != 1 {
+		return nil, nil, fmt.Errorf("%s expects one argument", name)
+	}
+	if len(call.OrderBy) > 0 && !g.input.options.supports(5, 2) {
+		return nil, nil, fmt.Errorf("%s with ORDER BY requires MongoDB 5.2 or later, which added $sortArray", name)
+	}
+
+	value, err := g.collectedArg(call.Args[0], joined)
+	if err != nil {
+		return nil, nil, err
+	}
+	var sortBy interface{}
+	element := value
+	if len(call.OrderBy) == 1 && call.OrderBy[0].Expr.String() == call.Args[0].String() {
+		sortBy = sortDirection(call.OrderBy[0])
+	} else if len(call.OrderBy) > 0 {
+		keys := mongo.Document{}
+		document := mongo.Document{{Key: collectedValue, Value: value}}
+		for i, item := range call.OrderBy {
+			key, err := convertExpression(item.Expr, g.input)
+			if err != nil {
+				return nil, nil, err
+			}
+			keyField := fmt.Sprintf("key%d", i+1)
+			document = append(document, mongo.Element{Key: keyField, Value: key})
+			keys = append(keys, mongo.Element{Key: keyField, Value: sortDirection(item)})
+		}
+		element, sortBy = document, keys
+	}
+	filter, err := g.convertAggregateFilter(call)
+	if err != nil {
+		return nil, nil, err
+	}
+	if filter != nil {
+		// $push and $addToSet skip missing values, which drops the rows the filter does not hold for
+		element = mongo.Document{{Key: "$cond", Value: mongo.Array{filter, element, "$$REMOVE"}}}
+	}
+	op := "$push"
+	if call.Distinct {
+		op = "$addToSet"
+	}
+	accumulator := mongo.Document{{Key: op, Value: element}}
+
+	var values interface{} = "$" + field
+	switch sortBy.(type) {
+	case nil:
+	case mongo.Document:
+		sorted := mongo.Document{{Key: "$sortArray", Value: mongo.Document{{Key: "input", Value: values}, {Key: "sortBy", Value: sortBy}}}}
+		values = mongo.Document{{Key: "$map", Value: mongo.Document{{Key: "input", Value: sorted}, {Key: "in", Value: "$$this." + collectedValue}}}}
+	default:
+		values = mongo.Document{{Key: "$sortArray", Value: mongo.Document{{Key: "input", Value: values}, {Key: "sortBy", Value: sortBy}}}}
+	}
+	if joined {
+		return accumulator, joinStrings(values, separator), nil
+	}
+	if sortBy == nil {
+		return accumulator, nil, nil
+	}
+	return accumulator, values, nil
+}
+
+// collectedArg converts the argument of an array or string aggregate. A string aggregate joins
+// the string form of its values, and an array aggregate of a table name collects its rows
+func (g *groupStage) collectedArg(arg sql.Expr, joined bool) (interface{}, error) {
+	if column, ok := arg.(*sql.ColumnRef); ok && column.Table == "" && !joined {
+		if prefix, ok := g.input.tables[column.Name]; ok {
+			if prefix == "" {
+				return "$$ROOT", nil
+			}
+			return "$" + prefix, nil
+		}
+	}
+	value, err := convertExpression(arg, g.input)
+	if err != nil {
+		return nil, err
+	}
+	if joined && kindOf(arg) != stringValue {
+		value = mongo.Document{{Key: "$toString", Value: value}}
+	}
+	return value, nil
+}
+
+// sortDirection returns the $sortArray direction of an ORDER BY item
+func sortDirection(item sql.OrderItem) int64 {
+	if item.Desc {
+		return -1
+	}
+	return 1
+}
+
+// joinStrings returns the expression that joins the strings of an array that are not null with a
+// separator, which is null when there are none, as the string aggregates of SQL are
+func joinStrings(values interface{}, separator string) mongo.Document {
+	present := mongo.Document{{Key: "$filter", Value: mongo.Document{
+		{Key: "input", Value: values},
+		{Key: "cond", Value: mongo.Document{{Key: "$ne", Value: mongo.Array{"$$this", nil}}}},
+	}}}
+	first := mongo.Document{{Key: "$eq", Value: mongo.Array{"$$value", nil}}}
+	next := mongo.Document{{Key: "$concat", Value: mongo.Array{"$$value", stringExpression(separator), "$$this"}}}
+	return mongo.Document{{Key: "$reduce", Value: mongo.Document{
+		{Key: "input", Value: present},
+		{Key: "initialValue", Value: nil},
+		{Key: "in", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{first, "$$this", next}}}},
+	}}}
+}
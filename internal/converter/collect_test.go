@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertCollect(t *testing.T) {
+	name := &sql.ColumnRef{Name: "name"}
+	age := &sql.ColumnRef{Name: "age"}
+	separator := func(value string) *sql.Literal { return &sql.Literal{Kind: sql.StringLiteral, Value: value} }
+	joined := func(values interface{}, separator interface{}) mongo.Document {
+		return mongo.Document{{Key: "$reduce", Value: mongo.Document{
+			{Key: "input", Value: mongo.Document{{Key: "$filter", Value: mongo.Document{
+				{Key: "input", Value: values},
+				{Key: "cond", Value: mongo.Document{{Key: "$ne", Value: mongo.Array{"$$this", nil}}}},
+			}}}},
+			{Key: "initialValue", Value: nil},
+			{Key: "in", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{
+				mongo.Document{{Key: "$eq", Value: mongo.Array{"$$value", nil}}},
+				"$$this",
+				mongo.Document{{Key: "$concat", Value: mongo.Array{"$$value", separator, "$$this"}}},
+			}}}},
+		}}}
+	}
+	tests := []struct {
+		name          string
+		call          *sql.FuncCall
+		version       string
+		wantAggregate interface{}
+		wantFinalizer interface{}
+		wantErr       bool
+	}{
+		{
+			name:          "array",
+			call:          &sql.FuncCall{Name: "array_agg", Args: []sql.Expr{name}},
+			wantAggregate: mongo.Document{{Key: "$push", Value: "$name"}},
+		},
+		{
+			name:          "distinct array",
+			call:          &sql.FuncCall{Name: "JSON_AGG", Args: []sql.Expr{name}, Distinct: true},
+			wantAggregate: mongo.Document{{Key: "$addToSet", Value: "$name"}},
+		},
+		{
+			name:          "array sorted by another column",
+			call:          &sql.FuncCall{Name: "ARRAY_AGG", Args: []sql.Expr{name}, OrderBy: []sql.OrderItem{{Expr: age, Desc: true}}},
+			wantAggregate: mongo.Document{{Key: "$push", Value: mongo.Document{{Key: "value", Value: "$name"}, {Key: "key1", Value: "$age"}}}},
+			wantFinalizer: mongo.Document{{Key: "$map", Value: mongo.Document{
+				{Key: "input", Value: mongo.Document{{Key: "$sortArray", Value: mongo.Document{
+					{Key: "input", Value: "$c"},
+					{Key: "sortBy", Value: mongo.Document{{Key: "key1", Value: int64(-1)}}},
+				}}}},
+				{Key: "in", Value: "$$this.value"},
+			}}},
+		},
+		{
+			name:          "array of rows",
+			call:          &sql.FuncCall{Name: "JSON_AGG", Args: []sql.Expr{&sql.ColumnRef{Name: "u"}}},
+			wantAggregate: mongo.Document{{Key: "$push", Value: "$$ROOT"}},
+		},
+		{
+			name: "filtered array",
+			call: &sql.FuncCall{Name: "ARRAY_AGG", Args: []sql.Expr{name}, Filter: &sql.ColumnRef{Name: "active"}},
+			wantAggregate: mongo.Document{{Key: "$push", Value: mongo.Document{
+				{Key: "$cond", Value: mongo.Array{"$active", "$name", "$$REMOVE"}},
+			}}},
+		},
+		{
+			name:          "string sorted by its value",
+			call:          &sql.FuncCall{Name: "STRING_AGG", Args: []sql.Expr{name, separator(", ")}, OrderBy: []sql.OrderItem{{Expr: name}}},
+			wantAggregate: mongo.Document{{Key: "$push", Value: mongo.Document{{Key: "$toString", Value: "$name"}}}},
+			wantFinalizer: joined(mongo.Document{{Key: "$sortArray", Value: mongo.Document{{Key: "input", Value: "$c"}, {Key: "sortBy", Value: int64(1)}}}}, ", "),
+		},
+		{
+			name:          "string with a default separator",
+			call:          &sql.FuncCall{Name: "LISTAGG", Args: []sql.Expr{name}},
+			wantAggregate: mongo.Document{{Key: "$push", Value: mongo.Document{{Key: "$toString", Value: "$name"}}}},
+			wantFinalizer: joined("$c", ""),
+		},
+		{
+			name:          "string with a separator starting with a dollar",
+			call:          &sql.FuncCall{Name: "GROUP_CONCAT", Args: []sql.Expr{separator("x"), separator("$")}},
+			wantAggregate: mongo.Document{{Key: "$push", Value: "x"}},
+			wantFinalizer: joined("$c", mongo.Document{{Key: "$literal", Value: "$"}}),
+		},
+		{
+			name:    "string with a separator column",
+			call:    &sql.FuncCall{Name: "STRING_AGG", Args: []sql.Expr{name, age}},
+			wantErr: true,
+		},
+		{
+			name:    "sorted before 5.2",
+			call:    &sql.FuncCall{Name: "ARRAY_AGG", Args: []sql.Expr{name}, OrderBy: []sql.OrderItem{{Expr: name}}},
+			version: "5.0",
+			wantErr: true,
+		},
+		{
+			name:    "array of two columns",
+			call:    &sql.FuncCall{Name: "ARRAY_AGG", Args: []sql.Expr{name, age}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := newScope()
+			input.tables["u"] = ""
+			input.options = Options{ServerVersion: tt.version}
+			group := newGroupStage(input)
+			aggregate, finalizer, err := group.convertAggregate(tt.call, "c")
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAggregate, aggregate)
+			require.Equal(t, tt.wantFinalizer, finalizer)
+		})
+	}
+}
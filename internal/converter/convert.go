@@ -2,6 +2,8 @@ package converter
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/oabraham1/mongosqlgen/internal/mongo"
 	"github.com/oabraham1/mongosqlgen/internal/sql"
@@ -23,18 +25,498 @@ func ConvertSQLCommandToMongoCommand(command sql.Command) (mongo.Command, error)
 	}
 }
 
+// Options configures how SQL queries are converted
+type Options struct {
+	// TimeZone is the time zone of the date literals and date functions that do not name one,
+	// as an Olson name such as "Europe/Paris" or an offset such as "+02:00", and UTC when empty
+	TimeZone string
+	// NullOnCastError makes a CAST return null for the values it can not convert, instead of
+	// failing the whole command, for collections that mix types in the same field
+	NullOnCastError bool
+	// ServerVersion is the version of the MongoDB server the commands run on, such as "6.0", which
+	// makes operators the server lacks be emulated with older ones. It is the latest version when empty
+	ServerVersion string
+}
+
 // ConvertSQLQueryToMongoQuery converts a SQL query to a MongoDB query
 func ConvertSQLQueryToMongoQuery(query sql.Query) (mongo.Query, error) {
+	return ConvertSQLQueryToMongoQueryWithOptions(query, Options{})
+}
+
+// ConvertSQLQueryToMongoQueryWithOptions converts a SQL query to a MongoDB query with the given options
+func ConvertSQLQueryToMongoQueryWithOptions(query sql.Query, options Options) (mongo.Query, error) {
+	if _, err := timeZoneLocation(options.TimeZone); err != nil {
+		return mongo.Query{}, err
+	}
+	if options.ServerVersion != "" {
+		if _, _, err := parseServerVersion(options.ServerVersion); err != nil {
+			return mongo.Query{}, err
+		}
+	}
 	mongoCommand, err := ConvertSQLCommandToMongoCommand(query.Command)
 	if err != nil {
 		return mongo.Query{}, err
 	}
-	return mongo.Query{
-		Command:     mongoCommand,
+	collation, err := convertCollations(query.Collations)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	var result mongo.Query
+	switch {
+	case query.Command == sql.SQLSelect && len(query.Projections) > 0:
+		result, err = convertSelectQuery(query, options)
+	case query.Command == sql.SQLUpdate && len(query.Set) > 0:
+		result, err = convertUpdateQuery(query, options)
+	case query.Command == sql.SQLDelete:
+		result, err = convertDeleteQuery(query, options)
+	default:
+		result = mongo.Query{
+			Command:     mongoCommand,
+			Database:    query.Database,
+			Collections: query.Table,
+			Field:       query.Columns,
+			Filter:      query.Filter,
+			Values:      query.Values,
+		}
+	}
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	result.Collation = collation
+	return result, nil
+}
+
+// convertSelectQuery converts a parsed SELECT query to a find, countDocuments or aggregate command
+func convertSelectQuery(query sql.Query, options Options) (mongo.Query, error) {
+	var warnings []string
+	if len(query.With) > 0 {
+		var err error
+		if query, warnings, err = inlineCTEs(query, nil); err != nil {
+			return mongo.Query{}, err
+		}
+	}
+	if len(query.SetOperations) > 0 {
+		result, err := convertSetQuery(query, options)
+		if err != nil {
+			return mongo.Query{}, err
+		}
+		result.Warnings = append(warnings, result.Warnings...)
+		return result, nil
+	}
+	if query.Pivot != nil {
+		var err error
+		if query, err = rewritePivot(query); err != nil {
+			return mongo.Query{}, err
+		}
+	}
+	query = rewriteRightJoin(query)
+	query, err := rewriteDistinct(query)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	query = nameQualifiedColumns(query)
+	if err := checkColumnNames(query.Projections); err != nil {
+		return mongo.Query{}, err
+	}
+	src, err := convertSource(query, options)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	result := mongo.Query{
+		Command:     mongo.MongoFind,
+		Database:    src.database,
+		Collections: src.collection,
+		Field:       query.Columns,
+		Filter:      query.Filter,
+		Hint:        src.hint,
+		Warnings:    append(warnings, src.warnings...),
+	}
+
+	if len(query.GroupBy) > 0 || query.GroupingSets != nil || query.Having != nil {
+		return convertAggregateQuery(query, result, src)
+	}
+	for _, projection := range query.Projections {
+		if sql.ContainsAggregate(projection.Expr) {
+			return convertAggregateQuery(query, result, src)
+		}
+	}
+
+	windows, err := convertWindows(query.Projections, src.scope)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	projection, set, root, err := convertProjection(query.Projections, src.scope)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	order, err := resolveOrderBy(query.OrderBy, query.Projections)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	sample := query.Limit != nil && isRandomOrder(order)
+	if sample {
+		order = nil
+	}
+	keys, sort, err := convertOrderBy(order, src.scope)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	result.Warnings = append(result.Warnings, windows.warnings...)
+	if len(src.stages) > 0 || src.sample != nil || len(windows.windows) > 0 || len(keys) > 0 || sample || set != nil || root != nil {
+		result.Command = mongo.MongoAggregate
+		result.Pipeline = append(src.pipeline(), windows.stages()...)
+		result.Pipeline = append(result.Pipeline, sortStages(keys, sort)...)
+		result.Pipeline = append(result.Pipeline, limitStages(query.Limit, sample)...)
+		if set != nil {
+			result.Pipeline = append(result.Pipeline, mongo.Document{{Key: "$set", Value: set}})
+		}
+		if root != nil {
+			result.Pipeline = append(result.Pipeline, mongo.Document{{Key: "$replaceWith", Value: root}})
+		}
+		if projection != nil {
+			result.Pipeline = append(result.Pipeline, mongo.Document{{Key: "$project", Value: projection}})
+		}
+		// The computed sort keys of the windows and the ORDER BY are kept until the projection reads them
+		if keys := append(windows.sortKeys, keys...); len(keys) > 0 && (projection == nil || isExclusion(projection)) && root == nil {
+			result.Pipeline = append(result.Pipeline, unsetKeys(keys))
+		}
+		return result, nil
+	}
+	result.Match = src.match
+	result.Projection = projection
+	result.Sort = sort
+	if query.Limit != nil {
+		result.Limit = *query.Limit
+	}
+	return result, nil
+}
+
+// convertUpdateQuery converts an UPDATE to an update that $sets the constant values it assigns, or
+// to an update with an aggregation pipeline when it sets a column to an expression, which can
+// compute the new values from the fields of each document
+func convertUpdateQuery(query sql.Query, options Options) (mongo.Query, error) {
+	s := tableScope(query, options)
+	result := mongo.Query{
+		Command:     mongo.MongoUpdate,
 		Database:    query.Database,
 		Collections: query.Table,
 		Field:       query.Columns,
 		Filter:      query.Filter,
-		Values:      query.Values,
-	}, nil
+		Hint:        query.Hint,
+	}
+	if query.Where != nil {
+		match, err := convertFilter(query.Where, s)
+		if err != nil {
+			return mongo.Query{}, err
+		}
+		result.Match = match
+	}
+	values := make([]sql.Expr, len(query.Set))
+	for i, assignment := range query.Set {
+		values[i] = assignment.Value
+	}
+	if constants, ok, err := constantValues(values, s); err != nil {
+		return mongo.Query{}, err
+	} else if ok {
+		var set mongo.Document
+		for i, assignment := range query.Set {
+			set = append(set, mongo.Element{Key: assignment.Column, Value: constants[i]})
+		}
+		result.Update = mongo.Document{{Key: "$set", Value: set}}
+		return result, nil
+	}
+	var set mongo.Document
+	for _, assignment := range query.Set {
+		if sql.ContainsAggregate(assignment.Value) || sql.ContainsWindow(assignment.Value) {
+			return mongo.Query{}, fmt.Errorf("UPDATE can not set %s to %s", assignment.Column, assignment.Value)
+		}
+		value, err := convertExpression(assignment.Value, s)
+		if err != nil {
+			return mongo.Query{}, err
+		}
+		set = append(set, mongo.Element{Key: assignment.Column, Value: projectedValue(value)})
+	}
+	result.Pipeline = []mongo.Document{{{Key: "$set", Value: set}}}
+	return result, nil
+}
+
+// convertDeleteQuery converts a DELETE to a deleteOne whose filter is converted from its WHERE clause
+func convertDeleteQuery(query sql.Query, options Options) (mongo.Query, error) {
+	s := tableScope(query, options)
+	result := mongo.Query{
+		Command:     mongo.MongoDelete,
+		Database:    query.Database,
+		Collections: query.Table,
+		Filter:      query.Filter,
+		Hint:        query.Hint,
+	}
+	if query.Where != nil {
+		match, err := convertFilter(query.Where, s)
+		if err != nil {
+			return mongo.Query{}, err
+		}
+		result.Match = match
+	}
+	return result, nil
+}
+
+// tableScope returns the scope of an UPDATE or DELETE, whose columns may be qualified with the
+// name or alias of the updated table
+func tableScope(query sql.Query, options Options) *scope {
+	s := newScope()
+	s.options = options
+	s.tables[query.Table] = ""
+	if query.Alias != "" {
+		s.tables[query.Alias] = ""
+	}
+	return s
+}
+
+// convertPipeline converts a SELECT query to the aggregation stages that produce its rows from
+// the returned command's collection, so that it can be composed into the pipeline of another query
+func convertPipeline(query sql.Query, options Options) (mongo.Query, []mongo.Document, error) {
+	result, err := convertSelectQuery(query, options)
+	if err != nil {
+		return mongo.Query{}, nil, err
+	}
+	var pipeline []mongo.Document
+	switch result.Command {
+	case mongo.MongoCount:
+		if len(result.Match) > 0 {
+			pipeline = append(pipeline, mongo.Document{{Key: "$match", Value: result.Match}})
+		}
+		pipeline = append(pipeline, mongo.Document{{Key: "$count", Value: fieldName(query.Projections[0].Name())}})
+	case mongo.MongoFind:
+		if result.Match != nil {
+			pipeline = append(pipeline, mongo.Document{{Key: "$match", Value: result.Match}})
+		}
+		if result.Sort != nil {
+			pipeline = append(pipeline, mongo.Document{{Key: "$sort", Value: result.Sort}})
+		}
+		if result.Limit > 0 {
+			pipeline = append(pipeline, mongo.Document{{Key: "$limit", Value: result.Limit}})
+		}
+		if result.Projection != nil {
+			pipeline = append(pipeline, mongo.Document{{Key: "$project", Value: result.Projection}})
+		}
+	default:
+		pipeline = result.Pipeline
+	}
+	if n := len(pipeline); n > 0 {
+		if project, ok := pipeline[n-1].Get("$project"); ok {
+			pipeline[n-1] = mongo.Document{{Key: "$project", Value: excludeID(project.(mongo.Document))}}
+		}
+	}
+	return result, pipeline, nil
+}
+
+// nameQualifiedColumns names the columns of a SELECT list that select columns of the same name from
+// different tables without an alias by their qualified path, such as u_name and o_name for u.name
+// and o.name, as a document can only hold one field of each name
+func nameQualifiedColumns(query sql.Query) sql.Query {
+	counts := map[string]int{}
+	for _, projection := range query.Projections {
+		if _, ok := projection.Expr.(*sql.StarExpr); !ok {
+			counts[fieldName(projection.Name())]++
+		}
+	}
+	var projections []sql.Projection
+	for i, projection := range query.Projections {
+		column, ok := projection.Expr.(*sql.ColumnRef)
+		if !ok || column.Table == "" || projection.Alias != "" || counts[fieldName(column.Name)] < 2 {
+			continue
+		}
+		if projections == nil {
+			projections = append([]sql.Projection(nil), query.Projections...)
+			query.Columns = append([]string(nil), query.Columns...)
+		}
+		projections[i].Alias = fieldName(column.Table + "." + column.Name)
+		if i < len(query.Columns) && query.Columns[i] == projection.Name() {
+			query.Columns[i] = projections[i].Alias
+		}
+	}
+	if projections != nil {
+		query.Projections = projections
+	}
+	return query
+}
+
+// checkColumnNames checks that the columns of a SELECT list have distinct names, as a document can
+// only hold one field of each name
+func checkColumnNames(projections []sql.Projection) error {
+	names := map[string]bool{}
+	for _, projection := range projections {
+		if _, ok := projection.Expr.(*sql.StarExpr); ok {
+			continue
+		}
+		name := fieldName(projection.Name())
+		if names[name] {
+			return fmt.Errorf("column %s is selected more than once, give each column a distinct alias", name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// excludeID excludes _id from a $project stage that does not select it, as a SELECT list only
+// produces the columns it names
+func excludeID(project mongo.Document) mongo.Document {
+	if _, ok := project.Get("_id"); ok {
+		return project
+	}
+	return append(mongo.Document{{Key: "_id", Value: int64(0)}}, project...)
+}
+
+// convertProjection converts a SELECT list to a projection, returning nil when all fields are selected
+// and an exclusion projection for the columns left out by SELECT * EXCEPT. The columns listed next to
+// * are returned separately as the fields to set on each document, since a projection can not add
+// fields to a document it keeps whole. The * of the queried table leaves out the tables joined to it,
+// and a SELECT list that only selects joined tables with * returns the document that replaces each
+// row instead, made of the fields of those tables and the other columns
+func convertProjection(projections []sql.Projection, s *scope) (mongo.Document, mongo.Document, interface{}, error) {
+	all, qualified := false, true
+	joined := map[string]bool{}
+	var projection, set mongo.Document
+	for _, p := range projections {
+		star, ok := p.Expr.(*sql.StarExpr)
+		if !ok {
+			continue
+		}
+		if star.Table != "" && s.tables[star.Table] != "" {
+			joined[s.tables[star.Table]] = true
+			continue
+		}
+		all = true
+		qualified = qualified && star.Table != ""
+		for _, column := range star.Except {
+			if _, ok := projection.Get(column); !ok {
+				projection = append(projection, mongo.Element{Key: column, Value: int64(0)})
+			}
+		}
+	}
+	if !all && len(joined) > 0 {
+		root, err := joinedRoot(projections, s)
+		return nil, nil, root, err
+	}
+	for _, p := range projections {
+		if star, ok := p.Expr.(*sql.StarExpr); ok {
+			if star.Table != "" && s.tables[star.Table] != "" && len(star.Except) > 0 {
+				return nil, nil, nil, fmt.Errorf("%s is not supported for a joined table", star)
+			}
+			continue
+		}
+		name, value, err := projectedColumn(p, s)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !all {
+			projection = append(projection, mongo.Element{Key: name, Value: value})
+			continue
+		}
+		if value == int64(1) {
+			continue
+		}
+		set = append(set, mongo.Element{Key: name, Value: value})
+	}
+	if all {
+		if qualified {
+			for _, prefix := range joinedPrefixes(s) {
+				if _, ok := projection.Get(prefix); !ok && !joined[prefix] {
+					projection = append(projection, mongo.Element{Key: prefix, Value: int64(0)})
+				}
+			}
+		}
+		// A column computed next to * EXCEPT replaces the field of the same name instead of hiding it
+		var exclusion mongo.Document
+		for _, element := range projection {
+			if _, ok := set.Get(element.Key); !ok {
+				exclusion = append(exclusion, element)
+			}
+		}
+		projection = exclusion
+	}
+	return projection, set, nil, nil
+}
+
+// joinedRoot returns the document that replaces each row for a SELECT list that selects joined
+// tables with *, which merges the fields of those tables with the other columns of the list
+func joinedRoot(projections []sql.Projection, s *scope) (interface{}, error) {
+	var merged mongo.Array
+	var columns mongo.Document
+	for _, p := range projections {
+		if star, ok := p.Expr.(*sql.StarExpr); ok {
+			if len(star.Except) > 0 {
+				return nil, fmt.Errorf("%s is not supported for a joined table", star)
+			}
+			merged = append(merged, "$"+s.tables[star.Table])
+			continue
+		}
+		name, value, err := projectedColumn(p, s)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(name, ".") {
+			return nil, fmt.Errorf("%s can not be selected next to the * of a joined table, give it an alias", p.Expr)
+		}
+		if value == int64(1) {
+			value = "$" + name
+		}
+		columns = append(columns, mongo.Element{Key: name, Value: value})
+	}
+	if columns != nil {
+		merged = append(merged, columns)
+	}
+	// $mergeObjects skips the missing row of a left join that matched nothing, which $replaceWith rejects
+	return mongo.Document{{Key: "$mergeObjects", Value: merged}}, nil
+}
+
+// joinedPrefixes returns the fields that hold the rows of the tables joined to a scope, in order
+func joinedPrefixes(s *scope) []string {
+	var prefixes []string
+	for _, prefix := range s.tables {
+		if prefix != "" && indexOfString(prefixes, prefix) == -1 {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// isExclusion checks if a projection only excludes fields, keeping every other field of a document
+func isExclusion(projection mongo.Document) bool {
+	for _, element := range projection {
+		if element.Value != int64(0) {
+			return false
+		}
+	}
+	return len(projection) > 0
+}
+
+// projectedColumn returns the name and value of a column of a SELECT list in a projection, which is
+// 1 for a field that keeps its name
+func projectedColumn(p sql.Projection, s *scope) (string, interface{}, error) {
+	if column, ok := p.Expr.(*sql.ColumnRef); ok {
+		field, _ := s.fieldPath(column)
+		name := p.Alias
+		if name == "" {
+			name = field
+			_, known := s.tables[column.Table]
+			if _, computed := s.fields[column.String()]; computed || (known && column.Table != "") {
+				name = column.Name
+			}
+		}
+		if name == field {
+			return field, int64(1), nil
+		}
+		return fieldName(name), "$" + field, nil
+	}
+	name := fieldName(p.Name())
+	if field, ok := s.fieldPath(p.Expr); ok && field == name {
+		return name, int64(1), nil
+	}
+	value, err := convertExpression(p.Expr, s)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, projectedValue(value), nil
 }
@@ -119,6 +119,24 @@ func TestConvertSQLQueryToMongoQuery(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "update with constant values",
+			sql: sql.Query{
+				Command: sql.SQLUpdate,
+				Table:   "users",
+				Columns: []string{"age"},
+				Where:   &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Name: "id"}, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "1"}},
+				Set:     []sql.Assignment{{Column: "age", Value: &sql.Literal{Kind: sql.NumberLiteral, Value: "21"}}},
+			},
+			want: mongo.Query{
+				Command:     mongo.MongoUpdate,
+				Collections: "users",
+				Field:       []string{"age"},
+				Match:       mongo.Document{{Key: "id", Value: int64(1)}},
+				Update:      mongo.Document{{Key: "$set", Value: mongo.Document{{Key: "age", Value: int64(21)}}}},
+			},
+			wantErr: false,
+		},
 		{
 			name: "delete",
 			sql: sql.Query{
@@ -159,3 +177,71 @@ func TestConvertSQLQueryToMongoQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertPipeline(t *testing.T) {
+	name := sql.Projection{Expr: &sql.ColumnRef{Name: "name"}}
+	active := &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Name: "active"}, Right: &sql.Literal{Kind: sql.BooleanLiteral, Value: "true"}}
+	tests := []struct {
+		name    string
+		query   sql.Query
+		want    []mongo.Document
+		wantErr bool
+	}{
+		{
+			name:  "find",
+			query: sql.Query{Command: sql.SQLSelect, Table: "users", Projections: []sql.Projection{name}, Where: active},
+			want: []mongo.Document{
+				{{Key: "$match", Value: mongo.Document{{Key: "active", Value: true}}}},
+				{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "name", Value: int64(1)}}}},
+			},
+		},
+		{
+			name:  "count",
+			query: sql.Query{Command: sql.SQLSelect, Table: "users", Projections: []sql.Projection{{Expr: &sql.FuncCall{Name: "COUNT", Args: []sql.Expr{&sql.StarExpr{}}}, Alias: "n"}}},
+			want:  []mongo.Document{{{Key: "$count", Value: "n"}}},
+		},
+		{
+			name: "nested derived tables",
+			query: sql.Query{
+				Command:     sql.SQLSelect,
+				Alias:       "b",
+				Projections: []sql.Projection{name},
+				From: &sql.Query{
+					Command:     sql.SQLSelect,
+					Alias:       "a",
+					Projections: []sql.Projection{{Expr: &sql.StarExpr{}}},
+					Where:       active,
+					From:        &sql.Query{Command: sql.SQLSelect, Table: "users", Projections: []sql.Projection{name, {Expr: &sql.ColumnRef{Name: "active"}}}},
+				},
+			},
+			want: []mongo.Document{
+				{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "name", Value: int64(1)}, {Key: "active", Value: int64(1)}}}},
+				{{Key: "$match", Value: mongo.Document{{Key: "active", Value: true}}}},
+				{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "name", Value: int64(1)}}}},
+			},
+		},
+		{
+			name: "right join to a derived table",
+			query: sql.Query{
+				Command:     sql.SQLSelect,
+				Alias:       "a",
+				Projections: []sql.Projection{name},
+				From:        &sql.Query{Command: sql.SQLSelect, Table: "users", Projections: []sql.Projection{name}},
+				Joins:       []sql.Join{{Type: sql.RightJoin, Table: "orders", On: active}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, pipeline, err := convertPipeline(tt.query, Options{})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "users", got.Collections)
+			require.Equal(t, tt.want, pipeline)
+		})
+	}
+}
@@ -0,0 +1,454 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// treeField and depthField hold the documents found by the $graphLookup of a recursive query
+// and their distance from the row they were found from
+const (
+	treeField  = "_tree"
+	depthField = "_depth"
+)
+
+// commonTable is a common table expression that references can be resolved to
+type commonTable struct {
+	cte sql.CommonTableExpression
+	// query is the query of a non-recursive expression, with its own references resolved
+	query      sql.Query
+	recursive  bool
+	references int
+}
+
+// inlineCTEs replaces the references of a query to common table expressions with derived
+// tables, so that each reference becomes a sub-pipeline. References to a recursive expression
+// are kept, and the expression is defined on the query that reads from it
+func inlineCTEs(query sql.Query, tables map[string]*commonTable) (sql.Query, []string, error) {
+	var warnings []string
+	with := query.With
+	if len(with) > 0 {
+		defined := make(map[string]*commonTable, len(tables)+len(query.With))
+		for name, table := range tables {
+			defined[name] = table
+		}
+		for _, cte := range with {
+			table := &commonTable{cte: cte, recursive: cte.Recursive && queriesTable(cte.Query, cte.Name)}
+			inner, innerWarnings, err := inlineCTEs(cte.Query, defined)
+			if err != nil {
+				return sql.Query{}, nil, err
+			}
+			if table.recursive {
+				table.cte.Query = inner
+			} else if table.query, err = renameColumns(inner, cte); err != nil {
+				return sql.Query{}, nil, err
+			}
+			warnings = append(warnings, innerWarnings...)
+			defined[cte.Name] = table
+		}
+		tables = defined
+	}
+	query.With = nil
+
+	if query.From != nil {
+		from, fromWarnings, err := inlineCTEs(*query.From, tables)
+		if err != nil {
+			return sql.Query{}, nil, err
+		}
+		query.From = &from
+		warnings = append(warnings, fromWarnings...)
+	} else if table, ok := tables[query.Table]; ok && query.Database == "" {
+		table.references++
+		if query.Alias == "" {
+			query.Alias = query.Table
+		}
+		if table.recursive {
+			query.With = []sql.CommonTableExpression{table.cte}
+		} else {
+			from := table.query
+			query.From, query.Table = &from, ""
+		}
+	}
+
+	joins := make([]sql.Join, len(query.Joins))
+	for i, join := range query.Joins {
+		if join.Subquery != nil {
+			subquery, joinWarnings, err := inlineCTEs(*join.Subquery, tables)
+			if err != nil {
+				return sql.Query{}, nil, err
+			}
+			join.Subquery = &subquery
+			warnings = append(warnings, joinWarnings...)
+		} else if table, ok := tables[join.Table]; ok {
+			if table.recursive {
+				return sql.Query{}, nil, fmt.Errorf("recursive common table expression %s can only be queried in FROM", join.Table)
+			}
+			table.references++
+			subquery := table.query
+			join.Alias, join.Table, join.Subquery = join.Name(), "", &subquery
+		}
+		joins[i] = join
+	}
+	if len(joins) > 0 {
+		query.Joins = joins
+	}
+
+	operations := make([]sql.SetOperation, len(query.SetOperations))
+	for i, operation := range query.SetOperations {
+		branch, branchWarnings, err := inlineCTEs(operation.Query, tables)
+		if err != nil {
+			return sql.Query{}, nil, err
+		}
+		operation.Query = branch
+		operations[i] = operation
+		warnings = append(warnings, branchWarnings...)
+	}
+	if len(operations) > 0 {
+		query.SetOperations = operations
+	}
+	for _, cte := range with {
+		if table := tables[cte.Name]; table.references > 1 {
+			warnings = append(warnings, fmt.Sprintf(
+				"common table expression %s is referenced %d times and is computed again for each reference",
+				cte.Name, table.references))
+		}
+	}
+	return query, warnings, nil
+}
+
+// queriesTable checks if a query or any of its subqueries reads from the named table
+func queriesTable(query sql.Query, name string) bool {
+	if query.Table == name && query.Database == "" {
+		return true
+	}
+	if query.From != nil && queriesTable(*query.From, name) {
+		return true
+	}
+	for _, join := range query.Joins {
+		if join.Table == name || (join.Subquery != nil && queriesTable(*join.Subquery, name)) {
+			return true
+		}
+	}
+	for _, operation := range query.SetOperations {
+		if queriesTable(operation.Query, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// renameColumns names the columns of a query after the column list of a common table expression
+func renameColumns(query sql.Query, cte sql.CommonTableExpression) (sql.Query, error) {
+	if len(cte.Columns) == 0 {
+		return query, nil
+	}
+	if len(cte.Columns) != len(query.Projections) {
+		return sql.Query{}, fmt.Errorf("common table expression %s has %d columns but its query selects %d",
+			cte.Name, len(cte.Columns), len(query.Projections))
+	}
+	projections := make([]sql.Projection, len(query.Projections))
+	for i, projection := range query.Projections {
+		if _, ok := projection.Expr.(*sql.StarExpr); ok {
+			return sql.Query{}, fmt.Errorf("common table expression %s can not name the columns of %s", cte.Name, projection.Expr)
+		}
+		projections[i] = sql.Projection{Expr: projection.Expr, Alias: cte.Columns[i]}
+	}
+	query.Projections = projections
+	query.Columns = cte.Columns
+	return query, nil
+}
+
+// recursiveTable returns the recursive common table expression a query reads from, if any
+func recursiveTable(query sql.Query) (sql.CommonTableExpression, bool) {
+	for _, cte := range query.With {
+		if cte.Name == query.Table && query.From == nil && query.Database == "" {
+			return cte, true
+		}
+	}
+	return sql.CommonTableExpression{}, false
+}
+
+// hierarchy describes a recursive query that walks a hierarchy: starting from the rows of an
+// anchor query, it repeatedly adds the documents of a collection that refer to the rows already found
+type hierarchy struct {
+	name    string
+	columns []string
+	anchor  sql.Query
+	// collection and alias identify the collection walked by the recursive query, and tree
+	// the alias it gives the rows already found
+	collection string
+	alias      string
+	tree       string
+	// fields are the fields of the walked collection selected for each column, empty for the depth column
+	fields []string
+	// depth is the position of the column that counts the steps from the anchor row, -1 if there is none
+	depth     int
+	start     interface{}
+	increment interface{}
+	from      string
+	to        string
+	// limit is the condition on the depth column that stops the recursion
+	limit    *sql.BinaryExpr
+	restrict sql.Expr
+}
+
+// convertRecursive converts a recursive common table expression to a pipeline that selects its
+// anchor rows and finds the rows below them with $graphLookup
+func convertRecursive(cte sql.CommonTableExpression, options Options) (mongo.Query, []mongo.Document, error) {
+	h, err := analyzeHierarchy(cte)
+	if err != nil {
+		return mongo.Query{}, nil, err
+	}
+
+	anchor := h.anchor
+	anchor.Projections = []sql.Projection{{Expr: &sql.StarExpr{}}}
+	anchor.Columns = []string{"*"}
+	result, pipeline, err := convertPipeline(anchor, options)
+	if err != nil {
+		return mongo.Query{}, nil, err
+	}
+	anchorScope := newScope()
+	anchorScope.options = options
+	anchorScope.tables[anchorName(h.anchor)] = ""
+	start, _ := anchorScope.fieldPath(h.anchor.Projections[indexOfString(h.columns, h.from)].Expr)
+	connectFrom := h.fields[indexOfString(h.columns, h.from)]
+
+	graph := mongo.Document{
+		{Key: "from", Value: h.collection},
+		{Key: "startWith", Value: "$" + start},
+		{Key: "connectFromField", Value: connectFrom},
+		{Key: "connectToField", Value: h.to},
+		{Key: "as", Value: treeField},
+	}
+	if h.limit != nil {
+		maxDepth, err := h.maxDepth()
+		if err != nil {
+			return mongo.Query{}, nil, err
+		}
+		graph = append(graph, mongo.Element{Key: "maxDepth", Value: maxDepth})
+	}
+	anchorRow := mongo.Document{{Key: treeField, Value: nil}}
+	if h.depth >= 0 {
+		graph = append(graph, mongo.Element{Key: "depthField", Value: depthField})
+		anchorRow = append(anchorRow, mongo.Element{Key: depthField, Value: int64(-1)})
+	}
+	if h.restrict != nil {
+		walked := newScope()
+		walked.options = options
+		walked.tables[h.alias] = ""
+		restrict, err := convertFilter(h.restrict, walked)
+		if err != nil {
+			return mongo.Query{}, nil, err
+		}
+		graph = append(graph, mongo.Element{Key: "restrictSearchWithMatch", Value: restrict})
+	}
+
+	rows := mongo.Document{{Key: "$concatArrays", Value: mongo.Array{
+		mongo.Array{mongo.Document{{Key: "$mergeObjects", Value: mongo.Array{"$$ROOT", anchorRow}}}},
+		"$" + treeField,
+	}}}
+	pipeline = append(pipeline,
+		mongo.Document{{Key: "$graphLookup", Value: graph}},
+		mongo.Document{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: treeField, Value: rows}}}},
+		mongo.Document{{Key: "$unwind", Value: "$" + treeField}},
+		mongo.Document{{Key: "$replaceWith", Value: "$" + treeField}},
+		mongo.Document{{Key: "$project", Value: h.projection()}},
+	)
+	return result, pipeline, nil
+}
+
+// analyzeHierarchy recognizes the hierarchy walk of a recursive common table expression
+func analyzeHierarchy(cte sql.CommonTableExpression) (*hierarchy, error) {
+	body := cte.Query
+	if len(body.SetOperations) != 1 || body.SetOperations[0].Operator != sql.Union || !body.SetOperations[0].All {
+		return nil, fmt.Errorf("recursive common table expression %s must be an anchor query UNION ALL a recursive query", cte.Name)
+	}
+	h := &hierarchy{name: cte.Name, anchor: body, depth: -1}
+	h.anchor.SetOperations = nil
+	recursive := body.SetOperations[0].Query
+	if queriesTable(h.anchor, cte.Name) {
+		return nil, fmt.Errorf("the anchor query of %s can not refer to %s", cte.Name, cte.Name)
+	}
+
+	h.columns = cte.Columns
+	if len(h.columns) == 0 {
+		for _, projection := range h.anchor.Projections {
+			h.columns = append(h.columns, fieldName(projection.Name()))
+		}
+	}
+	if len(h.anchor.Projections) != len(h.columns) || len(recursive.Projections) != len(h.columns) {
+		return nil, fmt.Errorf("the queries of %s must select %d columns", cte.Name, len(h.columns))
+	}
+
+	if len(recursive.Joins) != 1 || recursive.Joins[0].Type != sql.InnerJoin || recursive.Joins[0].Subquery != nil || recursive.Joins[0].Unnest != nil ||
+		recursive.From != nil || len(recursive.GroupBy) > 0 || recursive.Having != nil {
+		return nil, fmt.Errorf("the recursive query of %s must join a collection to %s", cte.Name, cte.Name)
+	}
+	join := recursive.Joins[0]
+	base := recursive.Alias
+	if base == "" {
+		base = recursive.Table
+	}
+	switch {
+	case recursive.Table == cte.Name && join.Table != cte.Name:
+		h.tree, h.collection, h.alias = base, join.Table, join.Name()
+	case join.Table == cte.Name && recursive.Table != cte.Name:
+		h.tree, h.collection, h.alias = join.Name(), recursive.Table, base
+	default:
+		return nil, fmt.Errorf("the recursive query of %s must join a collection to %s", cte.Name, cte.Name)
+	}
+
+	if err := h.analyzeColumns(recursive); err != nil {
+		return nil, err
+	}
+	if err := h.analyzeJoin(join.On); err != nil {
+		return nil, err
+	}
+	if recursive.Where != nil {
+		if err := h.analyzeWhere(recursive.Where); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// analyzeColumns matches each column of the recursive query to a field of the walked collection,
+// or to the depth column when it increments a column of the rows already found
+func (h *hierarchy) analyzeColumns(recursive sql.Query) error {
+	h.fields = make([]string, len(h.columns))
+	for i, projection := range recursive.Projections {
+		anchor := h.anchor.Projections[i].Expr
+		if column, ok := projection.Expr.(*sql.ColumnRef); ok && (column.Table == h.alias || column.Table == "") {
+			if anchorColumn, ok := anchor.(*sql.ColumnRef); !ok || anchorColumn.Name != column.Name {
+				return fmt.Errorf("column %s of %s must select %s in both queries", h.columns[i], h.name, column.Name)
+			}
+			h.fields[i] = column.Name
+			continue
+		}
+		binary, ok := projection.Expr.(*sql.BinaryExpr)
+		if ok && binary.Op == "+" && h.depth == -1 {
+			column, isColumn := binary.Left.(*sql.ColumnRef)
+			increment, isConstant, err := constantValue(binary.Right, nil)
+			if err != nil {
+				return err
+			}
+			start, isStart, err := constantValue(anchor, nil)
+			if err != nil {
+				return err
+			}
+			if isColumn && column.Table == h.tree && column.Name == h.columns[i] && isConstant && isStart {
+				h.depth, h.start, h.increment = i, start, increment
+				continue
+			}
+		}
+		return fmt.Errorf("column %s of %s must select a column of %s or increment a column of %s",
+			h.columns[i], h.name, h.alias, h.tree)
+	}
+	return nil
+}
+
+// analyzeJoin finds the fields that connect the documents of the hierarchy
+func (h *hierarchy) analyzeJoin(on sql.Expr) error {
+	eq, ok := on.(*sql.BinaryExpr)
+	if ok && eq.Op == "=" {
+		a, aok := eq.Left.(*sql.ColumnRef)
+		b, bok := eq.Right.(*sql.ColumnRef)
+		if aok && bok && a.Table == h.tree {
+			a, b = b, a
+		}
+		if aok && bok && a.Table == h.alias && b.Table == h.tree {
+			if position := indexOfString(h.columns, b.Name); position != -1 && h.fields[position] != "" {
+				h.from, h.to = b.Name, a.Name
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("the recursive query of %s must join on a column of %s equal to a column of %s", h.name, h.alias, h.tree)
+}
+
+// analyzeWhere splits the condition of the recursive query into the limit on the depth column
+// and the filter on the documents of the walked collection
+func (h *hierarchy) analyzeWhere(where sql.Expr) error {
+	tree := map[string]bool{h.tree: true}
+	for _, conjunct := range flatten(where, "AND") {
+		if !refersTo(conjunct, tree, nil) {
+			h.restrict = and(h.restrict, conjunct)
+			continue
+		}
+		if limit, ok := conjunct.(*sql.BinaryExpr); ok && h.depth >= 0 && h.limit == nil {
+			if _, ok := limit.Left.(*sql.Literal); ok {
+				if op, ok := flippedOperators[limit.Op]; ok {
+					limit = &sql.BinaryExpr{Op: op, Left: limit.Right, Right: limit.Left}
+				}
+			}
+			column, ok := limit.Left.(*sql.ColumnRef)
+			if ok && column.Table == h.tree && column.Name == h.columns[h.depth] && (limit.Op == "<" || limit.Op == "<=") {
+				h.limit = limit
+				continue
+			}
+		}
+		return fmt.Errorf("the recursive query of %s can only limit the depth column of %s: %s", h.name, h.tree, conjunct)
+	}
+	return nil
+}
+
+// maxDepth returns the deepest $graphLookup depth allowed by the limit on the depth column
+func (h *hierarchy) maxDepth() (int64, error) {
+	start, startOK := h.start.(int64)
+	increment, incrementOK := h.increment.(int64)
+	bound, boundOK, err := constantValue(h.limit.Right, nil)
+	if err != nil {
+		return 0, err
+	}
+	limit, limitOK := bound.(int64)
+	if !startOK || !incrementOK || !boundOK || !limitOK || increment <= 0 {
+		return 0, fmt.Errorf("the depth of %s can only be limited by a positive integer increment and an integer bound", h.name)
+	}
+	if h.limit.Op == "<" {
+		limit--
+	}
+	if limit < start {
+		return 0, fmt.Errorf("the limit %s of %s excludes every recursive row", h.limit, h.name)
+	}
+	return (limit - start) / increment, nil
+}
+
+// projection returns the $project stage that selects the columns of the hierarchy rows
+func (h *hierarchy) projection() mongo.Document {
+	var project mongo.Document
+	if indexOfString(h.columns, "_id") == -1 {
+		project = mongo.Document{{Key: "_id", Value: int64(0)}}
+	}
+	for i, name := range h.columns {
+		switch {
+		case i == h.depth:
+			project = append(project, mongo.Element{Key: name, Value: h.depthValue()})
+		case h.fields[i] == name:
+			project = append(project, mongo.Element{Key: name, Value: int64(1)})
+		default:
+			project = append(project, mongo.Element{Key: name, Value: "$" + h.fields[i]})
+		}
+	}
+	return project
+}
+
+// depthValue returns the expression that computes the depth column from the $graphLookup depth,
+// which is -1 for the anchor rows
+func (h *hierarchy) depthValue() interface{} {
+	start, ok := h.start.(int64)
+	if ok && h.increment == int64(1) {
+		return mongo.Document{{Key: "$add", Value: mongo.Array{"$" + depthField, start + 1}}}
+	}
+	steps := mongo.Document{{Key: "$add", Value: mongo.Array{"$" + depthField, int64(1)}}}
+	return mongo.Document{{Key: "$add", Value: mongo.Array{h.start, mongo.Document{{Key: "$multiply", Value: mongo.Array{steps, h.increment}}}}}}
+}
+
+// anchorName returns the name the anchor query gives the collection it reads from
+func anchorName(query sql.Query) string {
+	if query.Alias != "" {
+		return query.Alias
+	}
+	return query.Table
+}
@@ -0,0 +1,96 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineCTEs(t *testing.T) {
+	names := sql.Query{Command: sql.SQLSelect, Table: "users", Columns: []string{"name"}, Projections: []sql.Projection{{Expr: &sql.ColumnRef{Name: "name"}}}}
+	query := sql.Query{
+		Command:     sql.SQLSelect,
+		Table:       "a",
+		Projections: []sql.Projection{{Expr: &sql.StarExpr{}}},
+		Joins:       []sql.Join{{Type: sql.InnerJoin, Table: "a", Alias: "b", On: &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Table: "a", Name: "n"}, Right: &sql.ColumnRef{Table: "b", Name: "n"}}}},
+		With:        []sql.CommonTableExpression{{Name: "a", Columns: []string{"n"}, Query: names}},
+	}
+	got, warnings, err := inlineCTEs(query, nil)
+	require.NoError(t, err)
+	renamed := sql.Query{Command: sql.SQLSelect, Table: "users", Columns: []string{"n"}, Projections: []sql.Projection{{Expr: &sql.ColumnRef{Name: "name"}, Alias: "n"}}}
+	require.Equal(t, sql.Query{
+		Command:     sql.SQLSelect,
+		Alias:       "a",
+		From:        &renamed,
+		Projections: query.Projections,
+		Joins:       []sql.Join{{Type: sql.InnerJoin, Alias: "b", On: query.Joins[0].On, Subquery: &renamed}},
+	}, got)
+	require.Len(t, warnings, 1)
+
+	query.With[0].Columns = []string{"n", "m"}
+	_, _, err = inlineCTEs(query, nil)
+	require.Error(t, err)
+}
+
+func TestConvertRecursive(t *testing.T) {
+	column := func(table string, name string) *sql.ColumnRef {
+		return &sql.ColumnRef{Table: table, Name: name}
+	}
+	number := func(value string) *sql.Literal {
+		return &sql.Literal{Kind: sql.NumberLiteral, Value: value}
+	}
+	anchor := sql.Query{
+		Command:     sql.SQLSelect,
+		Table:       "employees",
+		Projections: []sql.Projection{{Expr: column("", "_id")}, {Expr: column("", "name")}, {Expr: number("1"), Alias: "level"}},
+		Where:       &sql.IsNullExpr{Expr: column("", "manager_id")},
+	}
+	recursive := sql.Query{
+		Command:     sql.SQLSelect,
+		Table:       "employees",
+		Alias:       "e",
+		Projections: []sql.Projection{{Expr: column("e", "_id")}, {Expr: column("e", "name")}, {Expr: &sql.BinaryExpr{Op: "+", Left: column("t", "level"), Right: number("1")}}},
+		Joins:       []sql.Join{{Type: sql.InnerJoin, Table: "tree", Alias: "t", On: &sql.BinaryExpr{Op: "=", Left: column("e", "manager_id"), Right: column("t", "_id")}}},
+		Where:       &sql.BinaryExpr{Op: ">", Left: number("4"), Right: column("t", "level")},
+	}
+	cte := sql.CommonTableExpression{Name: "tree", Recursive: true, Query: anchor}
+	cte.Query.SetOperations = []sql.SetOperation{{Operator: sql.Union, All: true, Query: recursive}}
+
+	got, pipeline, err := convertRecursive(cte, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "employees", got.Collections)
+	require.Equal(t, []mongo.Document{
+		{{Key: "$match", Value: mongo.Document{{Key: "manager_id", Value: nil}}}},
+		{{Key: "$graphLookup", Value: mongo.Document{
+			{Key: "from", Value: "employees"},
+			{Key: "startWith", Value: "$_id"},
+			{Key: "connectFromField", Value: "_id"},
+			{Key: "connectToField", Value: "manager_id"},
+			{Key: "as", Value: "_tree"},
+			{Key: "maxDepth", Value: int64(2)},
+			{Key: "depthField", Value: "_depth"},
+		}}},
+		{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "_tree", Value: mongo.Document{{Key: "$concatArrays", Value: mongo.Array{
+			mongo.Array{mongo.Document{{Key: "$mergeObjects", Value: mongo.Array{"$$ROOT", mongo.Document{{Key: "_tree", Value: nil}, {Key: "_depth", Value: int64(-1)}}}}}},
+			"$_tree",
+		}}}}}}},
+		{{Key: "$unwind", Value: "$_tree"}},
+		{{Key: "$replaceWith", Value: "$_tree"}},
+		{{Key: "$project", Value: mongo.Document{
+			{Key: "_id", Value: int64(1)},
+			{Key: "name", Value: int64(1)},
+			{Key: "level", Value: mongo.Document{{Key: "$add", Value: mongo.Array{"$_depth", int64(2)}}}},
+		}}},
+	}, pipeline)
+
+	cte.Query.SetOperations[0].All = false
+	_, _, err = convertRecursive(cte, Options{})
+	require.Error(t, err)
+
+	cte.Query.SetOperations[0].All = true
+	cte.Query.SetOperations[0].Query.Where = &sql.BinaryExpr{Op: "=", Left: column("t", "name"), Right: &sql.Literal{Kind: sql.StringLiteral, Value: "Ann"}}
+	_, _, err = convertRecursive(cte, Options{})
+	require.Error(t, err)
+}
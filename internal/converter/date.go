@@ -0,0 +1,431 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	// tzdata embeds the time zone database so that Olson names resolve on any system
+	_ "time/tzdata"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// dateValue is the kind of value produced by date literals and date functions
+const dateValue valueKind = "date"
+
+// timeZoneOffset matches a time zone given as an offset from UTC, such as +02:00 or -0530
+var timeZoneOffset = regexp.MustCompile(`^([+-])(\d{2}):?(\d{2})$`)
+
+// dateLayouts lists the accepted layouts of DATE and TIMESTAMP literals, those with a time zone first
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// dateUnits maps the units of intervals and date functions, and their abbreviations, to aggregation units
+var dateUnits = map[string]string{
+	"YEAR":        "year",
+	"QUARTER":     "quarter",
+	"MONTH":       "month",
+	"MON":         "month",
+	"WEEK":        "week",
+	"DAY":         "day",
+	"HOUR":        "hour",
+	"MINUTE":      "minute",
+	"MIN":         "minute",
+	"SECOND":      "second",
+	"SEC":         "second",
+	"MILLISECOND": "millisecond",
+	"MS":          "millisecond",
+}
+
+// dateParts maps the fields of EXTRACT and DATE_PART to the operator that returns them
+var dateParts = map[string]string{
+	"YEAR":        "$year",
+	"MONTH":       "$month",
+	"DAY":         "$dayOfMonth",
+	"HOUR":        "$hour",
+	"MINUTE":      "$minute",
+	"SECOND":      "$second",
+	"MILLISECOND": "$millisecond",
+	"DOY":         "$dayOfYear",
+	"ISODOW":      "$isoDayOfWeek",
+	"WEEK":        "$isoWeek",
+	"ISOYEAR":     "$isoWeekYear",
+}
+
+// dateFunction describes a SQL date function, which unlike other scalar functions needs the
+// unconverted arguments and the time zone of the conversion
+type dateFunction struct {
+	minArgs int
+	maxArgs int
+	result  valueKind
+	convert func(call *sql.FuncCall, s *scope) (interface{}, error)
+}
+
+// dateFunctions maps the names of the supported SQL date functions to their description
+var dateFunctions map[string]dateFunction
+
+// init fills dateFunctions, whose conversions convert their arguments and so refer back to it
+func init() {
+	dateFunctions = map[string]dateFunction{
+		"NOW":               {0, 0, dateValue, convertNow},
+		"CURRENT_TIMESTAMP": {0, 0, dateValue, convertNow},
+		"CURRENT_DATE":      {0, 0, dateValue, convertCurrentDate},
+		"DATE_TRUNC":        {2, 3, dateValue, convertDateTrunc},
+		"EXTRACT":           {2, 3, numberValue, convertExtract},
+		"DATE_PART":         {2, 3, numberValue, convertExtract},
+		"DATE_ADD":          {2, 2, dateValue, convertDateAdd},
+		"DATE_SUB":          {2, 2, dateValue, convertDateAdd},
+		"DATEDIFF":          {2, 4, numberValue, convertDateDiff},
+	}
+}
+
+// timeZoneLocation returns the location of a time zone given as an Olson name or an offset from
+// UTC, which is UTC when the name is empty
+func timeZoneLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if match := timeZoneOffset.FindStringSubmatch(name); match != nil {
+		hours, _ := strconv.Atoi(match[2])
+		minutes, _ := strconv.Atoi(match[3])
+		offset := hours*3600 + minutes*60
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(name, offset), nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone: %s", name)
+	}
+	return location, nil
+}
+
+// timeZone returns the default time zone of a scope, which is empty for UTC
+func (s *scope) timeZone() string {
+	if s == nil {
+		return ""
+	}
+	return s.options.TimeZone
+}
+
+// parseDateLiteral parses a DATE or TIMESTAMP literal, reading it in the time zone of the scope unless it names one
+func parseDateLiteral(literal *sql.Literal, s *scope) (interface{}, error) {
+	location, err := timeZoneLocation(s.timeZone())
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSpace(literal.Value)
+	for _, layout := range dateLayouts {
+		var t time.Time
+		var err error
+		if strings.Contains(layout, "Z07") {
+			t, err = time.Parse(layout, text)
+		} else {
+			t, err = time.ParseInLocation(layout, text, location)
+		}
+		if err == nil {
+			if literal.Kind == sql.DateLiteral && layout != "2006-01-02" {
+				break
+			}
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s literal: %s", strings.ToUpper(string(literal.Kind)), literal)
+}
+
+// isInterval checks if an expression is an INTERVAL
+func isInterval(expr sql.Expr) bool {
+	_, ok := expr.(*sql.IntervalExpr)
+	return ok
+}
+
+// intervalPart is an amount of a single unit of an interval
+type intervalPart struct {
+	unit   string
+	amount interface{}
+}
+
+// dateUnit returns the aggregation unit named by a SQL unit, in the singular or the plural
+func dateUnit(name string) (string, bool) {
+	name = strings.ToUpper(name)
+	if unit, ok := dateUnits[name]; ok {
+		return unit, true
+	}
+	unit, ok := dateUnits[strings.TrimSuffix(name, "S")]
+	return unit, ok
+}
+
+// intervalParts splits an interval into the amounts of each of its units, which must be whole numbers
+func intervalParts(interval *sql.IntervalExpr, s *scope) ([]intervalPart, error) {
+	literal, isLiteral := interval.Value.(*sql.Literal)
+	if interval.Unit == "" {
+		if !isLiteral || literal.Kind != sql.StringLiteral {
+			return nil, fmt.Errorf("invalid interval: %s", interval)
+		}
+		fields := strings.Fields(literal.Value)
+		if len(fields) == 0 || len(fields)%2 != 0 {
+			return nil, fmt.Errorf("invalid interval: %s", interval)
+		}
+		var parts []intervalPart
+		for i := 0; i < len(fields); i += 2 {
+			amount, err := strconv.ParseInt(fields[i], 10, 64)
+			unit, ok := dateUnit(fields[i+1])
+			if err != nil || !ok {
+				return nil, fmt.Errorf("invalid interval: %s", interval)
+			}
+			parts = append(parts, intervalPart{unit: unit, amount: amount})
+		}
+		return parts, nil
+	}
+
+	unit, ok := dateUnit(interval.Unit)
+	if !ok {
+		return nil, fmt.Errorf("unsupported interval unit: %s", interval.Unit)
+	}
+	if isLiteral && (literal.Kind == sql.StringLiteral || literal.Kind == sql.NumberLiteral) {
+		amount, err := strconv.ParseInt(strings.TrimSpace(literal.Value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("interval amount must be a whole number: %s", interval)
+		}
+		return []intervalPart{{unit: unit, amount: amount}}, nil
+	}
+	if unary, ok := interval.Value.(*sql.UnaryExpr); ok && unary.Op == "-" {
+		if literal, ok := unary.Expr.(*sql.Literal); ok && literal.Kind == sql.NumberLiteral {
+			amount, err := strconv.ParseInt(literal.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("interval amount must be a whole number: %s", interval)
+			}
+			return []intervalPart{{unit: unit, amount: -amount}}, nil
+		}
+	}
+	amount, err := convertExpression(interval.Value, s)
+	if err != nil {
+		return nil, err
+	}
+	return []intervalPart{{unit: unit, amount: amount}}, nil
+}
+
+// addInterval adds an interval to a date, or subtracts it, with one $dateAdd or $dateSubtract per unit
+func addInterval(date interface{}, interval *sql.IntervalExpr, subtract bool, s *scope) (interface{}, error) {
+	parts, err := intervalParts(interval, s)
+	if err != nil {
+		return nil, err
+	}
+	op := "$dateAdd"
+	if subtract {
+		op = "$dateSubtract"
+	}
+	for _, part := range parts {
+		arithmetic := mongo.Document{
+			{Key: "startDate", Value: date},
+			{Key: "unit", Value: part.unit},
+			{Key: "amount", Value: part.amount},
+		}
+		date = mongo.Document{{Key: op, Value: withTimeZone(arithmetic, s.timeZone())}}
+	}
+	return date, nil
+}
+
+// convertDateArithmetic converts the addition of an interval to a date or its subtraction from one
+func convertDateArithmetic(expr *sql.BinaryExpr, s *scope) (interface{}, error) {
+	date, interval := expr.Left, expr.Right
+	if expr.Op == "+" && isInterval(date) && !isInterval(interval) {
+		date, interval = interval, date
+	}
+	if (expr.Op != "+" && expr.Op != "-") || isInterval(date) {
+		return nil, fmt.Errorf("INTERVAL can only be added to or subtracted from a date: %s", expr)
+	}
+	start, err := convertExpression(date, s)
+	if err != nil {
+		return nil, err
+	}
+	return addInterval(start, interval.(*sql.IntervalExpr), expr.Op == "-", s)
+}
+
+// withTimeZone adds a timezone field to the arguments of a date operator unless the time zone is UTC
+func withTimeZone(args mongo.Document, timeZone interface{}) mongo.Document {
+	if timeZone == nil || timeZone == "" {
+		return args
+	}
+	return append(args, mongo.Element{Key: "timezone", Value: timeZone})
+}
+
+// timeZoneArg returns the time zone a date function applies, which is its argument at the given
+// position if it has one and the default time zone of the scope otherwise
+func timeZoneArg(call *sql.FuncCall, position int, s *scope) (interface{}, error) {
+	if len(call.Args) <= position {
+		return s.timeZone(), nil
+	}
+	arg := call.Args[position]
+	if literal, ok := arg.(*sql.Literal); ok && literal.Kind == sql.StringLiteral {
+		if _, err := timeZoneLocation(literal.Value); err != nil {
+			return nil, err
+		}
+		return literal.Value, nil
+	}
+	return convertExpression(arg, s)
+}
+
+// unitArg returns the upper case name held by the string literal argument of a date function
+func unitArg(call *sql.FuncCall, position int) (string, error) {
+	literal, ok := call.Args[position].(*sql.Literal)
+	if !ok || literal.Kind != sql.StringLiteral {
+		return "", fmt.Errorf("%s expects a unit as argument %d but got %s", strings.ToUpper(call.Name), position+1, call.Args[position])
+	}
+	return strings.ToUpper(literal.Value), nil
+}
+
+// convertNow converts NOW and CURRENT_TIMESTAMP to the time the command runs at
+func convertNow(call *sql.FuncCall, s *scope) (interface{}, error) {
+	return "$$NOW", nil
+}
+
+// convertCurrentDate converts CURRENT_DATE to the start of the current day in the default time zone
+func convertCurrentDate(call *sql.FuncCall, s *scope) (interface{}, error) {
+	trunc := mongo.Document{{Key: "date", Value: "$$NOW"}, {Key: "unit", Value: "day"}}
+	return mongo.Document{{Key: "$dateTrunc", Value: withTimeZone(trunc, s.timeZone())}}, nil
+}
+
+// convertDateTrunc converts DATE_TRUNC, whose weeks start on Monday
+func convertDateTrunc(call *sql.FuncCall, s *scope) (interface{}, error) {
+	name, err := unitArg(call, 0)
+	if err != nil {
+		return nil, err
+	}
+	unit, ok := dateUnit(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported DATE_TRUNC unit: %s", call.Args[0])
+	}
+	date, err := convertExpression(call.Args[1], s)
+	if err != nil {
+		return nil, err
+	}
+	timeZone, err := timeZoneArg(call, 2, s)
+	if err != nil {
+		return nil, err
+	}
+	trunc := mongo.Document{{Key: "date", Value: date}, {Key: "unit", Value: unit}}
+	if unit == "week" {
+		trunc = append(trunc, mongo.Element{Key: "startOfWeek", Value: "monday"})
+	}
+	return mongo.Document{{Key: "$dateTrunc", Value: withTimeZone(trunc, timeZone)}}, nil
+}
+
+// convertExtract converts EXTRACT and DATE_PART, where the day of the week counts from 0 on Sunday
+// and the epoch is the number of seconds since 1970-01-01 UTC
+func convertExtract(call *sql.FuncCall, s *scope) (interface{}, error) {
+	field, err := unitArg(call, 0)
+	if err != nil {
+		return nil, err
+	}
+	date, err := convertExpression(call.Args[1], s)
+	if err != nil {
+		return nil, err
+	}
+	if field == "EPOCH" {
+		return mongo.Document{{Key: "$divide", Value: mongo.Array{mongo.Document{{Key: "$toLong", Value: date}}, int64(1000)}}}, nil
+	}
+	timeZone, err := timeZoneArg(call, 2, s)
+	if err != nil {
+		return nil, err
+	}
+	var operand interface{} = date
+	if timeZone != "" {
+		operand = withTimeZone(mongo.Document{{Key: "date", Value: date}}, timeZone)
+	}
+	switch field {
+	case "DOW":
+		return mongo.Document{{Key: "$subtract", Value: mongo.Array{mongo.Document{{Key: "$dayOfWeek", Value: operand}}, int64(1)}}}, nil
+	case "QUARTER":
+		month := mongo.Document{{Key: "$month", Value: operand}}
+		return mongo.Document{{Key: "$ceil", Value: mongo.Document{{Key: "$divide", Value: mongo.Array{month, int64(3)}}}}}, nil
+	}
+	op, ok := dateParts[field]
+	if !ok {
+		return nil, fmt.Errorf("unsupported date part: %s", call.Args[0])
+	}
+	return mongo.Document{{Key: op, Value: operand}}, nil
+}
+
+// convertDateAdd converts DATE_ADD and DATE_SUB, which add an interval to a date or subtract it
+func convertDateAdd(call *sql.FuncCall, s *scope) (interface{}, error) {
+	interval, ok := call.Args[1].(*sql.IntervalExpr)
+	if !ok {
+		return nil, fmt.Errorf("%s expects an INTERVAL as argument 2 but got %s", strings.ToUpper(call.Name), call.Args[1])
+	}
+	date, err := convertExpression(call.Args[0], s)
+	if err != nil {
+		return nil, err
+	}
+	return addInterval(date, interval, strings.EqualFold(call.Name, "DATE_SUB"), s)
+}
+
+// convertDateDiff converts DATEDIFF(end, start), the number of days between two dates, and
+// DATEDIFF(unit, start, end), the number of unit boundaries crossed between them
+func convertDateDiff(call *sql.FuncCall, s *scope) (interface{}, error) {
+	unit, args, timeZonePosition := "day", []sql.Expr{call.Args[1], call.Args[0]}, 2
+	if len(call.Args) > 2 {
+		name, err := unitArg(call, 0)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if unit, ok = dateUnit(name); !ok {
+			return nil, fmt.Errorf("unsupported DATEDIFF unit: %s", call.Args[0])
+		}
+		args, timeZonePosition = call.Args[1:3], 3
+	} else if isUnitLiteral(call.Args[0]) {
+		return nil, fmt.Errorf("DATEDIFF with a unit expects a start and an end date: %s", call)
+	}
+	dates, err := convertExpressions(args, s)
+	if err != nil {
+		return nil, err
+	}
+	timeZone, err := timeZoneArg(call, timeZonePosition, s)
+	if err != nil {
+		return nil, err
+	}
+	diff := mongo.Document{
+		{Key: "startDate", Value: dates[0]},
+		{Key: "endDate", Value: dates[1]},
+		{Key: "unit", Value: unit},
+	}
+	if unit == "week" {
+		diff = append(diff, mongo.Element{Key: "startOfWeek", Value: "monday"})
+	}
+	return mongo.Document{{Key: "$dateDiff", Value: withTimeZone(diff, timeZone)}}, nil
+}
+
+// isUnitLiteral checks if an expression is a string literal that names a date unit
+func isUnitLiteral(expr sql.Expr) bool {
+	literal, ok := expr.(*sql.Literal)
+	if !ok || literal.Kind != sql.StringLiteral {
+		return false
+	}
+	_, ok = dateUnit(literal.Value)
+	return ok
+}
+
+// convertDateFunction converts a call to a date function, checking its number of arguments
+func convertDateFunction(name string, function dateFunction, call *sql.FuncCall, s *scope) (interface{}, error) {
+	if call.Distinct {
+		return nil, fmt.Errorf("DISTINCT is only allowed in aggregate functions: %s", call)
+	}
+	check := scalarFunction{minArgs: function.minArgs, maxArgs: function.maxArgs, args: []valueKind{anyValue}}
+	if err := check.checkArgs(name, call.Args); err != nil {
+		return nil, err
+	}
+	return function.convert(call, s)
+}
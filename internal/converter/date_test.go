@@ -0,0 +1,196 @@
+package converter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDateLiteral(t *testing.T) {
+	tests := []struct {
+		name     string
+		literal  *sql.Literal
+		timeZone string
+		want     interface{}
+		wantErr  bool
+	}{
+		{
+			name:    "date",
+			literal: &sql.Literal{Kind: sql.DateLiteral, Value: "2024-03-01"},
+			want:    time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "date in the default time zone",
+			literal:  &sql.Literal{Kind: sql.DateLiteral, Value: "2024-03-01"},
+			timeZone: "-05:00",
+			want:     time.Date(2024, 3, 1, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "timestamp with a time zone",
+			literal:  &sql.Literal{Kind: sql.TimestampLiteral, Value: "2024-03-01T10:00:00+02:00"},
+			timeZone: "America/New_York",
+			want:     time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "timestamp with fractional seconds",
+			literal: &sql.Literal{Kind: sql.TimestampLiteral, Value: "2024-03-01 10:00:00.250"},
+			want:    time.Date(2024, 3, 1, 10, 0, 0, 250000000, time.UTC),
+		},
+		{
+			name:    "date with a time",
+			literal: &sql.Literal{Kind: sql.DateLiteral, Value: "2024-03-01 10:00"},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "invalid timestamp",
+			literal: &sql.Literal{Kind: sql.TimestampLiteral, Value: "yesterday"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScope()
+			s.options.TimeZone = tt.timeZone
+			got, err := parseDateLiteral(tt.literal, s)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Nil(t, got)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, tt.want.(time.Time).Equal(got.(time.Time)), "got %v", got)
+		})
+	}
+}
+
+func TestConvertDateExpression(t *testing.T) {
+	created := &sql.ColumnRef{Name: "created"}
+	str := func(value string) sql.Expr { return &sql.Literal{Kind: sql.StringLiteral, Value: value} }
+	number := func(value string) sql.Expr { return &sql.Literal{Kind: sql.NumberLiteral, Value: value} }
+	tests := []struct {
+		name     string
+		expr     sql.Expr
+		timeZone string
+		want     interface{}
+		wantErr  bool
+	}{
+		{
+			name: "interval with several units",
+			expr: &sql.BinaryExpr{Op: "+", Left: created, Right: &sql.IntervalExpr{Value: str("1 day 2 hours")}},
+			want: mongo.Document{{Key: "$dateAdd", Value: mongo.Document{
+				{Key: "startDate", Value: mongo.Document{{Key: "$dateAdd", Value: mongo.Document{
+					{Key: "startDate", Value: "$created"},
+					{Key: "unit", Value: "day"},
+					{Key: "amount", Value: int64(1)},
+				}}}},
+				{Key: "unit", Value: "hour"},
+				{Key: "amount", Value: int64(2)},
+			}}},
+		},
+		{
+			name:     "interval in the default time zone",
+			expr:     &sql.BinaryExpr{Op: "+", Left: &sql.IntervalExpr{Value: &sql.ColumnRef{Name: "n"}, Unit: "MONTHS"}, Right: created},
+			timeZone: "Europe/Paris",
+			want: mongo.Document{{Key: "$dateAdd", Value: mongo.Document{
+				{Key: "startDate", Value: "$created"},
+				{Key: "unit", Value: "month"},
+				{Key: "amount", Value: "$n"},
+				{Key: "timezone", Value: "Europe/Paris"},
+			}}},
+		},
+		{
+			name: "date sub",
+			expr: &sql.FuncCall{Name: "DATE_SUB", Args: []sql.Expr{created, &sql.IntervalExpr{Value: number("30"), Unit: "MINUTE"}}},
+			want: mongo.Document{{Key: "$dateSubtract", Value: mongo.Document{
+				{Key: "startDate", Value: "$created"},
+				{Key: "unit", Value: "minute"},
+				{Key: "amount", Value: int64(30)},
+			}}},
+		},
+		{
+			name: "current date",
+			expr: &sql.FuncCall{Name: "CURRENT_DATE"},
+			want: mongo.Document{{Key: "$dateTrunc", Value: mongo.Document{{Key: "date", Value: "$$NOW"}, {Key: "unit", Value: "day"}}}},
+		},
+		{
+			name: "truncate to the week",
+			expr: &sql.FuncCall{Name: "DATE_TRUNC", Args: []sql.Expr{str("week"), created, str("Asia/Tokyo")}},
+			want: mongo.Document{{Key: "$dateTrunc", Value: mongo.Document{
+				{Key: "date", Value: "$created"},
+				{Key: "unit", Value: "week"},
+				{Key: "startOfWeek", Value: "monday"},
+				{Key: "timezone", Value: "Asia/Tokyo"},
+			}}},
+		},
+		{
+			name: "extract the quarter",
+			expr: &sql.FuncCall{Name: "EXTRACT", Args: []sql.Expr{str("QUARTER"), created}},
+			want: mongo.Document{{Key: "$ceil", Value: mongo.Document{{Key: "$divide", Value: mongo.Array{
+				mongo.Document{{Key: "$month", Value: "$created"}},
+				int64(3),
+			}}}}},
+		},
+		{
+			name: "extract the epoch",
+			expr: &sql.FuncCall{Name: "DATE_PART", Args: []sql.Expr{str("epoch"), created}},
+			want: mongo.Document{{Key: "$divide", Value: mongo.Array{mongo.Document{{Key: "$toLong", Value: "$created"}}, int64(1000)}}},
+		},
+		{
+			name: "datediff with a unit",
+			expr: &sql.FuncCall{Name: "DATEDIFF", Args: []sql.Expr{str("hour"), created, &sql.FuncCall{Name: "NOW"}}},
+			want: mongo.Document{{Key: "$dateDiff", Value: mongo.Document{
+				{Key: "startDate", Value: "$created"},
+				{Key: "endDate", Value: "$$NOW"},
+				{Key: "unit", Value: "hour"},
+			}}},
+		},
+		{
+			name:    "interval minus a date",
+			expr:    &sql.BinaryExpr{Op: "-", Left: &sql.IntervalExpr{Value: str("1 day")}, Right: created},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "fractional interval",
+			expr:    &sql.BinaryExpr{Op: "+", Left: created, Right: &sql.IntervalExpr{Value: str("1.5 days")}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "unknown time zone argument",
+			expr:    &sql.FuncCall{Name: "DATE_TRUNC", Args: []sql.Expr{str("day"), created, str("Nowhere")}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "unknown date part",
+			expr:    &sql.FuncCall{Name: "EXTRACT", Args: []sql.Expr{str("FORTNIGHT"), created}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "interval alone",
+			expr:    &sql.IntervalExpr{Value: str("1 day")},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScope()
+			s.options.TimeZone = tt.timeZone
+			got, err := convertExpression(tt.expr, s)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
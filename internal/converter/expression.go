@@ -0,0 +1,321 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// expressionOperators maps SQL binary operators to aggregation expression operators
+var expressionOperators = map[string]string{
+	"+":   "$add",
+	"-":   "$subtract",
+	"*":   "$multiply",
+	"/":   "$divide",
+	"%":   "$mod",
+	"=":   "$eq",
+	"<>":  "$ne",
+	"!=":  "$ne",
+	"<":   "$lt",
+	"<=":  "$lte",
+	">":   "$gt",
+	">=":  "$gte",
+	"AND": "$and",
+	"OR":  "$or",
+	"||":  "$concat",
+}
+
+// scope resolves SQL expressions to the document fields that hold their values
+type scope struct {
+	// fields maps the SQL text of an already computed expression, such as an
+	// aggregate, to the field that holds its value
+	fields map[string]string
+	// grouped scopes only resolve the expressions listed in fields, since the
+	// remaining columns no longer exist after $group
+	grouped bool
+	// tables maps table names and aliases to the path their columns are stored
+	// under, which is empty for the collection being queried
+	tables map[string]string
+	// variables maps the SQL text of columns from outside a $lookup pipeline to
+	// the variable that holds their value
+	variables map[string]string
+	// options configures the conversion, such as the default time zone of dates
+	options Options
+}
+
+// newScope returns an empty scope
+func newScope() *scope {
+	return &scope{fields: map[string]string{}, tables: map[string]string{}, variables: map[string]string{}}
+}
+
+// fieldPath returns the document field an expression reads from, if it is a column or a computed expression
+func (s *scope) fieldPath(expr sql.Expr) (string, bool) {
+	if s != nil {
+		if field, ok := s.fields[expr.String()]; ok {
+			return field, true
+		}
+		if _, ok := s.variables[expr.String()]; ok || s.grouped {
+			return "", false
+		}
+	}
+	column, ok := expr.(*sql.ColumnRef)
+	if !ok {
+		return s.jsonFieldPath(expr, false)
+	}
+	if s != nil && column.Table != "" {
+		if prefix, ok := s.tables[column.Table]; ok {
+			return joinPath(prefix, column.Name), true
+		}
+	}
+	return columnPath(column), true
+}
+
+// joinPath joins a path prefix and a field name
+func joinPath(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// columnPath returns the dotted document path of a column reference
+func columnPath(column *sql.ColumnRef) string {
+	if column.Table != "" {
+		return column.Table + "." + column.Name
+	}
+	return column.Name
+}
+
+// fieldName turns a result column name into a name that is safe to use as a top level field
+func fieldName(name string) string {
+	name = strings.ReplaceAll(name, ".", "_")
+	if strings.HasPrefix(name, "$") {
+		name = "_" + name[1:]
+	}
+	return name
+}
+
+// literalValue converts a SQL literal to its MongoDB value, reading date literals that do not
+// name a time zone in the time zone of the scope
+func literalValue(literal *sql.Literal, s *scope) (interface{}, error) {
+	switch literal.Kind {
+	case sql.StringLiteral:
+		return literal.Value, nil
+	case sql.BooleanLiteral:
+		return literal.Value == "true", nil
+	case sql.NullLiteral:
+		return nil, nil
+	case sql.NumberLiteral:
+		if i, err := strconv.ParseInt(literal.Value, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(literal.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", literal.Value)
+		}
+		return f, nil
+	case sql.DateLiteral, sql.TimestampLiteral:
+		return parseDateLiteral(literal, s)
+	default:
+		return nil, fmt.Errorf("unknown literal: %s", literal)
+	}
+}
+
+// convertExpression converts a SQL expression to a MongoDB aggregation expression
+func convertExpression(expr sql.Expr, s *scope) (interface{}, error) {
+	if s != nil {
+		if variable, ok := s.variables[expr.String()]; ok {
+			return "$$" + variable, nil
+		}
+	}
+	if field, ok := s.fieldPath(expr); ok {
+		return "$" + field, nil
+	}
+	switch e := expr.(type) {
+	case *sql.Literal:
+		value, err := literalValue(e, s)
+		if err != nil {
+			return nil, err
+		}
+		if str, ok := value.(string); ok && strings.HasPrefix(str, "$") {
+			return mongo.Document{{Key: "$literal", Value: str}}, nil
+		}
+		return value, nil
+	case *sql.BinaryExpr:
+		return convertBinaryExpression(e, s)
+	case *sql.UnaryExpr:
+		operand, err := convertExpression(e.Expr, s)
+		if err != nil {
+			return nil, err
+		}
+		if e.Op == "NOT" {
+			return mongo.Document{{Key: "$not", Value: mongo.Array{operand}}}, nil
+		}
+		return mongo.Document{{Key: "$multiply", Value: mongo.Array{int64(-1), operand}}}, nil
+	case *sql.IsNullExpr:
+		operand, err := convertExpression(e.Expr, s)
+		if err != nil {
+			return nil, err
+		}
+		op := "$eq"
+		if e.Not {
+			op = "$ne"
+		}
+		return mongo.Document{{Key: op, Value: mongo.Array{mongo.Document{{Key: "$ifNull", Value: mongo.Array{operand, nil}}}, nil}}}, nil
+	case *sql.InExpr:
+		operand, err := convertExpression(e.Expr, s)
+		if err != nil {
+			return nil, err
+		}
+		values, err := convertExpressions(e.Values, s)
+		if err != nil {
+			return nil, err
+		}
+		in := mongo.Document{{Key: "$in", Value: mongo.Array{operand, values}}}
+		if e.Not {
+			return mongo.Document{{Key: "$not", Value: mongo.Array{in}}}, nil
+		}
+		return in, nil
+	case *sql.BetweenExpr:
+		operands, err := convertExpressions([]sql.Expr{e.Expr, e.Lower, e.Upper}, s)
+		if err != nil {
+			return nil, err
+		}
+		if e.Not {
+			return mongo.Document{{Key: "$or", Value: mongo.Array{
+				mongo.Document{{Key: "$lt", Value: mongo.Array{operands[0], operands[1]}}},
+				mongo.Document{{Key: "$gt", Value: mongo.Array{operands[0], operands[2]}}},
+			}}}, nil
+		}
+		return mongo.Document{{Key: "$and", Value: mongo.Array{
+			mongo.Document{{Key: "$gte", Value: mongo.Array{operands[0], operands[1]}}},
+			mongo.Document{{Key: "$lte", Value: mongo.Array{operands[0], operands[2]}}},
+		}}}, nil
+	case *sql.CaseExpr:
+		return convertCase(e, s)
+	case *sql.CastExpr:
+		return convertCast(e, s)
+	case *sql.IntervalExpr:
+		return nil, fmt.Errorf("%s can only be added to or subtracted from a date", e)
+	case *sql.ColumnRef:
+		return nil, fmt.Errorf("column %s must appear in the GROUP BY clause or be used in an aggregate function", e)
+	case *sql.FuncCall:
+		if sql.IsWindow(e) {
+			return nil, fmt.Errorf("window function %s is only allowed in the SELECT list", e)
+		}
+		if isGrouping(e) {
+			return convertGrouping(e, s)
+		}
+		if sql.IsAggregate(e) {
+			return nil, fmt.Errorf("aggregate function %s is not allowed here", e)
+		}
+		return convertScalarFunction(e, s)
+	case *sql.StarExpr:
+		return nil, fmt.Errorf("%s is not allowed in an expression", e)
+	default:
+		return nil, fmt.Errorf("unsupported expression: %s", expr)
+	}
+}
+
+// convertExpressions converts a list of SQL expressions to aggregation expressions
+func convertExpressions(exprs []sql.Expr, s *scope) (mongo.Array, error) {
+	values := make(mongo.Array, len(exprs))
+	for i, expr := range exprs {
+		value, err := convertExpression(expr, s)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// convertBinaryExpression converts an infix SQL operation to an aggregation expression
+func convertBinaryExpression(expr *sql.BinaryExpr, s *scope) (interface{}, error) {
+	if expr.Op == "LIKE" || expr.Op == "NOT LIKE" {
+		input, err := convertExpression(expr.Left, s)
+		if err != nil {
+			return nil, err
+		}
+		pattern, ok := expr.Right.(*sql.Literal)
+		if !ok || pattern.Kind != sql.StringLiteral {
+			return nil, fmt.Errorf("LIKE pattern must be a string: %s", expr.Right)
+		}
+		match := mongo.Document{{Key: "$regexMatch", Value: mongo.Document{
+			{Key: "input", Value: input},
+			{Key: "regex", Value: likeToRegex(pattern.Value)},
+		}}}
+		if expr.Op == "NOT LIKE" {
+			return mongo.Document{{Key: "$not", Value: mongo.Array{match}}}, nil
+		}
+		return match, nil
+	}
+
+	if _, ok := regexOperators[expr.Op]; ok {
+		return convertRegexMatch(expr, s)
+	}
+	if isInterval(expr.Left) || isInterval(expr.Right) {
+		return convertDateArithmetic(expr, s)
+	}
+	if expr.Op == "->" || expr.Op == "->>" {
+		return convertJSONPath(expr, s)
+	}
+	op, ok := expressionOperators[expr.Op]
+	if !ok {
+		return nil, fmt.Errorf("unsupported operator: %s", expr.Op)
+	}
+	var operands []sql.Expr
+	if expr.Op == "AND" || expr.Op == "OR" || expr.Op == "||" {
+		operands = flatten(expr, expr.Op)
+	} else {
+		operands = []sql.Expr{expr.Left, expr.Right}
+	}
+	values, err := convertExpressions(operands, s)
+	if err != nil {
+		return nil, err
+	}
+	return mongo.Document{{Key: op, Value: values}}, nil
+}
+
+// convertCase converts a CASE expression to $cond when it has a single branch and to $switch otherwise,
+// comparing the operand of a simple CASE to each WHEN value with $eq
+func convertCase(expr *sql.CaseExpr, s *scope) (interface{}, error) {
+	var otherwise interface{}
+	if expr.Else != nil {
+		var err error
+		if otherwise, err = convertExpression(expr.Else, s); err != nil {
+			return nil, err
+		}
+	}
+	var branches mongo.Array
+	for _, when := range expr.Whens {
+		cond := when.Cond
+		if expr.Operand != nil {
+			cond = &sql.BinaryExpr{Op: "=", Left: expr.Operand, Right: when.Cond}
+		}
+		values, err := convertExpressions([]sql.Expr{cond, when.Result}, s)
+		if err != nil {
+			return nil, err
+		}
+		if len(expr.Whens) == 1 {
+			return mongo.Document{{Key: "$cond", Value: mongo.Array{values[0], values[1], otherwise}}}, nil
+		}
+		branches = append(branches, mongo.Document{{Key: "case", Value: values[0]}, {Key: "then", Value: values[1]}})
+	}
+	return mongo.Document{{Key: "$switch", Value: mongo.Document{
+		{Key: "branches", Value: branches},
+		{Key: "default", Value: otherwise},
+	}}}, nil
+}
+
+// flatten returns the operands of a chain of the same associative operator
+func flatten(expr sql.Expr, op string) []sql.Expr {
+	binary, ok := expr.(*sql.BinaryExpr)
+	if !ok || binary.Op != op {
+		return []sql.Expr{expr}
+	}
+	return append(flatten(binary.Left, op), flatten(binary.Right, op)...)
+}
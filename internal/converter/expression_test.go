@@ -0,0 +1,86 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertExpression(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    sql.Expr
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "column",
+			expr: &sql.ColumnRef{Name: "age"},
+			want: "$age",
+		},
+		{
+			name: "dollar string",
+			expr: &sql.Literal{Kind: sql.StringLiteral, Value: "$5"},
+			want: mongo.Document{{Key: "$literal", Value: "$5"}},
+		},
+		{
+			name: "arithmetic",
+			expr: &sql.BinaryExpr{Op: "*", Left: &sql.ColumnRef{Name: "price"}, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "1.2"}},
+			want: mongo.Document{{Key: "$multiply", Value: mongo.Array{"$price", 1.2}}},
+		},
+		{
+			name: "flattened and",
+			expr: &sql.BinaryExpr{
+				Op:    "AND",
+				Left:  &sql.BinaryExpr{Op: "AND", Left: &sql.ColumnRef{Name: "a"}, Right: &sql.ColumnRef{Name: "b"}},
+				Right: &sql.ColumnRef{Name: "c"},
+			},
+			want: mongo.Document{{Key: "$and", Value: mongo.Array{"$a", "$b", "$c"}}},
+		},
+		{
+			name: "single branch case",
+			expr: &sql.CaseExpr{Whens: []sql.WhenClause{{
+				Cond:   &sql.BinaryExpr{Op: ">=", Left: &sql.ColumnRef{Name: "age"}, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "18"}},
+				Result: &sql.Literal{Kind: sql.StringLiteral, Value: "adult"},
+			}}},
+			want: mongo.Document{{Key: "$cond", Value: mongo.Array{mongo.Document{{Key: "$gte", Value: mongo.Array{"$age", int64(18)}}}, "adult", nil}}},
+		},
+		{
+			name: "simple case",
+			expr: &sql.CaseExpr{
+				Operand: &sql.ColumnRef{Name: "status"},
+				Whens: []sql.WhenClause{
+					{Cond: &sql.Literal{Kind: sql.StringLiteral, Value: "A"}, Result: &sql.Literal{Kind: sql.StringLiteral, Value: "active"}},
+					{Cond: &sql.Literal{Kind: sql.StringLiteral, Value: "I"}, Result: &sql.Literal{Kind: sql.StringLiteral, Value: "inactive"}},
+				},
+				Else: &sql.ColumnRef{Name: "status"},
+			},
+			want: mongo.Document{{Key: "$switch", Value: mongo.Document{
+				{Key: "branches", Value: mongo.Array{
+					mongo.Document{{Key: "case", Value: mongo.Document{{Key: "$eq", Value: mongo.Array{"$status", "A"}}}}, {Key: "then", Value: "active"}},
+					mongo.Document{{Key: "case", Value: mongo.Document{{Key: "$eq", Value: mongo.Array{"$status", "I"}}}}, {Key: "then", Value: "inactive"}},
+				}},
+				{Key: "default", Value: "$status"},
+			}}},
+		},
+		{
+			name:    "aggregate",
+			expr:    &sql.FuncCall{Name: "SUM", Args: []sql.Expr{&sql.ColumnRef{Name: "total"}}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertExpression(tt.expr, newScope())
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// queryOperators maps SQL comparison operators to query operators
+var queryOperators = map[string]string{
+	"<>": "$ne",
+	"!=": "$ne",
+	"<":  "$lt",
+	"<=": "$lte",
+	">":  "$gt",
+	">=": "$gte",
+}
+
+// flippedOperators maps comparison operators to their equivalent with swapped operands
+var flippedOperators = map[string]string{
+	"=":  "=",
+	"<>": "<>",
+	"!=": "!=",
+	"<":  ">",
+	"<=": ">=",
+	">":  "<",
+	">=": "<=",
+}
+
+// convertFilter converts a SQL condition to a MongoDB query filter, falling back to $expr
+// for conditions the query language can not express
+func convertFilter(expr sql.Expr, s *scope) (mongo.Document, error) {
+	switch e := expr.(type) {
+	case *sql.BinaryExpr:
+		switch e.Op {
+		case "AND":
+			var filters []mongo.Document
+			for _, operand := range flatten(e, "AND") {
+				filter, err := convertFilter(operand, s)
+				if err != nil {
+					return nil, err
+				}
+				filters = append(filters, filter)
+			}
+			return mergeFilters(filters), nil
+		case "OR":
+			var filters mongo.Array
+			for _, operand := range flatten(e, "OR") {
+				filter, err := convertFilter(operand, s)
+				if err != nil {
+					return nil, err
+				}
+				filters = append(filters, filter)
+			}
+			return mongo.Document{{Key: "$or", Value: filters}}, nil
+		case "LIKE", "NOT LIKE":
+			field, fieldOK := s.queryPath(e.Left)
+			pattern, patternOK := e.Right.(*sql.Literal)
+			if fieldOK && patternOK && pattern.Kind == sql.StringLiteral {
+				regex := mongo.Document{{Key: "$regex", Value: likeToRegex(pattern.Value)}}
+				if e.Op == "NOT LIKE" {
+					return mongo.Document{{Key: field, Value: mongo.Document{{Key: "$not", Value: regex}}}}, nil
+				}
+				return mongo.Document{{Key: field, Value: regex}}, nil
+			}
+		case "~", "~*", "!~", "!~*", "REGEXP", "NOT REGEXP":
+			if filter, ok, err := convertRegexFilter(e, s); ok || err != nil {
+				return filter, err
+			}
+		default:
+			if filter, ok, err := convertComparison(e, s); ok || err != nil {
+				return filter, err
+			}
+		}
+	case *sql.FuncCall:
+		if filter, ok, err := convertRegexFilter(e, s); ok || err != nil {
+			return filter, err
+		}
+	case *sql.UnaryExpr:
+		if e.Op == "NOT" {
+			filter, err := convertFilter(e.Expr, s)
+			if err != nil {
+				return nil, err
+			}
+			return mongo.Document{{Key: "$nor", Value: mongo.Array{filter}}}, nil
+		}
+	case *sql.IsNullExpr:
+		if field, ok := s.queryPath(e.Expr); ok {
+			if e.Not {
+				return mongo.Document{{Key: field, Value: mongo.Document{{Key: "$ne", Value: nil}}}}, nil
+			}
+			return mongo.Document{{Key: field, Value: nil}}, nil
+		}
+	case *sql.InExpr:
+		field, ok := s.queryPath(e.Expr)
+		values, constant, err := constantValues(e.Values, s)
+		if err != nil {
+			return nil, err
+		}
+		if ok && constant {
+			op := "$in"
+			if e.Not {
+				op = "$nin"
+			}
+			return mongo.Document{{Key: field, Value: mongo.Document{{Key: op, Value: values}}}}, nil
+		}
+	case *sql.BetweenExpr:
+		field, ok := s.queryPath(e.Expr)
+		bounds, constant, err := constantValues([]sql.Expr{e.Lower, e.Upper}, s)
+		if err != nil {
+			return nil, err
+		}
+		if ok && constant {
+			if e.Not {
+				return mongo.Document{{Key: "$or", Value: mongo.Array{
+					mongo.Document{{Key: field, Value: mongo.Document{{Key: "$lt", Value: bounds[0]}}}},
+					mongo.Document{{Key: field, Value: mongo.Document{{Key: "$gt", Value: bounds[1]}}}},
+				}}}, nil
+			}
+			return mongo.Document{{Key: field, Value: mongo.Document{{Key: "$gte", Value: bounds[0]}, {Key: "$lte", Value: bounds[1]}}}}, nil
+		}
+	case *sql.ColumnRef:
+		if field, ok := s.queryPath(e); ok {
+			return mongo.Document{{Key: field, Value: true}}, nil
+		}
+	}
+
+	value, err := convertExpression(expr, s)
+	if err != nil {
+		return nil, err
+	}
+	return mongo.Document{{Key: "$expr", Value: value}}, nil
+}
+
+// convertComparison converts a comparison between a field and a constant to a query filter,
+// reporting false if the comparison has some other shape
+func convertComparison(expr *sql.BinaryExpr, s *scope) (mongo.Document, bool, error) {
+	if _, ok := flippedOperators[expr.Op]; !ok {
+		return nil, false, nil
+	}
+	op, left, right := expr.Op, expr.Left, expr.Right
+	if _, ok := s.queryPath(left); !ok {
+		op, left, right = flippedOperators[op], right, left
+	}
+	field, ok := s.queryPath(left)
+	if !ok {
+		return nil, false, nil
+	}
+	value, constant, err := constantValue(right, s)
+	if err != nil || !constant {
+		return nil, false, err
+	}
+	if op == "=" {
+		return mongo.Document{{Key: field, Value: value}}, true, nil
+	}
+	return mongo.Document{{Key: field, Value: mongo.Document{{Key: queryOperators[op], Value: value}}}}, true, nil
+}
+
+// constantValue returns the MongoDB value of an expression that does not depend on the document,
+// reading date literals in the time zone of the scope
+func constantValue(expr sql.Expr, s *scope) (interface{}, bool, error) {
+	if cast, ok := expr.(*sql.CastExpr); ok {
+		return constantCast(cast, s)
+	}
+	literal, ok := expr.(*sql.Literal)
+	if !ok {
+		return nil, false, nil
+	}
+	value, err := literalValue(literal, s)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// constantValues returns the MongoDB values of a list of expressions if they are all constant
+func constantValues(exprs []sql.Expr, s *scope) (mongo.Array, bool, error) {
+	values := make(mongo.Array, len(exprs))
+	for i, expr := range exprs {
+		value, ok, err := constantValue(expr, s)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		values[i] = value
+	}
+	return values, true, nil
+}
+
+// mergeFilters combines filters that must all match into one document, falling back to $and
+// when the same field is constrained in ways that can not be merged or the same top level
+// operator such as $expr appears twice
+func mergeFilters(filters []mongo.Document) mongo.Document {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	var merged mongo.Document
+	for _, filter := range filters {
+		for _, element := range filter {
+			i := indexOf(merged, element.Key)
+			if i == -1 {
+				merged = append(merged, element)
+				continue
+			}
+			combined, ok := mergeOperators(merged[i].Value, element.Value)
+			if !ok || strings.HasPrefix(element.Key, "$") {
+				and := make(mongo.Array, len(filters))
+				for j, f := range filters {
+					and[j] = f
+				}
+				return mongo.Document{{Key: "$and", Value: and}}
+			}
+			merged[i].Value = combined
+		}
+	}
+	return merged
+}
+
+// mergeOperators combines two operator documents on the same field if their operators do not overlap
+func mergeOperators(a, b interface{}) (mongo.Document, bool) {
+	left, ok := a.(mongo.Document)
+	if !ok || !isOperatorDocument(left) {
+		return nil, false
+	}
+	right, ok := b.(mongo.Document)
+	if !ok || !isOperatorDocument(right) {
+		return nil, false
+	}
+	combined := append(mongo.Document{}, left...)
+	for _, element := range right {
+		if _, exists := combined.Get(element.Key); exists {
+			return nil, false
+		}
+		combined = append(combined, element)
+	}
+	return combined, true
+}
+
+// isOperatorDocument checks if every key of a document is a query operator
+func isOperatorDocument(document mongo.Document) bool {
+	for _, element := range document {
+		if !strings.HasPrefix(element.Key, "$") {
+			return false
+		}
+	}
+	return len(document) > 0
+}
+
+// indexOf returns the position of a key in a document, or -1 if it is missing
+func indexOf(document mongo.Document, key string) int {
+	for i, element := range document {
+		if element.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// likeToRegex converts a LIKE pattern to an anchored regular expression
+func likeToRegex(pattern string) string {
+	var regex strings.Builder
+	regex.WriteString("^")
+	for _, char := range pattern {
+		switch char {
+		case '%':
+			regex.WriteString(".*")
+		case '_':
+			regex.WriteString(".")
+		default:
+			regex.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	regex.WriteString("$")
+	result := strings.TrimSuffix(regex.String(), ".*$")
+	return strings.TrimPrefix(result, "^.*")
+}
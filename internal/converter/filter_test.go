@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertFilter(t *testing.T) {
+	age := &sql.ColumnRef{Name: "age"}
+	tests := []struct {
+		name    string
+		expr    sql.Expr
+		want    mongo.Document
+		wantErr bool
+	}{
+		{
+			name: "equality",
+			expr: &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Name: "active"}, Right: &sql.Literal{Kind: sql.BooleanLiteral, Value: "true"}},
+			want: mongo.Document{{Key: "active", Value: true}},
+		},
+		{
+			name: "flipped comparison",
+			expr: &sql.BinaryExpr{Op: "<", Left: &sql.Literal{Kind: sql.NumberLiteral, Value: "18"}, Right: age},
+			want: mongo.Document{{Key: "age", Value: mongo.Document{{Key: "$gt", Value: int64(18)}}}},
+		},
+		{
+			name: "merged range",
+			expr: &sql.BinaryExpr{
+				Op:    "AND",
+				Left:  &sql.BinaryExpr{Op: ">=", Left: age, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "18"}},
+				Right: &sql.BinaryExpr{Op: "<", Left: age, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "65"}},
+			},
+			want: mongo.Document{{Key: "age", Value: mongo.Document{{Key: "$gte", Value: int64(18)}, {Key: "$lt", Value: int64(65)}}}},
+		},
+		{
+			name: "conflicting equality",
+			expr: &sql.BinaryExpr{
+				Op:    "AND",
+				Left:  &sql.BinaryExpr{Op: "=", Left: age, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "1"}},
+				Right: &sql.BinaryExpr{Op: "=", Left: age, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "2"}},
+			},
+			want: mongo.Document{{Key: "$and", Value: mongo.Array{
+				mongo.Document{{Key: "age", Value: int64(1)}},
+				mongo.Document{{Key: "age", Value: int64(2)}},
+			}}},
+		},
+		{
+			name: "like",
+			expr: &sql.BinaryExpr{Op: "LIKE", Left: &sql.ColumnRef{Name: "name"}, Right: &sql.Literal{Kind: sql.StringLiteral, Value: "J_n%"}},
+			want: mongo.Document{{Key: "name", Value: mongo.Document{{Key: "$regex", Value: "^J.n"}}}},
+		},
+		{
+			name: "case-insensitive regular expression",
+			expr: &sql.BinaryExpr{Op: "~*", Left: &sql.ColumnRef{Name: "name"}, Right: &sql.Literal{Kind: sql.StringLiteral, Value: "^jo"}},
+			want: mongo.Document{{Key: "name", Value: mongo.Document{{Key: "$regex", Value: "^jo"}, {Key: "$options", Value: "i"}}}},
+		},
+		{
+			name: "regexp like",
+			expr: &sql.FuncCall{Name: "REGEXP_LIKE", Args: []sql.Expr{
+				&sql.ColumnRef{Name: "name"},
+				&sql.Literal{Kind: sql.StringLiteral, Value: "^jo$"},
+				&sql.Literal{Kind: sql.StringLiteral, Value: "m"},
+			}},
+			want: mongo.Document{{Key: "name", Value: mongo.Document{{Key: "$regex", Value: "^jo$"}, {Key: "$options", Value: "m"}}}},
+		},
+		{
+			name: "negated regular expression",
+			expr: &sql.BinaryExpr{Op: "NOT REGEXP", Left: &sql.ColumnRef{Name: "name"}, Right: &sql.Literal{Kind: sql.StringLiteral, Value: "x"}},
+			want: mongo.Document{{Key: "name", Value: mongo.Document{{Key: "$not", Value: mongo.Document{{Key: "$regex", Value: "x"}}}}}},
+		},
+		{
+			name:    "regular expression of a column",
+			expr:    &sql.BinaryExpr{Op: "~", Left: &sql.ColumnRef{Name: "name"}, Right: age},
+			wantErr: true,
+		},
+		{
+			name: "not in",
+			expr: &sql.InExpr{Expr: age, Values: []sql.Expr{&sql.Literal{Kind: sql.NumberLiteral, Value: "1"}}, Not: true},
+			want: mongo.Document{{Key: "age", Value: mongo.Document{{Key: "$nin", Value: mongo.Array{int64(1)}}}}},
+		},
+		{
+			name: "column comparison",
+			expr: &sql.BinaryExpr{Op: ">", Left: &sql.ColumnRef{Name: "spent"}, Right: &sql.ColumnRef{Name: "budget"}},
+			want: mongo.Document{{Key: "$expr", Value: mongo.Document{{Key: "$gt", Value: mongo.Array{"$spent", "$budget"}}}}},
+		},
+		{
+			name: "two expressions",
+			expr: &sql.BinaryExpr{
+				Op:    "AND",
+				Left:  &sql.BinaryExpr{Op: ">", Left: &sql.ColumnRef{Name: "spent"}, Right: &sql.ColumnRef{Name: "budget"}},
+				Right: &sql.BinaryExpr{Op: "=", Left: &sql.FuncCall{Name: "LOWER", Args: []sql.Expr{&sql.ColumnRef{Name: "email"}}}, Right: &sql.Literal{Kind: sql.StringLiteral, Value: "a@b.c"}},
+			},
+			want: mongo.Document{{Key: "$and", Value: mongo.Array{
+				mongo.Document{{Key: "$expr", Value: mongo.Document{{Key: "$gt", Value: mongo.Array{"$spent", "$budget"}}}}},
+				mongo.Document{{Key: "$expr", Value: mongo.Document{{Key: "$eq", Value: mongo.Array{mongo.Document{{Key: "$toLower", Value: "$email"}}, "a@b.c"}}}}},
+			}}},
+		},
+		{
+			name:    "aggregate",
+			expr:    &sql.BinaryExpr{Op: ">", Left: &sql.FuncCall{Name: "COUNT", Args: []sql.Expr{&sql.StarExpr{}}}, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "1"}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertFilter(tt.expr, newScope())
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// valueKind is the type of value an expression produces, as far as it can be told from the SQL
+type valueKind string
+
+// These are the kinds of values scalar functions check their arguments against
+const (
+	anyValue     valueKind = ""
+	stringValue  valueKind = "string"
+	numberValue  valueKind = "number"
+	booleanValue valueKind = "boolean"
+)
+
+// scalarFunction describes a SQL scalar function and how it is converted to an aggregation expression
+type scalarFunction struct {
+	minArgs int
+	// maxArgs is -1 for functions that take any number of arguments
+	maxArgs int
+	// args lists the kind of each argument, the last one applying to any further arguments
+	args    []valueKind
+	result  valueKind
+	convert func(args mongo.Array) interface{}
+}
+
+// scalarFunctions maps the names of the supported SQL scalar functions to their description
+var scalarFunctions = map[string]scalarFunction{
+	"UPPER":            {1, 1, []valueKind{stringValue}, stringValue, unaryOperator("$toUpper")},
+	"LOWER":            {1, 1, []valueKind{stringValue}, stringValue, unaryOperator("$toLower")},
+	"SUBSTRING":        {2, 3, []valueKind{stringValue, numberValue}, stringValue, convertSubstring},
+	"SUBSTR":           {2, 3, []valueKind{stringValue, numberValue}, stringValue, convertSubstring},
+	"CONCAT":           {1, -1, []valueKind{stringValue}, stringValue, convertConcat},
+	"TRIM":             {1, 2, []valueKind{stringValue}, stringValue, trimOperator("$trim")},
+	"LTRIM":            {1, 2, []valueKind{stringValue}, stringValue, trimOperator("$ltrim")},
+	"RTRIM":            {1, 2, []valueKind{stringValue}, stringValue, trimOperator("$rtrim")},
+	"LENGTH":           {1, 1, []valueKind{stringValue}, numberValue, convertLength},
+	"CHAR_LENGTH":      {1, 1, []valueKind{stringValue}, numberValue, convertLength},
+	"CHARACTER_LENGTH": {1, 1, []valueKind{stringValue}, numberValue, convertLength},
+	"REPLACE":          {3, 3, []valueKind{stringValue}, stringValue, convertReplace},
+	"POSITION":         {2, 2, []valueKind{stringValue}, numberValue, convertPosition},
+	"ABS":              {1, 1, []valueKind{numberValue}, numberValue, unaryOperator("$abs")},
+	"ROUND":            {1, 2, []valueKind{numberValue}, numberValue, arrayOperator("$round")},
+	"CEIL":             {1, 1, []valueKind{numberValue}, numberValue, unaryOperator("$ceil")},
+	"CEILING":          {1, 1, []valueKind{numberValue}, numberValue, unaryOperator("$ceil")},
+	"FLOOR":            {1, 1, []valueKind{numberValue}, numberValue, unaryOperator("$floor")},
+	"MOD":              {2, 2, []valueKind{numberValue}, numberValue, arrayOperator("$mod")},
+	"POWER":            {2, 2, []valueKind{numberValue}, numberValue, arrayOperator("$pow")},
+	"POW":              {2, 2, []valueKind{numberValue}, numberValue, arrayOperator("$pow")},
+	"SQRT":             {1, 1, []valueKind{numberValue}, numberValue, unaryOperator("$sqrt")},
+	"COALESCE":         {1, -1, []valueKind{anyValue}, anyValue, convertCoalesce},
+	"NULLIF":           {2, 2, []valueKind{anyValue}, anyValue, convertNullIf},
+	"RANDOM":           {0, 0, []valueKind{anyValue}, numberValue, convertRandom},
+	"RAND":             {0, 0, []valueKind{anyValue}, numberValue, convertRandom},
+}
+
+// unaryOperator converts a function of one argument to an operator that takes it directly
+func unaryOperator(op string) func(args mongo.Array) interface{} {
+	return func(args mongo.Array) interface{} {
+		return mongo.Document{{Key: op, Value: args[0]}}
+	}
+}
+
+// arrayOperator converts a function to an operator that takes its arguments as an array
+func arrayOperator(op string) func(args mongo.Array) interface{} {
+	return func(args mongo.Array) interface{} {
+		return mongo.Document{{Key: op, Value: args}}
+	}
+}
+
+// trimOperator converts a trim function, whose optional second argument holds the characters to remove
+func trimOperator(op string) func(args mongo.Array) interface{} {
+	return func(args mongo.Array) interface{} {
+		trim := mongo.Document{{Key: "input", Value: args[0]}}
+		if len(args) > 1 {
+			trim = append(trim, mongo.Element{Key: "chars", Value: args[1]})
+		}
+		return mongo.Document{{Key: op, Value: trim}}
+	}
+}
+
+// convertSubstring converts SUBSTRING, whose start is counted from 1 and whose length defaults to
+// the rest of the string
+func convertSubstring(args mongo.Array) interface{} {
+	var start interface{} = mongo.Document{{Key: "$subtract", Value: mongo.Array{args[1], int64(1)}}}
+	if i, ok := args[1].(int64); ok {
+		start = i - 1
+	}
+	var length interface{} = stringLength(args[0])
+	if len(args) > 2 {
+		length = args[2]
+	}
+	return mongo.Document{{Key: "$substrCP", Value: mongo.Array{args[0], start, length}}}
+}
+
+// convertLength converts LENGTH, which is NULL for a NULL string where $strLenCP fails
+func convertLength(args mongo.Array) interface{} {
+	if str, ok := args[0].(string); ok && !strings.HasPrefix(str, "$") {
+		return mongo.Document{{Key: "$strLenCP", Value: str}}
+	}
+	isNull := mongo.Document{{Key: "$eq", Value: mongo.Array{mongo.Document{{Key: "$ifNull", Value: mongo.Array{args[0], nil}}}, nil}}}
+	return mongo.Document{{Key: "$cond", Value: mongo.Array{isNull, nil, mongo.Document{{Key: "$strLenCP", Value: args[0]}}}}}
+}
+
+// stringLength returns the length of a string, counting a NULL string as empty since $strLenCP
+// fails on it
+func stringLength(input interface{}) mongo.Document {
+	return mongo.Document{{Key: "$strLenCP", Value: mongo.Document{{Key: "$ifNull", Value: mongo.Array{input, ""}}}}}
+}
+
+// convertConcat converts CONCAT, which skips NULL arguments unlike the || operator
+func convertConcat(args mongo.Array) interface{} {
+	values := make(mongo.Array, len(args))
+	for i, arg := range args {
+		values[i] = arg
+		if str, ok := arg.(string); !ok || strings.HasPrefix(str, "$") {
+			values[i] = mongo.Document{{Key: "$ifNull", Value: mongo.Array{arg, ""}}}
+		}
+	}
+	return mongo.Document{{Key: "$concat", Value: values}}
+}
+
+// convertReplace converts REPLACE, which replaces every occurrence of a string
+func convertReplace(args mongo.Array) interface{} {
+	return mongo.Document{{Key: "$replaceAll", Value: mongo.Document{
+		{Key: "input", Value: args[0]},
+		{Key: "find", Value: args[1]},
+		{Key: "replacement", Value: args[2]},
+	}}}
+}
+
+// convertPosition converts POSITION, which counts from 1 and returns 0 when the substring is not found
+func convertPosition(args mongo.Array) interface{} {
+	return mongo.Document{{Key: "$add", Value: mongo.Array{
+		mongo.Document{{Key: "$indexOfCP", Value: mongo.Array{args[1], args[0]}}},
+		int64(1),
+	}}}
+}
+
+// convertCoalesce converts COALESCE to $ifNull, which returns the first argument that is not null
+func convertCoalesce(args mongo.Array) interface{} {
+	if len(args) == 1 {
+		return args[0]
+	}
+	return mongo.Document{{Key: "$ifNull", Value: args}}
+}
+
+// convertNullIf converts NULLIF, which returns null when both arguments are equal and the first otherwise
+func convertNullIf(args mongo.Array) interface{} {
+	return mongo.Document{{Key: "$cond", Value: mongo.Array{
+		mongo.Document{{Key: "$eq", Value: mongo.Array{args[0], args[1]}}},
+		nil,
+		args[0],
+	}}}
+}
+
+// convertScalarFunction converts a call to a scalar function, checking the number and kind of its arguments
+func convertScalarFunction(call *sql.FuncCall, s *scope) (interface{}, error) {
+	name := strings.ToUpper(call.Name)
+	if function, ok := dateFunctions[name]; ok {
+		return convertDateFunction(name, function, call, s)
+	}
+	if _, ok := jsonFunctions[name]; ok {
+		return convertJSONPath(call, s)
+	}
+	if _, ok := regexFunctions[name]; ok {
+		return convertRegexFunction(call, s)
+	}
+	function, ok := scalarFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported function: %s", call.Name)
+	}
+	if call.Distinct {
+		return nil, fmt.Errorf("DISTINCT is only allowed in aggregate functions: %s", call)
+	}
+	if err := function.checkArgs(name, call.Args); err != nil {
+		return nil, err
+	}
+	args, err := convertExpressions(call.Args, s)
+	if err != nil {
+		return nil, err
+	}
+	return function.convert(args), nil
+}
+
+// checkArgs checks the number of arguments of a call and the kind of those whose kind is known
+func (f scalarFunction) checkArgs(name string, args []sql.Expr) error {
+	if len(args) < f.minArgs || (f.maxArgs != -1 && len(args) > f.maxArgs) {
+		expected := fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+		switch {
+		case f.maxArgs == -1:
+			expected = fmt.Sprintf("at least %d %s", f.minArgs, plural(f.minArgs, "argument"))
+		case f.minArgs == f.maxArgs:
+			expected = fmt.Sprintf("%d %s", f.minArgs, plural(f.minArgs, "argument"))
+		}
+		return fmt.Errorf("%s expects %s but got %d", name, expected, len(args))
+	}
+	for i, arg := range args {
+		if _, ok := arg.(*sql.StarExpr); ok {
+			return fmt.Errorf("%s does not accept *", name)
+		}
+		want := f.args[len(f.args)-1]
+		if i < len(f.args) {
+			want = f.args[i]
+		}
+		if got := kindOf(arg); want != anyValue && got != anyValue && got != want {
+			return fmt.Errorf("%s expects a %s as argument %d but got a %s: %s", name, want, i+1, got, arg)
+		}
+	}
+	return nil
+}
+
+// kindOf returns the kind of value an expression produces, or anyValue if it can not be told
+func kindOf(expr sql.Expr) valueKind {
+	switch e := expr.(type) {
+	case *sql.Literal:
+		switch e.Kind {
+		case sql.StringLiteral:
+			return stringValue
+		case sql.NumberLiteral:
+			return numberValue
+		case sql.BooleanLiteral:
+			return booleanValue
+		case sql.DateLiteral, sql.TimestampLiteral:
+			return dateValue
+		}
+	case *sql.FuncCall:
+		if function, ok := dateFunctions[strings.ToUpper(e.Name)]; ok && e.Over == nil {
+			return function.result
+		}
+		if function, ok := scalarFunctions[strings.ToUpper(e.Name)]; ok && e.Over == nil {
+			return function.result
+		}
+		if kind, ok := regexFunctions[strings.ToUpper(e.Name)]; ok && e.Over == nil {
+			return kind
+		}
+	case *sql.BinaryExpr:
+		switch e.Op {
+		case "||":
+			return stringValue
+		case "+", "-":
+			if isInterval(e.Left) || isInterval(e.Right) {
+				return dateValue
+			}
+			return numberValue
+		case "*", "/", "%":
+			return numberValue
+		case "->", "->>":
+			return anyValue
+		default:
+			return booleanValue
+		}
+	case *sql.UnaryExpr:
+		if e.Op == "NOT" {
+			return booleanValue
+		}
+		return numberValue
+	case *sql.CastExpr:
+		if to, ok := castType(e.Type); ok {
+			return castKinds[to]
+		}
+	case *sql.IsNullExpr, *sql.InExpr, *sql.BetweenExpr:
+		return booleanValue
+	}
+	return anyValue
+}
+
+// plural returns a noun in its plural form unless the count is one
+func plural(count int, noun string) string {
+	if count == 1 {
+		return noun
+	}
+	return noun + "s"
+}
@@ -0,0 +1,120 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertScalarFunction(t *testing.T) {
+	name := &sql.ColumnRef{Name: "name"}
+	number := func(value string) sql.Expr { return &sql.Literal{Kind: sql.NumberLiteral, Value: value} }
+	str := func(value string) sql.Expr { return &sql.Literal{Kind: sql.StringLiteral, Value: value} }
+	tests := []struct {
+		name    string
+		call    *sql.FuncCall
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "upper",
+			call: &sql.FuncCall{Name: "upper", Args: []sql.Expr{name}},
+			want: mongo.Document{{Key: "$toUpper", Value: "$name"}},
+		},
+		{
+			name: "substring with constant start",
+			call: &sql.FuncCall{Name: "SUBSTRING", Args: []sql.Expr{name, number("2"), number("3")}},
+			want: mongo.Document{{Key: "$substrCP", Value: mongo.Array{"$name", int64(1), int64(3)}}},
+		},
+		{
+			name: "substring to the end",
+			call: &sql.FuncCall{Name: "SUBSTR", Args: []sql.Expr{name, &sql.ColumnRef{Name: "start"}}},
+			want: mongo.Document{{Key: "$substrCP", Value: mongo.Array{
+				"$name",
+				mongo.Document{{Key: "$subtract", Value: mongo.Array{"$start", int64(1)}}},
+				mongo.Document{{Key: "$strLenCP", Value: mongo.Document{{Key: "$ifNull", Value: mongo.Array{"$name", ""}}}}},
+			}}},
+		},
+		{
+			name: "length of a column",
+			call: &sql.FuncCall{Name: "LENGTH", Args: []sql.Expr{name}},
+			want: mongo.Document{{Key: "$cond", Value: mongo.Array{
+				mongo.Document{{Key: "$eq", Value: mongo.Array{mongo.Document{{Key: "$ifNull", Value: mongo.Array{"$name", nil}}}, nil}}},
+				nil,
+				mongo.Document{{Key: "$strLenCP", Value: "$name"}},
+			}}},
+		},
+		{
+			name: "length of a string",
+			call: &sql.FuncCall{Name: "CHAR_LENGTH", Args: []sql.Expr{str("abc")}},
+			want: mongo.Document{{Key: "$strLenCP", Value: "abc"}},
+		},
+		{
+			name: "concat skips nulls",
+			call: &sql.FuncCall{Name: "CONCAT", Args: []sql.Expr{name, str("!")}},
+			want: mongo.Document{{Key: "$concat", Value: mongo.Array{mongo.Document{{Key: "$ifNull", Value: mongo.Array{"$name", ""}}}, "!"}}},
+		},
+		{
+			name: "trim characters",
+			call: &sql.FuncCall{Name: "RTRIM", Args: []sql.Expr{name, str(".")}},
+			want: mongo.Document{{Key: "$rtrim", Value: mongo.Document{{Key: "input", Value: "$name"}, {Key: "chars", Value: "."}}}},
+		},
+		{
+			name: "position",
+			call: &sql.FuncCall{Name: "POSITION", Args: []sql.Expr{str("@"), name}},
+			want: mongo.Document{{Key: "$add", Value: mongo.Array{mongo.Document{{Key: "$indexOfCP", Value: mongo.Array{"$name", "@"}}}, int64(1)}}},
+		},
+		{
+			name: "round",
+			call: &sql.FuncCall{Name: "ROUND", Args: []sql.Expr{&sql.ColumnRef{Name: "price"}, number("2")}},
+			want: mongo.Document{{Key: "$round", Value: mongo.Array{"$price", int64(2)}}},
+		},
+		{
+			name: "coalesce",
+			call: &sql.FuncCall{Name: "COALESCE", Args: []sql.Expr{name, &sql.ColumnRef{Name: "nickname"}, str("unknown")}},
+			want: mongo.Document{{Key: "$ifNull", Value: mongo.Array{"$name", "$nickname", "unknown"}}},
+		},
+		{
+			name: "nullif",
+			call: &sql.FuncCall{Name: "NULLIF", Args: []sql.Expr{name, str("")}},
+			want: mongo.Document{{Key: "$cond", Value: mongo.Array{mongo.Document{{Key: "$eq", Value: mongo.Array{"$name", ""}}}, nil, "$name"}}},
+		},
+		{
+			name:    "wrong arity",
+			call:    &sql.FuncCall{Name: "MOD", Args: []sql.Expr{number("1")}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "string for a number",
+			call:    &sql.FuncCall{Name: "SQRT", Args: []sql.Expr{str("4")}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "number for a string",
+			call:    &sql.FuncCall{Name: "LOWER", Args: []sql.Expr{&sql.FuncCall{Name: "LENGTH", Args: []sql.Expr{name}}}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "unknown function",
+			call:    &sql.FuncCall{Name: "SOUNDEX", Args: []sql.Expr{name}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertScalarFunction(tt.call, newScope())
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// facetWarning is the warning of a query with grouping sets, whose groups are all returned at once by $facet
+const facetWarning = "grouping sets are computed in a single $facet stage, whose output must fit in a 16MB document"
+
+// groupingCall is a GROUPING call of a query with grouping sets and its value in each set
+type groupingCall struct {
+	name   string
+	values []int64
+}
+
+// isGrouping checks if a function call is a GROUPING call
+func isGrouping(call *sql.FuncCall) bool {
+	return strings.EqualFold(call.Name, "GROUPING") && call.Over == nil
+}
+
+// groupingSets tells for each grouping set of a query which of its GROUP BY expressions it groups by
+func groupingSets(query sql.Query) [][]bool {
+	sets := make([][]bool, len(query.GroupingSets))
+	for i, set := range query.GroupingSets {
+		sets[i] = make([]bool, len(query.GroupBy))
+		for _, expr := range set {
+			for j, key := range query.GroupBy {
+				if key.String() == expr.String() {
+					sets[i][j] = true
+				}
+			}
+		}
+	}
+	return sets
+}
+
+// grouping checks that the arguments of a GROUPING call are group keys and, for a query with
+// grouping sets, stores its value in each set as a constant field of _id, the bit of each argument
+// being 1 in the sets that roll it up, with the first argument as the most significant bit
+func (g *groupStage) grouping(call *sql.FuncCall) (string, error) {
+	if field, ok := g.output.fields[call.String()]; ok {
+		return field, nil
+	}
+	if len(call.Args) == 0 {
+		return "", fmt.Errorf("GROUPING expects at least 1 argument")
+	}
+	id, _ := g.id.(mongo.Document)
+	positions := make([]int, len(call.Args))
+	for i, arg := range call.Args {
+		field, ok := g.output.fieldPath(arg)
+		if !ok || (field != "_id" && !strings.HasPrefix(field, "_id.")) {
+			return "", fmt.Errorf("arguments of GROUPING must be GROUP BY expressions: %s", arg)
+		}
+		positions[i] = indexOf(id, strings.TrimPrefix(field, "_id."))
+	}
+	if g.groupingSets == nil {
+		return "", nil
+	}
+
+	grouping := groupingCall{name: "_grouping", values: make([]int64, len(g.groupingSets))}
+	for i := 2; indexOf(id, grouping.name) != -1 || g.hasGrouping(grouping.name); i++ {
+		grouping.name = fmt.Sprintf("_grouping_%d", i)
+	}
+	for i, set := range g.groupingSets {
+		for _, position := range positions {
+			grouping.values[i] <<= 1
+			if !set[position] {
+				grouping.values[i] |= 1
+			}
+		}
+	}
+	g.groupings = append(g.groupings, grouping)
+	field := "_id." + grouping.name
+	g.output.fields[call.String()] = field
+	return field, nil
+}
+
+// hasGrouping checks if a GROUPING call is already stored under the given name
+func (g *groupStage) hasGrouping(name string) bool {
+	for _, grouping := range g.groupings {
+		if grouping.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// facetStages returns a $facet stage with one $group per grouping set, where the keys a set rolls
+// up are grouped by null, followed by the stages that concatenate the groups of every set
+func (g *groupStage) facetStages() []mongo.Document {
+	id, _ := g.id.(mongo.Document)
+	var facet mongo.Document
+	var branches mongo.Array
+	for i, set := range g.groupingSets {
+		setID := mongo.Document{}
+		for j, key := range id {
+			var value interface{}
+			if set[j] {
+				value = key.Value
+			}
+			setID = append(setID, mongo.Element{Key: key.Key, Value: value})
+		}
+		for _, grouping := range g.groupings {
+			setID = append(setID, mongo.Element{Key: grouping.name, Value: grouping.values[i]})
+		}
+		stages := []mongo.Document{{{Key: "$group", Value: append(mongo.Document{{Key: "_id", Value: setID}}, g.accumulators...)}}}
+		if len(g.finalizers) > 0 {
+			stages = append(stages, mongo.Document{{Key: "$addFields", Value: g.finalizers}})
+		}
+		name := fmt.Sprintf("set%d", i+1)
+		facet = append(facet, mongo.Element{Key: name, Value: stageArray(stages)})
+		branches = append(branches, "$"+name)
+	}
+	return []mongo.Document{
+		{{Key: "$facet", Value: facet}},
+		{{Key: "$project", Value: mongo.Document{{Key: "rows", Value: mongo.Document{{Key: "$concatArrays", Value: branches}}}}}},
+		{{Key: "$unwind", Value: "$rows"}},
+		{{Key: "$replaceWith", Value: "$rows"}},
+	}
+}
+
+// convertGrouping converts a GROUPING call of a query without grouping sets, where no key is ever rolled up
+func convertGrouping(call *sql.FuncCall, s *scope) (interface{}, error) {
+	if s == nil || !s.grouped {
+		return nil, fmt.Errorf("GROUPING is only allowed in a query with GROUP BY: %s", call)
+	}
+	for _, arg := range call.Args {
+		if _, ok := s.fieldPath(arg); !ok {
+			return nil, fmt.Errorf("arguments of GROUPING must be GROUP BY expressions: %s", arg)
+		}
+	}
+	return int64(0), nil
+}
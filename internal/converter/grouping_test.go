@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupingSets(t *testing.T) {
+	a, b := &sql.ColumnRef{Name: "a"}, &sql.ColumnRef{Name: "b"}
+	query := sql.Query{GroupBy: []sql.Expr{a, b}, GroupingSets: [][]sql.Expr{{a, b}, {b}, {}}}
+	require.Equal(t, [][]bool{{true, true}, {false, true}, {false, false}}, groupingSets(query))
+}
+
+func TestGroupStageGrouping(t *testing.T) {
+	a, b := &sql.ColumnRef{Name: "a"}, &sql.ColumnRef{Name: "b"}
+	group := newGroupStage(newScope())
+	group.groupingSets = [][]bool{{true, true}, {true, false}, {false, false}}
+	require.NoError(t, group.setKeys([]groupKey{{name: "a", expr: a}, {name: "b", expr: b}}))
+
+	field, err := group.grouping(&sql.FuncCall{Name: "GROUPING", Args: []sql.Expr{a, b}})
+	require.NoError(t, err)
+	require.Equal(t, "_id._grouping", field)
+	field, err = group.grouping(&sql.FuncCall{Name: "grouping", Args: []sql.Expr{b}})
+	require.NoError(t, err)
+	require.Equal(t, "_id._grouping_2", field)
+	_, err = group.grouping(&sql.FuncCall{Name: "GROUPING", Args: []sql.Expr{&sql.ColumnRef{Name: "c"}}})
+	require.EqualError(t, err, "arguments of GROUPING must be GROUP BY expressions: c")
+
+	_, err = group.accumulate(&sql.FuncCall{Name: "COUNT", Args: []sql.Expr{&sql.StarExpr{}}}, "n")
+	require.NoError(t, err)
+	count := mongo.Element{Key: "n", Value: mongo.Document{{Key: "$sum", Value: int64(1)}}}
+	set := func(a, b interface{}, grouping, groupingB int64) mongo.Array {
+		id := mongo.Document{{Key: "a", Value: a}, {Key: "b", Value: b}, {Key: "_grouping", Value: grouping}, {Key: "_grouping_2", Value: groupingB}}
+		return mongo.Array{mongo.Document{{Key: "$group", Value: mongo.Document{{Key: "_id", Value: id}, count}}}}
+	}
+	want := []mongo.Document{
+		{{Key: "$facet", Value: mongo.Document{
+			{Key: "set1", Value: set("$a", "$b", 0, 0)},
+			{Key: "set2", Value: set("$a", nil, 1, 1)},
+			{Key: "set3", Value: set(nil, nil, 3, 1)},
+		}}},
+		{{Key: "$project", Value: mongo.Document{{Key: "rows", Value: mongo.Document{{Key: "$concatArrays", Value: mongo.Array{"$set1", "$set2", "$set3"}}}}}}},
+		{{Key: "$unwind", Value: "$rows"}},
+		{{Key: "$replaceWith", Value: "$rows"}},
+	}
+	require.Equal(t, want, group.stages())
+}
+
+func TestConvertGrouping(t *testing.T) {
+	grouped := newScope()
+	grouped.grouped = true
+	grouped.fields["region"] = "_id"
+	got, err := convertGrouping(&sql.FuncCall{Name: "GROUPING", Args: []sql.Expr{&sql.ColumnRef{Name: "region"}}}, grouped)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), got)
+
+	_, err = convertGrouping(&sql.FuncCall{Name: "GROUPING", Args: []sql.Expr{&sql.ColumnRef{Name: "region"}}}, newScope())
+	require.EqualError(t, err, "GROUPING is only allowed in a query with GROUP BY: GROUPING(region)")
+}
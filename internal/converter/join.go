@@ -0,0 +1,345 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// variableUnsafe matches the characters that can not appear in a $lookup variable name
+var variableUnsafe = regexp.MustCompile(`[^a-z0-9_]`)
+
+// source is where the rows of a query come from: the filter applied to the queried
+// collection, the stages that join other collections to it, and the scope that
+// resolves the columns of the joined rows
+type source struct {
+	database   string
+	collection string
+	scope      *scope
+	match      mongo.Document
+	stages     []mongo.Document
+	// sample is the $sample stage of a TABLESAMPLE, which picks documents before they are filtered
+	sample mongo.Document
+	// hint is the index the collection is read with
+	hint string
+	// warnings explain joins whose emulation is expensive
+	warnings []string
+}
+
+// pipeline returns the stages that produce the rows of a source
+func (s source) pipeline() []mongo.Document {
+	var pipeline []mongo.Document
+	if s.sample != nil {
+		pipeline = append(pipeline, s.sample)
+	}
+	if s.match != nil {
+		pipeline = append(pipeline, mongo.Document{{Key: "$match", Value: s.match}})
+	}
+	return append(pipeline, s.stages...)
+}
+
+// convertSource converts the FROM, JOIN and WHERE clauses of a query, filtering the queried
+// collection before any join for the conditions that only refer to its own columns. The
+// pipeline of a derived table is inlined, its output columns becoming the fields of each row
+func convertSource(query sql.Query, options Options) (source, error) {
+	s := newScope()
+	s.options = options
+	base := query.Table
+	if query.Alias != "" {
+		base = query.Alias
+	}
+	s.tables[base] = ""
+
+	result := source{database: query.Database, collection: query.Table, hint: query.Hint}
+	cte, recursive := recursiveTable(query)
+	derived := query.From != nil || recursive
+	if derived {
+		var inner mongo.Query
+		var stages []mongo.Document
+		var err error
+		if recursive {
+			inner, stages, err = convertRecursive(cte, options)
+		} else {
+			inner, stages, err = convertPipeline(*query.From, options)
+		}
+		if err != nil {
+			return source{}, err
+		}
+		if query.Hint != "" {
+			return source{}, fmt.Errorf("index hints can only be given for a collection, not %s", base)
+		}
+		result.database, result.collection = inner.Database, inner.Collections
+		result.stages = stages
+		result.hint = inner.Hint
+		result.warnings = inner.Warnings
+	}
+	if query.Sample != nil {
+		if derived {
+			return source{}, fmt.Errorf("TABLESAMPLE can only sample a collection, not %s", query.Table)
+		}
+		result.sample = convertTableSample(query.Sample, query.Table)
+		result.warnings = append(result.warnings, sampleWarning(query.Table))
+	}
+
+	joined, columns := map[string]bool{}, map[string]bool{}
+	for _, join := range query.Joins {
+		joined[join.Name()] = true
+		if join.Unnest != nil {
+			element, ordinality := unnestColumns(join)
+			columns[element] = true
+			if ordinality != "" {
+				columns[ordinality] = true
+			}
+		}
+		if join.Type == sql.FullJoin {
+			// rows of the queried collection can only be filtered once the unmatched
+			// rows of the other side have been added
+			joined[base] = true
+		}
+	}
+	before, after := splitConjuncts(query.Where, joined, columns)
+
+	if before != nil {
+		match, err := convertFilter(before, s)
+		if err != nil {
+			return source{}, err
+		}
+		if len(result.stages) > 0 {
+			result.stages = append(result.stages, mongo.Document{{Key: "$match", Value: match}})
+		} else {
+			result.match = match
+		}
+	}
+	for i, join := range query.Joins {
+		if _, exists := s.tables[join.Name()]; exists {
+			return source{}, fmt.Errorf("table name %s specified more than once", join.Name())
+		}
+		if join.Unnest != nil {
+			stages, err := convertUnnest(join, s)
+			if err != nil {
+				return source{}, err
+			}
+			result.stages = append(result.stages, stages...)
+			continue
+		}
+		if join.Type == sql.CrossJoin {
+			return source{}, fmt.Errorf("CROSS JOIN %s is only supported with UNNEST", join.Name())
+		}
+		if derived && (join.Type == sql.RightJoin || join.Type == sql.FullJoin) {
+			return source{}, fmt.Errorf("%s JOIN %s can not be joined to a subquery", join.Type, join.Name())
+		}
+		if join.Type == sql.FullJoin && query.Sample != nil {
+			return source{}, fmt.Errorf("FULL JOIN %s can not be joined to a sampled table", join.Name())
+		}
+		if join.Type == sql.RightJoin || (join.Type == sql.FullJoin && i > 0) {
+			return source{}, fmt.Errorf("%s JOIN %s must be the first join of the query", join.Type, join.Name())
+		}
+		lookup, err := convertLookup(join, s)
+		if err != nil {
+			return source{}, err
+		}
+		s.tables[join.Name()] = fieldName(join.Name())
+		result.stages = append(result.stages, lookup, convertUnwind(join))
+		if join.Type == sql.FullJoin {
+			union, err := convertUnmatched(query, join, options)
+			if err != nil {
+				return source{}, err
+			}
+			result.stages = append(result.stages, union)
+			result.warnings = append(result.warnings, fmt.Sprintf(
+				"FULL OUTER JOIN %s is emulated with $unionWith, which reads %s a second time and looks up every one of its documents in %s",
+				join.Name(), join.Table, query.Table))
+		}
+	}
+	if after != nil {
+		match, err := convertFilter(after, s)
+		if err != nil {
+			return source{}, err
+		}
+		result.stages = append(result.stages, mongo.Document{{Key: "$match", Value: match}})
+	}
+	result.scope = s
+	return result, nil
+}
+
+// rewriteRightJoin rewrites a query whose first join is a RIGHT JOIN as a LEFT JOIN
+// from the joined table, which then becomes the queried collection
+func rewriteRightJoin(query sql.Query) sql.Query {
+	if len(query.Joins) == 0 || query.Joins[0].Type != sql.RightJoin || query.From != nil || len(query.With) > 0 {
+		return query
+	}
+	right := query.Joins[0]
+	joins := append([]sql.Join{{Type: sql.LeftJoin, Table: query.Table, Alias: query.Alias, On: right.On}}, query.Joins[1:]...)
+	query.Table, query.Alias, query.Joins = right.Table, right.Alias, joins
+	return query
+}
+
+// convertUnmatched converts a FULL JOIN clause to the $unionWith stage that adds the rows of
+// the joined collection that match no document of the queried collection
+func convertUnmatched(query sql.Query, join sql.Join, options Options) (mongo.Document, error) {
+	right := newScope()
+	right.options = options
+	right.tables[join.Name()] = ""
+	reverse := sql.Join{Type: sql.LeftJoin, Table: query.Table, Alias: query.Alias, On: join.On}
+	lookup, err := convertLookup(reverse, right)
+	if err != nil {
+		return nil, err
+	}
+	as := fieldName(reverse.Name())
+	pipeline := mongo.Array{
+		lookup,
+		mongo.Document{{Key: "$match", Value: mongo.Document{{Key: as, Value: mongo.Document{{Key: "$size", Value: int64(0)}}}}}},
+		mongo.Document{{Key: "$unset", Value: as}},
+		mongo.Document{{Key: "$replaceWith", Value: mongo.Document{{Key: fieldName(join.Name()), Value: "$$ROOT"}}}},
+	}
+	return mongo.Document{{Key: "$unionWith", Value: mongo.Document{
+		{Key: "coll", Value: join.Table},
+		{Key: "pipeline", Value: pipeline},
+	}}}, nil
+}
+
+// splitConjuncts splits a condition into the parts that do not and do refer to the given tables,
+// or to the given columns when they are not qualified by a table
+func splitConjuncts(expr sql.Expr, tables map[string]bool, columns map[string]bool) (sql.Expr, sql.Expr) {
+	if expr == nil {
+		return nil, nil
+	}
+	var before, after sql.Expr
+	for _, conjunct := range flatten(expr, "AND") {
+		if refersTo(conjunct, tables, columns) {
+			after = and(after, conjunct)
+		} else {
+			before = and(before, conjunct)
+		}
+	}
+	return before, after
+}
+
+// and combines two conditions with AND, either of which may be missing
+func and(left sql.Expr, right sql.Expr) sql.Expr {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	return &sql.BinaryExpr{Op: "AND", Left: left, Right: right}
+}
+
+// refersTo checks if an expression refers to a column of any of the given tables, or to any of the
+// given columns without qualifying it
+func refersTo(expr sql.Expr, tables map[string]bool, columns map[string]bool) bool {
+	found := false
+	sql.Walk(expr, func(e sql.Expr) bool {
+		if column, ok := e.(*sql.ColumnRef); ok && (tables[column.Table] || (column.Table == "" && columns[column.Name])) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// convertLookup converts a JOIN clause to a $lookup stage, using localField and foreignField
+// for a single equality and a let and pipeline for any other condition or a joined subquery
+func convertLookup(join sql.Join, left *scope) (mongo.Document, error) {
+	as := fieldName(join.Name())
+	from := join.Table
+	var stages mongo.Array
+	if join.Subquery != nil {
+		inner, pipeline, err := convertPipeline(*join.Subquery, left.options)
+		if err != nil {
+			return nil, err
+		}
+		from, stages = inner.Collections, stageArray(pipeline)
+	} else if local, foreign, ok := equiJoin(join, left); ok {
+		return mongo.Document{{Key: "$lookup", Value: mongo.Document{
+			{Key: "from", Value: join.Table},
+			{Key: "localField", Value: local},
+			{Key: "foreignField", Value: foreign},
+			{Key: "as", Value: as},
+		}}}, nil
+	}
+
+	right := newScope()
+	right.options = left.options
+	right.tables[join.Name()] = ""
+	var let mongo.Document
+	sql.Walk(join.On, func(e sql.Expr) bool {
+		column, ok := e.(*sql.ColumnRef)
+		if !ok || column.Table == join.Name() {
+			return true
+		}
+		if _, exists := right.variables[column.String()]; exists {
+			return true
+		}
+		field, _ := left.fieldPath(column)
+		name := variableName(column, let)
+		right.variables[column.String()] = name
+		let = append(let, mongo.Element{Key: name, Value: "$" + field})
+		return true
+	})
+
+	filter, err := convertFilter(join.On, right)
+	if err != nil {
+		return nil, err
+	}
+	lookup := mongo.Document{{Key: "from", Value: from}}
+	if len(let) > 0 {
+		lookup = append(lookup, mongo.Element{Key: "let", Value: let})
+	}
+	lookup = append(lookup,
+		mongo.Element{Key: "pipeline", Value: append(stages, mongo.Document{{Key: "$match", Value: filter}})},
+		mongo.Element{Key: "as", Value: as},
+	)
+	return mongo.Document{{Key: "$lookup", Value: lookup}}, nil
+}
+
+// equiJoin returns the local and foreign fields of a join on a single column equality
+func equiJoin(join sql.Join, left *scope) (string, string, bool) {
+	eq, ok := join.On.(*sql.BinaryExpr)
+	if !ok || eq.Op != "=" {
+		return "", "", false
+	}
+	a, aok := eq.Left.(*sql.ColumnRef)
+	b, bok := eq.Right.(*sql.ColumnRef)
+	if !aok || !bok {
+		return "", "", false
+	}
+	if a.Table == join.Name() {
+		a, b = b, a
+	}
+	if b.Table != join.Name() || a.Table == join.Name() {
+		return "", "", false
+	}
+	local, ok := left.fieldPath(a)
+	return local, b.Name, ok
+}
+
+// variableName returns a name for the $lookup variable holding a column, unique within let
+func variableName(column *sql.ColumnRef, let mongo.Document) string {
+	base := variableUnsafe.ReplaceAllString(strings.ToLower(column.String()), "_")
+	if base == "" || base[0] < 'a' || base[0] > 'z' {
+		base = "v" + base
+	}
+	name := base
+	for i := 2; indexOf(let, name) != -1; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	return name
+}
+
+// convertUnwind converts a JOIN clause to the $unwind stage that flattens its $lookup results,
+// keeping unmatched rows for a LEFT or FULL JOIN
+func convertUnwind(join sql.Join) mongo.Document {
+	path := "$" + fieldName(join.Name())
+	if join.Type == sql.LeftJoin || join.Type == sql.FullJoin {
+		return mongo.Document{{Key: "$unwind", Value: mongo.Document{
+			{Key: "path", Value: path},
+			{Key: "preserveNullAndEmptyArrays", Value: true},
+		}}}
+	}
+	return mongo.Document{{Key: "$unwind", Value: path}}
+}
@@ -0,0 +1,146 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertLookup(t *testing.T) {
+	left := newScope()
+	left.tables["u"] = ""
+	tests := []struct {
+		name    string
+		join    sql.Join
+		want    mongo.Document
+		wantErr bool
+	}{
+		{
+			name: "equality",
+			join: sql.Join{Type: sql.InnerJoin, Table: "orders", Alias: "o", On: &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Table: "u", Name: "_id"}, Right: &sql.ColumnRef{Table: "o", Name: "user_id"}}},
+			want: mongo.Document{{Key: "$lookup", Value: mongo.Document{
+				{Key: "from", Value: "orders"},
+				{Key: "localField", Value: "_id"},
+				{Key: "foreignField", Value: "user_id"},
+				{Key: "as", Value: "o"},
+			}}},
+		},
+		{
+			name: "non equality",
+			join: sql.Join{Type: sql.LeftJoin, Table: "prices", On: &sql.BinaryExpr{Op: "<=", Left: &sql.ColumnRef{Table: "prices", Name: "since"}, Right: &sql.ColumnRef{Table: "u", Name: "created"}}},
+			want: mongo.Document{{Key: "$lookup", Value: mongo.Document{
+				{Key: "from", Value: "prices"},
+				{Key: "let", Value: mongo.Document{{Key: "u_created", Value: "$created"}}},
+				{Key: "pipeline", Value: mongo.Array{mongo.Document{{Key: "$match", Value: mongo.Document{
+					{Key: "$expr", Value: mongo.Document{{Key: "$lte", Value: mongo.Array{"$since", "$$u_created"}}}},
+				}}}}},
+				{Key: "as", Value: "prices"},
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertLookup(tt.join, left)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConvertSource(t *testing.T) {
+	query := sql.Query{
+		Command: sql.SQLSelect,
+		Table:   "users",
+		Alias:   "u",
+		Joins: []sql.Join{
+			{Type: sql.LeftJoin, Table: "orders", Alias: "o", On: &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Table: "o", Name: "user_id"}, Right: &sql.ColumnRef{Table: "u", Name: "_id"}}},
+		},
+		Where: &sql.BinaryExpr{
+			Op:    "AND",
+			Left:  &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Table: "u", Name: "active"}, Right: &sql.Literal{Kind: sql.BooleanLiteral, Value: "true"}},
+			Right: &sql.IsNullExpr{Expr: &sql.ColumnRef{Table: "o", Name: "_id"}},
+		},
+	}
+	got, err := convertSource(query, Options{})
+	require.NoError(t, err)
+	require.Equal(t, []mongo.Document{
+		{{Key: "$match", Value: mongo.Document{{Key: "active", Value: true}}}},
+		{{Key: "$lookup", Value: mongo.Document{
+			{Key: "from", Value: "orders"},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: "user_id"},
+			{Key: "as", Value: "o"},
+		}}},
+		{{Key: "$unwind", Value: mongo.Document{{Key: "path", Value: "$o"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}},
+		{{Key: "$match", Value: mongo.Document{{Key: "o._id", Value: nil}}}},
+	}, got.pipeline())
+
+	query.Joins = append(query.Joins, sql.Join{Type: sql.InnerJoin, Table: "orders", Alias: "o", On: query.Joins[0].On})
+	_, err = convertSource(query, Options{})
+	require.Error(t, err)
+}
+
+func TestRewriteRightJoin(t *testing.T) {
+	on := &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Table: "o", Name: "user_id"}, Right: &sql.ColumnRef{Table: "u", Name: "_id"}}
+	query := sql.Query{
+		Command: sql.SQLSelect,
+		Table:   "users",
+		Alias:   "u",
+		Joins:   []sql.Join{{Type: sql.RightJoin, Table: "orders", Alias: "o", On: on}},
+	}
+	got := rewriteRightJoin(query)
+	require.Equal(t, sql.Query{
+		Command: sql.SQLSelect,
+		Table:   "orders",
+		Alias:   "o",
+		Joins:   []sql.Join{{Type: sql.LeftJoin, Table: "users", Alias: "u", On: on}},
+	}, got)
+	require.Equal(t, sql.RightJoin, query.Joins[0].Type)
+}
+
+func TestConvertUnmatched(t *testing.T) {
+	query := sql.Query{Command: sql.SQLSelect, Table: "users", Alias: "u"}
+	join := sql.Join{Type: sql.FullJoin, Table: "orders", Alias: "o", On: &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Table: "o", Name: "user_id"}, Right: &sql.ColumnRef{Table: "u", Name: "_id"}}}
+	got, err := convertUnmatched(query, join, Options{})
+	require.NoError(t, err)
+	require.Equal(t, mongo.Document{{Key: "$unionWith", Value: mongo.Document{
+		{Key: "coll", Value: "orders"},
+		{Key: "pipeline", Value: mongo.Array{
+			mongo.Document{{Key: "$lookup", Value: mongo.Document{
+				{Key: "from", Value: "users"},
+				{Key: "localField", Value: "user_id"},
+				{Key: "foreignField", Value: "_id"},
+				{Key: "as", Value: "u"},
+			}}},
+			mongo.Document{{Key: "$match", Value: mongo.Document{{Key: "u", Value: mongo.Document{{Key: "$size", Value: int64(0)}}}}}},
+			mongo.Document{{Key: "$unset", Value: "u"}},
+			mongo.Document{{Key: "$replaceWith", Value: mongo.Document{{Key: "o", Value: "$$ROOT"}}}},
+		}},
+	}}}, got)
+}
+
+func TestConvertSourceFullJoin(t *testing.T) {
+	query := sql.Query{
+		Command: sql.SQLSelect,
+		Table:   "users",
+		Alias:   "u",
+		Joins:   []sql.Join{{Type: sql.FullJoin, Table: "orders", Alias: "o", On: &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Table: "o", Name: "user_id"}, Right: &sql.ColumnRef{Table: "u", Name: "_id"}}}},
+		Where:   &sql.BinaryExpr{Op: "=", Left: &sql.ColumnRef{Table: "u", Name: "active"}, Right: &sql.Literal{Kind: sql.BooleanLiteral, Value: "true"}},
+	}
+	got, err := convertSource(query, Options{})
+	require.NoError(t, err)
+	require.Nil(t, got.match)
+	require.Len(t, got.stages, 4)
+	require.Equal(t, mongo.Document{{Key: "$match", Value: mongo.Document{{Key: "active", Value: true}}}}, got.stages[3])
+	require.Len(t, got.warnings, 1)
+
+	query.Joins = append([]sql.Join{{Type: sql.InnerJoin, Table: "items", Alias: "i", On: query.Joins[0].On}}, query.Joins...)
+	_, err = convertSource(query, Options{})
+	require.Error(t, err)
+}
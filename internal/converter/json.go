@@ -0,0 +1,201 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// jsonFunctions maps the functions that read the value at a JSON path of a document to their number
+// of arguments. JSON_UNQUOTE only unwraps the value it is given, since BSON strings are not quoted
+var jsonFunctions = map[string]int{"JSON_EXTRACT": 2, "JSON_VALUE": 2, "JSON_QUERY": 2, "JSON_UNQUOTE": 1}
+
+// jsonStep matches the next step of a JSON path: a .key, a ."quoted key", an [index] or a wildcard
+var jsonStep = regexp.MustCompile(`^(?:\.([^.\[\s"*]+)|\."((?:[^"\\]|\\.)*)"|\[(-?\d+)\]|(\.\*|\[\*\]|\*\*))`)
+
+// parseJSONPath parses a MySQL or SQL/JSON path such as $.a.b[0] to its keys and array indexes
+func parseJSONPath(path string) ([]interface{}, error) {
+	rest := strings.TrimSpace(path)
+	for _, mode := range []string{"lax ", "strict "} {
+		rest = strings.TrimPrefix(rest, mode)
+	}
+	if !strings.HasPrefix(rest, "$") {
+		return nil, fmt.Errorf("JSON path must start with $: %s", path)
+	}
+	rest = rest[1:]
+	var steps []interface{}
+	for rest != "" {
+		match := jsonStep.FindStringSubmatch(rest)
+		switch {
+		case match == nil:
+			return nil, fmt.Errorf("invalid JSON path: %s", path)
+		case match[4] != "":
+			return nil, fmt.Errorf("JSON path wildcards are not supported: %s", path)
+		case match[3] != "":
+			index, err := strconv.ParseInt(match[3], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSON path index: %s", path)
+			}
+			steps = append(steps, index)
+		case strings.HasPrefix(match[0], `."`):
+			key, err := strconv.Unquote(match[0][1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSON path key: %s", path)
+			}
+			steps = append(steps, key)
+		default:
+			steps = append(steps, match[1])
+		}
+		rest = rest[len(match[0]):]
+	}
+	return steps, nil
+}
+
+// jsonPath splits a JSON access, a chain of -> and ->> operators or a JSON function, into the
+// expression holding the document and the keys and array indexes read from it, reporting false
+// for any other expression
+func jsonPath(expr sql.Expr) (sql.Expr, []interface{}, bool, error) {
+	var base sql.Expr
+	var steps []interface{}
+	switch e := expr.(type) {
+	case *sql.BinaryExpr:
+		if e.Op != "->" && e.Op != "->>" {
+			return nil, nil, false, nil
+		}
+		step, err := jsonArrowStep(e.Right)
+		if err != nil {
+			return nil, nil, true, err
+		}
+		base, steps = e.Left, step
+	case *sql.FuncCall:
+		name := strings.ToUpper(e.Name)
+		count, ok := jsonFunctions[name]
+		if !ok || e.Over != nil {
+			return nil, nil, false, nil
+		}
+		if len(e.Args) != count {
+			return nil, nil, true, fmt.Errorf("%s expects %d %s but got %d", name, count, plural(count, "argument"), len(e.Args))
+		}
+		base = e.Args[0]
+		if count > 1 {
+			path, ok := e.Args[1].(*sql.Literal)
+			if !ok || path.Kind != sql.StringLiteral {
+				return nil, nil, true, fmt.Errorf("%s expects a constant JSON path but got %s", name, e.Args[1])
+			}
+			var err error
+			if steps, err = parseJSONPath(path.Value); err != nil {
+				return nil, nil, true, err
+			}
+		}
+	default:
+		return nil, nil, false, nil
+	}
+
+	inner, innerSteps, ok, err := jsonPath(base)
+	if err != nil {
+		return nil, nil, true, err
+	}
+	if ok {
+		return inner, append(innerSteps, steps...), true, nil
+	}
+	return base, steps, true, nil
+}
+
+// jsonArrowStep returns the steps of the right operand of -> or ->>, a key, an array index or,
+// as MySQL writes them, a JSON path
+func jsonArrowStep(expr sql.Expr) ([]interface{}, error) {
+	literal, ok := expr.(*sql.Literal)
+	switch {
+	case ok && literal.Kind == sql.StringLiteral:
+		if strings.HasPrefix(literal.Value, "$") {
+			return parseJSONPath(literal.Value)
+		}
+		return []interface{}{literal.Value}, nil
+	case ok && literal.Kind == sql.NumberLiteral:
+		if index, err := strconv.ParseInt(literal.Value, 10, 64); err == nil {
+			return []interface{}{index}, nil
+		}
+	}
+	return nil, fmt.Errorf("JSON operators expect a constant key or array index but got %s", expr)
+}
+
+// dottedPath appends the steps of a JSON path to a field path in dot notation, which the query
+// language also reads array indexes from, reporting false for steps dot notation can not express
+func dottedPath(field string, steps []interface{}, indexes bool) (string, bool) {
+	path := field
+	for _, step := range steps {
+		switch step := step.(type) {
+		case string:
+			if step == "" || strings.Contains(step, ".") || strings.HasPrefix(step, "$") {
+				return "", false
+			}
+			path = joinPath(path, step)
+		case int64:
+			if !indexes || step < 0 {
+				return "", false
+			}
+			path = joinPath(path, strconv.FormatInt(step, 10))
+		}
+	}
+	return path, true
+}
+
+// jsonFieldPath returns the field path of a JSON access of a column, allowing array indexes when
+// the path is used by a query filter rather than an aggregation expression
+func (s *scope) jsonFieldPath(expr sql.Expr, indexes bool) (string, bool) {
+	base, steps, ok, err := jsonPath(expr)
+	if !ok || err != nil {
+		return "", false
+	}
+	field, ok := s.fieldPath(base)
+	if !ok {
+		return "", false
+	}
+	return dottedPath(field, steps, indexes)
+}
+
+// queryPath returns the field a query filter reads for an expression, which unlike an aggregation
+// expression can read array elements by index in dot notation
+func (s *scope) queryPath(expr sql.Expr) (string, bool) {
+	if field, ok := s.fieldPath(expr); ok {
+		return field, true
+	}
+	return s.jsonFieldPath(expr, true)
+}
+
+// convertJSONPath converts a JSON access to an aggregation expression, extending the field path of
+// the document in dot notation for as long as it only reads keys, then reading array elements with
+// $arrayElemAt and the remaining keys with $getField
+func convertJSONPath(expr sql.Expr, s *scope) (interface{}, error) {
+	base, steps, _, err := jsonPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	value, err := convertExpression(base, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		field, isField := value.(string)
+		isField = isField && strings.HasPrefix(field, "$")
+		switch step := step.(type) {
+		case string:
+			if path, ok := dottedPath(field, []interface{}{step}, false); isField && ok {
+				value = path
+				continue
+			}
+			var key interface{} = step
+			if strings.HasPrefix(step, "$") {
+				key = mongo.Document{{Key: "$literal", Value: step}}
+			}
+			value = mongo.Document{{Key: "$getField", Value: mongo.Document{{Key: "field", Value: key}, {Key: "input", Value: value}}}}
+		case int64:
+			value = mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{value, step}}}
+		}
+	}
+	return value, nil
+}
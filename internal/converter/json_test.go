@@ -0,0 +1,126 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{
+			name: "keys and index",
+			path: "$.a.b[0]",
+			want: []interface{}{"a", "b", int64(0)},
+		},
+		{
+			name: "quoted key in lax mode",
+			path: `lax $."first name".x`,
+			want: []interface{}{"first name", "x"},
+		},
+		{
+			name: "root",
+			path: "$",
+			want: nil,
+		},
+		{
+			name:    "wildcard",
+			path:    "$.a[*]",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "missing root",
+			path:    "a.b",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJSONPath(tt.path)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConvertJSONPath(t *testing.T) {
+	data := &sql.ColumnRef{Name: "data"}
+	str := func(value string) sql.Expr { return &sql.Literal{Kind: sql.StringLiteral, Value: value} }
+	arrow := func(op string, left sql.Expr, right sql.Expr) sql.Expr {
+		return &sql.BinaryExpr{Op: op, Left: left, Right: right}
+	}
+	last := mongo.Document{{Key: "$getField", Value: mongo.Document{
+		{Key: "field", Value: "sku"},
+		{Key: "input", Value: mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$data", int64(-1)}}}},
+	}}}
+	dotted := mongo.Document{{Key: "$getField", Value: mongo.Document{{Key: "field", Value: "a.b"}, {Key: "input", Value: "$data"}}}}
+	tests := []struct {
+		name       string
+		expr       sql.Expr
+		wantFilter mongo.Document
+		want       interface{}
+		wantErr    bool
+	}{
+		{
+			name:       "keys",
+			expr:       arrow("->>", arrow("->", data, str("address")), str("city")),
+			wantFilter: mongo.Document{{Key: "data.address.city", Value: "x"}},
+			want:       "$data.address.city",
+		},
+		{
+			name:       "json path with an index",
+			expr:       &sql.FuncCall{Name: "JSON_EXTRACT", Args: []sql.Expr{data, str("$.a.b[0]")}},
+			wantFilter: mongo.Document{{Key: "data.a.b.0", Value: "x"}},
+			want:       mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$data.a.b", int64(0)}}},
+		},
+		{
+			name:       "key after an index",
+			expr:       arrow("->>", arrow("->", data, &sql.Literal{Kind: sql.NumberLiteral, Value: "-1"}), str("$.sku")),
+			wantFilter: mongo.Document{{Key: "$expr", Value: mongo.Document{{Key: "$eq", Value: mongo.Array{last, "x"}}}}},
+			want:       last,
+		},
+		{
+			name:       "key with a dot",
+			expr:       &sql.FuncCall{Name: "JSON_VALUE", Args: []sql.Expr{data, str(`$."a.b"`)}},
+			wantFilter: mongo.Document{{Key: "$expr", Value: mongo.Document{{Key: "$eq", Value: mongo.Array{dotted, "x"}}}}},
+			want:       dotted,
+		},
+		{
+			name:    "key from a column",
+			expr:    arrow("->", data, &sql.ColumnRef{Name: "key"}),
+			wantErr: true,
+		},
+		{
+			name:    "json path of a column",
+			expr:    &sql.FuncCall{Name: "JSON_EXTRACT", Args: []sql.Expr{data, &sql.ColumnRef{Name: "path"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := convertFilter(&sql.BinaryExpr{Op: "=", Left: tt.expr, Right: str("x")}, newScope())
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantFilter, filter)
+			got, err := convertExpression(tt.expr, newScope())
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// resolveOrderBy replaces the ORDER BY expressions that name a SELECT list entry by alias or
+// position with the expression of the entry
+func resolveOrderBy(items []sql.OrderItem, projections []sql.Projection) ([]sql.OrderItem, error) {
+	resolved := make([]sql.OrderItem, len(items))
+	for i, item := range items {
+		resolved[i] = item
+		switch e := item.Expr.(type) {
+		case *sql.Literal:
+			if e.Kind != sql.NumberLiteral {
+				continue
+			}
+			position, err := strconv.Atoi(e.Value)
+			if err != nil || position < 1 || position > len(projections) {
+				return nil, fmt.Errorf("ORDER BY position %s is not in the SELECT list", e)
+			}
+			if _, ok := projections[position-1].Expr.(*sql.StarExpr); ok {
+				return nil, fmt.Errorf("ORDER BY position %s refers to %s", e, projections[position-1].Expr)
+			}
+			resolved[i].Expr = projections[position-1].Expr
+		case *sql.ColumnRef:
+			if e.Table != "" {
+				continue
+			}
+			for _, projection := range projections {
+				if projection.Alias == e.Name {
+					resolved[i].Expr = projection.Expr
+					break
+				}
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// convertOrderBy converts resolved ORDER BY items to a $sort document over the documents of a
+// scope, returning the fields that must be computed first for the expressions that are not fields
+func convertOrderBy(items []sql.OrderItem, s *scope) (mongo.Document, mongo.Document, error) {
+	var keys, sort mongo.Document
+	for _, item := range items {
+		field, ok := s.fieldPath(item.Expr)
+		if !ok {
+			value, err := convertExpression(item.Expr, s)
+			if err != nil {
+				return nil, nil, err
+			}
+			field = fmt.Sprintf("_order%d", len(keys)+1)
+			keys = append(keys, mongo.Element{Key: field, Value: value})
+		}
+		if indexOf(sort, field) != -1 {
+			continue
+		}
+		direction := int64(1)
+		if item.Desc {
+			direction = -1
+		}
+		sort = append(sort, mongo.Element{Key: field, Value: direction})
+	}
+	return keys, sort, nil
+}
+
+// sortStages returns the stages that compute the sort keys of an ORDER BY and sort by them
+func sortStages(keys mongo.Document, sort mongo.Document) []mongo.Document {
+	var stages []mongo.Document
+	if len(keys) > 0 {
+		stages = append(stages, mongo.Document{{Key: "$set", Value: keys}})
+	}
+	if len(sort) > 0 {
+		stages = append(stages, mongo.Document{{Key: "$sort", Value: sort}})
+	}
+	return stages
+}
+
+// convertSetOrderBy converts the ORDER BY of a set operation, which can only refer to the columns
+// of the combined rows, to the stages that sort them
+func convertSetOrderBy(items []sql.OrderItem, names []string, options Options) ([]mongo.Document, error) {
+	s := newScope()
+	s.options = options
+	var projections []sql.Projection
+	if names != nil {
+		s.grouped = true
+		for _, name := range names {
+			s.fields[name] = name
+			projections = append(projections, sql.Projection{Expr: &sql.ColumnRef{Name: name}})
+		}
+	}
+	items, err := resolveOrderBy(items, projections)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		var err error
+		sql.Walk(item.Expr, func(e sql.Expr) bool {
+			if _, ok := e.(*sql.ColumnRef); ok && err == nil {
+				if _, ok := s.fieldPath(e); !ok {
+					err = fmt.Errorf("ORDER BY of a set operation can only refer to its result columns: %s", e)
+				}
+			}
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	keys, sort, err := convertOrderBy(items, s)
+	if err != nil {
+		return nil, err
+	}
+	stages := sortStages(keys, sort)
+	if len(keys) > 0 {
+		stages = append(stages, unsetKeys(keys))
+	}
+	return stages, nil
+}
+
+// unsetKeys returns the $unset stage that removes the computed sort keys once the rows are sorted
+func unsetKeys(keys mongo.Document) mongo.Document {
+	fields := make(mongo.Array, len(keys))
+	for i, key := range keys {
+		fields[i] = key.Key
+	}
+	return mongo.Document{{Key: "$unset", Value: fields}}
+}
@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveOrderBy(t *testing.T) {
+	total := &sql.FuncCall{Name: "SUM", Args: []sql.Expr{&sql.ColumnRef{Name: "amount"}}}
+	projections := []sql.Projection{
+		{Expr: &sql.ColumnRef{Name: "name"}},
+		{Expr: total, Alias: "total"},
+	}
+	tests := []struct {
+		name    string
+		items   []sql.OrderItem
+		want    []sql.OrderItem
+		wantErr bool
+	}{
+		{
+			name:  "alias and position",
+			items: []sql.OrderItem{{Expr: &sql.ColumnRef{Name: "total"}, Desc: true}, {Expr: &sql.Literal{Kind: sql.NumberLiteral, Value: "1"}}},
+			want:  []sql.OrderItem{{Expr: total, Desc: true}, {Expr: &sql.ColumnRef{Name: "name"}}},
+		},
+		{
+			name:  "qualified column",
+			items: []sql.OrderItem{{Expr: &sql.ColumnRef{Table: "t", Name: "total"}}},
+			want:  []sql.OrderItem{{Expr: &sql.ColumnRef{Table: "t", Name: "total"}}},
+		},
+		{
+			name:    "position out of range",
+			items:   []sql.OrderItem{{Expr: &sql.Literal{Kind: sql.NumberLiteral, Value: "3"}}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveOrderBy(tt.items, projections)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConvertOrderBy(t *testing.T) {
+	vip := &sql.CaseExpr{
+		Whens: []sql.WhenClause{{Cond: &sql.ColumnRef{Name: "vip"}, Result: &sql.Literal{Kind: sql.NumberLiteral, Value: "0"}}},
+		Else:  &sql.Literal{Kind: sql.NumberLiteral, Value: "1"},
+	}
+	items := []sql.OrderItem{{Expr: vip}, {Expr: &sql.ColumnRef{Name: "name"}, Desc: true}, {Expr: &sql.ColumnRef{Name: "name"}}}
+	keys, sort, err := convertOrderBy(items, newScope())
+	require.NoError(t, err)
+	require.Equal(t, mongo.Document{{Key: "_order1", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{"$vip", int64(0), int64(1)}}}}}, keys)
+	require.Equal(t, mongo.Document{{Key: "_order1", Value: int64(1)}, {Key: "name", Value: int64(-1)}}, sort)
+}
+
+func TestConvertSetOrderBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []sql.OrderItem
+		names   []string
+		want    []mongo.Document
+		wantErr bool
+	}{
+		{
+			name:  "result column",
+			items: []sql.OrderItem{{Expr: &sql.Literal{Kind: sql.NumberLiteral, Value: "2"}, Desc: true}},
+			names: []string{"name", "city"},
+			want:  []mongo.Document{{{Key: "$sort", Value: mongo.Document{{Key: "city", Value: int64(-1)}}}}},
+		},
+		{
+			name: "computed key",
+			items: []sql.OrderItem{{Expr: &sql.CaseExpr{
+				Operand: &sql.ColumnRef{Name: "city"},
+				Whens:   []sql.WhenClause{{Cond: &sql.Literal{Kind: sql.StringLiteral, Value: "Paris"}, Result: &sql.Literal{Kind: sql.NumberLiteral, Value: "0"}}},
+				Else:    &sql.Literal{Kind: sql.NumberLiteral, Value: "1"},
+			}}},
+			names: []string{"city"},
+			want: []mongo.Document{
+				{{Key: "$set", Value: mongo.Document{{Key: "_order1", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{
+					mongo.Document{{Key: "$eq", Value: mongo.Array{"$city", "Paris"}}}, int64(0), int64(1),
+				}}}}}}},
+				{{Key: "$sort", Value: mongo.Document{{Key: "_order1", Value: int64(1)}}}},
+				{{Key: "$unset", Value: mongo.Array{"_order1"}}},
+			},
+		},
+		{
+			name:    "not a result column",
+			items:   []sql.OrderItem{{Expr: &sql.ColumnRef{Name: "age"}}},
+			names:   []string{"name"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertSetOrderBy(tt.items, tt.names, Options{})
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
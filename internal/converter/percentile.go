@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// percentileFunctions lists the aggregate functions that read a percentile of the values of a group
+var percentileFunctions = map[string]bool{"PERCENTILE_CONT": true, "PERCENTILE_DISC": true, "MEDIAN": true, "APPROX_PERCENTILE": true}
+
+// percentileCall is a percentile aggregate call resolved to the values it sorts and the percentile
+// it reads from them
+type percentileCall struct {
+	value      sql.Expr
+	percentile float64
+	desc       bool
+	// continuous percentiles interpolate between the two values nearest to their position
+	continuous bool
+}
+
+// resolvePercentile reads the values, percentile and sort direction of a percentile call. MEDIAN is
+// the continuous 50th percentile, and APPROX_PERCENTILE takes the values first and an optional
+// accuracy last, which is ignored
+func resolvePercentile(call *sql.FuncCall) (percentileCall, error) {
+	name := strings.ToUpper(call.Name)
+	if call.Distinct {
+		return percentileCall{}, fmt.Errorf("%s does not accept DISTINCT", name)
+	}
+	var result percentileCall
+	var percentile sql.Expr
+	switch name {
+	case "MEDIAN":
+		if len(call.Args) != 1 || len(call.OrderBy) > 0 {
+			return percentileCall{}, fmt.Errorf("MEDIAN expects one argument")
+		}
+		return percentileCall{value: call.Args[0], percentile: 0.5, continuous: true}, nil
+	case "APPROX_PERCENTILE":
+		if len(call.Args) < 2 || len(call.Args) > 3 || len(call.OrderBy) > 0 {
+			return percentileCall{}, fmt.Errorf("APPROX_PERCENTILE expects a value, a percentile and an optional accuracy")
+		}
+		result.value, percentile = call.Args[0], call.Args[1]
+	default:
+		if len(call.Args) != 1 || len(call.OrderBy) != 1 {
+			return percentileCall{}, fmt.Errorf("%s expects a percentile and WITHIN GROUP (ORDER BY value)", name)
+		}
+		percentile = call.Args[0]
+		result.value, result.desc = call.OrderBy[0].Expr, call.OrderBy[0].Desc
+		result.continuous = name == "PERCENTILE_CONT"
+	}
+	literal, ok := percentile.(*sql.Literal)
+	if !ok || literal.Kind != sql.NumberLiteral {
+		return percentileCall{}, fmt.Errorf("%s expects a constant percentile but got %s", name, percentile)
+	}
+	value, err := strconv.ParseFloat(literal.Value, 64)
+	if err != nil || value < 0 || value > 1 {
+		return percentileCall{}, fmt.Errorf("%s expects a percentile between 0 and 1 but got %s", name, percentile)
+	}
+	result.percentile = value
+	return result, nil
+}
+
+// convertPercentile converts a percentile call to a $median or $percentile accumulator, which compute
+// approximate percentiles from MongoDB 7.0, or for servers from 5.2 to a $push of its values and a
+// finalizer that sorts them with $sortArray and reads the percentile from the sorted values
+func (g *groupStage) convertPercentile(call *sql.FuncCall, field string) (interface{}, interface{}, error) {
+	percentile, err := resolvePercentile(call)
+	if err != nil {
+		return nil, nil, err
+	}
+	filter, err := g.convertAggregateFilter(call)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := g.convertAggregateArg(percentile.value, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !g.input.options.supports(5, 2) {
+		return nil, nil, fmt.Errorf("%s requires MongoDB 5.2 or later, which added $sortArray", strings.ToUpper(call.Name))
+	}
+	if !g.input.options.supports(7, 0) {
+		return mongo.Document{{Key: "$push", Value: value}}, sortedPercentile(field, percentile), nil
+	}
+
+	name := strings.ToUpper(call.Name)
+	if name != "APPROX_PERCENTILE" {
+		g.warn(fmt.Sprintf("%s is computed with the approximate method of $percentile, which can differ from the exact percentile of large groups", name))
+	}
+	p := percentile.percentile
+	if percentile.desc {
+		// the percentile of values sorted in descending order, rounded to drop the error of the subtraction
+		p = math.Round((1-p)*1e12) / 1e12
+	}
+	if p == 0.5 {
+		median := mongo.Document{{Key: "input", Value: value}, {Key: "method", Value: "approximate"}}
+		return mongo.Document{{Key: "$median", Value: median}}, nil, nil
+	}
+	accumulator := mongo.Document{{Key: "input", Value: value}, {Key: "p", Value: mongo.Array{p}}, {Key: "method", Value: "approximate"}}
+	return mongo.Document{{Key: "$percentile", Value: accumulator}}, mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$" + field, int64(0)}}}, nil
+}
+
+// sortedPercentile returns the expression that reads a percentile of the values pushed to a field,
+// sorting the values that are not null and reading the first value whose cumulative distribution
+// reaches the percentile or, for a continuous percentile, interpolating between the two values
+// nearest to its position
+func sortedPercentile(field string, percentile percentileCall) mongo.Document {
+	direction := int64(1)
+	if percentile.desc {
+		direction = -1
+	}
+	present := mongo.Document{{Key: "$filter", Value: mongo.Document{
+		{Key: "input", Value: "$" + field},
+		{Key: "cond", Value: mongo.Document{{Key: "$ne", Value: mongo.Array{"$$this", nil}}}},
+	}}}
+	sorted := mongo.Document{{Key: "$sortArray", Value: mongo.Document{{Key: "input", Value: present}, {Key: "sortBy", Value: direction}}}}
+	size := mongo.Document{{Key: "$size", Value: "$$values"}}
+
+	var in mongo.Document
+	if percentile.continuous {
+		position := mongo.Document{{Key: "$multiply", Value: mongo.Array{percentile.percentile, mongo.Document{{Key: "$subtract", Value: mongo.Array{size, int64(1)}}}}}}
+		floor := mongo.Document{{Key: "$floor", Value: "$$position"}}
+		lower := mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$$values", floor}}}
+		upper := mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$$values", mongo.Document{{Key: "$ceil", Value: "$$position"}}}}}
+		fraction := mongo.Document{{Key: "$subtract", Value: mongo.Array{"$$position", floor}}}
+		difference := mongo.Document{{Key: "$subtract", Value: mongo.Array{upper, lower}}}
+		interpolated := mongo.Document{{Key: "$add", Value: mongo.Array{lower, mongo.Document{{Key: "$multiply", Value: mongo.Array{difference, fraction}}}}}}
+		in = mongo.Document{{Key: "$let", Value: mongo.Document{
+			{Key: "vars", Value: mongo.Document{{Key: "position", Value: position}}},
+			{Key: "in", Value: interpolated},
+		}}}
+	} else {
+		rank := mongo.Document{{Key: "$ceil", Value: mongo.Document{{Key: "$multiply", Value: mongo.Array{percentile.percentile, size}}}}}
+		index := mongo.Document{{Key: "$max", Value: mongo.Array{int64(0), mongo.Document{{Key: "$subtract", Value: mongo.Array{rank, int64(1)}}}}}}
+		in = mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$$values", index}}}
+	}
+	return mongo.Document{{Key: "$let", Value: mongo.Document{
+		{Key: "vars", Value: mongo.Document{{Key: "values", Value: sorted}}},
+		{Key: "in", Value: in},
+	}}}
+}
+
+// warn adds a warning to the query unless it already has it
+func (g *groupStage) warn(warning string) {
+	for _, existing := range g.warnings {
+		if existing == warning {
+			return
+		}
+	}
+	g.warnings = append(g.warnings, warning)
+}
@@ -0,0 +1,128 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertPercentile(t *testing.T) {
+	ms := &sql.ColumnRef{Name: "ms"}
+	number := func(value string) *sql.Literal { return &sql.Literal{Kind: sql.NumberLiteral, Value: value} }
+	values := mongo.Document{{Key: "$sortArray", Value: mongo.Document{
+		{Key: "input", Value: mongo.Document{{Key: "$filter", Value: mongo.Document{
+			{Key: "input", Value: "$p"},
+			{Key: "cond", Value: mongo.Document{{Key: "$ne", Value: mongo.Array{"$$this", nil}}}},
+		}}}},
+		{Key: "sortBy", Value: int64(-1)},
+	}}}
+	tests := []struct {
+		name          string
+		call          *sql.FuncCall
+		version       string
+		wantAggregate interface{}
+		wantFinalizer interface{}
+		wantWarnings  int
+		wantErr       bool
+	}{
+		{
+			name: "percentile",
+			call: &sql.FuncCall{Name: "percentile_cont", Args: []sql.Expr{number("0.95")}, OrderBy: []sql.OrderItem{{Expr: ms}}},
+			wantAggregate: mongo.Document{{Key: "$percentile", Value: mongo.Document{
+				{Key: "input", Value: "$ms"}, {Key: "p", Value: mongo.Array{0.95}}, {Key: "method", Value: "approximate"},
+			}}},
+			wantFinalizer: mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$p", int64(0)}}},
+			wantWarnings:  1,
+		},
+		{
+			name: "descending percentile",
+			call: &sql.FuncCall{Name: "PERCENTILE_CONT", Args: []sql.Expr{number("0.95")}, OrderBy: []sql.OrderItem{{Expr: ms, Desc: true}}},
+			wantAggregate: mongo.Document{{Key: "$percentile", Value: mongo.Document{
+				{Key: "input", Value: "$ms"}, {Key: "p", Value: mongo.Array{0.05}}, {Key: "method", Value: "approximate"},
+			}}},
+			wantFinalizer: mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$p", int64(0)}}},
+			wantWarnings:  1,
+		},
+		{
+			name: "approximate percentile",
+			call: &sql.FuncCall{Name: "APPROX_PERCENTILE", Args: []sql.Expr{ms, number("0.95")}},
+			wantAggregate: mongo.Document{{Key: "$percentile", Value: mongo.Document{
+				{Key: "input", Value: "$ms"}, {Key: "p", Value: mongo.Array{0.95}}, {Key: "method", Value: "approximate"},
+			}}},
+			wantFinalizer: mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$p", int64(0)}}},
+		},
+		{
+			name:    "median",
+			call:    &sql.FuncCall{Name: "MEDIAN", Args: []sql.Expr{ms}},
+			version: "7.0",
+			wantAggregate: mongo.Document{{Key: "$median", Value: mongo.Document{
+				{Key: "input", Value: "$ms"}, {Key: "method", Value: "approximate"},
+			}}},
+			wantWarnings: 1,
+		},
+		{
+			name:          "discrete percentile before 7.0",
+			call:          &sql.FuncCall{Name: "PERCENTILE_DISC", Args: []sql.Expr{number("0.9")}, OrderBy: []sql.OrderItem{{Expr: ms, Desc: true}}},
+			version:       "6.0",
+			wantAggregate: mongo.Document{{Key: "$push", Value: "$ms"}},
+			wantFinalizer: mongo.Document{{Key: "$let", Value: mongo.Document{
+				{Key: "vars", Value: mongo.Document{{Key: "values", Value: values}}},
+				{Key: "in", Value: mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$$values", mongo.Document{{Key: "$max", Value: mongo.Array{
+					int64(0),
+					mongo.Document{{Key: "$subtract", Value: mongo.Array{
+						mongo.Document{{Key: "$ceil", Value: mongo.Document{{Key: "$multiply", Value: mongo.Array{0.9, mongo.Document{{Key: "$size", Value: "$$values"}}}}}}},
+						int64(1),
+					}}},
+				}}}}}}},
+			}}},
+		},
+		{
+			name:    "percentile before 5.2",
+			call:    &sql.FuncCall{Name: "MEDIAN", Args: []sql.Expr{ms}},
+			version: "5.0",
+			wantErr: true,
+		},
+		{
+			name:    "percentile without an order",
+			call:    &sql.FuncCall{Name: "PERCENTILE_CONT", Args: []sql.Expr{number("0.5")}},
+			wantErr: true,
+		},
+		{
+			name:    "percentile out of range",
+			call:    &sql.FuncCall{Name: "APPROX_PERCENTILE", Args: []sql.Expr{ms, number("95")}},
+			wantErr: true,
+		},
+		{
+			name:    "percentile of a column",
+			call:    &sql.FuncCall{Name: "PERCENTILE_CONT", Args: []sql.Expr{ms}, OrderBy: []sql.OrderItem{{Expr: ms}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := newScope()
+			input.options = Options{ServerVersion: tt.version}
+			group := newGroupStage(input)
+			aggregate, finalizer, err := group.convertAggregate(tt.call, "p")
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAggregate, aggregate)
+			require.Equal(t, tt.wantFinalizer, finalizer)
+			require.Len(t, group.warnings, tt.wantWarnings)
+		})
+	}
+}
+
+func TestOptionsSupports(t *testing.T) {
+	require.True(t, Options{}.supports(7, 0))
+	require.True(t, Options{ServerVersion: "7.0.2"}.supports(7, 0))
+	require.True(t, Options{ServerVersion: "8.0"}.supports(7, 0))
+	require.False(t, Options{ServerVersion: "6.3"}.supports(7, 0))
+	_, _, err := parseServerVersion("seven")
+	require.Error(t, err)
+}
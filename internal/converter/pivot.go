@@ -0,0 +1,117 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// rewritePivot rewrites a query that reads pivoted rows to a query that reads them from a derived
+// table, which groups the rows by their remaining columns and aggregates, for each pivot value, the
+// rows whose pivot column holds it. The remaining columns are those of a derived table the pivot
+// reads from, or otherwise the columns the query refers to besides the pivoted ones
+func rewritePivot(query sql.Query) (sql.Query, error) {
+	pivot := query.Pivot
+	if len(query.Joins) > 0 {
+		return sql.Query{}, fmt.Errorf("PIVOT can not be combined with JOIN")
+	}
+	if len(query.GroupBy) > 0 || query.GroupingSets != nil || query.Having != nil {
+		return sql.Query{}, fmt.Errorf("PIVOT can not be combined with GROUP BY or HAVING")
+	}
+
+	pivoted := map[string]bool{}
+	var projections []sql.Projection
+	for _, value := range pivot.Values {
+		if pivoted[value.Name] {
+			return sql.Query{}, fmt.Errorf("PIVOT column %s is listed more than once", value.Name)
+		}
+		pivoted[value.Name] = true
+		aggregate := *pivot.Aggregate
+		aggregate.Filter = &sql.BinaryExpr{Op: "=", Left: pivot.Column, Right: value.Value}
+		projections = append(projections, sql.Projection{Expr: &aggregate, Alias: value.Name})
+	}
+	aggregated := map[string]bool{pivot.Column.Name: true}
+	sql.Walk(pivot.Aggregate, func(e sql.Expr) bool {
+		if column, ok := e.(*sql.ColumnRef); ok {
+			aggregated[column.Name] = true
+		}
+		return true
+	})
+
+	var groups []string
+	if query.From != nil {
+		for _, projection := range query.From.Projections {
+			if _, ok := projection.Expr.(*sql.StarExpr); ok {
+				groups = nil
+				break
+			}
+			if name := projection.Name(); !aggregated[name] {
+				groups = append(groups, name)
+			}
+		}
+	}
+	if groups == nil {
+		seen := map[string]bool{}
+		for _, expr := range pivotReferences(query) {
+			switch e := expr.(type) {
+			case *sql.StarExpr:
+				return sql.Query{}, fmt.Errorf("PIVOT with SELECT * requires a derived table that names its columns")
+			case *sql.ColumnRef:
+				if !pivoted[e.Name] && !aggregated[e.Name] && !seen[e.Name] {
+					seen[e.Name] = true
+					groups = append(groups, e.Name)
+				}
+			}
+		}
+	}
+
+	inner := sql.Query{
+		Command:  sql.SQLSelect,
+		Database: query.Database,
+		Table:    query.Table,
+		Alias:    query.Alias,
+		From:     query.From,
+		Sample:   query.Sample,
+		Hint:     query.Hint,
+	}
+	for _, name := range groups {
+		column := &sql.ColumnRef{Name: name}
+		inner.Projections = append(inner.Projections, sql.Projection{Expr: column})
+		inner.GroupBy = append(inner.GroupBy, column)
+	}
+	inner.Projections = append(inner.Projections, projections...)
+	for _, projection := range inner.Projections {
+		inner.Columns = append(inner.Columns, projection.Name())
+	}
+
+	outer := query
+	outer.Pivot, outer.Sample, outer.Hint = nil, nil, ""
+	outer.Database, outer.Table, outer.From = "", "", &inner
+	outer.Alias = pivot.Alias
+	if outer.Alias == "" {
+		outer.Alias = query.Table
+	}
+	return outer, nil
+}
+
+// pivotReferences returns the column references and wildcards of the clauses that apply to pivoted rows
+func pivotReferences(query sql.Query) []sql.Expr {
+	exprs := []sql.Expr{query.Where}
+	for _, projection := range query.Projections {
+		exprs = append(exprs, projection.Expr)
+	}
+	for _, item := range query.OrderBy {
+		exprs = append(exprs, item.Expr)
+	}
+	var references []sql.Expr
+	for _, expr := range exprs {
+		sql.Walk(expr, func(e sql.Expr) bool {
+			switch e.(type) {
+			case *sql.ColumnRef, *sql.StarExpr:
+				references = append(references, e)
+			}
+			return true
+		})
+	}
+	return references
+}
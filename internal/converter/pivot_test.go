@@ -0,0 +1,48 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewritePivot(t *testing.T) {
+	amount := &sql.FuncCall{Name: "SUM", Args: []sql.Expr{&sql.ColumnRef{Name: "amount"}}}
+	quarter := &sql.ColumnRef{Name: "quarter"}
+	q1 := &sql.Literal{Kind: sql.StringLiteral, Value: "Q1"}
+	pivot := &sql.Pivot{Aggregate: amount, Column: quarter, Values: []sql.PivotValue{{Value: q1, Name: "Q1"}}, Alias: "p"}
+	filtered := &sql.FuncCall{Name: "SUM", Args: amount.Args, Filter: &sql.BinaryExpr{Op: "=", Left: quarter, Right: q1}}
+	region := &sql.ColumnRef{Name: "region"}
+
+	got, err := rewritePivot(sql.Query{
+		Command:     sql.SQLSelect,
+		Table:       "sales",
+		Projections: []sql.Projection{{Expr: region}, {Expr: &sql.ColumnRef{Name: "Q1"}}},
+		Where:       &sql.BinaryExpr{Op: ">", Left: &sql.ColumnRef{Name: "Q1"}, Right: &sql.Literal{Kind: sql.NumberLiteral, Value: "0"}},
+		Pivot:       pivot,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "p", got.Alias)
+	require.Nil(t, got.Pivot)
+	require.Equal(t, &sql.Query{
+		Command:     sql.SQLSelect,
+		Table:       "sales",
+		Columns:     []string{"region", "Q1"},
+		Projections: []sql.Projection{{Expr: region}, {Expr: filtered, Alias: "Q1"}},
+		GroupBy:     []sql.Expr{region},
+	}, got.From)
+
+	_, err = rewritePivot(sql.Query{Command: sql.SQLSelect, Table: "sales", Projections: []sql.Projection{{Expr: &sql.StarExpr{}}}, Pivot: pivot})
+	require.EqualError(t, err, "PIVOT with SELECT * requires a derived table that names its columns")
+
+	from := &sql.Query{
+		Command:     sql.SQLSelect,
+		Table:       "sales",
+		Projections: []sql.Projection{{Expr: region}, {Expr: quarter}, {Expr: &sql.ColumnRef{Name: "amount"}}},
+	}
+	got, err = rewritePivot(sql.Query{Command: sql.SQLSelect, From: from, Alias: "s", Projections: []sql.Projection{{Expr: &sql.StarExpr{}}}, Pivot: pivot})
+	require.NoError(t, err)
+	require.Equal(t, []sql.Expr{region}, got.From.GroupBy)
+	require.Equal(t, from, got.From.From)
+}
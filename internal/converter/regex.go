@@ -0,0 +1,583 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// regexFunctions maps the regular expression functions to the kind of value they return
+var regexFunctions = map[string]valueKind{
+	"REGEXP_LIKE":    booleanValue,
+	"REGEXP_REPLACE": stringValue,
+	"REGEXP_SUBSTR":  stringValue,
+	"REGEXP_MATCHES": anyValue,
+}
+
+// regexOperator is a regular expression match operator, such as the ~* of PostgreSQL
+type regexOperator struct {
+	insensitive bool
+	negated     bool
+}
+
+// regexOperators maps the regular expression match operators to how they match. REGEXP is the
+// MySQL spelling of ~
+var regexOperators = map[string]regexOperator{
+	"~":          {false, false},
+	"~*":         {true, false},
+	"!~":         {false, true},
+	"!~*":        {true, true},
+	"REGEXP":     {false, false},
+	"NOT REGEXP": {false, true},
+}
+
+// regex is a regular expression converted to the PCRE syntax MongoDB matches with
+type regex struct {
+	pattern string
+	options string
+	// groups is the number of capturing groups of the pattern
+	groups int
+	// global is set by the g flag, which makes REGEXP_REPLACE and REGEXP_MATCHES read every match
+	global bool
+}
+
+// regexWordBoundaries maps the word boundary escapes of PostgreSQL, which PCRE lacks, to PCRE
+var regexWordBoundaries = map[byte]string{'m': `\b(?=\w)`, 'M': `\b(?<=\w)`, 'y': `\b`, 'Y': `\B`, 'Z': `\z`}
+
+// translateRegex converts a POSIX, PostgreSQL or MySQL regular expression to PCRE, rewriting the
+// word boundaries PCRE spells differently and counting the capturing groups. It rejects the
+// constructs PCRE can not express, such as collating elements, and malformed patterns
+func translateRegex(pattern string) (regex, error) {
+	if strings.HasPrefix(pattern, "***") {
+		return regex{}, fmt.Errorf("regular expression directors are not supported: %s", pattern)
+	}
+	var result strings.Builder
+	var groups, depth int
+	for i := 0; i < len(pattern); i++ {
+		char := pattern[i]
+		switch {
+		case char == '\\':
+			if i+1 == len(pattern) {
+				return regex{}, fmt.Errorf("regular expression ends with a backslash: %s", pattern)
+			}
+			i++
+			if boundary, ok := regexWordBoundaries[pattern[i]]; ok {
+				result.WriteString(boundary)
+			} else {
+				result.WriteByte('\\')
+				result.WriteByte(pattern[i])
+			}
+		case strings.HasPrefix(pattern[i:], "[[:<:]]"):
+			result.WriteString(regexWordBoundaries['m'])
+			i += len("[[:<:]]") - 1
+		case strings.HasPrefix(pattern[i:], "[[:>:]]"):
+			result.WriteString(regexWordBoundaries['M'])
+			i += len("[[:>:]]") - 1
+		case char == '[':
+			end, err := bracketEnd(pattern, i)
+			if err != nil {
+				return regex{}, err
+			}
+			result.WriteString(pattern[i : end+1])
+			i = end
+		case char == '{':
+			if min, max, ok := quantifierBounds(pattern[i:]); ok && max >= 0 && min > max {
+				return regex{}, fmt.Errorf("regular expression has a quantifier whose minimum exceeds its maximum: %s", pattern)
+			}
+			result.WriteByte(char)
+		case char == '(':
+			depth++
+			rest := pattern[i+1:]
+			if !strings.HasPrefix(rest, "?") || strings.HasPrefix(rest, "?<") && !strings.HasPrefix(rest, "?<=") && !strings.HasPrefix(rest, "?<!") ||
+				strings.HasPrefix(rest, "?P<") || strings.HasPrefix(rest, "?'") {
+				groups++
+			}
+			result.WriteByte(char)
+		case char == ')':
+			if depth--; depth < 0 {
+				return regex{}, fmt.Errorf("regular expression has an unmatched ): %s", pattern)
+			}
+			result.WriteByte(char)
+		default:
+			result.WriteByte(char)
+		}
+	}
+	if depth > 0 {
+		return regex{}, fmt.Errorf("regular expression has an unmatched (: %s", pattern)
+	}
+	return regex{pattern: result.String(), groups: groups}, nil
+}
+
+// quantifierBounds reads the bounds of a {m}, {m,} or {m,n} quantifier at the start of a pattern,
+// returning -1 as the maximum of an unbounded quantifier and false for a { that starts no quantifier
+func quantifierBounds(pattern string) (int, int, bool) {
+	end := strings.IndexByte(pattern, '}')
+	if end == -1 {
+		return 0, 0, false
+	}
+	bounds := strings.SplitN(pattern[1:end], ",", 2)
+	min, err := strconv.Atoi(bounds[0])
+	if err != nil || min < 0 {
+		return 0, 0, false
+	}
+	if len(bounds) == 1 {
+		return min, min, true
+	}
+	if bounds[1] == "" {
+		return min, -1, true
+	}
+	max, err := strconv.Atoi(bounds[1])
+	if err != nil || max < 0 {
+		return 0, 0, false
+	}
+	return min, max, true
+}
+
+// bracketEnd returns the position of the ] closing the bracket expression that starts at a
+// position of a pattern, where a ] right after the [ or [^ is a member of the expression
+func bracketEnd(pattern string, start int) (int, error) {
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+	for ; i < len(pattern); i++ {
+		switch {
+		case pattern[i] == ']':
+			return i, nil
+		case pattern[i] == '\\':
+			i++
+		case strings.HasPrefix(pattern[i:], "[.") || strings.HasPrefix(pattern[i:], "[="):
+			return 0, fmt.Errorf("collating elements and equivalence classes are not supported by MongoDB: %s", pattern)
+		case strings.HasPrefix(pattern[i:], "[:"):
+			end := strings.Index(pattern[i+2:], ":]")
+			if end == -1 {
+				return 0, fmt.Errorf("regular expression has an unterminated character class: %s", pattern)
+			}
+			i += end + 3
+		}
+	}
+	return 0, fmt.Errorf("regular expression has an unterminated bracket expression: %s", pattern)
+}
+
+// regexFlags converts the match flags of a regular expression function to MongoDB regex
+// options. i and c turn case-insensitive matching on and off, the last one winning, m makes ^ and
+// $ match at line breaks, n lets . match line breaks, x ignores whitespace in the pattern and g
+// reads every match
+func regexFlags(name string, flags string, r regex) (regex, error) {
+	options := map[byte]bool{}
+	for _, option := range r.options {
+		options[byte(option)] = true
+	}
+	for i := 0; i < len(flags); i++ {
+		switch flags[i] {
+		case 'i':
+			options['i'] = true
+		case 'c':
+			options['i'] = false
+		case 'm', 'x':
+			options[flags[i]] = true
+		case 'n':
+			options['s'] = true
+		case 'g':
+			if name != "REGEXP_REPLACE" && name != "REGEXP_MATCHES" {
+				return regex{}, fmt.Errorf("%s does not accept the g flag", name)
+			}
+			r.global = true
+		default:
+			return regex{}, fmt.Errorf("unsupported regular expression flag %q in %s", flags[i], name)
+		}
+	}
+	r.options = ""
+	for _, option := range "imsx" {
+		if options[byte(option)] {
+			r.options += string(option)
+		}
+	}
+	return r, nil
+}
+
+// constantString returns the value of a string literal, reporting false for any other expression
+func constantString(expr sql.Expr) (string, bool) {
+	literal, ok := expr.(*sql.Literal)
+	if !ok || literal.Kind != sql.StringLiteral {
+		return "", false
+	}
+	return literal.Value, true
+}
+
+// constantInteger returns the value of an integer literal of at least a minimum, reporting false
+// for any other expression
+func constantInteger(expr sql.Expr, min int64) (int64, bool) {
+	literal, ok := expr.(*sql.Literal)
+	if !ok || literal.Kind != sql.NumberLiteral {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(literal.Value, 10, 64)
+	return value, err == nil && value >= min
+}
+
+// stringExpression returns a string as an aggregation expression, wrapping a string that starts
+// with $ in $literal so it is not read as a field path
+func stringExpression(value string) interface{} {
+	if strings.HasPrefix(value, "$") {
+		return mongo.Document{{Key: "$literal", Value: value}}
+	}
+	return value
+}
+
+// resolveRegex translates the constant pattern and flags of a regular expression match
+func resolveRegex(name string, pattern sql.Expr, flags sql.Expr) (regex, error) {
+	value, ok := constantString(pattern)
+	if !ok {
+		return regex{}, fmt.Errorf("%s expects a constant pattern but got %s", name, pattern)
+	}
+	r, err := translateRegex(value)
+	if err != nil {
+		return regex{}, err
+	}
+	if flags == nil {
+		return r, nil
+	}
+	value, ok = constantString(flags)
+	if !ok {
+		return regex{}, fmt.Errorf("%s expects constant flags but got %s", name, flags)
+	}
+	return regexFlags(name, value, r)
+}
+
+// regexMatch resolves a regular expression match operation or a REGEXP_LIKE call to the value it
+// matches, the regular expression and whether the match is negated, reporting false for any other
+// expression
+func regexMatch(expr sql.Expr) (sql.Expr, regex, bool, bool, error) {
+	switch e := expr.(type) {
+	case *sql.BinaryExpr:
+		op, ok := regexOperators[e.Op]
+		if !ok {
+			return nil, regex{}, false, false, nil
+		}
+		r, err := resolveRegex(e.Op, e.Right, nil)
+		if op.insensitive {
+			r.options = "i"
+		}
+		return e.Left, r, op.negated, true, err
+	case *sql.FuncCall:
+		if !strings.EqualFold(e.Name, "REGEXP_LIKE") || e.Over != nil {
+			return nil, regex{}, false, false, nil
+		}
+		if len(e.Args) < 2 || len(e.Args) > 3 {
+			return nil, regex{}, false, true, fmt.Errorf("REGEXP_LIKE expects a value, a pattern and optional flags")
+		}
+		var flags sql.Expr
+		if len(e.Args) == 3 {
+			flags = e.Args[2]
+		}
+		r, err := resolveRegex("REGEXP_LIKE", e.Args[1], flags)
+		return e.Args[0], r, false, true, err
+	}
+	return nil, regex{}, false, false, nil
+}
+
+// convertRegexFilter converts a regular expression match of a field to a $regex query filter,
+// reporting false if the match has some other shape
+func convertRegexFilter(expr sql.Expr, s *scope) (mongo.Document, bool, error) {
+	input, r, negated, ok, err := regexMatch(expr)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	field, ok := s.queryPath(input)
+	if !ok {
+		return nil, false, nil
+	}
+	condition := mongo.Document{{Key: "$regex", Value: r.pattern}}
+	if r.options != "" {
+		condition = append(condition, mongo.Element{Key: "$options", Value: r.options})
+	}
+	if negated {
+		condition = mongo.Document{{Key: "$not", Value: condition}}
+	}
+	return mongo.Document{{Key: field, Value: condition}}, true, nil
+}
+
+// convertRegexMatch converts a regular expression match to $regexMatch
+func convertRegexMatch(expr sql.Expr, s *scope) (interface{}, error) {
+	input, r, negated, _, err := regexMatch(expr)
+	if err != nil {
+		return nil, err
+	}
+	value, err := convertExpression(input, s)
+	if err != nil {
+		return nil, err
+	}
+	match := mongo.Document{{Key: "$regexMatch", Value: regexOperand(value, r)}}
+	if negated {
+		return mongo.Document{{Key: "$not", Value: mongo.Array{match}}}, nil
+	}
+	return match, nil
+}
+
+// regexOperand returns the operand of $regexMatch, $regexFind and $regexFindAll
+func regexOperand(input interface{}, r regex) mongo.Document {
+	operand := mongo.Document{{Key: "input", Value: input}, {Key: "regex", Value: stringExpression(r.pattern)}}
+	if r.options != "" {
+		operand = append(operand, mongo.Element{Key: "options", Value: r.options})
+	}
+	return operand
+}
+
+// convertRegexFunction converts a call to a regular expression function
+func convertRegexFunction(call *sql.FuncCall, s *scope) (interface{}, error) {
+	name := strings.ToUpper(call.Name)
+	switch name {
+	case "REGEXP_LIKE":
+		return convertRegexMatch(call, s)
+	case "REGEXP_REPLACE":
+		return convertRegexReplace(call, s)
+	case "REGEXP_SUBSTR":
+		return convertRegexSubstr(call, s)
+	default:
+		return convertRegexMatches(call, s)
+	}
+}
+
+// replacementPart is a part of the replacement of REGEXP_REPLACE: a string, or the text of a
+// capturing group, 0 being the whole match
+type replacementPart struct {
+	text  string
+	group int
+}
+
+// parseReplacement splits the replacement of REGEXP_REPLACE into its strings and its references
+// to capturing groups, written \1 to \9, and to the whole match, written \&
+func parseReplacement(replacement string, groups int) ([]replacementPart, error) {
+	var parts []replacementPart
+	var text strings.Builder
+	for i := 0; i < len(replacement); i++ {
+		if replacement[i] != '\\' || i+1 == len(replacement) {
+			text.WriteByte(replacement[i])
+			continue
+		}
+		i++
+		group := -1
+		switch next := replacement[i]; {
+		case next == '&':
+			group = 0
+		case next >= '1' && next <= '9':
+			if group = int(next - '0'); group > groups {
+				return nil, fmt.Errorf("REGEXP_REPLACE refers to group %d of a pattern with %d %s", group, groups, plural(groups, "group"))
+			}
+		default:
+			text.WriteByte(next)
+			continue
+		}
+		if text.Len() > 0 {
+			parts = append(parts, replacementPart{text: text.String(), group: -1})
+			text.Reset()
+		}
+		parts = append(parts, replacementPart{group: group})
+	}
+	if text.Len() > 0 {
+		parts = append(parts, replacementPart{text: text.String(), group: -1})
+	}
+	return parts, nil
+}
+
+// replacementValue returns the expression of a replacement for the match held by a variable
+func replacementValue(parts []replacementPart, match string) interface{} {
+	values := mongo.Array{}
+	for _, part := range parts {
+		switch part.group {
+		case -1:
+			values = append(values, stringExpression(part.text))
+		case 0:
+			values = append(values, match+".match")
+		default:
+			group := mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{match + ".captures", int64(part.group - 1)}}}
+			values = append(values, mongo.Document{{Key: "$ifNull", Value: mongo.Array{group, ""}}})
+		}
+	}
+	switch len(values) {
+	case 0:
+		return ""
+	case 1:
+		return values[0]
+	}
+	return mongo.Document{{Key: "$concat", Value: values}}
+}
+
+// isLiteralRegex checks if a regular expression only matches its own text
+func isLiteralRegex(r regex) bool {
+	return r.options == "" && !strings.ContainsAny(r.pattern, `\.+*?()|[]{}^$`)
+}
+
+// convertRegexReplace converts REGEXP_REPLACE, which replaces the first match or, with the g flag,
+// every match as in PostgreSQL. A pattern without special characters is replaced with $replaceOne
+// or $replaceAll, and any other pattern by rebuilding the string around the matches that
+// $regexFind or $regexFindAll return
+func convertRegexReplace(call *sql.FuncCall, s *scope) (interface{}, error) {
+	if len(call.Args) < 3 || len(call.Args) > 4 {
+		return nil, fmt.Errorf("REGEXP_REPLACE expects a value, a pattern, a replacement and optional flags")
+	}
+	var flags sql.Expr
+	if len(call.Args) == 4 {
+		if _, ok := constantString(call.Args[3]); !ok {
+			return nil, fmt.Errorf("REGEXP_REPLACE only accepts flags after the replacement, not positions or occurrences: %s", call)
+		}
+		flags = call.Args[3]
+	}
+	r, err := resolveRegex("REGEXP_REPLACE", call.Args[1], flags)
+	if err != nil {
+		return nil, err
+	}
+	replacement, ok := constantString(call.Args[2])
+	if !ok {
+		return nil, fmt.Errorf("REGEXP_REPLACE expects a constant replacement but got %s", call.Args[2])
+	}
+	parts, err := parseReplacement(replacement, r.groups)
+	if err != nil {
+		return nil, err
+	}
+	input, err := convertExpression(call.Args[0], s)
+	if err != nil {
+		return nil, err
+	}
+
+	literal := len(parts) == 0 || len(parts) == 1 && parts[0].group == -1
+	if isLiteralRegex(r) && r.pattern != "" && literal {
+		op := "$replaceOne"
+		if r.global {
+			op = "$replaceAll"
+		}
+		return mongo.Document{{Key: op, Value: mongo.Document{
+			{Key: "input", Value: input},
+			{Key: "find", Value: stringExpression(r.pattern)},
+			{Key: "replacement", Value: stringExpression(replacement)},
+		}}}, nil
+	}
+
+	length := mongo.Document{{Key: "$strLenCP", Value: input}}
+	if !r.global {
+		before := mongo.Document{{Key: "$substrCP", Value: mongo.Array{input, int64(0), "$$match.idx"}}}
+		end := mongo.Document{{Key: "$add", Value: mongo.Array{"$$match.idx", mongo.Document{{Key: "$strLenCP", Value: "$$match.match"}}}}}
+		after := mongo.Document{{Key: "$substrCP", Value: mongo.Array{input, end, length}}}
+		replaced := mongo.Document{{Key: "$concat", Value: mongo.Array{before, replacementValue(parts, "$$match"), after}}}
+		return mongo.Document{{Key: "$let", Value: mongo.Document{
+			{Key: "vars", Value: mongo.Document{{Key: "match", Value: mongo.Document{{Key: "$regexFind", Value: regexOperand(input, r)}}}}},
+			{Key: "in", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{mongo.Document{{Key: "$eq", Value: mongo.Array{"$$match", nil}}}, input, replaced}}}},
+		}}}, nil
+	}
+
+	// each step appends the text before a match and its replacement, and moves past the match
+	gap := mongo.Document{{Key: "$substrCP", Value: mongo.Array{input, "$$value.end", mongo.Document{{Key: "$subtract", Value: mongo.Array{"$$this.idx", "$$value.end"}}}}}}
+	step := mongo.Document{
+		{Key: "text", Value: mongo.Document{{Key: "$concat", Value: mongo.Array{"$$value.text", gap, replacementValue(parts, "$$this")}}}},
+		{Key: "end", Value: mongo.Document{{Key: "$add", Value: mongo.Array{"$$this.idx", mongo.Document{{Key: "$strLenCP", Value: "$$this.match"}}}}}},
+	}
+	replaced := mongo.Document{{Key: "$reduce", Value: mongo.Document{
+		{Key: "input", Value: mongo.Document{{Key: "$regexFindAll", Value: regexOperand(input, r)}}},
+		{Key: "initialValue", Value: mongo.Document{{Key: "text", Value: ""}, {Key: "end", Value: int64(0)}}},
+		{Key: "in", Value: step},
+	}}}
+	rest := mongo.Document{{Key: "$substrCP", Value: mongo.Array{input, "$$replaced.end", length}}}
+	result := mongo.Document{{Key: "$let", Value: mongo.Document{
+		{Key: "vars", Value: mongo.Document{{Key: "replaced", Value: replaced}}},
+		{Key: "in", Value: mongo.Document{{Key: "$concat", Value: mongo.Array{"$$replaced.text", rest}}}},
+	}}}
+	// $substrCP reads null as an empty string, so a null value is kept explicitly
+	isNull := mongo.Document{{Key: "$eq", Value: mongo.Array{mongo.Document{{Key: "$ifNull", Value: mongo.Array{input, nil}}}, nil}}}
+	return mongo.Document{{Key: "$cond", Value: mongo.Array{isNull, nil, result}}}, nil
+}
+
+// convertRegexSubstr converts REGEXP_SUBSTR(value, pattern [, position [, occurrence [, flags
+// [, group]]]]) to the match, or the capturing group, that $regexFind or $regexFindAll return
+func convertRegexSubstr(call *sql.FuncCall, s *scope) (interface{}, error) {
+	if len(call.Args) < 2 || len(call.Args) > 6 {
+		return nil, fmt.Errorf("REGEXP_SUBSTR expects a value, a pattern and an optional position, occurrence, flags and group")
+	}
+	// the position and occurrence count from 1, and group 0 is the whole match
+	numbers := []int64{0, 0, 1, 1, 0, 0}
+	for _, i := range []int{2, 3, 5} {
+		if i >= len(call.Args) {
+			continue
+		}
+		value, ok := constantInteger(call.Args[i], numbers[i])
+		if !ok {
+			return nil, fmt.Errorf("REGEXP_SUBSTR expects a constant integer of at least %d as argument %d but got %s", numbers[i], i+1, call.Args[i])
+		}
+		numbers[i] = value
+	}
+	var flags sql.Expr
+	if len(call.Args) > 4 {
+		flags = call.Args[4]
+	}
+	r, err := resolveRegex("REGEXP_SUBSTR", call.Args[1], flags)
+	if err != nil {
+		return nil, err
+	}
+	position, occurrence, group := numbers[2], numbers[3], numbers[5]
+	if group > int64(r.groups) {
+		return nil, fmt.Errorf("REGEXP_SUBSTR reads group %d of a pattern with %d %s", group, r.groups, plural(r.groups, "group"))
+	}
+	input, err := convertExpression(call.Args[0], s)
+	if err != nil {
+		return nil, err
+	}
+	if position > 1 {
+		input = mongo.Document{{Key: "$substrCP", Value: mongo.Array{input, position - 1, stringLength(input)}}}
+	}
+
+	match := mongo.Document{{Key: "$regexFind", Value: regexOperand(input, r)}}
+	if occurrence > 1 {
+		match = mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{mongo.Document{{Key: "$regexFindAll", Value: regexOperand(input, r)}}, occurrence - 1}}}
+	}
+	var in interface{} = "$$match.match"
+	if group > 0 {
+		in = mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$$match.captures", group - 1}}}
+	}
+	return mongo.Document{{Key: "$let", Value: mongo.Document{
+		{Key: "vars", Value: mongo.Document{{Key: "match", Value: match}}},
+		{Key: "in", Value: in},
+	}}}, nil
+}
+
+// convertRegexMatches converts REGEXP_MATCHES, which returns the capturing groups of the first
+// match or, for a pattern without groups, the match itself. With the g flag it returns them for
+// every match, as one array rather than as a row per match
+func convertRegexMatches(call *sql.FuncCall, s *scope) (interface{}, error) {
+	if len(call.Args) < 2 || len(call.Args) > 3 {
+		return nil, fmt.Errorf("REGEXP_MATCHES expects a value, a pattern and optional flags")
+	}
+	var flags sql.Expr
+	if len(call.Args) == 3 {
+		flags = call.Args[2]
+	}
+	r, err := resolveRegex("REGEXP_MATCHES", call.Args[1], flags)
+	if err != nil {
+		return nil, err
+	}
+	input, err := convertExpression(call.Args[0], s)
+	if err != nil {
+		return nil, err
+	}
+	groups := func(match string) interface{} {
+		if r.groups > 0 {
+			return match + ".captures"
+		}
+		return mongo.Array{match + ".match"}
+	}
+	if r.global {
+		return mongo.Document{{Key: "$map", Value: mongo.Document{
+			{Key: "input", Value: mongo.Document{{Key: "$regexFindAll", Value: regexOperand(input, r)}}},
+			{Key: "in", Value: groups("$$this")},
+		}}}, nil
+	}
+	return mongo.Document{{Key: "$let", Value: mongo.Document{
+		{Key: "vars", Value: mongo.Document{{Key: "match", Value: mongo.Document{{Key: "$regexFind", Value: regexOperand(input, r)}}}}},
+		{Key: "in", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{mongo.Document{{Key: "$eq", Value: mongo.Array{"$$match", nil}}}, nil, groups("$$match")}}}},
+	}}}, nil
+}
@@ -0,0 +1,178 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateRegex(t *testing.T) {
+	tests := []struct {
+		name       string
+		pattern    string
+		want       string
+		wantGroups int
+		wantErr    bool
+	}{
+		{name: "plain", pattern: `^a.c$`, want: `^a.c$`},
+		{name: "word boundaries", pattern: `\mcat\M|\ydog\Y`, want: `\b(?=\w)cat\b(?<=\w)|\bdog\B`},
+		{name: "mysql word boundaries", pattern: `[[:<:]]cat[[:>:]]`, want: `\b(?=\w)cat\b(?<=\w)`},
+		{name: "end of string", pattern: `cat\Z`, want: `cat\z`},
+		{name: "bracket with a closing bracket", pattern: `[]a(]+`, want: `[]a(]+`},
+		{name: "character class", pattern: `[[:alpha:]_]+`, want: `[[:alpha:]_]+`},
+		{name: "groups", pattern: `(a)(?:b)(?<c>c)(?=d)\(`, want: `(a)(?:b)(?<c>c)(?=d)\(`, wantGroups: 2},
+		{name: "collating element", pattern: `[[.hyphen.]]`, wantErr: true},
+		{name: "equivalence class", pattern: `[[=e=]]`, wantErr: true},
+		{name: "director", pattern: `***:abc`, wantErr: true},
+		{name: "unterminated bracket", pattern: `[abc`, wantErr: true},
+		{name: "unmatched parenthesis", pattern: `a)`, wantErr: true},
+		{name: "trailing backslash", pattern: `a\`, wantErr: true},
+		{name: "quantifier range", pattern: `a{2,3}b{2,}c{2}d{x}`, want: `a{2,3}b{2,}c{2}d{x}`},
+		{name: "inverted quantifier range", pattern: `a{2,1}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := translateRegex(tt.pattern)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.pattern)
+			require.Equal(t, tt.wantGroups, got.groups)
+		})
+	}
+}
+
+func TestRegexFlags(t *testing.T) {
+	got, err := regexFlags("REGEXP_REPLACE", "ximgn", regex{})
+	require.NoError(t, err)
+	require.Equal(t, regex{options: "imsx", global: true}, got)
+
+	got, err = regexFlags("REGEXP_LIKE", "ic", regex{options: "i"})
+	require.NoError(t, err)
+	require.Equal(t, regex{}, got)
+
+	_, err = regexFlags("REGEXP_LIKE", "g", regex{})
+	require.Error(t, err)
+	_, err = regexFlags("REGEXP_LIKE", "u", regex{})
+	require.Error(t, err)
+}
+
+func TestConvertRegexFunction(t *testing.T) {
+	name := &sql.ColumnRef{Name: "name"}
+	str := func(value string) *sql.Literal { return &sql.Literal{Kind: sql.StringLiteral, Value: value} }
+	number := func(value string) *sql.Literal { return &sql.Literal{Kind: sql.NumberLiteral, Value: value} }
+	tests := []struct {
+		name    string
+		call    *sql.FuncCall
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "like",
+			call: &sql.FuncCall{Name: "regexp_like", Args: []sql.Expr{name, str("^a"), str("i")}},
+			want: mongo.Document{{Key: "$regexMatch", Value: mongo.Document{{Key: "input", Value: "$name"}, {Key: "regex", Value: "^a"}, {Key: "options", Value: "i"}}}},
+		},
+		{
+			name: "replace a literal pattern",
+			call: &sql.FuncCall{Name: "REGEXP_REPLACE", Args: []sql.Expr{name, str("-"), str("$"), str("g")}},
+			want: mongo.Document{{Key: "$replaceAll", Value: mongo.Document{
+				{Key: "input", Value: "$name"},
+				{Key: "find", Value: "-"},
+				{Key: "replacement", Value: mongo.Document{{Key: "$literal", Value: "$"}}},
+			}}},
+		},
+		{
+			name: "replace the first match",
+			call: &sql.FuncCall{Name: "REGEXP_REPLACE", Args: []sql.Expr{name, str(`(\d+)`), str(`<\1>`)}},
+			want: mongo.Document{{Key: "$let", Value: mongo.Document{
+				{Key: "vars", Value: mongo.Document{{Key: "match", Value: mongo.Document{{Key: "$regexFind", Value: mongo.Document{{Key: "input", Value: "$name"}, {Key: "regex", Value: `(\d+)`}}}}}}},
+				{Key: "in", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{
+					mongo.Document{{Key: "$eq", Value: mongo.Array{"$$match", nil}}},
+					"$name",
+					mongo.Document{{Key: "$concat", Value: mongo.Array{
+						mongo.Document{{Key: "$substrCP", Value: mongo.Array{"$name", int64(0), "$$match.idx"}}},
+						mongo.Document{{Key: "$concat", Value: mongo.Array{
+							"<",
+							mongo.Document{{Key: "$ifNull", Value: mongo.Array{mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$$match.captures", int64(0)}}}, ""}}},
+							">",
+						}}},
+						mongo.Document{{Key: "$substrCP", Value: mongo.Array{
+							"$name",
+							mongo.Document{{Key: "$add", Value: mongo.Array{"$$match.idx", mongo.Document{{Key: "$strLenCP", Value: "$$match.match"}}}}},
+							mongo.Document{{Key: "$strLenCP", Value: "$name"}},
+						}}},
+					}}},
+				}}}},
+			}}},
+		},
+		{
+			name: "substring of a group",
+			call: &sql.FuncCall{Name: "REGEXP_SUBSTR", Args: []sql.Expr{name, str("(a)(b)"), number("1"), number("2"), str("c"), number("2")}},
+			want: mongo.Document{{Key: "$let", Value: mongo.Document{
+				{Key: "vars", Value: mongo.Document{{Key: "match", Value: mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{
+					mongo.Document{{Key: "$regexFindAll", Value: mongo.Document{{Key: "input", Value: "$name"}, {Key: "regex", Value: "(a)(b)"}}}},
+					int64(1),
+				}}}}}},
+				{Key: "in", Value: mongo.Document{{Key: "$arrayElemAt", Value: mongo.Array{"$$match.captures", int64(1)}}}},
+			}}},
+		},
+		{
+			name: "substring from a position",
+			call: &sql.FuncCall{Name: "REGEXP_SUBSTR", Args: []sql.Expr{name, str("a+"), number("3")}},
+			want: mongo.Document{{Key: "$let", Value: mongo.Document{
+				{Key: "vars", Value: mongo.Document{{Key: "match", Value: mongo.Document{{Key: "$regexFind", Value: mongo.Document{
+					{Key: "input", Value: mongo.Document{{Key: "$substrCP", Value: mongo.Array{
+						"$name",
+						int64(2),
+						mongo.Document{{Key: "$strLenCP", Value: mongo.Document{{Key: "$ifNull", Value: mongo.Array{"$name", ""}}}}},
+					}}}},
+					{Key: "regex", Value: "a+"},
+				}}}}}},
+				{Key: "in", Value: "$$match.match"},
+			}}},
+		},
+		{
+			name: "every match",
+			call: &sql.FuncCall{Name: "REGEXP_MATCHES", Args: []sql.Expr{name, str(`\w+`), str("g")}},
+			want: mongo.Document{{Key: "$map", Value: mongo.Document{
+				{Key: "input", Value: mongo.Document{{Key: "$regexFindAll", Value: mongo.Document{{Key: "input", Value: "$name"}, {Key: "regex", Value: `\w+`}}}}},
+				{Key: "in", Value: mongo.Array{"$$this.match"}},
+			}}},
+		},
+		{
+			name:    "replace with a missing group",
+			call:    &sql.FuncCall{Name: "REGEXP_REPLACE", Args: []sql.Expr{name, str("a"), str(`\1`)}},
+			wantErr: true,
+		},
+		{
+			name:    "replace from a position",
+			call:    &sql.FuncCall{Name: "REGEXP_REPLACE", Args: []sql.Expr{name, str("a"), str("b"), number("2")}},
+			wantErr: true,
+		},
+		{
+			name:    "substring of occurrence zero",
+			call:    &sql.FuncCall{Name: "REGEXP_SUBSTR", Args: []sql.Expr{name, str("a"), number("1"), number("0")}},
+			wantErr: true,
+		},
+		{
+			name:    "pattern of a column",
+			call:    &sql.FuncCall{Name: "REGEXP_LIKE", Args: []sql.Expr{name, name}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertRegexFunction(tt.call, newScope())
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// randomFunctions lists the functions that return a random number, which ORDER BY can sort rows by to shuffle them
+var randomFunctions = map[string]bool{"RANDOM": true, "RAND": true}
+
+// convertRandom converts RANDOM and RAND, which return a number between 0 and 1
+func convertRandom(args mongo.Array) interface{} {
+	return mongo.Document{{Key: "$rand", Value: mongo.Document{}}}
+}
+
+// isRandomOrder checks if an ORDER BY only sorts rows by a random number, as ORDER BY RANDOM() does
+func isRandomOrder(items []sql.OrderItem) bool {
+	if len(items) != 1 {
+		return false
+	}
+	call, ok := items[0].Expr.(*sql.FuncCall)
+	return ok && call.Over == nil && len(call.Args) == 0 && randomFunctions[strings.ToUpper(call.Name)]
+}
+
+// limitStages returns the stage that keeps the first rows of a LIMIT or, for rows sorted randomly,
+// the $sample stage that picks as many rows at random without sorting them
+func limitStages(limit *int64, sample bool) []mongo.Document {
+	switch {
+	case limit == nil:
+		return nil
+	case sample:
+		return []mongo.Document{{{Key: "$sample", Value: mongo.Document{{Key: "size", Value: *limit}}}}}
+	default:
+		return []mongo.Document{{{Key: "$limit", Value: *limit}}}
+	}
+}
+
+// convertTableSample converts a TABLESAMPLE to a $sample stage whose size is the sampled percentage
+// of the estimated number of documents of the collection, as counted by the shell when it runs the
+// command. Both SYSTEM and BERNOULLI pick a random set of documents of that size
+func convertTableSample(sample *sql.TableSample, collection string) mongo.Document {
+	fraction := strconv.FormatFloat(sample.Percent/100, 'f', -1, 64)
+	size := mongo.Expression(fmt.Sprintf("Math.ceil(db.%s.estimatedDocumentCount() * %s)", collection, fraction))
+	return mongo.Document{{Key: "$sample", Value: mongo.Document{{Key: "size", Value: size}}}}
+}
+
+// sampleWarning returns the warning of a query that samples a collection with TABLESAMPLE
+func sampleWarning(collection string) string {
+	return fmt.Sprintf("TABLESAMPLE is emulated with $sample, whose size is computed by the shell from the estimated document count of %s", collection)
+}
@@ -0,0 +1,69 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertSampleQuery(t *testing.T) {
+	limit := int64(5)
+	name := []sql.Projection{{Expr: &sql.ColumnRef{Name: "name"}}}
+	tests := []struct {
+		name    string
+		query   sql.Query
+		want    []mongo.Document
+		wantErr bool
+	}{
+		{
+			name:  "tablesample",
+			query: sql.Query{Command: sql.SQLSelect, Table: "events", Projections: name, Sample: &sql.TableSample{Method: "BERNOULLI", Percent: 2.5}},
+			want: []mongo.Document{
+				{{Key: "$sample", Value: mongo.Document{{Key: "size", Value: mongo.Expression("Math.ceil(db.events.estimatedDocumentCount() * 0.025)")}}}},
+				{{Key: "$project", Value: mongo.Document{{Key: "name", Value: int64(1)}}}},
+			},
+		},
+		{
+			name:  "random order with limit",
+			query: sql.Query{Command: sql.SQLSelect, Table: "events", Projections: name, OrderBy: []sql.OrderItem{{Expr: &sql.FuncCall{Name: "rand"}}}, Limit: &limit},
+			want: []mongo.Document{
+				{{Key: "$sample", Value: mongo.Document{{Key: "size", Value: int64(5)}}}},
+				{{Key: "$project", Value: mongo.Document{{Key: "name", Value: int64(1)}}}},
+			},
+		},
+		{
+			name:  "random order without limit",
+			query: sql.Query{Command: sql.SQLSelect, Table: "events", Projections: name, OrderBy: []sql.OrderItem{{Expr: &sql.FuncCall{Name: "RANDOM"}}}},
+			want: []mongo.Document{
+				{{Key: "$set", Value: mongo.Document{{Key: "_order1", Value: mongo.Document{{Key: "$rand", Value: mongo.Document{}}}}}}},
+				{{Key: "$sort", Value: mongo.Document{{Key: "_order1", Value: int64(1)}}}},
+				{{Key: "$project", Value: mongo.Document{{Key: "name", Value: int64(1)}}}},
+			},
+		},
+		{
+			name: "tablesample of a derived table",
+			query: sql.Query{
+				Command:     sql.SQLSelect,
+				From:        &sql.Query{Command: sql.SQLSelect, Table: "events", Projections: name},
+				Alias:       "e",
+				Projections: name,
+				Sample:      &sql.TableSample{Method: "SYSTEM", Percent: 1},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertSQLQueryToMongoQuery(tt.query)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, mongo.MongoAggregate, got.Command)
+			require.Equal(t, tt.want, got.Pipeline)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// matchedField holds the rows of the other branch that an INTERSECT or EXCEPT looks up
+const matchedField = "_matched"
+
+// convertSetQuery converts a query combined with others by UNION, INTERSECT and EXCEPT to an
+// aggregation pipeline, naming the columns of every branch after the columns of the first and
+// sorting and limiting the combined rows by the ORDER BY and LIMIT of the query
+func convertSetQuery(query sql.Query, options Options) (mongo.Query, error) {
+	operations, order, limit := query.SetOperations, query.OrderBy, query.Limit
+	query.SetOperations, query.OrderBy, query.Limit = nil, nil, nil
+	names, err := setColumns(query)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	for i, operation := range operations {
+		columns, err := setColumns(operation.Query)
+		if err != nil {
+			return mongo.Query{}, err
+		}
+		if (names == nil) != (columns == nil) {
+			return mongo.Query{}, fmt.Errorf("%s with SELECT * requires every query to select *", operation.Operator)
+		}
+		if len(columns) != len(names) {
+			return mongo.Query{}, fmt.Errorf("each %s query must have the same number of columns: the first query selects %d but query %d selects %d",
+				operation.Operator, len(names), i+2, len(columns))
+		}
+	}
+
+	result, pipeline, err := convertBranch(query, names, options)
+	if err != nil {
+		return mongo.Query{}, err
+	}
+	for _, operation := range operations {
+		if operation.All && operation.Operator != sql.Union {
+			return mongo.Query{}, fmt.Errorf("%s ALL is not supported", operation.Operator)
+		}
+		branch, other, err := convertBranch(operation.Query, names, options)
+		if err != nil {
+			return mongo.Query{}, err
+		}
+		if branch.Database != result.Database {
+			return mongo.Query{}, fmt.Errorf("%s can not combine queries of different databases", operation.Operator)
+		}
+		result.Warnings = append(result.Warnings, branch.Warnings...)
+
+		if operation.Operator == sql.Union {
+			union := mongo.Document{{Key: "coll", Value: branch.Collections}}
+			if len(other) > 0 {
+				union = append(union, mongo.Element{Key: "pipeline", Value: stageArray(other)})
+			}
+			pipeline = append(pipeline, mongo.Document{{Key: "$unionWith", Value: union}})
+		} else {
+			pipeline = append(pipeline, convertMembership(operation.Operator, branch.Collections, other, names)...)
+		}
+		if !operation.All {
+			pipeline = append(pipeline, distinctRows(names)...)
+		}
+	}
+
+	sample := limit != nil && isRandomOrder(order)
+	if len(order) > 0 && !sample {
+		stages, err := convertSetOrderBy(order, names, options)
+		if err != nil {
+			return mongo.Query{}, err
+		}
+		pipeline = append(pipeline, stages...)
+	}
+	pipeline = append(pipeline, limitStages(limit, sample)...)
+
+	result.Command = mongo.MongoAggregate
+	result.Match = nil
+	result.Projection = nil
+	result.Pipeline = pipeline
+	return result, nil
+}
+
+// setColumns returns the output columns of a branch of a set operation, or nil if it selects *
+func setColumns(query sql.Query) ([]string, error) {
+	var names []string
+	for _, projection := range query.Projections {
+		if _, ok := projection.Expr.(*sql.StarExpr); ok {
+			if len(query.Projections) > 1 {
+				return nil, fmt.Errorf("%s can not be combined with other columns in a set operation", projection.Expr)
+			}
+			return nil, nil
+		}
+		names = append(names, fieldName(projection.Name()))
+	}
+	return names, nil
+}
+
+// convertBranch converts a branch of a set operation to the pipeline that produces its rows
+// under the given column names
+func convertBranch(query sql.Query, names []string, options Options) (mongo.Query, []mongo.Document, error) {
+	result, pipeline, err := convertPipeline(query, options)
+	if err != nil {
+		return mongo.Query{}, nil, err
+	}
+	last := len(pipeline) - 1
+	switch {
+	case len(query.SetOperations) > 0 && names != nil:
+		// A branch that is itself an INTERSECT returns its rows under the columns of its first query
+		columns, err := setColumns(query)
+		if err != nil {
+			return mongo.Query{}, nil, err
+		}
+		var project mongo.Document
+		renamed := false
+		for i, column := range columns {
+			project = append(project, mongo.Element{Key: column, Value: int64(1)})
+			renamed = renamed || column != names[i]
+		}
+		if renamed {
+			pipeline = append(pipeline, mongo.Document{{Key: "$project", Value: alignColumns(project, names)}})
+		}
+	case result.Command == mongo.MongoCount:
+		pipeline[last] = mongo.Document{{Key: "$count", Value: names[0]}}
+	case names != nil:
+		project, _ := pipeline[last].Get("$project")
+		pipeline[last] = mongo.Document{{Key: "$project", Value: alignColumns(project.(mongo.Document), names)}}
+	}
+	return result, pipeline, nil
+}
+
+// alignColumns renames the columns of a $project stage by position and excludes _id unless it is selected
+func alignColumns(project mongo.Document, names []string) mongo.Document {
+	var columns mongo.Document
+	for _, column := range project {
+		if column.Key != "_id" || column.Value != int64(0) {
+			columns = append(columns, column)
+		}
+	}
+	var aligned mongo.Document
+	if indexOfString(names, "_id") == -1 {
+		aligned = mongo.Document{{Key: "_id", Value: int64(0)}}
+	}
+	for i, column := range columns {
+		value := column.Value
+		if value == int64(1) && column.Key != names[i] {
+			value = "$" + column.Key
+		}
+		aligned = append(aligned, mongo.Element{Key: names[i], Value: value})
+	}
+	return aligned
+}
+
+// convertMembership converts an INTERSECT or EXCEPT to the stages that keep the rows that
+// are or are not produced by the pipeline of the other branch
+func convertMembership(operator sql.SetOperator, collection string, other []mongo.Document, names []string) []mongo.Document {
+	var let mongo.Document
+	var equal mongo.Array
+	if names == nil {
+		let = mongo.Document{{Key: "row", Value: "$$ROOT"}}
+		equal = mongo.Array{mongo.Document{{Key: "$eq", Value: mongo.Array{"$$ROOT", "$$row"}}}}
+	}
+	for i, name := range names {
+		variable := fmt.Sprintf("c%d", i+1)
+		let = append(let, mongo.Element{Key: variable, Value: "$" + name})
+		equal = append(equal, mongo.Document{{Key: "$eq", Value: mongo.Array{"$" + name, "$$" + variable}}})
+	}
+	condition := equal[0]
+	if len(equal) > 1 {
+		condition = mongo.Document{{Key: "$and", Value: equal}}
+	}
+	lookup := append(stageArray(other),
+		mongo.Document{{Key: "$match", Value: mongo.Document{{Key: "$expr", Value: condition}}}},
+		mongo.Document{{Key: "$limit", Value: int64(1)}},
+	)
+
+	var present interface{} = mongo.Document{{Key: "$size", Value: int64(0)}}
+	if operator == sql.Intersect {
+		present = mongo.Document{{Key: "$not", Value: present}}
+	}
+	return []mongo.Document{
+		{{Key: "$lookup", Value: mongo.Document{
+			{Key: "from", Value: collection},
+			{Key: "let", Value: let},
+			{Key: "pipeline", Value: lookup},
+			{Key: "as", Value: matchedField},
+		}}},
+		{{Key: "$match", Value: mongo.Document{{Key: matchedField, Value: present}}}},
+		{{Key: "$unset", Value: matchedField}},
+	}
+}
+
+// distinctRows returns the stages that remove duplicate rows with the given columns, or
+// duplicate documents if all fields are selected
+func distinctRows(names []string) []mongo.Document {
+	var key interface{} = "$$ROOT"
+	if names != nil {
+		var columns mongo.Document
+		for _, name := range names {
+			columns = append(columns, mongo.Element{Key: name, Value: "$" + name})
+		}
+		key = columns
+	}
+	return []mongo.Document{
+		{{Key: "$group", Value: mongo.Document{{Key: "_id", Value: key}}}},
+		{{Key: "$replaceWith", Value: "$_id"}},
+	}
+}
+
+// stageArray converts pipeline stages to an array value
+func stageArray(stages []mongo.Document) mongo.Array {
+	array := make(mongo.Array, len(stages))
+	for i, stage := range stages {
+		array[i] = stage
+	}
+	return array
+}
+
+// indexOfString returns the position of a string in a list, or -1 if it is not there
+func indexOfString(list []string, value string) int {
+	for i, item := range list {
+		if item == value {
+			return i
+		}
+	}
+	return -1
+}
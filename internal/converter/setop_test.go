@@ -0,0 +1,125 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlignColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		project mongo.Document
+		names   []string
+		want    mongo.Document
+	}{
+		{
+			name:    "find projection",
+			project: mongo.Document{{Key: "company", Value: int64(1)}, {Key: "address.city", Value: int64(1)}},
+			names:   []string{"name", "address_city"},
+			want:    mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "name", Value: "$company"}, {Key: "address_city", Value: "$address.city"}},
+		},
+		{
+			name:    "aggregate projection",
+			project: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "country", Value: "$_id"}, {Key: "n", Value: int64(1)}},
+			names:   []string{"country", "n"},
+			want:    mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "country", Value: "$_id"}, {Key: "n", Value: int64(1)}},
+		},
+		{
+			name:    "selected id",
+			project: mongo.Document{{Key: "_id", Value: int64(1)}},
+			names:   []string{"_id"},
+			want:    mongo.Document{{Key: "_id", Value: int64(1)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, alignColumns(tt.project, tt.names))
+		})
+	}
+}
+
+func TestConvertMembership(t *testing.T) {
+	other := []mongo.Document{{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "name", Value: int64(1)}}}}}
+	got := convertMembership(sql.Intersect, "admins", other, []string{"name"})
+	require.Equal(t, []mongo.Document{
+		{{Key: "$lookup", Value: mongo.Document{
+			{Key: "from", Value: "admins"},
+			{Key: "let", Value: mongo.Document{{Key: "c1", Value: "$name"}}},
+			{Key: "pipeline", Value: mongo.Array{
+				other[0],
+				mongo.Document{{Key: "$match", Value: mongo.Document{{Key: "$expr", Value: mongo.Document{{Key: "$eq", Value: mongo.Array{"$name", "$$c1"}}}}}}},
+				mongo.Document{{Key: "$limit", Value: int64(1)}},
+			}},
+			{Key: "as", Value: "_matched"},
+		}}},
+		{{Key: "$match", Value: mongo.Document{{Key: "_matched", Value: mongo.Document{{Key: "$not", Value: mongo.Document{{Key: "$size", Value: int64(0)}}}}}}}},
+		{{Key: "$unset", Value: "_matched"}},
+	}, got)
+}
+
+func TestConvertSetQuery(t *testing.T) {
+	branch := func(table string, columns ...string) sql.Query {
+		query := sql.Query{Command: sql.SQLSelect, Table: table, Columns: columns}
+		for _, column := range columns {
+			query.Projections = append(query.Projections, sql.Projection{Expr: &sql.ColumnRef{Name: column}})
+		}
+		return query
+	}
+	tests := []struct {
+		name    string
+		query   sql.Query
+		want    []mongo.Document
+		wantErr bool
+	}{
+		{
+			name: "union",
+			query: sql.Query{
+				Command: sql.SQLSelect, Table: "users", Columns: []string{"name"},
+				Projections:   []sql.Projection{{Expr: &sql.ColumnRef{Name: "name"}}},
+				SetOperations: []sql.SetOperation{{Operator: sql.Union, Query: branch("admins", "login")}},
+			},
+			want: []mongo.Document{
+				{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "name", Value: int64(1)}}}},
+				{{Key: "$unionWith", Value: mongo.Document{
+					{Key: "coll", Value: "admins"},
+					{Key: "pipeline", Value: mongo.Array{mongo.Document{{Key: "$project", Value: mongo.Document{{Key: "_id", Value: int64(0)}, {Key: "name", Value: "$login"}}}}}},
+				}}},
+				{{Key: "$group", Value: mongo.Document{{Key: "_id", Value: mongo.Document{{Key: "name", Value: "$name"}}}}}},
+				{{Key: "$replaceWith", Value: "$_id"}},
+			},
+		},
+		{
+			name: "column count mismatch",
+			query: sql.Query{
+				Command: sql.SQLSelect, Table: "users", Columns: []string{"name"},
+				Projections:   []sql.Projection{{Expr: &sql.ColumnRef{Name: "name"}}},
+				SetOperations: []sql.SetOperation{{Operator: sql.Except, Query: branch("admins", "name", "age")}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "star with columns",
+			query: sql.Query{
+				Command: sql.SQLSelect, Table: "users", Columns: []string{"*"},
+				Projections:   []sql.Projection{{Expr: &sql.StarExpr{}}},
+				SetOperations: []sql.SetOperation{{Operator: sql.Union, All: true, Query: branch("admins", "name")}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertSetQuery(tt.query, Options{})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, mongo.MongoAggregate, got.Command)
+			require.Equal(t, tt.want, got.Pipeline)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// deviation is the $group accumulator of a standard deviation or variance aggregate, which is
+// squared to the variance
+type deviation struct {
+	op      string
+	squared bool
+}
+
+// deviationFunctions maps the standard deviation and variance aggregates to their accumulator.
+// STDDEV and VARIANCE are the sample forms, as in PostgreSQL
+var deviationFunctions = map[string]deviation{
+	"STDDEV":      {"$stdDevSamp", false},
+	"STDDEV_SAMP": {"$stdDevSamp", false},
+	"STDDEV_POP":  {"$stdDevPop", false},
+	"VARIANCE":    {"$stdDevSamp", true},
+	"VAR_SAMP":    {"$stdDevSamp", true},
+	"VAR_POP":     {"$stdDevPop", true},
+}
+
+// bivariate describes an aggregate of pairs of values, computed from the number of pairs and the
+// sums listed in vars once the group is accumulated
+type bivariate struct {
+	// minRows is the number of pairs below which the aggregate is null
+	minRows int64
+	// vars lists the sums the result reads: sx and sy are the sums of the values, and sxx, syy
+	// and sxy the sums of their squares and products centered on the means
+	vars []string
+	// result computes the aggregate from the number of pairs $$n and the variables in vars
+	result func() interface{}
+}
+
+// bivariateFunctions maps the aggregates of pairs of values, whose first argument is the
+// dependent value y and second the independent value x, to how they are computed
+var bivariateFunctions = map[string]bivariate{
+	"COVAR_POP": {1, []string{"sxy"}, func() interface{} {
+		return divide("$$sxy", "$$n")
+	}},
+	"COVAR_SAMP": {2, []string{"sxy"}, func() interface{} {
+		return divide("$$sxy", mongo.Document{{Key: "$subtract", Value: mongo.Array{"$$n", int64(1)}}})
+	}},
+	"CORR": {1, []string{"sxx", "syy", "sxy"}, func() interface{} {
+		product := mongo.Document{{Key: "$multiply", Value: mongo.Array{"$$sxx", "$$syy"}}}
+		correlation := divide("$$sxy", mongo.Document{{Key: "$sqrt", Value: product}})
+		return mongo.Document{{Key: "$cond", Value: mongo.Array{mongo.Document{{Key: "$lte", Value: mongo.Array{product, int64(0)}}}, nil, correlation}}}
+	}},
+	"REGR_SLOPE": {1, []string{"sxx", "sxy"}, func() interface{} {
+		return unlessZero("$$sxx", divide("$$sxy", "$$sxx"))
+	}},
+	"REGR_INTERCEPT": {1, []string{"sx", "sy", "sxx", "sxy"}, func() interface{} {
+		slope := divide("$$sxy", "$$sxx")
+		intercept := mongo.Document{{Key: "$subtract", Value: mongo.Array{"$$sy", mongo.Document{{Key: "$multiply", Value: mongo.Array{slope, "$$sx"}}}}}}
+		return unlessZero("$$sxx", divide(intercept, "$$n"))
+	}},
+	"REGR_R2": {1, []string{"sxx", "syy", "sxy"}, func() interface{} {
+		r2 := divide(mongo.Document{{Key: "$multiply", Value: mongo.Array{"$$sxy", "$$sxy"}}}, mongo.Document{{Key: "$multiply", Value: mongo.Array{"$$sxx", "$$syy"}}})
+		constant := mongo.Document{{Key: "$eq", Value: mongo.Array{"$$syy", int64(0)}}}
+		return unlessZero("$$sxx", mongo.Document{{Key: "$cond", Value: mongo.Array{constant, int64(1), r2}}})
+	}},
+	"REGR_AVGX":  {1, []string{"sx"}, func() interface{} { return divide("$$sx", "$$n") }},
+	"REGR_AVGY":  {1, []string{"sy"}, func() interface{} { return divide("$$sy", "$$n") }},
+	"REGR_SXX":   {1, []string{"sxx"}, func() interface{} { return "$$sxx" }},
+	"REGR_SYY":   {1, []string{"syy"}, func() interface{} { return "$$syy" }},
+	"REGR_SXY":   {1, []string{"sxy"}, func() interface{} { return "$$sxy" }},
+	"REGR_COUNT": {0, nil, nil},
+}
+
+// convertDeviation converts a standard deviation or variance aggregate to its accumulator, or
+// for DISTINCT to an $addToSet whose values the finalizer computes the deviation of, squaring
+// the deviation of a variance
+func (g *groupStage) convertDeviation(call *sql.FuncCall, field string) (interface{}, interface{}, error) {
+	function := deviationFunctions[strings.ToUpper(call.Name)]
+	if len(call.Args) != 1 {
+		return nil, nil, fmt.Errorf("%s expects one argument", call.Name)
+	}
+	if len(call.OrderBy) > 0 {
+		return nil, nil, fmt.Errorf("%s does not accept ORDER BY or WITHIN GROUP", call.Name)
+	}
+	filter, err := g.convertAggregateFilter(call)
+	if err != nil {
+		return nil, nil, err
+	}
+	arg, err := g.convertAggregateArg(call.Args[0], filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	accumulator := mongo.Document{{Key: function.op, Value: arg}}
+	var finalizer interface{}
+	if call.Distinct {
+		accumulator = mongo.Document{{Key: "$addToSet", Value: arg}}
+		finalizer = mongo.Document{{Key: function.op, Value: "$" + field}}
+	}
+	if function.squared {
+		var value interface{} = "$" + field
+		if finalizer != nil {
+			value = finalizer
+		}
+		finalizer = mongo.Document{{Key: "$pow", Value: mongo.Array{value, int64(2)}}}
+	}
+	return accumulator, finalizer, nil
+}
+
+// convertBivariate converts an aggregate of pairs of values to a $sum counting the pairs whose
+// values are both numbers, and adds the accumulators of the sums it is computed from next to it.
+// The finalizer centers the sums on the means and computes the aggregate from them
+func (g *groupStage) convertBivariate(call *sql.FuncCall, field string) (interface{}, interface{}, error) {
+	function := bivariateFunctions[strings.ToUpper(call.Name)]
+	if len(call.Args) != 2 {
+		return nil, nil, fmt.Errorf("%s expects two arguments", call.Name)
+	}
+	if call.Distinct || len(call.OrderBy) > 0 {
+		return nil, nil, fmt.Errorf("%s does not accept DISTINCT, ORDER BY or WITHIN GROUP", call.Name)
+	}
+	args, err := convertExpressions(call.Args, g.input)
+	if err != nil {
+		return nil, nil, err
+	}
+	y, x := args[0], args[1]
+	conditions := mongo.Array{mongo.Document{{Key: "$isNumber", Value: y}}, mongo.Document{{Key: "$isNumber", Value: x}}}
+	filter, err := g.convertAggregateFilter(call)
+	if err != nil {
+		return nil, nil, err
+	}
+	if filter != nil {
+		conditions = append(conditions, filter)
+	}
+	pair := mongo.Document{{Key: "$and", Value: conditions}}
+	sum := func(value interface{}) mongo.Document {
+		return mongo.Document{{Key: "$sum", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{pair, value, int64(0)}}}}}
+	}
+	count := sum(int64(1))
+	g.counts[field] = true
+	if function.result == nil {
+		return count, nil, nil
+	}
+
+	sums := map[string]string{}
+	raw := func(name string, value interface{}) string {
+		if sums[name] == "" {
+			sums[name] = g.uniqueField(field + "_" + name)
+			g.accumulators = append(g.accumulators, mongo.Element{Key: sums[name], Value: sum(value)})
+		}
+		return "$" + sums[name]
+	}
+	product := func(a interface{}, b interface{}) mongo.Document {
+		return mongo.Document{{Key: "$multiply", Value: mongo.Array{a, b}}}
+	}
+	centered := func(a string, b string, products string) interface{} {
+		return mongo.Document{{Key: "$subtract", Value: mongo.Array{products, divide(product(a, b), "$"+field)}}}
+	}
+	vars := mongo.Document{{Key: "n", Value: "$" + field}}
+	for _, name := range function.vars {
+		var value interface{}
+		switch name {
+		case "sx":
+			value = raw("sx", x)
+		case "sy":
+			value = raw("sy", y)
+		case "sxx":
+			value = centered(raw("sx", x), raw("sx", x), raw("sxx", product(x, x)))
+		case "syy":
+			value = centered(raw("sy", y), raw("sy", y), raw("syy", product(y, y)))
+		case "sxy":
+			value = centered(raw("sx", x), raw("sy", y), raw("sxy", product(x, y)))
+		}
+		vars = append(vars, mongo.Element{Key: name, Value: value})
+	}
+	result := mongo.Document{{Key: "$let", Value: mongo.Document{{Key: "vars", Value: vars}, {Key: "in", Value: function.result()}}}}
+	tooFew := mongo.Document{{Key: "$lt", Value: mongo.Array{"$" + field, function.minRows}}}
+	return count, mongo.Document{{Key: "$cond", Value: mongo.Array{tooFew, nil, result}}}, nil
+}
+
+// divide returns the expression dividing two values
+func divide(dividend interface{}, divisor interface{}) mongo.Document {
+	return mongo.Document{{Key: "$divide", Value: mongo.Array{dividend, divisor}}}
+}
+
+// unlessZero returns the expression that is null when a value is zero, and the given result otherwise
+func unlessZero(value interface{}, result interface{}) mongo.Document {
+	zero := mongo.Document{{Key: "$eq", Value: mongo.Array{value, int64(0)}}}
+	return mongo.Document{{Key: "$cond", Value: mongo.Array{zero, nil, result}}}
+}
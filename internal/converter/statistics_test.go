@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertStatistics(t *testing.T) {
+	y := &sql.ColumnRef{Name: "y"}
+	x := &sql.ColumnRef{Name: "x"}
+	pair := mongo.Document{{Key: "$and", Value: mongo.Array{mongo.Document{{Key: "$isNumber", Value: "$y"}}, mongo.Document{{Key: "$isNumber", Value: "$x"}}}}}
+	sum := func(value interface{}) mongo.Document {
+		return mongo.Document{{Key: "$sum", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{pair, value, int64(0)}}}}}
+	}
+	tests := []struct {
+		name             string
+		call             *sql.FuncCall
+		wantAccumulators mongo.Document
+		wantFinalizers   mongo.Document
+		wantErr          bool
+	}{
+		{
+			name:             "standard deviation",
+			call:             &sql.FuncCall{Name: "stddev", Args: []sql.Expr{x}},
+			wantAccumulators: mongo.Document{{Key: "s", Value: mongo.Document{{Key: "$stdDevSamp", Value: "$x"}}}},
+		},
+		{
+			name:             "population variance",
+			call:             &sql.FuncCall{Name: "VAR_POP", Args: []sql.Expr{x}},
+			wantAccumulators: mongo.Document{{Key: "s", Value: mongo.Document{{Key: "$stdDevPop", Value: "$x"}}}},
+			wantFinalizers:   mongo.Document{{Key: "s", Value: mongo.Document{{Key: "$pow", Value: mongo.Array{"$s", int64(2)}}}}},
+		},
+		{
+			name:             "distinct variance",
+			call:             &sql.FuncCall{Name: "VARIANCE", Args: []sql.Expr{x}, Distinct: true},
+			wantAccumulators: mongo.Document{{Key: "s", Value: mongo.Document{{Key: "$addToSet", Value: "$x"}}}},
+			wantFinalizers: mongo.Document{{Key: "s", Value: mongo.Document{{Key: "$pow", Value: mongo.Array{
+				mongo.Document{{Key: "$stdDevSamp", Value: "$s"}},
+				int64(2),
+			}}}}},
+		},
+		{
+			name:             "regression count",
+			call:             &sql.FuncCall{Name: "REGR_COUNT", Args: []sql.Expr{y, x}},
+			wantAccumulators: mongo.Document{{Key: "s", Value: sum(int64(1))}},
+		},
+		{
+			name: "sample covariance",
+			call: &sql.FuncCall{Name: "COVAR_SAMP", Args: []sql.Expr{y, x}},
+			wantAccumulators: mongo.Document{
+				{Key: "s", Value: sum(int64(1))},
+				{Key: "s_sx", Value: sum("$x")},
+				{Key: "s_sy", Value: sum("$y")},
+				{Key: "s_sxy", Value: sum(mongo.Document{{Key: "$multiply", Value: mongo.Array{"$x", "$y"}}})},
+			},
+			wantFinalizers: mongo.Document{{Key: "s", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{
+				mongo.Document{{Key: "$lt", Value: mongo.Array{"$s", int64(2)}}},
+				nil,
+				mongo.Document{{Key: "$let", Value: mongo.Document{
+					{Key: "vars", Value: mongo.Document{
+						{Key: "n", Value: "$s"},
+						{Key: "sxy", Value: mongo.Document{{Key: "$subtract", Value: mongo.Array{
+							"$s_sxy",
+							mongo.Document{{Key: "$divide", Value: mongo.Array{mongo.Document{{Key: "$multiply", Value: mongo.Array{"$s_sx", "$s_sy"}}}, "$s"}}},
+						}}}},
+					}},
+					{Key: "in", Value: mongo.Document{{Key: "$divide", Value: mongo.Array{"$$sxy", mongo.Document{{Key: "$subtract", Value: mongo.Array{"$$n", int64(1)}}}}}}},
+				}}},
+			}}}}},
+		},
+		{
+			name: "regression average",
+			call: &sql.FuncCall{Name: "REGR_AVGX", Args: []sql.Expr{y, x}},
+			wantAccumulators: mongo.Document{
+				{Key: "s", Value: sum(int64(1))},
+				{Key: "s_sx", Value: sum("$x")},
+			},
+			wantFinalizers: mongo.Document{{Key: "s", Value: mongo.Document{{Key: "$cond", Value: mongo.Array{
+				mongo.Document{{Key: "$lt", Value: mongo.Array{"$s", int64(1)}}},
+				nil,
+				mongo.Document{{Key: "$let", Value: mongo.Document{
+					{Key: "vars", Value: mongo.Document{{Key: "n", Value: "$s"}, {Key: "sx", Value: "$s_sx"}}},
+					{Key: "in", Value: mongo.Document{{Key: "$divide", Value: mongo.Array{"$$sx", "$$n"}}}},
+				}}},
+			}}}}},
+		},
+		{
+			name:    "correlation of one column",
+			call:    &sql.FuncCall{Name: "CORR", Args: []sql.Expr{y}},
+			wantErr: true,
+		},
+		{
+			name:    "distinct correlation",
+			call:    &sql.FuncCall{Name: "CORR", Args: []sql.Expr{y, x}, Distinct: true},
+			wantErr: true,
+		},
+		{
+			name:    "standard deviation of two columns",
+			call:    &sql.FuncCall{Name: "STDDEV_POP", Args: []sql.Expr{y, x}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := newGroupStage(newScope())
+			_, err := group.accumulate(tt.call, "s")
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAccumulators, group.accumulators)
+			require.Equal(t, tt.wantFinalizers, group.finalizers)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// defaultOrdinality is the name of the WITH ORDINALITY column of an UNNEST that does not rename it
+const defaultOrdinality = "ordinality"
+
+// unnestColumns returns the names of the element and ordinality columns of an UNNEST join, which
+// can be referred to without the alias of the join
+func unnestColumns(join sql.Join) (string, string) {
+	element, ordinality := join.Name(), ""
+	if len(join.Unnest.Columns) > 0 {
+		element = join.Unnest.Columns[0]
+	}
+	if join.Unnest.Ordinality {
+		ordinality = defaultOrdinality
+		if len(join.Unnest.Columns) > 1 {
+			ordinality = join.Unnest.Columns[1]
+		}
+	}
+	return element, ordinality
+}
+
+// convertUnnest converts a JOIN of an UNNEST to the stages that store the array under the name of
+// the join and $unwind it, so that the fields of each element are the columns of the join. A LEFT
+// JOIN keeps the rows whose array is empty or missing, and WITH ORDINALITY stores the position of
+// each element, counted from 1
+func convertUnnest(join sql.Join, s *scope) ([]mongo.Document, error) {
+	if join.Type == sql.RightJoin || join.Type == sql.FullJoin {
+		return nil, fmt.Errorf("%s JOIN can not expand UNNEST %s", join.Type, join.Name())
+	}
+	if sql.ContainsAggregate(join.Unnest.Array) || sql.ContainsWindow(join.Unnest.Array) {
+		return nil, fmt.Errorf("UNNEST can not expand %s", join.Unnest.Array)
+	}
+	array, err := convertExpression(join.Unnest.Array, s)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fieldName(join.Name())
+	element, ordinality := unnestColumns(join)
+	s.tables[join.Name()] = name
+	if element != join.Name() {
+		s.fields[element] = name
+		s.fields[(&sql.ColumnRef{Table: join.Name(), Name: element}).String()] = name
+	}
+	stages := []mongo.Document{{{Key: "$set", Value: mongo.Document{{Key: name, Value: array}}}}}
+	var unwind interface{} = "$" + name
+	var index string
+	if ordinality != "" || join.Type == sql.LeftJoin {
+		options := mongo.Document{{Key: "path", Value: "$" + name}}
+		if ordinality != "" {
+			index = fieldName(join.Name() + "_" + ordinality)
+			s.fields[ordinality] = index
+			s.fields[(&sql.ColumnRef{Table: join.Name(), Name: ordinality}).String()] = index
+			options = append(options, mongo.Element{Key: "includeArrayIndex", Value: index})
+		}
+		if join.Type == sql.LeftJoin {
+			options = append(options, mongo.Element{Key: "preserveNullAndEmptyArrays", Value: true})
+		}
+		unwind = options
+	}
+	stages = append(stages, mongo.Document{{Key: "$unwind", Value: unwind}})
+	if index != "" {
+		position := mongo.Document{{Key: "$add", Value: mongo.Array{"$" + index, int64(1)}}}
+		stages = append(stages, mongo.Document{{Key: "$set", Value: mongo.Document{{Key: index, Value: position}}}})
+	}
+
+	if join.On == nil || isTrue(join.On) {
+		return stages, nil
+	}
+	if join.Type == sql.LeftJoin {
+		return nil, fmt.Errorf("LEFT JOIN UNNEST %s only supports ON TRUE", join.Name())
+	}
+	match, err := convertFilter(join.On, s)
+	if err != nil {
+		return nil, err
+	}
+	return append(stages, mongo.Document{{Key: "$match", Value: match}}), nil
+}
+
+// isTrue checks if an expression is the TRUE literal
+func isTrue(expr sql.Expr) bool {
+	literal, ok := expr.(*sql.Literal)
+	return ok && literal.Kind == sql.BooleanLiteral && strings.EqualFold(literal.Value, "true")
+}
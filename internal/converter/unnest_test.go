@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertUnnest(t *testing.T) {
+	items := &sql.ColumnRef{Table: "o", Name: "items"}
+	tests := []struct {
+		name    string
+		join    sql.Join
+		want    []mongo.Document
+		wantErr bool
+	}{
+		{
+			name: "cross join",
+			join: sql.Join{Type: sql.CrossJoin, Alias: "i", Unnest: &sql.Unnest{Array: items}},
+			want: []mongo.Document{
+				{{Key: "$set", Value: mongo.Document{{Key: "i", Value: "$items"}}}},
+				{{Key: "$unwind", Value: "$i"}},
+			},
+		},
+		{
+			name: "left join with ordinality",
+			join: sql.Join{
+				Type:   sql.LeftJoin,
+				Alias:  "i",
+				On:     &sql.Literal{Kind: sql.BooleanLiteral, Value: "true"},
+				Unnest: &sql.Unnest{Array: items, Ordinality: true},
+			},
+			want: []mongo.Document{
+				{{Key: "$set", Value: mongo.Document{{Key: "i", Value: "$items"}}}},
+				{{Key: "$unwind", Value: mongo.Document{
+					{Key: "path", Value: "$i"},
+					{Key: "includeArrayIndex", Value: "i_ordinality"},
+					{Key: "preserveNullAndEmptyArrays", Value: true},
+				}}},
+				{{Key: "$set", Value: mongo.Document{{Key: "i_ordinality", Value: mongo.Document{{Key: "$add", Value: mongo.Array{"$i_ordinality", int64(1)}}}}}}},
+			},
+		},
+		{
+			name: "inner join on a condition",
+			join: sql.Join{
+				Type:   sql.InnerJoin,
+				Alias:  "t",
+				On:     &sql.BinaryExpr{Op: "<>", Left: &sql.ColumnRef{Name: "tag"}, Right: &sql.Literal{Kind: sql.StringLiteral, Value: ""}},
+				Unnest: &sql.Unnest{Array: &sql.ColumnRef{Name: "tags"}, Columns: []string{"tag"}},
+			},
+			want: []mongo.Document{
+				{{Key: "$set", Value: mongo.Document{{Key: "t", Value: "$tags"}}}},
+				{{Key: "$unwind", Value: "$t"}},
+				{{Key: "$match", Value: mongo.Document{{Key: "t", Value: mongo.Document{{Key: "$ne", Value: ""}}}}}},
+			},
+		},
+		{
+			name:    "left join on a condition",
+			join:    sql.Join{Type: sql.LeftJoin, Alias: "i", On: &sql.ColumnRef{Table: "i", Name: "active"}, Unnest: &sql.Unnest{Array: items}},
+			wantErr: true,
+		},
+		{
+			name:    "full join",
+			join:    sql.Join{Type: sql.FullJoin, Alias: "i", On: &sql.Literal{Kind: sql.BooleanLiteral, Value: "true"}, Unnest: &sql.Unnest{Array: items}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScope()
+			s.tables["o"] = ""
+			got, err := convertUnnest(tt.join, s)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Nil(t, got)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// serverVersionPattern matches a MongoDB server version such as 6.0 or 7.0.2
+var serverVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.\d+)?$`)
+
+// parseServerVersion returns the major and minor numbers of a server version
+func parseServerVersion(version string) (int, int, error) {
+	match := serverVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return 0, 0, fmt.Errorf("invalid server version: %s", version)
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return major, minor, nil
+}
+
+// supports checks if the server the commands are generated for is at least the given version,
+// which the latest version is when no version is set
+func (o Options) supports(major, minor int) bool {
+	if o.ServerVersion == "" {
+		return true
+	}
+	serverMajor, serverMinor, err := parseServerVersion(o.ServerVersion)
+	if err != nil {
+		return true
+	}
+	return serverMajor > major || (serverMajor == major && serverMinor >= minor)
+}
@@ -0,0 +1,385 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+)
+
+// windowOperators maps SQL window functions to $setWindowFields operators
+var windowOperators = map[string]string{
+	"ROW_NUMBER":  "$documentNumber",
+	"RANK":        "$rank",
+	"DENSE_RANK":  "$denseRank",
+	"LAG":         "$shift",
+	"LEAD":        "$shift",
+	"FIRST_VALUE": "$first",
+	"LAST_VALUE":  "$last",
+	"COUNT":       "$sum",
+	"SUM":         "$sum",
+	"AVG":         "$avg",
+	"MIN":         "$min",
+	"MAX":         "$max",
+	"STDDEV":      "$stdDevSamp",
+	"STDDEV_SAMP": "$stdDevSamp",
+	"STDDEV_POP":  "$stdDevPop",
+}
+
+// windowStage is a $setWindowFields stage computing the functions of one partition and order
+type windowStage struct {
+	key    string
+	spec   mongo.Document
+	output mongo.Document
+}
+
+// windowSet builds the $setWindowFields stages of a query, sharing a stage between the
+// window functions with the same partition and order
+type windowSet struct {
+	// input resolves the columns of the documents the windows are computed over, and is
+	// extended with the fields that hold the window function results
+	input *scope
+	// sortKeys computes the ORDER BY expressions that are not fields, as sortBy only accepts fields
+	sortKeys mongo.Document
+	windows  []*windowStage
+	// reserved holds the fields that window results must not overwrite
+	reserved map[string]bool
+	// warnings explain window functions whose frame only approximates the SQL default
+	warnings []string
+}
+
+// newWindowSet returns a window set over the documents of a scope, whose results must not
+// overwrite the fields read by the given expressions
+func newWindowSet(input *scope, exprs []sql.Expr) *windowSet {
+	w := &windowSet{input: input, reserved: map[string]bool{"_id": true}}
+	for _, expr := range exprs {
+		sql.Walk(expr, func(e sql.Expr) bool {
+			if field, ok := input.fieldPath(e); ok {
+				w.reserved[strings.SplitN(field, ".", 2)[0]] = true
+			}
+			return true
+		})
+	}
+	return w
+}
+
+// addAll adds every window function call inside an expression, naming a call that is the whole expression
+func (w *windowSet) addAll(expr sql.Expr, name string) error {
+	var err error
+	sql.Walk(expr, func(e sql.Expr) bool {
+		if err != nil {
+			return false
+		}
+		if call, ok := e.(*sql.FuncCall); ok && call.Over != nil {
+			callName := call.String()
+			if e == expr {
+				callName = name
+			}
+			err = w.add(call, callName)
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+// add adds a window function call unless an equal one exists
+func (w *windowSet) add(call *sql.FuncCall, name string) error {
+	if _, ok := w.input.fields[call.String()]; ok {
+		return nil
+	}
+	stage, err := w.stage(call.Over)
+	if err != nil {
+		return err
+	}
+	output, err := w.convertWindowFunction(call)
+	if err != nil {
+		return err
+	}
+	field := fieldName(name)
+	for w.reserved[field] {
+		field = "_" + field
+	}
+	w.reserved[field] = true
+	stage.output = append(stage.output, mongo.Element{Key: field, Value: output})
+	w.input.fields[call.String()] = field
+	return nil
+}
+
+// stage returns the stage that computes the functions of a window's partition and order
+func (w *windowSet) stage(window *sql.Window) (*windowStage, error) {
+	var keys []string
+	for _, expr := range window.PartitionBy {
+		keys = append(keys, expr.String())
+	}
+	keys = append(keys, "ORDER BY")
+	for _, item := range window.OrderBy {
+		keys = append(keys, item.String())
+	}
+	key := strings.Join(keys, ", ")
+	for _, stage := range w.windows {
+		if stage.key == key {
+			return stage, nil
+		}
+	}
+
+	stage := &windowStage{key: key}
+	if len(window.PartitionBy) == 1 {
+		partition, err := convertExpression(window.PartitionBy[0], w.input)
+		if err != nil {
+			return nil, err
+		}
+		stage.spec = append(stage.spec, mongo.Element{Key: "partitionBy", Value: partition})
+	} else if len(window.PartitionBy) > 1 {
+		var partition mongo.Document
+		for _, expr := range window.PartitionBy {
+			value, err := convertExpression(expr, w.input)
+			if err != nil {
+				return nil, err
+			}
+			partition = append(partition, mongo.Element{Key: fieldName(expr.String()), Value: value})
+		}
+		stage.spec = append(stage.spec, mongo.Element{Key: "partitionBy", Value: partition})
+	}
+	if len(window.OrderBy) > 0 {
+		var sortBy mongo.Document
+		for _, item := range window.OrderBy {
+			field, err := w.sortField(item.Expr)
+			if err != nil {
+				return nil, err
+			}
+			direction := int64(1)
+			if item.Desc {
+				direction = -1
+			}
+			sortBy = append(sortBy, mongo.Element{Key: field, Value: direction})
+		}
+		stage.spec = append(stage.spec, mongo.Element{Key: "sortBy", Value: sortBy})
+	}
+	w.windows = append(w.windows, stage)
+	return stage, nil
+}
+
+// sortField returns the field to sort by for an ORDER BY expression, computing it first when
+// it is not a field
+func (w *windowSet) sortField(expr sql.Expr) (string, error) {
+	if field, ok := w.input.fieldPath(expr); ok {
+		return field, nil
+	}
+	value, err := convertExpression(expr, w.input)
+	if err != nil {
+		return "", err
+	}
+	field := fmt.Sprintf("_sort%d", len(w.sortKeys)+1)
+	w.sortKeys = append(w.sortKeys, mongo.Element{Key: field, Value: value})
+	w.input.fields[expr.String()] = field
+	return field, nil
+}
+
+// convertWindowFunction converts a window function call to its $setWindowFields output
+func (w *windowSet) convertWindowFunction(call *sql.FuncCall) (mongo.Document, error) {
+	name := strings.ToUpper(call.Name)
+	op, ok := windowOperators[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported window function: %s", call.Name)
+	}
+	if call.Distinct {
+		return nil, fmt.Errorf("DISTINCT is not supported in window functions: %s", call)
+	}
+	if call.Filter != nil {
+		return nil, fmt.Errorf("FILTER is not supported in window functions: %s", call)
+	}
+	window := call.Over
+	switch name {
+	case "ROW_NUMBER", "RANK", "DENSE_RANK":
+		if len(call.Args) > 0 {
+			return nil, fmt.Errorf("%s does not take arguments", call.Name)
+		}
+		if len(window.OrderBy) == 0 || window.Frame != nil {
+			return nil, fmt.Errorf("%s requires an ORDER BY and no frame in its window", call.Name)
+		}
+		if name != "ROW_NUMBER" && len(window.OrderBy) > 1 {
+			return nil, fmt.Errorf("%s can only order by a single expression", call.Name)
+		}
+		return mongo.Document{{Key: op, Value: mongo.Document{}}}, nil
+	case "LAG", "LEAD":
+		return w.convertShift(call)
+	}
+
+	if len(call.Args) != 1 {
+		return nil, fmt.Errorf("%s expects one argument", call.Name)
+	}
+	var arg interface{}
+	if _, ok := call.Args[0].(*sql.StarExpr); ok {
+		if name != "COUNT" {
+			return nil, fmt.Errorf("%s does not accept *", call)
+		}
+		arg = int64(1)
+	} else {
+		var err error
+		if arg, err = convertExpression(call.Args[0], w.input); err != nil {
+			return nil, err
+		}
+		if name == "COUNT" {
+			arg = countNonNull(arg)
+		}
+	}
+	output := mongo.Document{{Key: op, Value: arg}}
+	bounds, err := convertFrame(window)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := bounds.Get("documents"); ok && window.Frame == nil {
+		w.warnings = append(w.warnings, fmt.Sprintf(
+			"%s ends its window at the current row and leaves out the rows that sort equal to it, which only a window sorted by a single number or date can include",
+			call))
+	}
+	if bounds != nil {
+		output = append(output, mongo.Element{Key: "window", Value: bounds})
+	}
+	return output, nil
+}
+
+// convertShift converts a LAG or LEAD call to a $shift output
+func (w *windowSet) convertShift(call *sql.FuncCall) (mongo.Document, error) {
+	if len(call.Args) < 1 || len(call.Args) > 3 {
+		return nil, fmt.Errorf("%s expects one to three arguments", call.Name)
+	}
+	if len(call.Over.OrderBy) == 0 || call.Over.Frame != nil {
+		return nil, fmt.Errorf("%s requires an ORDER BY and no frame in its window", call.Name)
+	}
+	output, err := convertExpression(call.Args[0], w.input)
+	if err != nil {
+		return nil, err
+	}
+	by := int64(1)
+	if len(call.Args) > 1 {
+		value, _, err := constantValue(call.Args[1], w.input)
+		if err != nil {
+			return nil, err
+		}
+		offset, ok := value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("%s offset must be an integer: %s", call.Name, call.Args[1])
+		}
+		by = offset
+	}
+	if strings.EqualFold(call.Name, "LAG") {
+		by = -by
+	}
+	shift := mongo.Document{{Key: "output", Value: output}, {Key: "by", Value: by}}
+	if len(call.Args) > 2 {
+		value, ok, err := constantValue(call.Args[2], w.input)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s default must be a constant: %s", call.Name, call.Args[2])
+		}
+		shift = append(shift, mongo.Element{Key: "default", Value: value})
+	}
+	return mongo.Document{{Key: "$shift", Value: shift}}, nil
+}
+
+// convertFrame converts the frame of a window to $setWindowFields bounds, defaulting to the rows
+// up to the current one and its peers when the window is ordered, and to the whole partition
+// otherwise. MongoDB rejects a range window unless it is sorted by a single number or date, so
+// the default frame of a window sorted by anything else stops at the current row instead
+func convertFrame(window *sql.Window) (mongo.Document, error) {
+	frame := window.Frame
+	if frame == nil {
+		if len(window.OrderBy) == 0 {
+			return nil, nil
+		}
+		unit := "ROWS"
+		if len(window.OrderBy) == 1 && isRangeKey(kindOf(window.OrderBy[0].Expr)) {
+			unit = "RANGE"
+		}
+		frame = &sql.WindowFrame{Unit: unit, Start: sql.FrameBound{Kind: sql.UnboundedPreceding}, End: sql.FrameBound{Kind: sql.CurrentRow}}
+	}
+	if len(window.OrderBy) == 0 {
+		return nil, fmt.Errorf("a window frame requires an ORDER BY in its window")
+	}
+	if frame.Unit == "RANGE" && len(window.OrderBy) > 1 {
+		return nil, fmt.Errorf("a RANGE frame can only order by a single expression")
+	}
+	if kind := kindOf(window.OrderBy[0].Expr); frame.Unit == "RANGE" && kind != anyValue && !isRangeKey(kind) {
+		return nil, fmt.Errorf("a RANGE frame can only order by a number or date, but %s is a %s", window.OrderBy[0].Expr, kind)
+	}
+	start, err := convertFrameBound(frame.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := convertFrameBound(frame.End)
+	if err != nil {
+		return nil, err
+	}
+	unit := "documents"
+	if frame.Unit == "RANGE" {
+		unit = "range"
+	}
+	return mongo.Document{{Key: unit, Value: mongo.Array{start, end}}}, nil
+}
+
+// isRangeKey checks if a range window can be sorted by values of a kind
+func isRangeKey(kind valueKind) bool {
+	return kind == numberValue || kind == dateValue
+}
+
+// convertFrameBound converts a window frame boundary to a $setWindowFields bound
+func convertFrameBound(bound sql.FrameBound) (interface{}, error) {
+	switch bound.Kind {
+	case sql.UnboundedPreceding, sql.UnboundedFollowing:
+		return "unbounded", nil
+	case sql.CurrentRow:
+		return "current", nil
+	}
+	value, _, err := constantValue(bound.Offset, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch offset := value.(type) {
+	case int64:
+		if bound.Kind == sql.Preceding {
+			return -offset, nil
+		}
+		return offset, nil
+	case float64:
+		if bound.Kind == sql.Preceding {
+			return -offset, nil
+		}
+		return offset, nil
+	default:
+		return nil, fmt.Errorf("window frame offset must be a number: %s", bound)
+	}
+}
+
+// stages returns the stages that compute the window functions
+func (w *windowSet) stages() []mongo.Document {
+	var stages []mongo.Document
+	if len(w.sortKeys) > 0 {
+		stages = append(stages, mongo.Document{{Key: "$set", Value: w.sortKeys}})
+	}
+	for _, window := range w.windows {
+		spec := append(window.spec, mongo.Element{Key: "output", Value: window.output})
+		stages = append(stages, mongo.Document{{Key: "$setWindowFields", Value: spec}})
+	}
+	return stages
+}
+
+// convertWindows adds the window functions of a SELECT list to a scope and returns the window
+// set that computes them
+func convertWindows(projections []sql.Projection, s *scope) (*windowSet, error) {
+	exprs := make([]sql.Expr, len(projections))
+	for i, projection := range projections {
+		exprs[i] = projection.Expr
+	}
+	windows := newWindowSet(s, exprs)
+	for _, projection := range projections {
+		if err := windows.addAll(projection.Expr, projection.Name()); err != nil {
+			return nil, err
+		}
+	}
+	return windows, nil
+}
@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/oabraham1/mongosqlgen/internal/mongo"
+	"github.com/oabraham1/mongosqlgen/internal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertWindows(t *testing.T) {
+	window := func(partition string, order string, desc bool) *sql.Window {
+		w := &sql.Window{OrderBy: []sql.OrderItem{{Expr: &sql.ColumnRef{Name: order}, Desc: desc}}}
+		if partition != "" {
+			w.PartitionBy = []sql.Expr{&sql.ColumnRef{Name: partition}}
+		}
+		return w
+	}
+	amount := &sql.ColumnRef{Name: "amount"}
+	tests := []struct {
+		name         string
+		projections  []sql.Projection
+		want         []mongo.Document
+		wantWarnings []string
+		wantErr      bool
+	}{
+		{
+			name: "shared window",
+			projections: []sql.Projection{
+				{Expr: &sql.FuncCall{Name: "ROW_NUMBER", Over: window("dept", "salary", true)}, Alias: "rn"},
+				{Expr: &sql.FuncCall{Name: "DENSE_RANK", Over: window("dept", "salary", true)}, Alias: "r"},
+			},
+			want: []mongo.Document{{{Key: "$setWindowFields", Value: mongo.Document{
+				{Key: "partitionBy", Value: "$dept"},
+				{Key: "sortBy", Value: mongo.Document{{Key: "salary", Value: int64(-1)}}},
+				{Key: "output", Value: mongo.Document{
+					{Key: "rn", Value: mongo.Document{{Key: "$documentNumber", Value: mongo.Document{}}}},
+					{Key: "r", Value: mongo.Document{{Key: "$denseRank", Value: mongo.Document{}}}},
+				}},
+			}}}},
+		},
+		{
+			name: "frames and shifts",
+			projections: []sql.Projection{
+				{Expr: &sql.FuncCall{Name: "SUM", Args: []sql.Expr{amount}, Over: window("", "day", false)}, Alias: "running"},
+				{Expr: &sql.FuncCall{Name: "LEAD", Args: []sql.Expr{amount, &sql.Literal{Kind: sql.NumberLiteral, Value: "2"}}, Over: window("", "day", false)}, Alias: "next"},
+				{Expr: &sql.FuncCall{Name: "AVG", Args: []sql.Expr{amount}, Over: &sql.Window{
+					OrderBy: window("", "day", false).OrderBy,
+					Frame:   &sql.WindowFrame{Unit: "ROWS", Start: sql.FrameBound{Kind: sql.Preceding, Offset: &sql.Literal{Kind: sql.NumberLiteral, Value: "1"}}, End: sql.FrameBound{Kind: sql.Following, Offset: &sql.Literal{Kind: sql.NumberLiteral, Value: "1"}}},
+				}}, Alias: "amount"},
+			},
+			want: []mongo.Document{{{Key: "$setWindowFields", Value: mongo.Document{
+				{Key: "sortBy", Value: mongo.Document{{Key: "day", Value: int64(1)}}},
+				{Key: "output", Value: mongo.Document{
+					{Key: "running", Value: mongo.Document{{Key: "$sum", Value: "$amount"}, {Key: "window", Value: mongo.Document{{Key: "documents", Value: mongo.Array{"unbounded", "current"}}}}}},
+					{Key: "next", Value: mongo.Document{{Key: "$shift", Value: mongo.Document{{Key: "output", Value: "$amount"}, {Key: "by", Value: int64(2)}}}}},
+					{Key: "_amount", Value: mongo.Document{{Key: "$avg", Value: "$amount"}, {Key: "window", Value: mongo.Document{{Key: "documents", Value: mongo.Array{int64(-1), int64(1)}}}}}},
+				}},
+			}}}},
+			wantWarnings: []string{"SUM(amount) OVER (ORDER BY day) ends its window at the current row and leaves out the rows that sort equal to it, which only a window sorted by a single number or date can include"},
+		},
+		{
+			name: "default frame of a numeric sort key",
+			projections: []sql.Projection{
+				{Expr: &sql.FuncCall{Name: "SUM", Args: []sql.Expr{amount}, Over: &sql.Window{OrderBy: []sql.OrderItem{{Expr: &sql.BinaryExpr{Op: "*", Left: amount, Right: &sql.ColumnRef{Name: "qty"}}}}}}, Alias: "running"},
+			},
+			want: []mongo.Document{
+				{{Key: "$set", Value: mongo.Document{{Key: "_sort1", Value: mongo.Document{{Key: "$multiply", Value: mongo.Array{"$amount", "$qty"}}}}}}},
+				{{Key: "$setWindowFields", Value: mongo.Document{
+					{Key: "sortBy", Value: mongo.Document{{Key: "_sort1", Value: int64(1)}}},
+					{Key: "output", Value: mongo.Document{{Key: "running", Value: mongo.Document{{Key: "$sum", Value: "$amount"}, {Key: "window", Value: mongo.Document{{Key: "range", Value: mongo.Array{"unbounded", "current"}}}}}}}},
+				}}},
+			},
+		},
+		{
+			name: "range frame of a string sort key",
+			projections: []sql.Projection{{Expr: &sql.FuncCall{Name: "SUM", Args: []sql.Expr{amount}, Over: &sql.Window{
+				OrderBy: []sql.OrderItem{{Expr: &sql.FuncCall{Name: "UPPER", Args: []sql.Expr{&sql.ColumnRef{Name: "name"}}}}},
+				Frame:   &sql.WindowFrame{Unit: "RANGE", Start: sql.FrameBound{Kind: sql.UnboundedPreceding}, End: sql.FrameBound{Kind: sql.CurrentRow}},
+			}}}},
+			wantErr: true,
+		},
+		{
+			name: "computed sort key",
+			projections: []sql.Projection{
+				{Expr: &sql.FuncCall{Name: "RANK", Over: &sql.Window{OrderBy: []sql.OrderItem{{Expr: &sql.BinaryExpr{Op: "*", Left: amount, Right: &sql.ColumnRef{Name: "qty"}}}}}}, Alias: "r"},
+			},
+			want: []mongo.Document{
+				{{Key: "$set", Value: mongo.Document{{Key: "_sort1", Value: mongo.Document{{Key: "$multiply", Value: mongo.Array{"$amount", "$qty"}}}}}}},
+				{{Key: "$setWindowFields", Value: mongo.Document{
+					{Key: "sortBy", Value: mongo.Document{{Key: "_sort1", Value: int64(1)}}},
+					{Key: "output", Value: mongo.Document{{Key: "r", Value: mongo.Document{{Key: "$rank", Value: mongo.Document{}}}}}},
+				}}},
+			},
+		},
+		{
+			name:        "rank without order",
+			projections: []sql.Projection{{Expr: &sql.FuncCall{Name: "RANK", Over: &sql.Window{}}}},
+			wantErr:     true,
+		},
+		{
+			name:        "frame without order",
+			projections: []sql.Projection{{Expr: &sql.FuncCall{Name: "SUM", Args: []sql.Expr{amount}, Over: &sql.Window{Frame: &sql.WindowFrame{Unit: "ROWS", Start: sql.FrameBound{Kind: sql.UnboundedPreceding}, End: sql.FrameBound{Kind: sql.CurrentRow}}}}}},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertWindows(tt.projections, newScope())
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.stages())
+			require.Equal(t, tt.wantWarnings, got.warnings)
+		})
+	}
+}
@@ -10,24 +10,44 @@ import (
 
 // GenerateMongoQueryFromSQLQuery generates a MongoDB query from a SQL query
 func GenerateMongoQueryFromSQLQuery(input string) (string, error) {
+	return GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{})
+}
+
+// Result is a generated MongoDB query together with the warnings about its translation
+type Result struct {
+	Query string
+	// Warnings explain parts of the translation that may perform poorly, such as the emulation of a FULL OUTER JOIN
+	Warnings []string
+}
+
+// GenerateMongoQueryFromSQLQueryWithOptions generates a MongoDB query from a SQL query with the given conversion options
+func GenerateMongoQueryFromSQLQueryWithOptions(input string, options converter.Options) (string, error) {
+	result, err := GenerateResultFromSQLQuery(input, options)
+	if err != nil {
+		return "", err
+	}
+	return result.Query, nil
+}
+
+// GenerateResultFromSQLQuery generates a MongoDB query from a SQL query with the given conversion
+// options, returning it with the warnings about its translation
+func GenerateResultFromSQLQuery(input string, options converter.Options) (Result, error) {
 	// Parse the input into a SQL Query
 	sqlQuery, err := sql.ConvertUserInputToSQLQuery(input)
 	if err != nil {
-		return "", err
+		return Result{}, err
 	}
 
 	fmt.Printf("SQL Query: %+v\n", sqlQuery)
 
 	// Convert the SQL Query into a Mongo Query
-	mongoQuery, err := converter.ConvertSQLQueryToMongoQuery(sqlQuery)
+	mongoQuery, err := converter.ConvertSQLQueryToMongoQueryWithOptions(sqlQuery, options)
 	if err != nil {
-		return "", err
+		return Result{}, err
 	}
 
 	fmt.Printf("Mongo Query: %+v\n", mongoQuery)
 
 	// Generate the Mongo Query
-	mongoQueryStr := mongo.GenerateMongoQuery(mongoQuery)
-
-	return mongoQueryStr, nil
+	return Result{Query: mongo.GenerateMongoQuery(mongoQuery), Warnings: mongoQuery.Warnings}, nil
 }
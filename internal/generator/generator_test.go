@@ -3,6 +3,7 @@ package generator
 import (
 	"testing"
 
+	"github.com/oabraham1/mongosqlgen/internal/converter"
 	"github.com/stretchr/testify/require"
 )
 
@@ -62,4 +63,1047 @@ func TestGenerateMongoQueryFromSQLQuery(t *testing.T) {
 	got, err = GenerateMongoQueryFromSQLQuery(input)
 	require.NoError(t, err)
 	require.Equal(t, want, got)
+
+	// Test for an UPDATE query with a compound filter
+	input = "UPDATE users SET firstName = 'John' WHERE lastName = 'Doe' AND age IS NULL OR id IN (1, 2)"
+	want = `db.users.update({$or: [{lastName: "Doe", age: null}, {id: {$in: [1, 2]}}]}, {$set: {firstName: "John"}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a DELETE query with a compound filter
+	input = "DELETE FROM users WHERE firstName = 'John' OR age IS NOT NULL"
+	want = `db.users.deleteOne({$or: [{firstName: "John"}, {age: {$ne: null}}]})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for UPDATE and DELETE queries on an aliased table
+	input = "UPDATE users u SET age = 21 WHERE u.name = 'Bob'"
+	want = `db.users.update({name: "Bob"}, {$set: {age: 21}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	input = "DELETE FROM users u WHERE u.name = 'Bob'"
+	want = `db.users.deleteOne({name: "Bob"})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for UPDATE and DELETE queries that can not be parsed
+	for _, input := range []string{
+		"DELETE FROM users WHERE a = 1 LIMIT 1",
+		"DELETE FROM users WHERE a = 1 OR",
+		"DELETE FROM users WHERE a = 1 AND b SIMILAR TO 'x'",
+		"DELETE FROM users WHERE b ILIKE 'x'",
+		"UPDATE users SET a = 1 WHERE b IN (SELECT x FROM y)",
+	} {
+		_, err = GenerateMongoQueryFromSQLQuery(input)
+		require.Error(t, err, input)
+	}
+
+	// Test for keywords as qualified column names and explicit aliases
+	input = "SELECT u.limit AS full FROM users u WHERE u.order > 1"
+	want = `db.users.find({order: {$gt: 1}}, {full: "$limit"})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a clause keyword after the table, which is not read as its alias
+	input = "SELECT * FROM users OFFSET 3"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "unexpected OFFSET")
+}
+
+func TestGenerateAggregateQueryFromSQLQuery(t *testing.T) {
+	// Test for a COUNT(*) query with a WHERE clause
+	input := "SELECT COUNT(*) FROM users WHERE active = true"
+	want := `db.users.countDocuments({active: true})`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a COUNT of a constant
+	input = "SELECT COUNT(1) FROM users WHERE active = true"
+	want = `db.users.countDocuments({active: true})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a COUNT of an expression
+	input = "SELECT COUNT(UPPER(name)) AS n FROM users"
+	want = `db.users.aggregate([{$facet: {groups: [{$group: {_id: null, n: {$sum: {$cond: [{$eq: [{$ifNull: [{$toUpper: "$name"}, null]}, null]}, 0, 1]}}}}]}}, {$replaceWith: {$ifNull: [{$arrayElemAt: ["$groups", 0]}, {_id: null, n: 0}]}}, {$project: {_id: 0, n: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for aggregates without GROUP BY, which return one row even when no rows match
+	input = "SELECT SUM(amount), MAX(age), COUNT(name) FROM users WHERE active = false"
+	want = `db.users.aggregate([{$match: {active: false}}, {$facet: {groups: [{$group: {_id: null, "SUM(amount)": {$sum: "$amount"}, "MAX(age)": {$max: "$age"}, "COUNT(name)": {$sum: {$cond: [{$eq: [{$ifNull: ["$name", null]}, null]}, 0, 1]}}}}]}}, {$replaceWith: {$ifNull: [{$arrayElemAt: ["$groups", 0]}, {_id: null, "SUM(amount)": null, "MAX(age)": null, "COUNT(name)": 0}]}}, {$project: {_id: 0, "SUM(amount)": 1, "MAX(age)": 1, "COUNT(name)": 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for SELECT DISTINCT
+	input = "SELECT DISTINCT city, country FROM users WHERE active = true ORDER BY city"
+	want = `db.users.aggregate([{$match: {active: true}}, {$group: {_id: {city: "$city", country: "$country"}}}, {$sort: {"_id.city": 1}}, {$project: {_id: 0, city: "$_id.city", country: "$_id.country"}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for SELECT DISTINCT with GROUP BY
+	input = "SELECT DISTINCT country, COUNT(*) FROM orders GROUP BY country"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "SELECT DISTINCT is not supported with GROUP BY or HAVING")
+
+	// Test for aggregates without a GROUP BY clause
+	input = "SELECT MAX(age), AVG(score) FROM t"
+	want = `db.t.aggregate([{$facet: {groups: [{$group: {_id: null, "MAX(age)": {$max: "$age"}, "AVG(score)": {$avg: "$score"}}}]}}, {$replaceWith: {$ifNull: [{$arrayElemAt: ["$groups", 0]}, {_id: null, "MAX(age)": null, "AVG(score)": null}]}}, {$project: {_id: 0, "MAX(age)": 1, "AVG(score)": 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for an expression over aggregates with a WHERE clause
+	input = "SELECT SUM(total) / COUNT(*) AS average FROM orders WHERE status = 'paid'"
+	want = `db.orders.aggregate([{$match: {status: "paid"}}, {$facet: {groups: [{$group: {_id: null, "SUM(total)": {$sum: "$total"}, "COUNT(*)": {$sum: 1}}}]}}, {$replaceWith: {$ifNull: [{$arrayElemAt: ["$groups", 0]}, {_id: null, "SUM(total)": null, "COUNT(*)": 0}]}}, {$project: {_id: 0, average: {$divide: ["$SUM(total)", "$COUNT(*)"]}}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a GROUP BY query
+	input = "SELECT country, COUNT(*), SUM(total) FROM orders WHERE status = 'paid' GROUP BY country"
+	want = `db.orders.aggregate([{$match: {status: "paid"}}, {$group: {_id: "$country", "COUNT(*)": {$sum: 1}, "SUM(total)": {$sum: "$total"}}}, {$project: {_id: 0, country: "$_id", "COUNT(*)": 1, "SUM(total)": 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a GROUP BY query with several keys and COUNT(DISTINCT)
+	input = "SELECT country, city, COUNT(DISTINCT customer) AS customers FROM orders GROUP BY country, city"
+	want = `db.orders.aggregate([{$group: {_id: {country: "$country", city: "$city"}, customers: {$addToSet: "$customer"}}}, {$addFields: {customers: {$size: {$setDifference: ["$customers", [null]]}}}}, {$project: {_id: 0, country: "$_id.country", city: "$_id.city", customers: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a GROUP BY query that selects _id
+	input = "SELECT _id, COUNT(*) AS n FROM orders GROUP BY _id"
+	want = `db.orders.aggregate([{$group: {_id: "$_id", n: {$sum: 1}}}, {$project: {_id: 1, n: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a column selected twice
+	input = "SELECT MIN(total), MIN(total) FROM orders"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "column MIN(total) is selected more than once, give each column a distinct alias")
+
+	// Test for a HAVING clause with an aggregate that is not selected
+	input = "SELECT country, COUNT(*) FROM orders GROUP BY country HAVING COUNT(*) > 5 AND SUM(total) >= 100"
+	want = `db.orders.aggregate([{$group: {_id: "$country", "COUNT(*)": {$sum: 1}, "SUM(total)": {$sum: "$total"}}}, {$match: {"COUNT(*)": {$gt: 5}, "SUM(total)": {$gte: 100}}}, {$project: {_id: 0, country: "$_id", "COUNT(*)": 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a column that is not grouped
+	input = "SELECT city, COUNT(*) FROM orders GROUP BY country"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.Error(t, err)
+
+	// Test for a column mixed with aggregates without a GROUP BY clause
+	input = "SELECT name, COUNT(*) FROM users"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.Error(t, err)
+}
+
+func TestGenerateJoinQueryFromSQLQuery(t *testing.T) {
+	// Test for an INNER JOIN on a single equality
+	input := "SELECT u.name, o.total FROM users u JOIN orders o ON o.user_id = u._id"
+	want := `db.users.aggregate([{$lookup: {from: "orders", localField: "_id", foreignField: "user_id", as: "o"}}, {$unwind: "$o"}, {$project: {name: 1, total: "$o.total"}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for columns of the same name from joined tables, which are named by their qualified path
+	input = "SELECT u.name, o.name FROM users u JOIN orders o ON o.user_id = u._id"
+	want = `db.users.aggregate([{$lookup: {from: "orders", localField: "_id", foreignField: "user_id", as: "o"}}, {$unwind: "$o"}, {$project: {u_name: "$name", o_name: "$o.name"}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for joined columns given the same alias
+	input = "SELECT u.name AS name, o.name AS name FROM users u JOIN orders o ON o.user_id = u._id"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "column name is selected more than once, give each column a distinct alias")
+
+	input = "SELECT u.name, o.total FROM users u LEFT JOIN orders o ON o.user_id = u._id AND o.status = 'paid' WHERE u.active = true AND o.total > 100"
+	want = `db.users.aggregate([{$match: {active: true}}, {$lookup: {from: "orders", let: {u__id: "$_id"}, pipeline: [{$match: {$expr: {$eq: ["$user_id", "$$u__id"]}, status: "paid"}}], as: "o"}}, {$unwind: {path: "$o", preserveNullAndEmptyArrays: true}}, {$match: {"o.total": {$gt: 100}}}, {$project: {name: 1, total: "$o.total"}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a LEFT JOIN with a GROUP BY clause
+	input = "SELECT u.name, COUNT(o._id) AS orders FROM users u LEFT JOIN orders o ON u._id = o.user_id GROUP BY u.name"
+	want = `db.users.aggregate([{$lookup: {from: "orders", localField: "_id", foreignField: "user_id", as: "o"}}, {$unwind: {path: "$o", preserveNullAndEmptyArrays: true}}, {$group: {_id: "$name", orders: {$sum: {$cond: [{$eq: [{$ifNull: ["$o._id", null]}, null]}, 0, 1]}}}}, {$project: {_id: 0, name: "$_id", orders: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a RIGHT JOIN, rewritten as a LEFT JOIN from the joined table
+	input = "SELECT u.name, o.total FROM users u RIGHT JOIN orders o ON o.user_id = u._id"
+	want = `db.orders.aggregate([{$lookup: {from: "users", localField: "user_id", foreignField: "_id", as: "u"}}, {$unwind: {path: "$u", preserveNullAndEmptyArrays: true}}, {$project: {name: "$u.name", total: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a FULL OUTER JOIN, emulated with the unmatched rows of the joined collection
+	input = "SELECT u.name, o.total FROM users u FULL OUTER JOIN orders o ON o.user_id = u._id WHERE u.active = true"
+	want = `db.users.aggregate([{$lookup: {from: "orders", localField: "_id", foreignField: "user_id", as: "o"}}, {$unwind: {path: "$o", preserveNullAndEmptyArrays: true}}, {$unionWith: {coll: "orders", pipeline: [{$lookup: {from: "users", localField: "user_id", foreignField: "_id", as: "u"}}, {$match: {u: {$size: 0}}}, {$unset: "u"}, {$replaceWith: {o: "$$ROOT"}}]}}, {$match: {active: true}}, {$project: {name: 1, total: "$o.total"}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for the warning of a FULL OUTER JOIN
+	result, err := GenerateResultFromSQLQuery(input, converter.Options{})
+	require.NoError(t, err)
+	require.Equal(t, want, result.Query)
+	require.Equal(t, []string{"FULL OUTER JOIN o is emulated with $unionWith, which reads orders a second time and looks up every one of its documents in users"}, result.Warnings)
+
+	// Test for a RIGHT JOIN after another join
+	input = "SELECT * FROM users u JOIN items i ON i.user_id = u._id RIGHT JOIN orders o ON o.user_id = u._id"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.Error(t, err)
+}
+
+func TestGenerateSetOperationQueryFromSQLQuery(t *testing.T) {
+	// Test for a UNION ALL of queries with different column names
+	input := "SELECT name, city FROM users UNION ALL SELECT company, address.city FROM vendors WHERE active = true"
+	want := `db.users.aggregate([{$project: {_id: 0, name: 1, city: 1}}, {$unionWith: {coll: "vendors", pipeline: [{$match: {active: true}}, {$project: {_id: 0, name: "$company", city: "$address.city"}}]}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a UNION of all fields
+	input = "SELECT * FROM users UNION SELECT * FROM admins"
+	want = `db.users.aggregate([{$unionWith: {coll: "admins"}}, {$group: {_id: "$$ROOT"}}, {$replaceWith: "$_id"}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for an EXCEPT
+	input = "SELECT name FROM users EXCEPT SELECT name FROM banned"
+	want = `db.users.aggregate([{$project: {_id: 0, name: 1}}, {$lookup: {from: "banned", let: {c1: "$name"}, pipeline: [{$project: {_id: 0, name: 1}}, {$match: {$expr: {$eq: ["$name", "$$c1"]}}}, {$limit: 1}], as: "_matched"}}, {$match: {_matched: {$size: 0}}}, {$unset: "_matched"}, {$group: {_id: {name: "$name"}}}, {$replaceWith: "$_id"}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for an INTERSECT, which is applied before the UNION that precedes it
+	input = "SELECT name FROM users UNION SELECT name FROM admins INTERSECT SELECT name FROM staff"
+	want = `db.users.aggregate([{$project: {_id: 0, name: 1}}, {$unionWith: {coll: "admins", pipeline: [{$project: {_id: 0, name: 1}}, {$lookup: {from: "staff", let: {c1: "$name"}, pipeline: [{$project: {_id: 0, name: 1}}, {$match: {$expr: {$eq: ["$name", "$$c1"]}}}, {$limit: 1}], as: "_matched"}}, {$match: {_matched: {$not: {$size: 0}}}}, {$unset: "_matched"}, {$group: {_id: {name: "$name"}}}, {$replaceWith: "$_id"}]}}, {$group: {_id: {name: "$name"}}}, {$replaceWith: "$_id"}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for an INTERSECT of columns named differently from the query it is subtracted from
+	input = "SELECT name FROM users EXCEPT SELECT login FROM admins INTERSECT SELECT name FROM staff"
+	want = `db.users.aggregate([{$project: {_id: 0, name: 1}}, {$lookup: {from: "admins", let: {c1: "$name"}, pipeline: [{$project: {_id: 0, login: 1}}, {$lookup: {from: "staff", let: {c1: "$login"}, pipeline: [{$project: {_id: 0, login: "$name"}}, {$match: {$expr: {$eq: ["$login", "$$c1"]}}}, {$limit: 1}], as: "_matched"}}, {$match: {_matched: {$not: {$size: 0}}}}, {$unset: "_matched"}, {$group: {_id: {login: "$login"}}}, {$replaceWith: "$_id"}, {$project: {_id: 0, name: "$login"}}, {$match: {$expr: {$eq: ["$name", "$$c1"]}}}, {$limit: 1}], as: "_matched"}}, {$match: {_matched: {$size: 0}}}, {$unset: "_matched"}, {$group: {_id: {name: "$name"}}}, {$replaceWith: "$_id"}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for queries with different numbers of columns
+	input = "SELECT a, b FROM x UNION SELECT a FROM y"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.Error(t, err)
+}
+
+func TestGenerateDerivedTableQueryFromSQLQuery(t *testing.T) {
+	// Test for a filter on the result of a grouped subquery
+	input := "SELECT * FROM (SELECT user_id, SUM(total) s FROM orders GROUP BY user_id) t WHERE t.s > 100"
+	want := `db.orders.aggregate([{$group: {_id: "$user_id", s: {$sum: "$total"}}}, {$project: {_id: 0, user_id: "$_id", s: 1}}, {$match: {s: {$gt: 100}}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for nested subqueries
+	input = "SELECT t.user_id FROM (SELECT user_id, s FROM (SELECT user_id, SUM(total) AS s FROM orders WHERE status = 'paid' GROUP BY user_id) a WHERE s > 10) t"
+	want = `db.orders.aggregate([{$match: {status: "paid"}}, {$group: {_id: "$user_id", s: {$sum: "$total"}}}, {$project: {_id: 0, user_id: "$_id", s: 1}}, {$match: {s: {$gt: 10}}}, {$project: {_id: 0, user_id: 1, s: 1}}, {$project: {user_id: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a count of the rows of a set operation
+	input = "SELECT COUNT(*) FROM (SELECT name FROM users UNION SELECT name FROM admins) n"
+	want = `db.users.aggregate([{$project: {_id: 0, name: 1}}, {$unionWith: {coll: "admins", pipeline: [{$project: {_id: 0, name: 1}}]}}, {$group: {_id: {name: "$name"}}}, {$replaceWith: "$_id"}, {$facet: {groups: [{$group: {_id: null, "COUNT(*)": {$sum: 1}}}]}}, {$replaceWith: {$ifNull: [{$arrayElemAt: ["$groups", 0]}, {_id: null, "COUNT(*)": 0}]}}, {$project: {_id: 0, "COUNT(*)": 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestGenerateCommonTableExpressionQueryFromSQLQuery(t *testing.T) {
+	// Test for a common table expression used as a table
+	input := "WITH big AS (SELECT user_id, SUM(total) AS s FROM orders GROUP BY user_id) SELECT * FROM big WHERE s > 100"
+	want := `db.orders.aggregate([{$group: {_id: "$user_id", s: {$sum: "$total"}}}, {$project: {_id: 0, user_id: "$_id", s: 1}}, {$match: {s: {$gt: 100}}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a joined common table expression
+	input = "WITH big AS (SELECT user_id, SUM(total) AS s FROM orders GROUP BY user_id) SELECT u.name, b.s FROM users u JOIN big b ON b.user_id = u._id"
+	want = `db.users.aggregate([{$lookup: {from: "orders", let: {u__id: "$_id"}, pipeline: [{$group: {_id: "$user_id", s: {$sum: "$total"}}}, {$project: {_id: 0, user_id: "$_id", s: 1}}, {$match: {$expr: {$eq: ["$user_id", "$$u__id"]}}}], as: "b"}}, {$unwind: "$b"}, {$project: {name: 1, s: "$b.s"}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a recursive walk down an org chart
+	input = "WITH RECURSIVE tree AS (SELECT _id, name, manager_id, 1 AS level FROM employees WHERE _id = 1 UNION ALL SELECT e._id, e.name, e.manager_id, t.level + 1 FROM employees e JOIN tree t ON e.manager_id = t._id WHERE t.level < 5 AND e.active = true) SELECT name, level FROM tree"
+	want = `db.employees.aggregate([{$match: {_id: 1}}, {$graphLookup: {from: "employees", startWith: "$_id", connectFromField: "_id", connectToField: "manager_id", as: "_tree", maxDepth: 3, depthField: "_depth", restrictSearchWithMatch: {active: true}}}, {$project: {_id: 0, _tree: {$concatArrays: [[{$mergeObjects: ["$$ROOT", {_tree: null, _depth: -1}]}], "$_tree"]}}}, {$unwind: "$_tree"}, {$replaceWith: "$_tree"}, {$project: {_id: 1, name: 1, manager_id: 1, level: {$add: ["$_depth", 2]}}}, {$project: {name: 1, level: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a recursive query that does not walk a collection
+	input = "WITH RECURSIVE n AS (SELECT 1 AS x FROM t UNION ALL SELECT x + 1 FROM n WHERE x < 10) SELECT * FROM n"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.Error(t, err)
+}
+
+func TestGenerateWindowQueryFromSQLQuery(t *testing.T) {
+	// Test for window functions sharing a partition and order
+	input := "SELECT name, ROW_NUMBER() OVER (PARTITION BY dept ORDER BY salary DESC) AS rn, RANK() OVER (PARTITION BY dept ORDER BY salary DESC) r FROM emp"
+	want := `db.emp.aggregate([{$setWindowFields: {partitionBy: "$dept", sortBy: {salary: -1}, output: {rn: {$documentNumber: {}}, r: {$rank: {}}}}}, {$project: {name: 1, rn: 1, r: 1}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for the top rows of each group
+	input = "SELECT * FROM (SELECT name, dept, ROW_NUMBER() OVER (PARTITION BY dept ORDER BY salary DESC) AS rn FROM emp) t WHERE rn <= 3"
+	want = `db.emp.aggregate([{$setWindowFields: {partitionBy: "$dept", sortBy: {salary: -1}, output: {rn: {$documentNumber: {}}}}}, {$project: {_id: 0, name: 1, dept: 1, rn: 1}}, {$match: {rn: {$lte: 3}}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a moving sum and a previous value
+	input = "SELECT day, SUM(amount) OVER (ORDER BY day ROWS BETWEEN 6 PRECEDING AND CURRENT ROW) AS week, LAG(amount, 1, 0) OVER (ORDER BY day) prev FROM sales"
+	want = `db.sales.aggregate([{$setWindowFields: {sortBy: {day: 1}, output: {week: {$sum: "$amount", window: {documents: [-6, "current"]}}, prev: {$shift: {output: "$amount", by: -1, default: 0}}}}}, {$project: {day: 1, week: 1, prev: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for the default frame of a window ordered by strings and by several keys
+	input = "SELECT name, SUM(amount) OVER (PARTITION BY dept ORDER BY name) AS running, COUNT(*) OVER (ORDER BY dept, name) AS n FROM emp"
+	want = `db.emp.aggregate([{$setWindowFields: {partitionBy: "$dept", sortBy: {name: 1}, output: {running: {$sum: "$amount", window: {documents: ["unbounded", "current"]}}}}}, {$setWindowFields: {sortBy: {dept: 1, name: 1}, output: {n: {$sum: 1, window: {documents: ["unbounded", "current"]}}}}}, {$project: {name: 1, running: 1, n: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for the default frame of a window ordered by a number, which includes the rows that tie with the current one
+	input = "SELECT day, SUM(amount) OVER (ORDER BY amount * 2) AS s FROM sales"
+	want = `db.sales.aggregate([{$set: {_sort1: {$multiply: ["$amount", 2]}}}, {$setWindowFields: {sortBy: {_sort1: 1}, output: {s: {$sum: "$amount", window: {range: ["unbounded", "current"]}}}}}, {$project: {day: 1, s: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for the warning of a default frame that leaves out the rows that tie with the current one
+	result, err := GenerateResultFromSQLQuery("SELECT name, SUM(amount) OVER (ORDER BY name) AS running FROM emp", converter.Options{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"SUM(amount) OVER (ORDER BY name) ends its window at the current row and leaves out the rows that sort equal to it, which only a window sorted by a single number or date can include"}, result.Warnings)
+
+	// Test for a RANGE frame of a window ordered by strings
+	input = "SELECT SUM(amount) OVER (ORDER BY UPPER(name) RANGE BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) FROM emp"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "a RANGE frame can only order by a number or date, but UPPER(name) is a string")
+
+	// Test for the removal of a computed sort key of a window
+	input = "SELECT *, ROW_NUMBER() OVER (ORDER BY UPPER(name)) rn FROM emp"
+	want = `db.emp.aggregate([{$set: {_sort1: {$toUpper: "$name"}}}, {$setWindowFields: {sortBy: {_sort1: 1}, output: {rn: {$documentNumber: {}}}}}, {$unset: ["_sort1"]}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a computed sort key shared by a window and the ORDER BY
+	input = "SELECT *, ROW_NUMBER() OVER (ORDER BY UPPER(name)) rn FROM emp ORDER BY UPPER(name)"
+	want = `db.emp.aggregate([{$set: {_sort1: {$toUpper: "$name"}}}, {$setWindowFields: {sortBy: {_sort1: 1}, output: {rn: {$documentNumber: {}}}}}, {$sort: {_sort1: 1}}, {$unset: ["_sort1"]}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a rank of groups
+	input = "SELECT dept, SUM(salary) AS total, RANK() OVER (ORDER BY SUM(salary) DESC) AS r FROM emp GROUP BY dept"
+	want = `db.emp.aggregate([{$group: {_id: "$dept", total: {$sum: "$salary"}}}, {$setWindowFields: {sortBy: {total: -1}, output: {r: {$rank: {}}}}}, {$project: {_id: 0, dept: "$_id", total: 1, r: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a window function in WHERE
+	input = "SELECT name FROM emp WHERE ROW_NUMBER() OVER (ORDER BY a) = 1"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.Error(t, err)
+}
+
+func TestGenerateCaseQueryFromSQLQuery(t *testing.T) {
+	// Test for a searched CASE with a single branch
+	input := "SELECT name, CASE WHEN age >= 18 THEN 'adult' ELSE 'minor' END AS grp FROM users"
+	want := `db.users.find({}, {name: 1, grp: {$cond: [{$gte: ["$age", 18]}, "adult", "minor"]}})`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a simple CASE without ELSE
+	input = "SELECT CASE status WHEN 'A' THEN 'active' WHEN 'I' THEN 'inactive' END AS s FROM users"
+	want = `db.users.find({}, {s: {$switch: {branches: [{case: {$eq: ["$status", "A"]}, then: "active"}, {case: {$eq: ["$status", "I"]}, then: "inactive"}], default: null}}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a CASE in WHERE
+	input = "SELECT * FROM users WHERE CASE WHEN age > 18 THEN 1 ELSE 0 END = 1"
+	want = `db.users.find({$expr: {$eq: [{$cond: [{$gt: ["$age", 18]}, 1, 0]}, 1]}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a CASE in ORDER BY
+	input = "SELECT name FROM users ORDER BY CASE WHEN vip THEN 0 ELSE 1 END, name DESC"
+	want = `db.users.aggregate([{$set: {_order1: {$cond: ["$vip", 0, 1]}}}, {$sort: {_order1: 1, name: -1}}, {$project: {name: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a CASE group key
+	input = "SELECT CASE WHEN age < 18 THEN 'minor' ELSE 'adult' END AS grp, COUNT(*) AS n FROM users GROUP BY CASE WHEN age < 18 THEN 'minor' ELSE 'adult' END ORDER BY n DESC"
+	want = `db.users.aggregate([{$group: {_id: {$cond: [{$lt: ["$age", 18]}, "minor", "adult"]}, n: {$sum: 1}}}, {$sort: {n: -1}}, {$project: {_id: 0, grp: "$_id", n: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a CASE in UPDATE SET
+	input = "UPDATE users SET tier = CASE WHEN points > 100 THEN 'gold' ELSE 'silver' END WHERE active = true"
+	want = `db.users.update({active: true}, [{$set: {tier: {$cond: [{$gt: ["$points", 100]}, "gold", "silver"]}}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a CASE in UPDATE WHERE
+	input = "UPDATE users SET tier = 'gold' WHERE CASE WHEN points > 100 THEN true ELSE false END"
+	want = `db.users.update({$expr: {$cond: [{$gt: ["$points", 100]}, true, false]}}, {$set: {tier: "gold"}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a CASE in DELETE WHERE
+	input = "DELETE FROM users WHERE CASE WHEN age > 18 THEN 1 ELSE 0 END = 0"
+	want = `db.users.deleteOne({$expr: {$eq: [{$cond: [{$gt: ["$age", 18]}, 1, 0]}, 0]}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestGenerateOrderByQueryFromSQLQuery(t *testing.T) {
+	// Test for a sorted find
+	input := "SELECT name, age FROM users WHERE age > 3 ORDER BY 2 DESC, name"
+	want := `db.users.find({age: {$gt: 3}}, {name: 1, age: 1}).sort({age: -1, name: 1})`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a sorted set operation
+	input = "SELECT city FROM a UNION SELECT city FROM b ORDER BY city DESC"
+	want = `db.a.aggregate([{$project: {_id: 0, city: 1}}, {$unionWith: {coll: "b", pipeline: [{$project: {_id: 0, city: 1}}]}}, {$group: {_id: {city: "$city"}}}, {$replaceWith: "$_id"}, {$sort: {city: -1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestGenerateScalarFunctionQueryFromSQLQuery(t *testing.T) {
+	// Test for string functions
+	input := "SELECT UPPER(name) AS n, SUBSTRING(code FROM 2 FOR 3) c, POSITION('@' IN email) AS at, first || ' ' || last AS fullname FROM users"
+	want := `db.users.find({}, {n: {$toUpper: "$name"}, c: {$substrCP: ["$code", 1, 3]}, at: {$add: [{$indexOfCP: ["$email", "@"]}, 1]}, fullname: {$concat: ["$first", " ", "$last"]}})`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for string lengths, which are NULL for a NULL string
+	input = "SELECT LENGTH(name) AS len, SUBSTRING(code, 2) AS rest FROM users WHERE CHAR_LENGTH(name) > 3"
+	want = `db.users.find({$expr: {$gt: [{$cond: [{$eq: [{$ifNull: ["$name", null]}, null]}, null, {$strLenCP: "$name"}]}, 3]}}, {len: {$cond: [{$eq: [{$ifNull: ["$name", null]}, null]}, null, {$strLenCP: "$name"}]}, rest: {$substrCP: ["$code", 1, {$strLenCP: {$ifNull: ["$code", ""]}}]}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for functions in WHERE
+	input = "SELECT * FROM users WHERE LOWER(email) = 'a@b.c' AND COALESCE(age, 0) > 18"
+	want = `db.users.find({$and: [{$expr: {$eq: [{$toLower: "$email"}, "a@b.c"]}}, {$expr: {$gt: [{$ifNull: ["$age", 0]}, 18]}}]})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for math and null handling functions
+	input = "SELECT ROUND(price * 1.2, 2) AS p, NULLIF(a, 0) AS z FROM t"
+	want = `db.t.find({}, {p: {$round: [{$multiply: ["$price", 1.2]}, 2]}, z: {$cond: [{$eq: ["$a", 0]}, null, "$a"]}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a function in UPDATE SET
+	input = "UPDATE users SET name = TRIM(name) WHERE id = 3"
+	want = `db.users.update({id: 3}, [{$set: {name: {$trim: {input: "$name"}}}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for functions in UPDATE and DELETE WHERE
+	input = "UPDATE users SET verified = true WHERE UPPER(name) = 'X'"
+	want = `db.users.update({$expr: {$eq: [{$toUpper: "$name"}, "X"]}}, {$set: {verified: true}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	input = "DELETE FROM users WHERE COALESCE(age, 0) = 0"
+	want = `db.users.deleteOne({$expr: {$eq: [{$ifNull: ["$age", 0]}, 0]}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a wrong argument type
+	input = "SELECT UPPER(5) FROM t"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "UPPER expects a string as argument 1 but got a number: 5")
+}
+
+func TestGenerateDateQueryFromSQLQuery(t *testing.T) {
+	// Test for a date literal in WHERE
+	input := "SELECT * FROM orders WHERE created >= DATE '2024-01-01' AND created < TIMESTAMP '2024-02-01 12:30:00'"
+	want := `db.orders.find({created: {$gte: ISODate("2024-01-01T00:00:00.000Z"), $lt: ISODate("2024-02-01T12:30:00.000Z")}})`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for interval arithmetic
+	input = "SELECT * FROM orders WHERE created > NOW() - INTERVAL '7 days'"
+	want = `db.orders.find({$expr: {$gt: ["$created", {$dateSubtract: {startDate: "$$NOW", unit: "day", amount: 7}}]}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for date functions
+	input = "SELECT DATE_TRUNC('month', created) AS m, EXTRACT(YEAR FROM created) AS y, DATEDIFF(shipped, created) AS days FROM orders"
+	want = `db.orders.find({}, {m: {$dateTrunc: {date: "$created", unit: "month"}}, y: {$year: "$created"}, days: {$dateDiff: {startDate: "$created", endDate: "$shipped", unit: "day"}}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a grouping by month in a default time zone
+	input = "SELECT DATE_TRUNC('month', created) AS month, COUNT(*) AS n FROM orders WHERE created >= DATE '2024-01-01' GROUP BY 1"
+	want = `db.orders.aggregate([{$match: {created: {$gte: ISODate("2023-12-31T23:00:00.000Z")}}}, {$group: {_id: {$dateTrunc: {date: "$created", unit: "month", timezone: "Europe/Paris"}}, n: {$sum: 1}}}, {$project: {_id: 0, month: "$_id", n: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{TimeZone: "Europe/Paris"})
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a time zone argument
+	input = "SELECT EXTRACT(HOUR FROM created) AS h, DATE_PART('dow', created, '+05:30') AS d FROM orders"
+	want = `db.orders.find({}, {h: {$hour: {date: "$created", timezone: "UTC"}}, d: {$subtract: [{$dayOfWeek: {date: "$created", timezone: "+05:30"}}, 1]}})`
+	got, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{TimeZone: "UTC"})
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for interval arithmetic in DELETE WHERE
+	input = "DELETE FROM events WHERE ts < NOW() - INTERVAL '30 days'"
+	want = `db.events.deleteOne({$expr: {$lt: ["$ts", {$dateSubtract: {startDate: "$$NOW", unit: "day", amount: 30}}]}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a date literal in UPDATE and DELETE WHERE
+	input = "UPDATE events SET archived = true WHERE ts < DATE '2024-01-01'"
+	want = `db.events.update({ts: {$lt: ISODate("2024-01-01T00:00:00.000Z")}}, {$set: {archived: true}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	input = "DELETE FROM events WHERE ts < DATE '2024-01-01'"
+	want = `db.events.deleteOne({ts: {$lt: ISODate("2023-12-31T23:00:00.000Z")}})`
+	got, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{TimeZone: "Europe/Paris"})
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for an unknown default time zone
+	input = "SELECT * FROM orders"
+	_, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{TimeZone: "Mars/Olympus"})
+	require.EqualError(t, err, "unknown time zone: Mars/Olympus")
+}
+
+func TestGenerateCastQueryFromSQLQuery(t *testing.T) {
+	// Test for conversions in the SELECT list
+	input := "SELECT CAST(age AS INTEGER) AS age, CONVERT(varchar, id) AS id, price::numeric AS price FROM items"
+	want := `db.items.find({}, {age: {$toInt: "$age"}, id: {$toString: "$id"}, price: {$toDecimal: "$price"}})`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for conversions that return null on bad data
+	input = "SELECT * FROM items WHERE CAST(qty AS INT) > 5"
+	want = `db.items.find({$expr: {$gt: [{$convert: {input: "$qty", to: "int", onError: null}}, 5]}})`
+	got, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{NullOnCastError: true})
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a conversion in DELETE WHERE
+	input = "DELETE FROM items WHERE CAST(qty AS INT) > 5"
+	want = `db.items.deleteOne({$expr: {$gt: [{$toInt: "$qty"}, 5]}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a conversion in UPDATE WHERE that returns null on bad data
+	input = "UPDATE items SET low = true WHERE qty::integer < 3"
+	want = `db.items.update({$expr: {$lt: [{$convert: {input: "$qty", to: "int", onError: null}}, 3]}}, {$set: {low: true}})`
+	got, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{NullOnCastError: true})
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a constant cast to a date
+	input = "SELECT * FROM items WHERE created >= '2024-05-01'::date"
+	want = `db.items.find({created: {$gte: ISODate("2024-05-01T00:00:00.000Z")}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for an unsupported type
+	input = "SELECT CAST(data AS JSONB) FROM items"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "unsupported CAST type: JSONB")
+}
+
+func TestGenerateGroupingSetsQueryFromSQLQuery(t *testing.T) {
+	// Test for ROLLUP subtotals
+	input := "SELECT region, country, SUM(amount) AS total, GROUPING(country) AS subtotal FROM sales GROUP BY ROLLUP(region, country)"
+	want := `db.sales.aggregate([{$facet: {set1: [{$group: {_id: {region: "$region", country: "$country", _grouping: 0}, total: {$sum: "$amount"}}}], set2: [{$group: {_id: {region: "$region", country: null, _grouping: 1}, total: {$sum: "$amount"}}}], set3: [{$group: {_id: {region: null, country: null, _grouping: 1}, total: {$sum: "$amount"}}}]}}, {$project: {rows: {$concatArrays: ["$set1", "$set2", "$set3"]}}}, {$unwind: "$rows"}, {$replaceWith: "$rows"}, {$project: {_id: 0, region: "$_id.region", country: "$_id.country", total: 1, subtotal: "$_id._grouping"}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for GROUPING SETS with a grand total
+	input = "SELECT region, channel, COUNT(*) AS n FROM sales GROUP BY GROUPING SETS ((region), (channel), ()) HAVING COUNT(*) > 10"
+	want = `db.sales.aggregate([{$facet: {set1: [{$group: {_id: {region: "$region", channel: null}, n: {$sum: 1}}}], set2: [{$group: {_id: {region: null, channel: "$channel"}, n: {$sum: 1}}}], set3: [{$group: {_id: {region: null, channel: null}, n: {$sum: 1}}}]}}, {$project: {rows: {$concatArrays: ["$set1", "$set2", "$set3"]}}}, {$unwind: "$rows"}, {$replaceWith: "$rows"}, {$match: {n: {$gt: 10}}}, {$project: {_id: 0, region: "$_id.region", channel: "$_id.channel", n: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for GROUPING of a column that is not grouped
+	input = "SELECT region, GROUPING(channel) FROM sales GROUP BY CUBE(region)"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "arguments of GROUPING must be GROUP BY expressions: channel")
+}
+
+func TestGenerateConditionalAggregationQueryFromSQLQuery(t *testing.T) {
+	// Test for CASE inside an aggregate and FILTER
+	input := "SELECT region, SUM(CASE WHEN status = 'paid' THEN total ELSE 0 END) AS paid, COUNT(*) FILTER (WHERE status = 'open') AS open FROM orders GROUP BY region"
+	want := `db.orders.aggregate([{$group: {_id: "$region", paid: {$sum: {$cond: [{$eq: ["$status", "paid"]}, "$total", 0]}}, open: {$sum: {$cond: [{$eq: ["$status", "open"]}, 1, 0]}}}}, {$project: {_id: 0, region: "$_id", paid: 1, open: 1}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a PIVOT of a derived table
+	input = "SELECT * FROM (SELECT region, quarter, amount FROM sales) AS s PIVOT (SUM(amount) FOR quarter IN ([Q1], [Q2])) AS p"
+	want = `db.sales.aggregate([{$project: {_id: 0, region: 1, quarter: 1, amount: 1}}, {$group: {_id: "$region", Q1: {$sum: {$cond: [{$eq: ["$quarter", "Q1"]}, "$amount", null]}}, Q1_n: {$sum: {$cond: [{$eq: [{$ifNull: [{$cond: [{$eq: ["$quarter", "Q1"]}, "$amount", null]}, null]}, null]}, 0, 1]}}, Q2: {$sum: {$cond: [{$eq: ["$quarter", "Q2"]}, "$amount", null]}}, Q2_n: {$sum: {$cond: [{$eq: [{$ifNull: [{$cond: [{$eq: ["$quarter", "Q2"]}, "$amount", null]}, null]}, null]}, 0, 1]}}}}, {$addFields: {Q1: {$cond: [{$eq: ["$Q1_n", 0]}, null, "$Q1"]}, Q2: {$cond: [{$eq: ["$Q2_n", 0]}, null, "$Q2"]}}}, {$project: {_id: 0, region: "$_id", Q1: 1, Q2: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a PIVOT whose cells are null when no row holds their value
+	input = "SELECT region, [Q4] FROM sales PIVOT (SUM(amount) FOR quarter IN ([Q4])) AS p"
+	want = `db.sales.aggregate([{$group: {_id: "$region", Q4: {$sum: {$cond: [{$eq: ["$quarter", "Q4"]}, "$amount", null]}}, Q4_n: {$sum: {$cond: [{$eq: [{$ifNull: [{$cond: [{$eq: ["$quarter", "Q4"]}, "$amount", null]}, null]}, null]}, 0, 1]}}}}, {$addFields: {Q4: {$cond: [{$eq: ["$Q4_n", 0]}, null, "$Q4"]}}}, {$project: {_id: 0, region: "$_id", Q4: 1}}, {$project: {region: 1, Q4: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a PIVOT of a collection filtered on a pivoted column
+	input = "SELECT region, [Q1] FROM sales PIVOT (SUM(amount) FOR quarter IN ([Q1])) AS p WHERE [Q1] > 100"
+	want = `db.sales.aggregate([{$group: {_id: "$region", Q1: {$sum: {$cond: [{$eq: ["$quarter", "Q1"]}, "$amount", null]}}, Q1_n: {$sum: {$cond: [{$eq: [{$ifNull: [{$cond: [{$eq: ["$quarter", "Q1"]}, "$amount", null]}, null]}, null]}, 0, 1]}}}}, {$addFields: {Q1: {$cond: [{$eq: ["$Q1_n", 0]}, null, "$Q1"]}}}, {$project: {_id: 0, region: "$_id", Q1: 1}}, {$match: {Q1: {$gt: 100}}}, {$project: {region: 1, Q1: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for FILTER in a window function
+	input = "SELECT SUM(total) FILTER (WHERE paid) OVER (PARTITION BY region) FROM orders"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "FILTER is not supported in window functions: SUM(total) FILTER (WHERE paid) OVER (PARTITION BY region)")
+}
+
+func TestGenerateSampleQueryFromSQLQuery(t *testing.T) {
+	// Test for TABLESAMPLE
+	input := "SELECT * FROM events TABLESAMPLE SYSTEM (1) WHERE type = 'click'"
+	want := `db.events.aggregate([{$sample: {size: Math.ceil(db.events.estimatedDocumentCount() * 0.01)}}, {$match: {type: "click"}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for ORDER BY RANDOM() with LIMIT
+	input = "SELECT name FROM events WHERE type = 'click' ORDER BY RANDOM() LIMIT 100"
+	want = `db.events.aggregate([{$match: {type: "click"}}, {$sample: {size: 100}}, {$project: {name: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for ORDER BY RAND() of groups with LIMIT
+	input = "SELECT type, COUNT(*) AS n FROM events GROUP BY type ORDER BY RAND() LIMIT 3"
+	want = `db.events.aggregate([{$group: {_id: "$type", n: {$sum: 1}}}, {$sample: {size: 3}}, {$project: {_id: 0, type: "$_id", n: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for LIMIT without a random order
+	input = "SELECT name FROM users ORDER BY name LIMIT 10"
+	want = `db.users.find({}, {name: 1}).sort({name: 1}).limit(10)`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestGenerateUnnestQueryFromSQLQuery(t *testing.T) {
+	// Test for CROSS JOIN UNNEST
+	input := "SELECT o._id, i.sku FROM orders o CROSS JOIN UNNEST(o.items) AS i WHERE o.status = 'paid' AND i.qty > 1"
+	want := `db.orders.aggregate([{$match: {status: "paid"}}, {$set: {i: "$items"}}, {$unwind: "$i"}, {$match: {"i.qty": {$gt: 1}}}, {$project: {_id: 1, sku: "$i.sku"}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for LEFT JOIN LATERAL UNNEST WITH ORDINALITY
+	input = "SELECT t, n FROM posts LEFT JOIN LATERAL UNNEST(tags) WITH ORDINALITY AS t(tag, n) ON TRUE WHERE n = 1"
+	want = `db.posts.aggregate([{$set: {t: "$tags"}}, {$unwind: {path: "$t", includeArrayIndex: "t_n", preserveNullAndEmptyArrays: true}}, {$set: {t_n: {$add: ["$t_n", 1]}}}, {$match: {t_n: 1}}, {$project: {t: 1, n: "$t_n"}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a CROSS JOIN of a collection
+	input = "SELECT * FROM orders CROSS JOIN users"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "CROSS JOIN users is only supported with UNNEST")
+}
+
+func TestGenerateJSONQueryFromSQLQuery(t *testing.T) {
+	// Test for PostgreSQL JSON operators
+	input := "SELECT data->'address'->>'city' AS city FROM users WHERE data->'tags'->>0 = 'vip'"
+	want := `db.users.find({"data.tags.0": "vip"}, {city: "$data.address.city"})`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for MySQL JSON functions
+	input = "SELECT JSON_EXTRACT(data, '$.a.b[0]') AS first FROM t WHERE JSON_VALUE(data, '$.a.c') > 3"
+	want = `db.t.find({"data.a.c": {$gt: 3}}, {first: {$arrayElemAt: ["$data.a.b", 0]}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a JSON field used as a group key
+	input = "SELECT data->>'city' AS city, COUNT(*) AS n FROM users GROUP BY data->>'city'"
+	want = `db.users.aggregate([{$group: {_id: "$data.city", n: {$sum: 1}}}, {$project: {_id: 0, city: "$_id", n: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a JSON operator in UPDATE WHERE
+	input = "UPDATE users SET region = 'EU' WHERE data->>'city' = 'Paris'"
+	want = `db.users.update({"data.city": "Paris"}, {$set: {region: "EU"}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for JSON functions in UPDATE and DELETE WHERE
+	input = "UPDATE users SET region = 'EU' WHERE JSON_VALUE(data, '$.address.city') = 'Paris'"
+	want = `db.users.update({"data.address.city": "Paris"}, {$set: {region: "EU"}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	input = "DELETE FROM users WHERE data->>'city' = 'Paris'"
+	want = `db.users.deleteOne({"data.city": "Paris"})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestGenerateExclusionProjectionQueryFromSQLQuery(t *testing.T) {
+	// Test for SELECT * EXCEPT
+	input := "SELECT * EXCEPT (password, ssn) FROM users WHERE age > 21"
+	want := `db.users.find({age: {$gt: 21}}, {password: 0, ssn: 0})`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for SELECT * EXCLUDE
+	input = "SELECT * EXCLUDE (password) FROM users"
+	want = `db.users.find({}, {password: 0})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for computed columns next to *
+	input = "SELECT *, price * 2 AS dbl FROM items"
+	want = `db.items.aggregate([{$set: {dbl: {$multiply: ["$price", 2]}}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for computed columns next to * EXCEPT
+	input = "SELECT * EXCEPT (cost), price * qty AS total FROM items"
+	want = `db.items.aggregate([{$set: {total: {$multiply: ["$price", "$qty"]}}}, {$project: {cost: 0}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for columns listed before *
+	input = "SELECT name, * FROM users"
+	want = `db.users.find({})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for EXCEPT on the columns of a joined table
+	input = "SELECT o.* EXCEPT (total) FROM users u JOIN orders o ON u._id = o.user_id"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.Error(t, err)
+	// Test for the * of the queried table in a join
+	input = "SELECT u.* FROM users u JOIN orders o ON o.user_id = u._id"
+	want = `db.users.aggregate([{$lookup: {from: "orders", localField: "_id", foreignField: "user_id", as: "o"}}, {$unwind: "$o"}, {$project: {o: 0}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for the * of the queried table next to a column of a joined table
+	input = "SELECT u.*, o.total FROM users u JOIN orders o ON o.user_id = u._id"
+	want = `db.users.aggregate([{$lookup: {from: "orders", localField: "_id", foreignField: "user_id", as: "o"}}, {$unwind: "$o"}, {$set: {total: "$o.total"}}, {$project: {o: 0}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for the * of a joined table
+	input = "SELECT o.* FROM users u LEFT JOIN orders o ON o.user_id = u._id"
+	want = `db.users.aggregate([{$lookup: {from: "orders", localField: "_id", foreignField: "user_id", as: "o"}}, {$unwind: {path: "$o", preserveNullAndEmptyArrays: true}}, {$replaceWith: {$mergeObjects: ["$o"]}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for the * of a joined table next to a column of the queried table
+	input = "SELECT o.*, u.name FROM users u JOIN orders o ON o.user_id = u._id"
+	want = `db.users.aggregate([{$lookup: {from: "orders", localField: "_id", foreignField: "user_id", as: "o"}}, {$unwind: "$o"}, {$replaceWith: {$mergeObjects: ["$o", {name: "$name"}]}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+}
+
+func TestGenerateIndexHintQueryFromSQLQuery(t *testing.T) {
+	// Test for MySQL index hints
+	input := "SELECT * FROM users FORCE INDEX (idx_email) WHERE email = 'bob@example.com'"
+	want := `db.users.find({email: "bob@example.com"}).hint("idx_email")`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for T-SQL table hints
+	input = "SELECT status, COUNT(*) AS n FROM users WITH (INDEX(idx_status)) GROUP BY status"
+	want = `db.users.aggregate([{$group: {_id: "$status", n: {$sum: 1}}}, {$project: {_id: 0, status: "$_id", n: 1}}], {hint: "idx_status"})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for optimizer hint comments
+	input = "SELECT /*+ INDEX(u idx_email) */ COUNT(*) FROM users u WHERE u.email = 'bob@example.com'"
+	want = `db.users.countDocuments({email: "bob@example.com"}, {hint: "idx_email"})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for an index hint on an update
+	input = "UPDATE /*+ INDEX(users idx_email) */ users SET active = false WHERE email = 'bob@example.com'"
+	want = `db.users.update({email: "bob@example.com"}, {$set: {active: false}}, {hint: "idx_email"})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for an index hint on a delete
+	input = "DELETE FROM users WITH (INDEX(idx_email)) WHERE email = 'bob@example.com'"
+	want = `db.users.deleteOne({email: "bob@example.com"}, {hint: "idx_email"})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestGenerateCollationQueryFromSQLQuery(t *testing.T) {
+	// Test for an ICU collation in a filter
+	input := `SELECT * FROM users WHERE name = 'muller' COLLATE "de-u-ks-level1"`
+	want := `db.users.find({name: "muller"}).collation({locale: "de", strength: 1})`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a MySQL collation in ORDER BY
+	input = "SELECT name FROM users ORDER BY name COLLATE utf8mb4_0900_ai_ci"
+	want = `db.users.find({}, {name: 1}).sort({name: 1}).collation({locale: "en", strength: 1})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a collation of an aggregation
+	input = "SELECT name, COUNT(*) AS n FROM users GROUP BY name COLLATE latin1_swedish_ci"
+	want = `db.users.aggregate([{$group: {_id: "$name", n: {$sum: 1}}}, {$project: {_id: 0, name: "$_id", n: 1}}], {collation: {locale: "sv", strength: 1}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a collation of a delete
+	input = "DELETE FROM users WHERE name = 'bob' COLLATE utf8mb4_unicode_ci"
+	want = `db.users.deleteOne({name: "bob"}, {collation: {locale: "en", strength: 1}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a SQL Server collation
+	input = "SELECT name FROM users WHERE name = 'bob' COLLATE SQL_Latin1_General_CP1_CI_AS"
+	want = `db.users.find({name: "bob"}, {name: 1}).collation({locale: "en", strength: 2})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a collation of a language MongoDB has no collation for
+	input = "SELECT name FROM users WHERE name = 'bob' COLLATE \"xx-bogus\""
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.EqualError(t, err, "unsupported collation xx-bogus")
+
+	// Test for conflicting collations
+	input = "SELECT name FROM users WHERE name = 'bob' COLLATE utf8mb4_0900_ai_ci ORDER BY name COLLATE utf8mb4_0900_as_cs"
+	_, err = GenerateMongoQueryFromSQLQuery(input)
+	require.Error(t, err)
+}
+
+func TestGeneratePercentileQueryFromSQLQuery(t *testing.T) {
+	// Test for PERCENTILE_CONT with $percentile
+	input := "SELECT route, PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY ms) AS p95 FROM requests GROUP BY route"
+	want := `db.requests.aggregate([{$group: {_id: "$route", p95: {$percentile: {input: "$ms", p: [0.95], method: "approximate"}}}}, {$addFields: {p95: {$arrayElemAt: ["$p95", 0]}}}, {$project: {_id: 0, route: "$_id", p95: 1}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for MEDIAN with $median
+	input = "SELECT MEDIAN(ms) AS m FROM requests"
+	want = `db.requests.aggregate([{$facet: {groups: [{$group: {_id: null, m: {$median: {input: "$ms", method: "approximate"}}}}]}}, {$replaceWith: {$ifNull: [{$arrayElemAt: ["$groups", 0]}, {_id: null, m: null}]}}, {$project: {_id: 0, m: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{ServerVersion: "7.0"})
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for PERCENTILE_DISC on servers without $percentile
+	input = "SELECT PERCENTILE_DISC(0.9) WITHIN GROUP (ORDER BY ms) AS p90 FROM requests"
+	want = `db.requests.aggregate([{$facet: {groups: [{$group: {_id: null, p90: {$push: "$ms"}}}]}}, {$replaceWith: {$ifNull: [{$arrayElemAt: ["$groups", 0]}, {_id: null, p90: []}]}}, {$addFields: {p90: {$let: {vars: {values: {$sortArray: {input: {$filter: {input: "$p90", cond: {$ne: ["$$this", null]}}}, sortBy: 1}}}, in: {$arrayElemAt: ["$$values", {$max: [0, {$subtract: [{$ceil: {$multiply: [0.9, {$size: "$$values"}]}}, 1]}]}]}}}}}, {$project: {_id: 0, p90: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{ServerVersion: "6.0"})
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a percentile on servers without $sortArray
+	input = "SELECT MEDIAN(ms) AS m FROM requests"
+	_, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{ServerVersion: "5.0"})
+	require.EqualError(t, err, "MEDIAN requires MongoDB 5.2 or later, which added $sortArray")
+
+	// Test for an invalid server version
+	_, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{ServerVersion: "latest"})
+	require.Error(t, err)
+}
+
+func TestGenerateStringAggregateQueryFromSQLQuery(t *testing.T) {
+	// Test for STRING_AGG sorted by another column
+	input := "SELECT customer, STRING_AGG(product, ', ' ORDER BY created_at DESC) AS products FROM orders GROUP BY customer"
+	want := `db.orders.aggregate([{$group: {_id: "$customer", products: {$push: {value: {$toString: "$product"}, key1: "$created_at"}}}}, {$addFields: {products: {$reduce: {input: {$filter: {input: {$map: {input: {$sortArray: {input: "$products", sortBy: {key1: -1}}}, in: "$$this.value"}}, cond: {$ne: ["$$this", null]}}}, initialValue: null, in: {$cond: [{$eq: ["$$value", null]}, "$$this", {$concat: ["$$value", ", ", "$$this"]}]}}}}}, {$project: {_id: 0, customer: "$_id", products: 1}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for GROUP_CONCAT with a separator
+	input = "SELECT customer, GROUP_CONCAT(DISTINCT product ORDER BY product SEPARATOR ';') AS products FROM orders GROUP BY customer"
+	want = `db.orders.aggregate([{$group: {_id: "$customer", products: {$addToSet: {$toString: "$product"}}}}, {$addFields: {products: {$reduce: {input: {$filter: {input: {$sortArray: {input: "$products", sortBy: 1}}, cond: {$ne: ["$$this", null]}}}, initialValue: null, in: {$cond: [{$eq: ["$$value", null]}, "$$this", {$concat: ["$$value", ";", "$$this"]}]}}}}}, {$project: {_id: 0, customer: "$_id", products: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for ARRAY_AGG
+	input = "SELECT customer, ARRAY_AGG(product) AS products FROM orders GROUP BY customer"
+	want = `db.orders.aggregate([{$group: {_id: "$customer", products: {$push: "$product"}}}, {$project: {_id: 0, customer: "$_id", products: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for JSON_AGG of whole rows
+	input = "SELECT customer, JSON_AGG(o ORDER BY o.total) AS orders FROM orders o GROUP BY customer"
+	want = `db.orders.aggregate([{$group: {_id: "$customer", orders: {$push: {value: "$$ROOT", key1: "$total"}}}}, {$addFields: {orders: {$map: {input: {$sortArray: {input: "$orders", sortBy: {key1: 1}}}, in: "$$this.value"}}}}, {$project: {_id: 0, customer: "$_id", orders: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a sorted aggregate on servers without $sortArray
+	input = "SELECT STRING_AGG(name, ',' ORDER BY name) AS names FROM users"
+	_, err = GenerateMongoQueryFromSQLQueryWithOptions(input, converter.Options{ServerVersion: "5.0"})
+	require.Error(t, err)
+}
+
+func TestGenerateStatisticalQueryFromSQLQuery(t *testing.T) {
+	// Test for standard deviations and variances
+	input := "SELECT region, STDDEV(amount) AS sd, VAR_POP(amount) AS v FROM sales GROUP BY region"
+	want := `db.sales.aggregate([{$group: {_id: "$region", sd: {$stdDevSamp: "$amount"}, v: {$stdDevPop: "$amount"}}}, {$addFields: {v: {$pow: ["$v", 2]}}}, {$project: {_id: 0, region: "$_id", sd: 1, v: 1}}])`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for CORR computed from sums of the pairs
+	input = "SELECT region, CORR(revenue, spend) AS r FROM sales GROUP BY region"
+	want = `db.sales.aggregate([{$group: {_id: "$region", r: {$sum: {$cond: [{$and: [{$isNumber: "$revenue"}, {$isNumber: "$spend"}]}, 1, 0]}}, r_sx: {$sum: {$cond: [{$and: [{$isNumber: "$revenue"}, {$isNumber: "$spend"}]}, "$spend", 0]}}, r_sxx: {$sum: {$cond: [{$and: [{$isNumber: "$revenue"}, {$isNumber: "$spend"}]}, {$multiply: ["$spend", "$spend"]}, 0]}}, r_sy: {$sum: {$cond: [{$and: [{$isNumber: "$revenue"}, {$isNumber: "$spend"}]}, "$revenue", 0]}}, r_syy: {$sum: {$cond: [{$and: [{$isNumber: "$revenue"}, {$isNumber: "$spend"}]}, {$multiply: ["$revenue", "$revenue"]}, 0]}}, r_sxy: {$sum: {$cond: [{$and: [{$isNumber: "$revenue"}, {$isNumber: "$spend"}]}, {$multiply: ["$spend", "$revenue"]}, 0]}}}}, {$addFields: {r: {$cond: [{$lt: ["$r", 1]}, null, {$let: {vars: {n: "$r", sxx: {$subtract: ["$r_sxx", {$divide: [{$multiply: ["$r_sx", "$r_sx"]}, "$r"]}]}, syy: {$subtract: ["$r_syy", {$divide: [{$multiply: ["$r_sy", "$r_sy"]}, "$r"]}]}, sxy: {$subtract: ["$r_sxy", {$divide: [{$multiply: ["$r_sx", "$r_sy"]}, "$r"]}]}}, in: {$cond: [{$lte: [{$multiply: ["$$sxx", "$$syy"]}, 0]}, null, {$divide: ["$$sxy", {$sqrt: {$multiply: ["$$sxx", "$$syy"]}}]}]}}}]}}}, {$project: {_id: 0, region: "$_id", r: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a standard deviation over a window
+	input = "SELECT region, STDDEV_POP(amount) OVER (PARTITION BY region) AS sd FROM sales"
+	want = `db.sales.aggregate([{$setWindowFields: {partitionBy: "$region", output: {sd: {$stdDevPop: "$amount"}}}}, {$project: {region: 1, sd: 1}}])`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestGenerateRegexQueryFromSQLQuery(t *testing.T) {
+	// Test for a case-insensitive match as a $regex filter
+	input := `SELECT * FROM users WHERE email ~* '@example\.com$'`
+	want := `db.users.find({email: {$regex: "@example\\.com$", $options: "i"}})`
+	got, err := GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for MySQL REGEXP and REGEXP_LIKE with flags
+	input = `SELECT * FROM users WHERE name NOT REGEXP '^a' AND REGEXP_LIKE(city, '\myork', 'i')`
+	want = `db.users.find({name: {$not: {$regex: "^a"}}, city: {$regex: "\\b(?=\\w)york", $options: "i"}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a match and a literal replacement in the SELECT list
+	input = "SELECT name ~ '^a' AS a, REGEXP_REPLACE(phone, '-', '', 'g') AS digits FROM users"
+	want = `db.users.find({}, {a: {$regexMatch: {input: "$name", regex: "^a"}}, digits: {$replaceAll: {input: "$phone", find: "-", replacement: ""}}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for REGEXP_SUBSTR of a capturing group
+	input = "SELECT REGEXP_SUBSTR(email, '@(.*)$', 1, 1, 'i', 1) AS domain FROM users"
+	want = `db.users.find({}, {domain: {$let: {vars: {match: {$regexFind: {input: "$email", regex: "@(.*)$", options: "i"}}}, in: {$arrayElemAt: ["$$match.captures", 0]}}}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for a regular expression filter of a DELETE
+	input = "DELETE FROM users WHERE name ~ 'x'"
+	want = `db.users.deleteOne({name: {$regex: "x"}})`
+	got, err = GenerateMongoQueryFromSQLQuery(input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Test for regex syntax MongoDB can not express
+	_, err = GenerateMongoQueryFromSQLQuery("SELECT * FROM users WHERE name ~ '[[=e=]]'")
+	require.Error(t, err)
 }
@@ -0,0 +1,92 @@
+package mongo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Document is an ordered MongoDB document
+type Document []Element
+
+// Element is a single key/value pair of a Document
+type Element struct {
+	Key   string
+	Value interface{}
+}
+
+// Array is an ordered list of values
+type Array []interface{}
+
+// Expression is a mongo shell expression that is written as is, for values the shell computes
+// when it runs the command
+type Expression string
+
+// identifierKey matches keys that can be written without quotes in the mongo shell
+var identifierKey = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Get returns the value stored under a key and whether the key exists
+func (d Document) Get(key string) (interface{}, bool) {
+	for _, element := range d {
+		if element.Key == key {
+			return element.Value, true
+		}
+	}
+	return nil, false
+}
+
+// String returns the document in mongo shell syntax
+func (d Document) String() string {
+	elements := make([]string, len(d))
+	for i, element := range d {
+		elements[i] = formatKey(element.Key) + ": " + formatValue(element.Value)
+	}
+	return "{" + strings.Join(elements, ", ") + "}"
+}
+
+// String returns the array in mongo shell syntax
+func (a Array) String() string {
+	values := make([]string, len(a))
+	for i, value := range a {
+		values[i] = formatValue(value)
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// formatKey returns a document key, quoted if the shell requires it
+func formatKey(key string) string {
+	if identifierKey.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// formatValue returns a value in mongo shell syntax
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case Document:
+		return v.String()
+	case Array:
+		return v.String()
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return fmt.Sprintf("ISODate(%q)", v.UTC().Format("2006-01-02T15:04:05.000Z"))
+	case Expression:
+		return string(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
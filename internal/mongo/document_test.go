@@ -0,0 +1,52 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentString(t *testing.T) {
+	tests := []struct {
+		name     string
+		document Document
+		want     string
+	}{
+		{
+			name:     "empty",
+			document: Document{},
+			want:     "{}",
+		},
+		{
+			name:     "scalars",
+			document: Document{{Key: "name", Value: "John"}, {Key: "age", Value: int64(25)}, {Key: "score", Value: 9.5}, {Key: "active", Value: true}, {Key: "email", Value: nil}},
+			want:     `{name: "John", age: 25, score: 9.5, active: true, email: null}`,
+		},
+		{
+			name:     "quoted keys",
+			document: Document{{Key: "COUNT(*)", Value: Document{{Key: "$sum", Value: int64(1)}}}, {Key: "address.city", Value: "Paris"}},
+			want:     `{"COUNT(*)": {$sum: 1}, "address.city": "Paris"}`,
+		},
+		{
+			name:     "arrays",
+			document: Document{{Key: "$in", Value: Array{"a", int64(1), Array{}}}},
+			want:     `{$in: ["a", 1, []]}`,
+		},
+		{
+			name:     "dates",
+			document: Document{{Key: "$gte", Value: time.Date(2024, 1, 1, 1, 0, 0, 0, time.FixedZone("+01:00", 3600))}},
+			want:     `{$gte: ISODate("2024-01-01T00:00:00.000Z")}`,
+		},
+		{
+			name:     "shell expression",
+			document: Document{{Key: "size", Value: Expression("Math.ceil(db.events.estimatedDocumentCount() * 0.01)")}},
+			want:     `{size: Math.ceil(db.events.estimatedDocumentCount() * 0.01)}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.document.String())
+		})
+	}
+}
@@ -11,10 +11,12 @@ type Command string
 
 // These are the MongoDB commands that are currently supported
 const (
-	MongoFind   Command = "find"
-	MongoInsert Command = "insert"
-	MongoUpdate Command = "update"
-	MongoDelete Command = "deleteOne"
+	MongoFind      Command = "find"
+	MongoInsert    Command = "insert"
+	MongoUpdate    Command = "update"
+	MongoDelete    Command = "deleteOne"
+	MongoCount     Command = "countDocuments"
+	MongoAggregate Command = "aggregate"
 )
 
 // Query is a struct that represents a MongoDB query
@@ -25,6 +27,22 @@ type Query struct {
 	Field       []string
 	Filter      string
 	Values      []interface{}
+	Match       Document
+	Projection  Document
+	// Sort orders the documents returned by a find
+	Sort     Document
+	Pipeline []Document
+	// Update is the update document of an update that sets fields to constant values, which
+	// does not need an aggregation pipeline
+	Update Document
+	// Limit caps the number of documents returned by a find, and is 0 when there is no limit
+	Limit int64
+	// Hint names the index the command must use, and is empty to let the server pick one
+	Hint string
+	// Collation sets the language rules every string comparison of the command follows
+	Collation Document
+	// Warnings explain parts of the translation that may perform poorly
+	Warnings []string
 }
 
 // GenerateMongoQuery generates a MongoDB query from a Query struct
@@ -38,6 +56,10 @@ func GenerateMongoQuery(query Query) string {
 		return generateUpdateQuery(query)
 	case MongoDelete:
 		return generateDeleteQuery(query)
+	case MongoCount:
+		return generateCountQuery(query)
+	case MongoAggregate:
+		return generateAggregateQuery(query)
 	default:
 		return ""
 	}
@@ -46,6 +68,37 @@ func GenerateMongoQuery(query Query) string {
 
 // generateFindQuery generates a MongoDB find query from a Query struct
 func generateFindQuery(query Query) string {
+	if query.Match != nil || query.Projection != nil || query.Sort != nil || query.Limit > 0 || query.Hint != "" || query.Collation != nil {
+		filter := "{}"
+		if query.Match != nil {
+			filter = query.Match.String()
+		}
+		find := fmt.Sprintf("db.%s.%s(%s)", query.Collections, query.Command, filter)
+		if query.Projection != nil {
+			find = fmt.Sprintf("db.%s.%s(%s, %s)", query.Collections, query.Command, filter, query.Projection)
+		}
+		if query.Sort != nil {
+			find += fmt.Sprintf(".sort(%s)", query.Sort)
+		}
+		if query.Limit > 0 {
+			find += fmt.Sprintf(".limit(%d)", query.Limit)
+		}
+		if query.Hint != "" {
+			find += fmt.Sprintf(".hint(%s)", formatValue(query.Hint))
+		}
+		if query.Collation != nil {
+			find += fmt.Sprintf(".collation(%s)", query.Collation)
+		}
+		return find
+	}
+
+	// A * selects every field, including the fields listed next to it
+	for _, field := range query.Field {
+		if field == "*" {
+			query.Field = []string{"*"}
+			break
+		}
+	}
 	fieldsAndValues := ""
 	for i, field := range query.Field {
 		if field == "*" {
@@ -129,6 +182,22 @@ func generateInsertQuery(query Query) string {
 
 // generateUpdateQuery generates a MongoDB update query from a Query struct
 func generateUpdateQuery(query Query) string {
+	if query.Pipeline != nil || query.Update != nil {
+		filter := "{}"
+		if query.Match != nil {
+			filter = query.Match.String()
+		}
+		var update fmt.Stringer = query.Update
+		if query.Pipeline != nil {
+			stages := make(Array, len(query.Pipeline))
+			for i, stage := range query.Pipeline {
+				stages[i] = stage
+			}
+			update = stages
+		}
+		return fmt.Sprintf("db.%s.%s(%s, %s%s)", query.Collections, query.Command, filter, update, commandOptions(query))
+	}
+
 	var fieldsAndValues string
 	// Add filter
 	if query.Filter != "" {
@@ -168,6 +237,14 @@ func generateUpdateQuery(query Query) string {
 
 // generateDeleteQuery generates a MongoDB delete query from a Query struct
 func generateDeleteQuery(query Query) string {
+	if query.Match != nil || query.Hint != "" || query.Collation != nil {
+		filter := "{}"
+		if query.Match != nil {
+			filter = query.Match.String()
+		}
+		return fmt.Sprintf("db.%s.%s(%s%s)", query.Collections, query.Command, filter, commandOptions(query))
+	}
+
 	var fieldsAndValues string
 	// Add filter
 	if query.Filter != "" {
@@ -186,3 +263,37 @@ func generateDeleteQuery(query Query) string {
 	}
 	return fmt.Sprintf("db.%s.%s({%s})", query.Collections, query.Command, fieldsAndValues)
 }
+
+// generateCountQuery generates a MongoDB countDocuments query from a Query struct
+func generateCountQuery(query Query) string {
+	filter := "{}"
+	if query.Match != nil {
+		filter = query.Match.String()
+	}
+	return fmt.Sprintf("db.%s.%s(%s%s)", query.Collections, query.Command, filter, commandOptions(query))
+}
+
+// generateAggregateQuery generates a MongoDB aggregate query from a Query struct
+func generateAggregateQuery(query Query) string {
+	stages := make(Array, len(query.Pipeline))
+	for i, stage := range query.Pipeline {
+		stages[i] = stage
+	}
+	return fmt.Sprintf("db.%s.%s(%s%s)", query.Collections, query.Command, stages, commandOptions(query))
+}
+
+// commandOptions returns the options argument of a command, with a leading comma, which is empty
+// when the command has no options
+func commandOptions(query Query) string {
+	var options Document
+	if query.Hint != "" {
+		options = append(options, Element{Key: "hint", Value: query.Hint})
+	}
+	if query.Collation != nil {
+		options = append(options, Element{Key: "collation", Value: query.Collation})
+	}
+	if options == nil {
+		return ""
+	}
+	return ", " + options.String()
+}
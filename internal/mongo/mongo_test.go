@@ -44,3 +44,135 @@ func TestGenerateDeleteQuery(t *testing.T) {
 	actual := GenerateMongoQuery(query)
 	require.Equal(t, expected, actual)
 }
+
+func TestGenerateCountQuery(t *testing.T) {
+	query := Query{
+		Command:     MongoCount,
+		Database:    "test",
+		Collections: "users",
+		Match:       Document{{Key: "active", Value: true}},
+	}
+	expected := "db.users.countDocuments({active: true})"
+	actual := GenerateMongoQuery(query)
+	require.Equal(t, expected, actual)
+}
+
+func TestGenerateAggregateQuery(t *testing.T) {
+	query := Query{
+		Command:     MongoAggregate,
+		Database:    "test",
+		Collections: "t",
+		Pipeline: []Document{
+			{{Key: "$group", Value: Document{{Key: "_id", Value: nil}, {Key: "MAX(age)", Value: Document{{Key: "$max", Value: "$age"}}}}}},
+			{{Key: "$project", Value: Document{{Key: "_id", Value: int64(0)}, {Key: "MAX(age)", Value: int64(1)}}}},
+		},
+	}
+	expected := `db.t.aggregate([{$group: {_id: null, "MAX(age)": {$max: "$age"}}}, {$project: {_id: 0, "MAX(age)": 1}}])`
+	actual := GenerateMongoQuery(query)
+	require.Equal(t, expected, actual)
+}
+
+func TestGenerateSortedFindQuery(t *testing.T) {
+	query := Query{
+		Command:     MongoFind,
+		Database:    "test",
+		Collections: "users",
+		Projection:  Document{{Key: "name", Value: int64(1)}},
+		Sort:        Document{{Key: "age", Value: int64(-1)}, {Key: "name", Value: int64(1)}},
+	}
+	expected := `db.users.find({}, {name: 1}).sort({age: -1, name: 1})`
+	actual := GenerateMongoQuery(query)
+	require.Equal(t, expected, actual)
+}
+
+func TestGenerateStarFindQuery(t *testing.T) {
+	query := Query{
+		Command:     MongoFind,
+		Database:    "test",
+		Collections: "users",
+		Field:       []string{"name", "*"},
+	}
+	expected := `db.users.find({})`
+	actual := GenerateMongoQuery(query)
+	require.Equal(t, expected, actual)
+}
+
+func TestGenerateHintedQuery(t *testing.T) {
+	query := Query{
+		Command:     MongoFind,
+		Database:    "test",
+		Collections: "users",
+		Match:       Document{{Key: "email", Value: "bob@example.com"}},
+		Hint:        "idx_email",
+	}
+	expected := `db.users.find({email: "bob@example.com"}).hint("idx_email")`
+	require.Equal(t, expected, GenerateMongoQuery(query))
+
+	query.Command = MongoCount
+	expected = `db.users.countDocuments({email: "bob@example.com"}, {hint: "idx_email"})`
+	require.Equal(t, expected, GenerateMongoQuery(query))
+
+	query.Command = MongoDelete
+	expected = `db.users.deleteOne({email: "bob@example.com"}, {hint: "idx_email"})`
+	require.Equal(t, expected, GenerateMongoQuery(query))
+
+	query.Command = MongoAggregate
+	query.Pipeline = []Document{{{Key: "$match", Value: query.Match}}}
+	expected = `db.users.aggregate([{$match: {email: "bob@example.com"}}], {hint: "idx_email"})`
+	require.Equal(t, expected, GenerateMongoQuery(query))
+}
+
+func TestGenerateCollatedQuery(t *testing.T) {
+	query := Query{
+		Command:     MongoFind,
+		Database:    "test",
+		Collections: "users",
+		Match:       Document{{Key: "name", Value: "muller"}},
+		Collation:   Document{{Key: "locale", Value: "de"}, {Key: "strength", Value: int64(1)}},
+	}
+	expected := `db.users.find({name: "muller"}).collation({locale: "de", strength: 1})`
+	require.Equal(t, expected, GenerateMongoQuery(query))
+
+	query.Command = MongoDelete
+	query.Hint = "idx_name"
+	expected = `db.users.deleteOne({name: "muller"}, {hint: "idx_name", collation: {locale: "de", strength: 1}})`
+	require.Equal(t, expected, GenerateMongoQuery(query))
+}
+
+func TestGenerateLimitedFindQuery(t *testing.T) {
+	query := Query{
+		Command:     MongoFind,
+		Database:    "test",
+		Collections: "users",
+		Sort:        Document{{Key: "age", Value: int64(-1)}},
+		Limit:       10,
+	}
+	expected := `db.users.find({}).sort({age: -1}).limit(10)`
+	actual := GenerateMongoQuery(query)
+	require.Equal(t, expected, actual)
+}
+
+func TestGeneratePipelineUpdateQuery(t *testing.T) {
+	query := Query{
+		Command:     MongoUpdate,
+		Database:    "test",
+		Collections: "users",
+		Match:       Document{{Key: "active", Value: true}},
+		Pipeline:    []Document{{{Key: "$set", Value: Document{{Key: "age", Value: Document{{Key: "$add", Value: Array{"$age", int64(1)}}}}}}}},
+	}
+	expected := `db.users.update({active: true}, [{$set: {age: {$add: ["$age", 1]}}}])`
+	actual := GenerateMongoQuery(query)
+	require.Equal(t, expected, actual)
+}
+
+func TestGenerateDocumentUpdateQuery(t *testing.T) {
+	query := Query{
+		Command:     MongoUpdate,
+		Database:    "test",
+		Collections: "users",
+		Update:      Document{{Key: "$set", Value: Document{{Key: "age", Value: int64(21)}}}},
+	}
+	expected := `db.users.update({}, {$set: {age: 21}})`
+	actual := GenerateMongoQuery(query)
+	require.Equal(t, expected, actual)
+}
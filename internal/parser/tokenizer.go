@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TokenType is the kind of a token produced by Tokenize
+type TokenType int
+
+// These are the token types produced by Tokenize
+const (
+	TokenEOF TokenType = iota
+	TokenIdentifier
+	TokenQuotedIdentifier
+	TokenString
+	TokenNumber
+	TokenOperator
+	TokenPunctuation
+	// TokenHint is an optimizer hint comment /*+ ... */, whose value is the text of the comment
+	TokenHint
+)
+
+// Token is a single lexical element of a SQL statement
+type Token struct {
+	Type  TokenType
+	Value string
+}
+
+// operators lists the multi and single character operators, longest first
+var operators = []string{
+	"->>", "!~*",
+	"->", "::", "<>", "!=", "<=", ">=", "||", "!~", "~*",
+	"=", "<", ">", "+", "-", "*", "/", "%", "~",
+}
+
+// IsKeyword checks if a token is the given keyword, ignoring case
+func (t Token) IsKeyword(keyword string) bool {
+	return t.Type == TokenIdentifier && strings.EqualFold(t.Value, keyword)
+}
+
+// IsSymbol checks if a token is the given operator or punctuation
+func (t Token) IsSymbol(symbol string) bool {
+	return (t.Type == TokenOperator || t.Type == TokenPunctuation) && t.Value == symbol
+}
+
+// Tokenize splits a SQL statement into tokens, dropping whitespace and comments other than
+// optimizer hints
+func Tokenize(input string) ([]Token, error) {
+	var tokens []Token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		char := runes[i]
+		switch {
+		case unicode.IsSpace(char):
+			i++
+		case char == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case char == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			end := i + 2
+			for end+1 < len(runes) && !(runes[end] == '*' && runes[end+1] == '/') {
+				end++
+			}
+			if end+1 >= len(runes) {
+				return nil, fmt.Errorf("unterminated comment: %s", string(runes[i:]))
+			}
+			if i+2 < end && runes[i+2] == '+' {
+				tokens = append(tokens, Token{Type: TokenHint, Value: strings.TrimSpace(string(runes[i+3 : end]))})
+			}
+			i = end + 2
+		case char == '\'':
+			value, next, err := readQuoted(runes, i, '\'')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, Token{Type: TokenString, Value: value})
+			i = next
+		case char == '"' || char == '`':
+			value, next, err := readQuoted(runes, i, char)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, Token{Type: TokenQuotedIdentifier, Value: value})
+			i = next
+		case char == '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated identifier: %s", string(runes[i:]))
+			}
+			tokens = append(tokens, Token{Type: TokenQuotedIdentifier, Value: string(runes[i+1 : end])})
+			i = end + 1
+		case unicode.IsDigit(char) || (char == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := readNumber(runes, i)
+			tokens = append(tokens, Token{Type: TokenNumber, Value: string(runes[i:end])})
+			i = end
+		case isIdentifierStart(char):
+			end := i + 1
+			for end < len(runes) && isIdentifierPart(runes[end]) {
+				end++
+			}
+			tokens = append(tokens, Token{Type: TokenIdentifier, Value: string(runes[i:end])})
+			i = end
+		case strings.ContainsRune("(),.;", char):
+			tokens = append(tokens, Token{Type: TokenPunctuation, Value: string(char)})
+			i++
+		default:
+			operator := matchOperator(string(runes[i:]))
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character: %c", char)
+			}
+			tokens = append(tokens, Token{Type: TokenOperator, Value: operator})
+			i += len([]rune(operator))
+		}
+	}
+	if len(tokens) < 1 {
+		return nil, fmt.Errorf("invalid input: %s", input)
+	}
+	return tokens, nil
+}
+
+// readQuoted reads a quoted string starting at start, where a doubled quote is an escaped quote
+func readQuoted(runes []rune, start int, quote rune) (string, int, error) {
+	var value strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] != quote {
+			value.WriteRune(runes[i])
+			continue
+		}
+		if i+1 < len(runes) && runes[i+1] == quote {
+			value.WriteRune(quote)
+			i++
+			continue
+		}
+		return value.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("unterminated quote: %s", string(runes[start:]))
+}
+
+// readNumber returns the index just past the number starting at start
+func readNumber(runes []rune, start int) int {
+	i := start
+	for i < len(runes) && unicode.IsDigit(runes[i]) {
+		i++
+	}
+	if i < len(runes) && runes[i] == '.' {
+		i++
+		for i < len(runes) && unicode.IsDigit(runes[i]) {
+			i++
+		}
+	}
+	if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+		j := i + 1
+		if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+			j++
+		}
+		if j < len(runes) && unicode.IsDigit(runes[j]) {
+			i = j
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+// matchOperator returns the longest operator at the start of input
+func matchOperator(input string) string {
+	for _, operator := range operators {
+		if strings.HasPrefix(input, operator) {
+			return operator
+		}
+	}
+	return ""
+}
+
+// isIdentifierStart checks if a character can start an identifier
+func isIdentifierStart(char rune) bool {
+	return unicode.IsLetter(char) || char == '_' || char == '@' || char == '#'
+}
+
+// isIdentifierPart checks if a character can appear inside an identifier
+func isIdentifierPart(char rune) bool {
+	return isIdentifierStart(char) || unicode.IsDigit(char) || char == '$'
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Token
+		wantErr bool
+	}{
+		{
+			name:  "select",
+			input: "SELECT * FROM users",
+			want: []Token{
+				{Type: TokenIdentifier, Value: "SELECT"},
+				{Type: TokenOperator, Value: "*"},
+				{Type: TokenIdentifier, Value: "FROM"},
+				{Type: TokenIdentifier, Value: "users"},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "function call",
+			input: "COUNT(*)",
+			want: []Token{
+				{Type: TokenIdentifier, Value: "COUNT"},
+				{Type: TokenPunctuation, Value: "("},
+				{Type: TokenOperator, Value: "*"},
+				{Type: TokenPunctuation, Value: ")"},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "strings and numbers",
+			input: "name = 'O''Brien' AND age >= 2.5e3",
+			want: []Token{
+				{Type: TokenIdentifier, Value: "name"},
+				{Type: TokenOperator, Value: "="},
+				{Type: TokenString, Value: "O'Brien"},
+				{Type: TokenIdentifier, Value: "AND"},
+				{Type: TokenIdentifier, Value: "age"},
+				{Type: TokenOperator, Value: ">="},
+				{Type: TokenNumber, Value: "2.5e3"},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "quoted identifiers",
+			input: "\"first name\", `last`, [middle]",
+			want: []Token{
+				{Type: TokenQuotedIdentifier, Value: "first name"},
+				{Type: TokenPunctuation, Value: ","},
+				{Type: TokenQuotedIdentifier, Value: "last"},
+				{Type: TokenPunctuation, Value: ","},
+				{Type: TokenQuotedIdentifier, Value: "middle"},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "comments",
+			input: "a -- trailing\n/* block */ <> b",
+			want: []Token{
+				{Type: TokenIdentifier, Value: "a"},
+				{Type: TokenOperator, Value: "<>"},
+				{Type: TokenIdentifier, Value: "b"},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "optimizer hint",
+			input: "SELECT /*+ INDEX(users idx_email) */ *",
+			want: []Token{
+				{Type: TokenIdentifier, Value: "SELECT"},
+				{Type: TokenHint, Value: "INDEX(users idx_email)"},
+				{Type: TokenOperator, Value: "*"},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "unterminated string",
+			input:   "name = 'Bob",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			input:   "   ",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Tokenize(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
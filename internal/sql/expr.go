@@ -0,0 +1,565 @@
+package sql
+
+import (
+	"strings"
+)
+
+// Expr is a node of a parsed SQL expression
+type Expr interface {
+	String() string
+}
+
+// LiteralKind is the type of a literal value
+type LiteralKind string
+
+// These are the kinds of literal values
+const (
+	StringLiteral  LiteralKind = "string"
+	NumberLiteral  LiteralKind = "number"
+	BooleanLiteral LiteralKind = "boolean"
+	NullLiteral    LiteralKind = "null"
+	// DateLiteral and TimestampLiteral hold the text of a DATE '...' or TIMESTAMP '...' literal
+	DateLiteral      LiteralKind = "date"
+	TimestampLiteral LiteralKind = "timestamp"
+)
+
+// ColumnRef is a reference to a column, optionally qualified by a table name or alias
+type ColumnRef struct {
+	Table string
+	Name  string
+}
+
+// Literal is a constant value
+type Literal struct {
+	Kind  LiteralKind
+	Value string
+}
+
+// StarExpr is the * wildcard, optionally qualified by a table name or alias
+type StarExpr struct {
+	Table string
+	// Except lists the columns left out of the wildcard, as SELECT * EXCEPT and EXCLUDE ask
+	Except []string
+}
+
+// BinaryExpr is an infix operation such as a comparison, AND, OR or arithmetic
+type BinaryExpr struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+// UnaryExpr is a prefix operation such as NOT or negation
+type UnaryExpr struct {
+	Op   string
+	Expr Expr
+}
+
+// FuncCall is a call to a scalar, aggregate or window function
+type FuncCall struct {
+	Name     string
+	Args     []Expr
+	Distinct bool
+	// Filter restricts the rows an aggregate call aggregates to those it holds for
+	Filter Expr
+	// OrderBy sorts the rows an ordered aggregate reads, as given by WITHIN GROUP (ORDER BY ...)
+	// or by an ORDER BY after its arguments
+	OrderBy []OrderItem
+	// Over is the window of a window function call
+	Over *Window
+}
+
+// OrderItem is an expression of an ORDER BY clause and its direction
+type OrderItem struct {
+	Expr Expr
+	Desc bool
+}
+
+// Window is the set of rows a window function is computed over
+type Window struct {
+	PartitionBy []Expr
+	OrderBy     []OrderItem
+	Frame       *WindowFrame
+}
+
+// WindowFrame limits the rows of a window partition to those around the current row,
+// counted in rows for ROWS frames and in values of the ORDER BY expression for RANGE frames
+type WindowFrame struct {
+	Unit  string
+	Start FrameBound
+	End   FrameBound
+}
+
+// BoundKind is the kind of a window frame boundary
+type BoundKind string
+
+// These are the kinds of window frame boundaries
+const (
+	UnboundedPreceding BoundKind = "UNBOUNDED PRECEDING"
+	Preceding          BoundKind = "PRECEDING"
+	CurrentRow         BoundKind = "CURRENT ROW"
+	Following          BoundKind = "FOLLOWING"
+	UnboundedFollowing BoundKind = "UNBOUNDED FOLLOWING"
+)
+
+// FrameBound is a boundary of a window frame, with an offset for PRECEDING and FOLLOWING
+type FrameBound struct {
+	Kind   BoundKind
+	Offset Expr
+}
+
+// IsNullExpr is an IS NULL or IS NOT NULL test
+type IsNullExpr struct {
+	Expr Expr
+	Not  bool
+}
+
+// InExpr is an IN or NOT IN test against a list of values
+type InExpr struct {
+	Expr   Expr
+	Values []Expr
+	Not    bool
+}
+
+// BetweenExpr is a BETWEEN or NOT BETWEEN range test
+type BetweenExpr struct {
+	Expr  Expr
+	Lower Expr
+	Upper Expr
+	Not   bool
+}
+
+// CaseExpr is a CASE expression, which is a simple CASE comparing Operand to the value of each
+// WHEN clause when Operand is set, and a searched CASE testing the condition of each WHEN clause otherwise
+type CaseExpr struct {
+	Operand Expr
+	Whens   []WhenClause
+	// Else is the result when no WHEN clause matches, which is NULL when it is nil
+	Else Expr
+}
+
+// IntervalExpr is an INTERVAL, either a string such as '1 day 2 hours' without a Unit,
+// or an amount of a Unit such as INTERVAL 7 DAY
+type IntervalExpr struct {
+	Value Expr
+	Unit  string
+}
+
+// CastExpr converts a value to a type, written CAST(x AS type), CONVERT(type, x) or x::type
+type CastExpr struct {
+	Expr Expr
+	// Type is the upper case name of the target type, followed by its length or precision if it has one
+	Type string
+}
+
+// WhenClause is a WHEN ... THEN branch of a CASE expression
+type WhenClause struct {
+	Cond   Expr
+	Result Expr
+}
+
+// aggregateFunctions lists the functions that aggregate over a group of rows
+var aggregateFunctions = map[string]bool{
+	"COUNT": true,
+	"SUM":   true,
+	"AVG":   true,
+	"MIN":   true,
+	"MAX":   true,
+	// GROUPING tells which of its arguments a grouping set rolls up
+	"GROUPING": true,
+	// the percentile functions read the value at a position of the sorted values of a group
+	"PERCENTILE_CONT":   true,
+	"PERCENTILE_DISC":   true,
+	"MEDIAN":            true,
+	"APPROX_PERCENTILE": true,
+	// the string aggregates join the values of a group, and the array aggregates collect them
+	"STRING_AGG":    true,
+	"GROUP_CONCAT":  true,
+	"LISTAGG":       true,
+	"ARRAY_AGG":     true,
+	"JSON_AGG":      true,
+	"JSONB_AGG":     true,
+	"JSON_ARRAYAGG": true,
+	// the statistical aggregates measure the spread of the values of a group, or how two values vary together
+	"STDDEV":         true,
+	"STDDEV_SAMP":    true,
+	"STDDEV_POP":     true,
+	"VARIANCE":       true,
+	"VAR_SAMP":       true,
+	"VAR_POP":        true,
+	"CORR":           true,
+	"COVAR_POP":      true,
+	"COVAR_SAMP":     true,
+	"REGR_SLOPE":     true,
+	"REGR_INTERCEPT": true,
+	"REGR_R2":        true,
+	"REGR_COUNT":     true,
+	"REGR_AVGX":      true,
+	"REGR_AVGY":      true,
+	"REGR_SXX":       true,
+	"REGR_SYY":       true,
+	"REGR_SXY":       true,
+}
+
+// orderedSetFunctions lists the aggregate functions whose ORDER BY is written after their
+// arguments as WITHIN GROUP (ORDER BY ...), rather than inside them
+var orderedSetFunctions = map[string]bool{
+	"PERCENTILE_CONT": true,
+	"PERCENTILE_DISC": true,
+	"LISTAGG":         true,
+}
+
+// niladicFunctions lists the functions that are called without parentheses
+var niladicFunctions = map[string]bool{
+	"CURRENT_DATE":      true,
+	"CURRENT_TIMESTAMP": true,
+}
+
+// String returns the SQL text of a column reference
+func (c *ColumnRef) String() string {
+	if c.Table != "" {
+		return c.Table + "." + c.Name
+	}
+	return c.Name
+}
+
+// String returns the SQL text of a literal
+func (l *Literal) String() string {
+	switch l.Kind {
+	case StringLiteral:
+		return "'" + strings.ReplaceAll(l.Value, "'", "''") + "'"
+	case BooleanLiteral, NullLiteral:
+		return strings.ToUpper(l.Value)
+	case DateLiteral, TimestampLiteral:
+		return strings.ToUpper(string(l.Kind)) + " '" + strings.ReplaceAll(l.Value, "'", "''") + "'"
+	default:
+		return l.Value
+	}
+}
+
+// String returns the SQL text of a wildcard
+func (s *StarExpr) String() string {
+	star := "*"
+	if s.Table != "" {
+		star = s.Table + ".*"
+	}
+	if len(s.Except) > 0 {
+		star += " EXCEPT (" + strings.Join(s.Except, ", ") + ")"
+	}
+	return star
+}
+
+// String returns the SQL text of a binary expression
+func (b *BinaryExpr) String() string {
+	return wrap(b.Left, precedence(b.Op)) + " " + b.Op + " " + wrap(b.Right, precedence(b.Op)+1)
+}
+
+// String returns the SQL text of a unary expression
+func (u *UnaryExpr) String() string {
+	if u.Op == "NOT" {
+		return "NOT " + wrap(u.Expr, precedence("NOT"))
+	}
+	return u.Op + wrap(u.Expr, precedence("*")+1)
+}
+
+// String returns the SQL text of a function call
+func (f *FuncCall) String() string {
+	if niladicFunctions[strings.ToUpper(f.Name)] && len(f.Args) == 0 {
+		return f.Name
+	}
+	if strings.EqualFold(f.Name, "EXTRACT") && len(f.Args) == 2 {
+		if field, ok := f.Args[0].(*Literal); ok && field.Kind == StringLiteral {
+			return f.Name + "(" + field.Value + " FROM " + f.Args[1].String() + ")"
+		}
+	}
+	args := make([]string, len(f.Args))
+	for i, arg := range f.Args {
+		args[i] = arg.String()
+	}
+	distinct := ""
+	if f.Distinct {
+		distinct = "DISTINCT "
+	}
+	orderBy := ""
+	if len(f.OrderBy) > 0 {
+		items := make([]string, len(f.OrderBy))
+		for i, item := range f.OrderBy {
+			items[i] = item.String()
+		}
+		orderBy = "ORDER BY " + strings.Join(items, ", ")
+	}
+	var call string
+	switch {
+	case strings.EqualFold(f.Name, "GROUP_CONCAT") && len(f.Args) == 2:
+		parts := []string{distinct + args[0]}
+		if orderBy != "" {
+			parts = append(parts, orderBy)
+		}
+		if separator, ok := f.Args[1].(*Literal); !ok || separator.Value != "," {
+			parts = append(parts, "SEPARATOR "+args[1])
+		}
+		call = f.Name + "(" + strings.Join(parts, " ") + ")"
+	case orderBy != "" && !orderedSetFunctions[strings.ToUpper(f.Name)]:
+		call = f.Name + "(" + distinct + strings.Join(args, ", ") + " " + orderBy + ")"
+	default:
+		call = f.Name + "(" + distinct + strings.Join(args, ", ") + ")"
+		if orderBy != "" {
+			call += " WITHIN GROUP (" + orderBy + ")"
+		}
+	}
+	if f.Filter != nil {
+		call += " FILTER (WHERE " + f.Filter.String() + ")"
+	}
+	if f.Over != nil {
+		call += " OVER " + f.Over.String()
+	}
+	return call
+}
+
+// String returns the SQL text of an ORDER BY item
+func (o OrderItem) String() string {
+	if o.Desc {
+		return o.Expr.String() + " DESC"
+	}
+	return o.Expr.String()
+}
+
+// String returns the SQL text of a window
+func (w *Window) String() string {
+	var clauses []string
+	if len(w.PartitionBy) > 0 {
+		partition := make([]string, len(w.PartitionBy))
+		for i, expr := range w.PartitionBy {
+			partition[i] = expr.String()
+		}
+		clauses = append(clauses, "PARTITION BY "+strings.Join(partition, ", "))
+	}
+	if len(w.OrderBy) > 0 {
+		order := make([]string, len(w.OrderBy))
+		for i, item := range w.OrderBy {
+			order[i] = item.String()
+		}
+		clauses = append(clauses, "ORDER BY "+strings.Join(order, ", "))
+	}
+	if w.Frame != nil {
+		clauses = append(clauses, w.Frame.Unit+" BETWEEN "+w.Frame.Start.String()+" AND "+w.Frame.End.String())
+	}
+	return "(" + strings.Join(clauses, " ") + ")"
+}
+
+// String returns the SQL text of a window frame boundary
+func (b FrameBound) String() string {
+	if b.Offset != nil {
+		return b.Offset.String() + " " + string(b.Kind)
+	}
+	return string(b.Kind)
+}
+
+// String returns the SQL text of a null test
+func (n *IsNullExpr) String() string {
+	if n.Not {
+		return wrap(n.Expr, precedence("IS")) + " IS NOT NULL"
+	}
+	return wrap(n.Expr, precedence("IS")) + " IS NULL"
+}
+
+// String returns the SQL text of an IN test
+func (in *InExpr) String() string {
+	values := make([]string, len(in.Values))
+	for i, value := range in.Values {
+		values[i] = value.String()
+	}
+	op := " IN ("
+	if in.Not {
+		op = " NOT IN ("
+	}
+	return wrap(in.Expr, precedence("IN")) + op + strings.Join(values, ", ") + ")"
+}
+
+// String returns the SQL text of a BETWEEN test
+func (b *BetweenExpr) String() string {
+	op := " BETWEEN "
+	if b.Not {
+		op = " NOT BETWEEN "
+	}
+	return wrap(b.Expr, precedence("BETWEEN")) + op + wrap(b.Lower, precedence("||")) + " AND " + wrap(b.Upper, precedence("||"))
+}
+
+// String returns the SQL text of an interval
+func (i *IntervalExpr) String() string {
+	if i.Unit != "" {
+		return "INTERVAL " + i.Value.String() + " " + i.Unit
+	}
+	return "INTERVAL " + i.Value.String()
+}
+
+// String returns the SQL text of a conversion
+func (c *CastExpr) String() string {
+	return "CAST(" + c.Expr.String() + " AS " + c.Type + ")"
+}
+
+// String returns the SQL text of a CASE expression
+func (c *CaseExpr) String() string {
+	text := "CASE"
+	if c.Operand != nil {
+		text += " " + c.Operand.String()
+	}
+	for _, when := range c.Whens {
+		text += " WHEN " + when.Cond.String() + " THEN " + when.Result.String()
+	}
+	if c.Else != nil {
+		text += " ELSE " + c.Else.String()
+	}
+	return text + " END"
+}
+
+// precedence returns the binding strength of an operator, higher binds tighter
+func precedence(op string) int {
+	switch op {
+	case "OR":
+		return 1
+	case "AND":
+		return 2
+	case "NOT":
+		return 3
+	case "=", "<>", "!=", "<", "<=", ">", ">=", "IS", "IN", "BETWEEN", "LIKE", "NOT LIKE",
+		"~", "~*", "!~", "!~*", "REGEXP", "NOT REGEXP":
+		return 4
+	case "||":
+		return 5
+	case "+", "-":
+		return 6
+	case "*", "/", "%":
+		return 7
+	case "->", "->>":
+		return 8
+	default:
+		return 9
+	}
+}
+
+// exprPrecedence returns the binding strength of the operator at the root of an expression
+func exprPrecedence(expr Expr) int {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		return precedence(e.Op)
+	case *UnaryExpr:
+		if e.Op == "NOT" {
+			return precedence("NOT")
+		}
+	case *IsNullExpr:
+		return precedence("IS")
+	case *InExpr:
+		return precedence("IN")
+	case *BetweenExpr:
+		return precedence("BETWEEN")
+	}
+	return precedence("")
+}
+
+// wrap returns the SQL text of an expression, parenthesized if it binds looser than bound
+func wrap(expr Expr, bound int) string {
+	if exprPrecedence(expr) < bound {
+		return "(" + expr.String() + ")"
+	}
+	return expr.String()
+}
+
+// IsAggregate checks if a function call aggregates over a group of rows
+func IsAggregate(expr Expr) bool {
+	call, ok := expr.(*FuncCall)
+	return ok && call.Over == nil && aggregateFunctions[strings.ToUpper(call.Name)]
+}
+
+// IsWindow checks if a function call is computed over a window of rows
+func IsWindow(expr Expr) bool {
+	call, ok := expr.(*FuncCall)
+	return ok && call.Over != nil
+}
+
+// ContainsWindow checks if an expression contains a window function call
+func ContainsWindow(expr Expr) bool {
+	found := false
+	Walk(expr, func(e Expr) bool {
+		if IsWindow(e) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// ContainsAggregate checks if an expression contains an aggregate function call
+func ContainsAggregate(expr Expr) bool {
+	found := false
+	Walk(expr, func(e Expr) bool {
+		if IsAggregate(e) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// Walk visits an expression and its children depth first, skipping the children of a node when visit returns false
+func Walk(expr Expr, visit func(Expr) bool) {
+	if expr == nil || !visit(expr) {
+		return
+	}
+	for _, child := range children(expr) {
+		Walk(child, visit)
+	}
+}
+
+// children returns the direct sub-expressions of an expression
+func children(expr Expr) []Expr {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		return []Expr{e.Left, e.Right}
+	case *UnaryExpr:
+		return []Expr{e.Expr}
+	case *FuncCall:
+		exprs := e.Args
+		if e.Filter != nil || len(e.OrderBy) > 0 {
+			exprs = append([]Expr{}, e.Args...)
+			for _, item := range e.OrderBy {
+				exprs = append(exprs, item.Expr)
+			}
+			if e.Filter != nil {
+				exprs = append(exprs, e.Filter)
+			}
+		}
+		if e.Over == nil {
+			return exprs
+		}
+		exprs = append(append([]Expr{}, exprs...), e.Over.PartitionBy...)
+		for _, item := range e.Over.OrderBy {
+			exprs = append(exprs, item.Expr)
+		}
+		return exprs
+	case *IsNullExpr:
+		return []Expr{e.Expr}
+	case *InExpr:
+		return append([]Expr{e.Expr}, e.Values...)
+	case *BetweenExpr:
+		return []Expr{e.Expr, e.Lower, e.Upper}
+	case *IntervalExpr:
+		return []Expr{e.Value}
+	case *CastExpr:
+		return []Expr{e.Expr}
+	case *CaseExpr:
+		var exprs []Expr
+		if e.Operand != nil {
+			exprs = append(exprs, e.Operand)
+		}
+		for _, when := range e.Whens {
+			exprs = append(exprs, when.Cond, when.Result)
+		}
+		if e.Else != nil {
+			exprs = append(exprs, e.Else)
+		}
+		return exprs
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,202 @@
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExprString(t *testing.T) {
+	tests := []struct {
+		name string
+		expr Expr
+		want string
+	}{
+		{
+			name: "column",
+			expr: &ColumnRef{Table: "u", Name: "name"},
+			want: "u.name",
+		},
+		{
+			name: "string literal",
+			expr: &Literal{Kind: StringLiteral, Value: "O'Brien"},
+			want: "'O''Brien'",
+		},
+		{
+			name: "json operators",
+			expr: &BinaryExpr{
+				Op:    "->>",
+				Left:  &BinaryExpr{Op: "->", Left: &ColumnRef{Name: "data"}, Right: &Literal{Kind: StringLiteral, Value: "address"}},
+				Right: &Literal{Kind: NumberLiteral, Value: "-1"},
+			},
+			want: "data -> 'address' ->> -1",
+		},
+		{
+			name: "count star",
+			expr: &FuncCall{Name: "COUNT", Args: []Expr{&StarExpr{}}},
+			want: "COUNT(*)",
+		},
+		{
+			name: "ordered-set aggregate",
+			expr: &FuncCall{
+				Name:    "PERCENTILE_DISC",
+				Args:    []Expr{&Literal{Kind: NumberLiteral, Value: "0.5"}},
+				OrderBy: []OrderItem{{Expr: &ColumnRef{Name: "ms"}, Desc: true}},
+				Filter:  &ColumnRef{Name: "ok"},
+			},
+			want: "PERCENTILE_DISC(0.5) WITHIN GROUP (ORDER BY ms DESC) FILTER (WHERE ok)",
+		},
+		{
+			name: "regular expression match",
+			expr: &BinaryExpr{Op: "NOT REGEXP", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "^a"}},
+			want: "name NOT REGEXP '^a'",
+		},
+		{
+			name: "aggregate with an order",
+			expr: &FuncCall{
+				Name:    "ARRAY_AGG",
+				Args:    []Expr{&ColumnRef{Name: "name"}},
+				OrderBy: []OrderItem{{Expr: &ColumnRef{Name: "age"}, Desc: true}},
+			},
+			want: "ARRAY_AGG(name ORDER BY age DESC)",
+		},
+		{
+			name: "group concat",
+			expr: &FuncCall{
+				Name:     "GROUP_CONCAT",
+				Args:     []Expr{&ColumnRef{Name: "name"}, &Literal{Kind: StringLiteral, Value: ";"}},
+				Distinct: true,
+				OrderBy:  []OrderItem{{Expr: &ColumnRef{Name: "name"}}},
+			},
+			want: "GROUP_CONCAT(DISTINCT name ORDER BY name SEPARATOR ';')",
+		},
+		{
+			name: "group concat with the default separator",
+			expr: &FuncCall{Name: "GROUP_CONCAT", Args: []Expr{&ColumnRef{Name: "name"}, &Literal{Kind: StringLiteral, Value: ","}}},
+			want: "GROUP_CONCAT(name)",
+		},
+		{
+			name: "star except",
+			expr: &StarExpr{Table: "u", Except: []string{"password", "ssn"}},
+			want: "u.* EXCEPT (password, ssn)",
+		},
+		{
+			name: "count distinct",
+			expr: &FuncCall{Name: "count", Args: []Expr{&ColumnRef{Name: "x"}}, Distinct: true},
+			want: "count(DISTINCT x)",
+		},
+		{
+			name: "precedence",
+			expr: &BinaryExpr{
+				Op:    "*",
+				Left:  &BinaryExpr{Op: "+", Left: &ColumnRef{Name: "a"}, Right: &ColumnRef{Name: "b"}},
+				Right: &Literal{Kind: NumberLiteral, Value: "2"},
+			},
+			want: "(a + b) * 2",
+		},
+		{
+			name: "null test",
+			expr: &IsNullExpr{Expr: &ColumnRef{Name: "email"}, Not: true},
+			want: "email IS NOT NULL",
+		},
+		{
+			name: "in list",
+			expr: &InExpr{Expr: &ColumnRef{Name: "id"}, Values: []Expr{&Literal{Kind: NumberLiteral, Value: "1"}, &Literal{Kind: NumberLiteral, Value: "2"}}},
+			want: "id IN (1, 2)",
+		},
+		{
+			name: "window function",
+			expr: &FuncCall{Name: "SUM", Args: []Expr{&ColumnRef{Name: "x"}}, Over: &Window{
+				PartitionBy: []Expr{&ColumnRef{Name: "a"}},
+				OrderBy:     []OrderItem{{Expr: &ColumnRef{Name: "b"}, Desc: true}},
+				Frame:       &WindowFrame{Unit: "ROWS", Start: FrameBound{Kind: Preceding, Offset: &Literal{Kind: NumberLiteral, Value: "2"}}, End: FrameBound{Kind: CurrentRow}},
+			}},
+			want: "SUM(x) OVER (PARTITION BY a ORDER BY b DESC ROWS BETWEEN 2 PRECEDING AND CURRENT ROW)",
+		},
+		{
+			name: "simple case",
+			expr: &CaseExpr{
+				Operand: &ColumnRef{Name: "status"},
+				Whens:   []WhenClause{{Cond: &Literal{Kind: StringLiteral, Value: "A"}, Result: &Literal{Kind: NumberLiteral, Value: "1"}}},
+				Else:    &Literal{Kind: NullLiteral, Value: "null"},
+			},
+			want: "CASE status WHEN 'A' THEN 1 ELSE NULL END",
+		},
+		{
+			name: "dates",
+			expr: &BinaryExpr{
+				Op:    "-",
+				Left:  &FuncCall{Name: "CURRENT_DATE"},
+				Right: &IntervalExpr{Value: &Literal{Kind: NumberLiteral, Value: "7"}, Unit: "DAY"},
+			},
+			want: "CURRENT_DATE - INTERVAL 7 DAY",
+		},
+		{
+			name: "cast",
+			expr: &BinaryExpr{Op: "+", Left: &CastExpr{Expr: &ColumnRef{Name: "qty"}, Type: "INTEGER"}, Right: &Literal{Kind: NumberLiteral, Value: "1"}},
+			want: "CAST(qty AS INTEGER) + 1",
+		},
+		{
+			name: "filtered aggregate",
+			expr: &FuncCall{Name: "SUM", Args: []Expr{&ColumnRef{Name: "total"}}, Filter: &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "status"}, Right: &Literal{Kind: StringLiteral, Value: "paid"}}},
+			want: "SUM(total) FILTER (WHERE status = 'paid')",
+		},
+		{
+			name: "extract",
+			expr: &FuncCall{Name: "EXTRACT", Args: []Expr{
+				&Literal{Kind: StringLiteral, Value: "YEAR"},
+				&Literal{Kind: DateLiteral, Value: "2024-01-01"},
+			}},
+			want: "EXTRACT(YEAR FROM DATE '2024-01-01')",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.expr.String())
+		})
+	}
+}
+
+func TestContainsAggregate(t *testing.T) {
+	tests := []struct {
+		name string
+		expr Expr
+		want bool
+	}{
+		{
+			name: "column",
+			expr: &ColumnRef{Name: "age"},
+			want: false,
+		},
+		{
+			name: "aggregate",
+			expr: &FuncCall{Name: "max", Args: []Expr{&ColumnRef{Name: "age"}}},
+			want: true,
+		},
+		{
+			name: "nested aggregate",
+			expr: &BinaryExpr{Op: "/", Left: &FuncCall{Name: "SUM", Args: []Expr{&ColumnRef{Name: "total"}}}, Right: &Literal{Kind: NumberLiteral, Value: "100"}},
+			want: true,
+		},
+		{
+			name: "scalar function",
+			expr: &FuncCall{Name: "UPPER", Args: []Expr{&ColumnRef{Name: "name"}}},
+			want: false,
+		},
+		{
+			name: "window function",
+			expr: &FuncCall{Name: "SUM", Args: []Expr{&ColumnRef{Name: "total"}}, Over: &Window{}},
+			want: false,
+		},
+		{
+			name: "aggregate in case",
+			expr: &CaseExpr{Whens: []WhenClause{{Cond: &ColumnRef{Name: "vip"}, Result: &FuncCall{Name: "SUM", Args: []Expr{&ColumnRef{Name: "total"}}}}}},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, ContainsAggregate(tt.expr))
+		})
+	}
+}
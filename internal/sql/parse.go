@@ -0,0 +1,2015 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oabraham1/mongosqlgen/internal/parser"
+)
+
+// reservedWords lists the keywords that can not be used as an implicit alias
+var reservedWords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "IN": true, "IS": true,
+	"NULL": true, "LIKE": true, "REGEXP": true, "RLIKE": true, "BETWEEN": true, "GROUP": true, "BY": true,
+	"HAVING": true, "JOIN": true, "INNER": true, "LEFT": true, "OUTER": true,
+	"ON": true, "RIGHT": true, "FULL": true, "CROSS": true,
+	"UNION": true, "INTERSECT": true, "EXCEPT": true, "WITH": true,
+	"OVER": true, "ORDER": true, "LIMIT": true, "TABLESAMPLE": true,
+	"CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true,
+	"DISTINCT": true, "SET": true,
+}
+
+// clauseWords lists the keywords that start a clause or join this parser does not support, which
+// end a table or column rather than alias it, so that they are reported where they appear
+var clauseWords = map[string]bool{
+	"OFFSET": true, "FETCH": true, "WINDOW": true, "QUALIFY": true, "NATURAL": true, "USING": true,
+	"LATERAL": true, "UNPIVOT": true, "RETURNING": true, "INTO": true, "VALUES": true, "FOR": true,
+}
+
+// intervalUnits lists the units an INTERVAL amount can be given in
+var intervalUnits = map[string]bool{
+	"YEAR": true, "QUARTER": true, "MONTH": true, "WEEK": true, "DAY": true,
+	"HOUR": true, "MINUTE": true, "SECOND": true, "MILLISECOND": true,
+}
+
+// indexHintNames lists the optimizer hints that name the index a table is read with
+var indexHintNames = map[string]bool{
+	"INDEX": true, "INDEX_ASC": true, "INDEX_DESC": true,
+	"JOIN_INDEX": true, "GROUP_INDEX": true, "ORDER_INDEX": true,
+}
+
+// queryParser parses a tokenized SQL statement
+type queryParser struct {
+	tokens []parser.Token
+	pos    int
+	// hints holds the optimizer hint comments by the position of the token that follows them
+	hints map[int][]string
+	// collations lists the distinct collations named by COLLATE clauses so far
+	collations []string
+}
+
+// indexHint is an optimizer hint that names the index of a table
+type indexHint struct {
+	table string
+	index string
+}
+
+// newQueryParser tokenizes user input and returns a parser positioned at the first token, setting
+// aside the optimizer hint comments, which only the clauses they follow read
+func newQueryParser(input string) (*queryParser, error) {
+	tokens, err := parser.Tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{hints: map[int][]string{}}
+	for _, token := range tokens {
+		if token.Type == parser.TokenHint {
+			p.hints[len(p.tokens)] = append(p.hints[len(p.tokens)], token.Value)
+			continue
+		}
+		p.tokens = append(p.tokens, token)
+	}
+	return p, nil
+}
+
+// peek returns the current token without consuming it
+func (p *queryParser) peek() parser.Token {
+	return p.peekAt(0)
+}
+
+// peekAt returns the token offset positions after the current one without consuming it
+func (p *queryParser) peekAt(offset int) parser.Token {
+	if p.pos+offset >= len(p.tokens) {
+		return parser.Token{Type: parser.TokenEOF}
+	}
+	return p.tokens[p.pos+offset]
+}
+
+// next consumes and returns the current token
+func (p *queryParser) next() parser.Token {
+	token := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return token
+}
+
+// isKeyword checks if the current token is one of the given keywords
+func (p *queryParser) isKeyword(keywords ...string) bool {
+	for _, keyword := range keywords {
+		if p.peek().IsKeyword(keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptKeyword consumes the current token if it is the given keyword
+func (p *queryParser) acceptKeyword(keyword string) bool {
+	if p.peek().IsKeyword(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expectKeyword consumes the given keyword or returns an error
+func (p *queryParser) expectKeyword(keyword string) error {
+	if !p.acceptKeyword(keyword) {
+		return fmt.Errorf("expected %s but found %s", keyword, p.describe())
+	}
+	return nil
+}
+
+// acceptSymbol consumes the current token if it is the given operator or punctuation
+func (p *queryParser) acceptSymbol(symbol string) bool {
+	if p.peek().IsSymbol(symbol) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expectSymbol consumes the given operator or punctuation or returns an error
+func (p *queryParser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		return fmt.Errorf("expected %s but found %s", symbol, p.describe())
+	}
+	return nil
+}
+
+// expectEnd checks that the whole statement has been consumed
+func (p *queryParser) expectEnd() error {
+	p.acceptSymbol(";")
+	if p.peek().Type != parser.TokenEOF {
+		return fmt.Errorf("unexpected %s", p.describe())
+	}
+	return nil
+}
+
+// describe returns the current token for use in error messages
+func (p *queryParser) describe() string {
+	token := p.peek()
+	switch token.Type {
+	case parser.TokenEOF:
+		return "end of input"
+	case parser.TokenString:
+		return "'" + token.Value + "'"
+	default:
+		return token.Value
+	}
+}
+
+// parseIdentifier consumes a plain or quoted identifier
+func (p *queryParser) parseIdentifier() (string, error) {
+	token := p.peek()
+	if token.Type == parser.TokenQuotedIdentifier || (token.Type == parser.TokenIdentifier && !reservedWords[strings.ToUpper(token.Value)]) {
+		p.pos++
+		return token.Value, nil
+	}
+	return "", fmt.Errorf("expected identifier but found %s", p.describe())
+}
+
+// parseName consumes an identifier where only a name can appear, such as after a dot or AS, which
+// allows reserved words as well
+func (p *queryParser) parseName() (string, error) {
+	token := p.peek()
+	if token.Type == parser.TokenQuotedIdentifier || token.Type == parser.TokenIdentifier {
+		p.pos++
+		return token.Value, nil
+	}
+	return "", fmt.Errorf("expected identifier but found %s", p.describe())
+}
+
+// parseAlias consumes an optional AS alias, returning an empty string if there is none. An implicit
+// alias can not be a reserved word or a keyword that starts a clause
+func (p *queryParser) parseAlias() (string, error) {
+	if p.acceptKeyword("AS") {
+		return p.parseName()
+	}
+	token := p.peek()
+	if token.IsKeyword("PIVOT") && p.peekAt(1).IsSymbol("(") {
+		return "", nil
+	}
+	if p.isKeyword("USE", "FORCE", "IGNORE") && (p.peekAt(1).IsKeyword("INDEX") || p.peekAt(1).IsKeyword("KEY")) {
+		return "", nil
+	}
+	if token.Type == parser.TokenQuotedIdentifier || (token.Type == parser.TokenIdentifier && !reservedWords[strings.ToUpper(token.Value)] && !clauseWords[strings.ToUpper(token.Value)]) {
+		p.pos++
+		return token.Value, nil
+	}
+	return "", nil
+}
+
+// parseSelect parses a SELECT statement
+func (p *queryParser) parseSelect() (Query, error) {
+	result, err := p.parseQuery()
+	if err != nil {
+		return Query{}, err
+	}
+	if err := p.expectEnd(); err != nil {
+		return Query{}, err
+	}
+	result.Collations = p.collations
+	return result, nil
+}
+
+// parseQuery parses a SELECT query with an optional WITH clause, followed by any set operations,
+// which apply from left to right after INTERSECT, which binds tighter, and the ORDER BY and LIMIT clauses that sort and cap the combined rows
+func (p *queryParser) parseQuery() (Query, error) {
+	var with []CommonTableExpression
+	if p.acceptKeyword("WITH") {
+		recursive := p.acceptKeyword("RECURSIVE")
+		for {
+			cte, err := p.parseCommonTableExpression()
+			if err != nil {
+				return Query{}, err
+			}
+			cte.Recursive = recursive
+			with = append(with, cte)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+
+	result, err := p.parseIntersection()
+	if err != nil {
+		return Query{}, err
+	}
+	result.With = with
+	for p.isKeyword("UNION", "EXCEPT") {
+		operation := p.parseSetOperator()
+		if operation.Query, err = p.parseIntersection(); err != nil {
+			return Query{}, err
+		}
+		result.SetOperations = append(result.SetOperations, operation)
+	}
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return Query{}, err
+		}
+		if result.OrderBy, err = p.parseOrderItems(); err != nil {
+			return Query{}, err
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		if result.Limit, err = p.parseLimit(); err != nil {
+			return Query{}, err
+		}
+	}
+	return result, nil
+}
+
+// parseIntersection parses a SELECT combined with the queries that follow it by INTERSECT. The
+// first intersection of a query shares its set operations with the UNION and EXCEPT that follow,
+// which apply to the intersection from left to right, while a later operand holds its own
+func (p *queryParser) parseIntersection() (Query, error) {
+	result, err := p.parseSelectCore()
+	if err != nil {
+		return Query{}, err
+	}
+	for p.isKeyword("INTERSECT") {
+		operation := p.parseSetOperator()
+		if operation.Query, err = p.parseSelectCore(); err != nil {
+			return Query{}, err
+		}
+		result.SetOperations = append(result.SetOperations, operation)
+	}
+	return result, nil
+}
+
+// parseSetOperator consumes a UNION, INTERSECT or EXCEPT operator and its ALL or DISTINCT modifier
+func (p *queryParser) parseSetOperator() SetOperation {
+	operation := SetOperation{Operator: SetOperator(strings.ToUpper(p.next().Value))}
+	if p.acceptKeyword("ALL") {
+		operation.All = true
+	} else {
+		p.acceptKeyword("DISTINCT")
+	}
+	return operation
+}
+
+// parseLimit parses the number of rows a LIMIT keeps
+func (p *queryParser) parseLimit() (*int64, error) {
+	token := p.peek()
+	limit, err := strconv.ParseInt(token.Value, 10, 64)
+	if token.Type != parser.TokenNumber || err != nil || limit < 1 {
+		return nil, fmt.Errorf("LIMIT expects a positive integer but found %s", p.describe())
+	}
+	p.pos++
+	return &limit, nil
+}
+
+// parseUpdate parses an UPDATE statement
+func (p *queryParser) parseUpdate() (Query, error) {
+	result := Query{Command: SQLUpdate}
+	if err := p.expectKeyword("UPDATE"); err != nil {
+		return Query{}, err
+	}
+	hints, err := p.parseHintComments()
+	if err != nil {
+		return Query{}, err
+	}
+	if result.Database, result.Table, err = p.parseTableName(); err != nil {
+		return Query{}, err
+	}
+	if result.Alias, err = p.parseAlias(); err != nil {
+		return Query{}, err
+	}
+	if result.Hint, err = p.parseIndexHints(); err != nil {
+		return Query{}, err
+	}
+	if err := applyIndexHints(&result, hints); err != nil {
+		return Query{}, err
+	}
+	if err := p.expectKeyword("SET"); err != nil {
+		return Query{}, err
+	}
+	for {
+		var assignment Assignment
+		if assignment.Column, err = p.parseIdentifier(); err != nil {
+			return Query{}, err
+		}
+		if err := p.expectSymbol("="); err != nil {
+			return Query{}, err
+		}
+		if assignment.Value, err = p.parseExpr(); err != nil {
+			return Query{}, err
+		}
+		result.Set = append(result.Set, assignment)
+		result.Columns = append(result.Columns, assignment.Column)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if p.acceptKeyword("WHERE") {
+		start := p.pos
+		if result.Where, err = p.parseExpr(); err != nil {
+			return Query{}, err
+		}
+		result.Filter = compactFilter(p.tokens[start:p.pos])
+	}
+	if err := p.expectEnd(); err != nil {
+		return Query{}, err
+	}
+	result.Collations = p.collations
+	return result, nil
+}
+
+// parseDelete parses a DELETE statement
+func (p *queryParser) parseDelete() (Query, error) {
+	result := Query{Command: SQLDelete}
+	if err := p.expectKeyword("DELETE"); err != nil {
+		return Query{}, err
+	}
+	hints, err := p.parseHintComments()
+	if err != nil {
+		return Query{}, err
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return Query{}, err
+	}
+	if result.Database, result.Table, err = p.parseTableName(); err != nil {
+		return Query{}, err
+	}
+	if result.Alias, err = p.parseAlias(); err != nil {
+		return Query{}, err
+	}
+	if result.Hint, err = p.parseIndexHints(); err != nil {
+		return Query{}, err
+	}
+	if err := applyIndexHints(&result, hints); err != nil {
+		return Query{}, err
+	}
+	if p.acceptKeyword("WHERE") {
+		start := p.pos
+		if result.Where, err = p.parseExpr(); err != nil {
+			return Query{}, err
+		}
+		result.Filter = compactFilter(p.tokens[start:p.pos])
+	}
+	if err := p.expectEnd(); err != nil {
+		return Query{}, err
+	}
+	result.Collations = p.collations
+	return result, nil
+}
+
+// parseTableName parses a table name, optionally qualified by the name of its database
+func (p *queryParser) parseTableName() (string, string, error) {
+	table, err := p.parseIdentifier()
+	if err != nil {
+		return "", "", err
+	}
+	if !p.acceptSymbol(".") {
+		return "", table, nil
+	}
+	database := table
+	if table, err = p.parseName(); err != nil {
+		return "", "", err
+	}
+	return database, table, nil
+}
+
+// parseHintComments parses the index hints of the optimizer hint comments before the current token,
+// such as /*+ INDEX(users idx_email) */. Other hints are ignored, as MongoDB has no equivalent
+func (p *queryParser) parseHintComments() ([]indexHint, error) {
+	var hints []indexHint
+	for _, comment := range p.hints[p.pos] {
+		if comment == "" {
+			continue
+		}
+		tokens, err := parser.Tokenize(comment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid optimizer hint %s: %w", comment, err)
+		}
+		hint := &queryParser{tokens: tokens}
+		for hint.peek().Type != parser.TokenEOF {
+			name := strings.ToUpper(hint.next().Value)
+			if !hint.acceptSymbol("(") {
+				continue
+			}
+			var args []string
+			for depth := 1; depth > 0; {
+				token := hint.next()
+				switch {
+				case token.Type == parser.TokenEOF:
+					return nil, fmt.Errorf("unterminated optimizer hint %s", comment)
+				case token.IsSymbol("("):
+					depth++
+				case token.IsSymbol(")"):
+					depth--
+				case depth == 1 && (token.Type == parser.TokenIdentifier || token.Type == parser.TokenQuotedIdentifier):
+					args = append(args, token.Value)
+				}
+			}
+			// a hint naming no index lets the database pick any index of the table
+			if !indexHintNames[name] || len(args) < 2 {
+				continue
+			}
+			if len(args) > 2 {
+				return nil, fmt.Errorf("%s hint must name a single index, as MongoDB only uses one", name)
+			}
+			hints = append(hints, indexHint{table: args[0], index: args[1]})
+		}
+	}
+	return hints, nil
+}
+
+// applyIndexHints sets the index a query must use from the optimizer hints naming its table
+func applyIndexHints(query *Query, hints []indexHint) error {
+	for _, hint := range hints {
+		if !strings.EqualFold(hint.table, query.Table) && !strings.EqualFold(hint.table, query.Alias) {
+			return fmt.Errorf("index hints are only supported for the table read FROM, not %s", hint.table)
+		}
+		if query.Hint != "" && query.Hint != hint.index {
+			return fmt.Errorf("conflicting index hints %s and %s", query.Hint, hint.index)
+		}
+		query.Hint = hint.index
+	}
+	return nil
+}
+
+// parseIndexHints parses the MySQL USE INDEX and FORCE INDEX hints and the T-SQL WITH (INDEX(...))
+// table hint that follow a table, returning the index they name. Other T-SQL table hints, such as
+// NOLOCK, are ignored
+func (p *queryParser) parseIndexHints() (string, error) {
+	var hint string
+	use := func(index string) error {
+		if hint != "" && hint != index {
+			return fmt.Errorf("conflicting index hints %s and %s", hint, index)
+		}
+		hint = index
+		return nil
+	}
+	for {
+		switch {
+		case p.isKeyword("USE", "FORCE", "IGNORE") && (p.peekAt(1).IsKeyword("INDEX") || p.peekAt(1).IsKeyword("KEY")):
+			keyword := strings.ToUpper(p.next().Value)
+			p.next()
+			if keyword == "IGNORE" {
+				return "", fmt.Errorf("IGNORE INDEX is not supported, as MongoDB can only be told which index to use")
+			}
+			if p.acceptKeyword("FOR") {
+				if p.acceptKeyword("ORDER") || p.acceptKeyword("GROUP") {
+					if err := p.expectKeyword("BY"); err != nil {
+						return "", err
+					}
+				} else if err := p.expectKeyword("JOIN"); err != nil {
+					return "", err
+				}
+			}
+			indexes, err := p.parseIndexNames()
+			if err != nil {
+				return "", err
+			}
+			if len(indexes) != 1 {
+				return "", fmt.Errorf("%s INDEX must name a single index, as MongoDB only uses one", keyword)
+			}
+			if err := use(indexes[0]); err != nil {
+				return "", err
+			}
+		case p.isKeyword("WITH") && p.peekAt(1).IsSymbol("("):
+			p.pos += 2
+			for {
+				if p.acceptKeyword("INDEX") {
+					var indexes []string
+					var err error
+					if p.acceptSymbol("=") {
+						var index string
+						index, err = p.parseIdentifier()
+						indexes = []string{index}
+					} else {
+						indexes, err = p.parseIndexNames()
+					}
+					if err != nil {
+						return "", err
+					}
+					if len(indexes) != 1 {
+						return "", fmt.Errorf("INDEX table hint must name a single index, as MongoDB only uses one")
+					}
+					if err := use(indexes[0]); err != nil {
+						return "", err
+					}
+				} else {
+					if _, err := p.parseIdentifier(); err != nil {
+						return "", err
+					}
+					if err := p.skipParentheses(); err != nil {
+						return "", err
+					}
+				}
+				if !p.acceptSymbol(",") {
+					break
+				}
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return "", err
+			}
+		default:
+			return hint, nil
+		}
+	}
+}
+
+// parseIndexNames parses the parenthesized list of indexes of an index hint
+func (p *queryParser) parseIndexNames() ([]string, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var indexes []string
+	for !p.acceptSymbol(")") {
+		if len(indexes) > 0 {
+			if err := p.expectSymbol(","); err != nil {
+				return nil, err
+			}
+		}
+		index, err := p.parseIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// skipParentheses consumes the parenthesized arguments of an ignored hint, if it has any
+func (p *queryParser) skipParentheses() error {
+	if !p.acceptSymbol("(") {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		token := p.next()
+		switch {
+		case token.Type == parser.TokenEOF:
+			return fmt.Errorf("expected ) but found end of input")
+		case token.IsSymbol("("):
+			depth++
+		case token.IsSymbol(")"):
+			depth--
+		}
+	}
+	return nil
+}
+
+// parseCommonTableExpression parses a named query of a WITH clause
+func (p *queryParser) parseCommonTableExpression() (CommonTableExpression, error) {
+	var cte CommonTableExpression
+	var err error
+	if cte.Name, err = p.parseIdentifier(); err != nil {
+		return CommonTableExpression{}, err
+	}
+	if p.acceptSymbol("(") {
+		for {
+			column, err := p.parseIdentifier()
+			if err != nil {
+				return CommonTableExpression{}, err
+			}
+			cte.Columns = append(cte.Columns, column)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return CommonTableExpression{}, err
+		}
+	}
+	if err := p.expectKeyword("AS"); err != nil {
+		return CommonTableExpression{}, err
+	}
+	if cte.Query, err = p.parseSubquery(); err != nil {
+		return CommonTableExpression{}, err
+	}
+	return cte, nil
+}
+
+// parseSubquery parses a parenthesized query
+func (p *queryParser) parseSubquery() (Query, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return Query{}, err
+	}
+	query, err := p.parseQuery()
+	if err != nil {
+		return Query{}, err
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return Query{}, err
+	}
+	return query, nil
+}
+
+// parseSelectCore parses a single SELECT query without set operations
+func (p *queryParser) parseSelectCore() (Query, error) {
+	var result Query
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return Query{}, err
+	}
+	result.Command = SQLSelect
+	hints, err := p.parseHintComments()
+	if err != nil {
+		return Query{}, err
+	}
+	if result.Distinct = p.acceptKeyword("DISTINCT"); !result.Distinct {
+		p.acceptKeyword("ALL")
+	}
+
+	for {
+		projection, err := p.parseProjection()
+		if err != nil {
+			return Query{}, err
+		}
+		result.Projections = append(result.Projections, projection)
+		result.Columns = append(result.Columns, projection.Name())
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return Query{}, err
+	}
+	if p.peek().IsSymbol("(") {
+		from, err := p.parseSubquery()
+		if err != nil {
+			return Query{}, err
+		}
+		result.From = &from
+		if result.Alias, err = p.parseAlias(); err != nil {
+			return Query{}, err
+		}
+		if result.Alias == "" {
+			return Query{}, fmt.Errorf("a subquery in FROM must have an alias")
+		}
+	} else {
+		if result.Database, result.Table, err = p.parseTableName(); err != nil {
+			return Query{}, err
+		}
+		if result.Alias, err = p.parseAlias(); err != nil {
+			return Query{}, err
+		}
+		if result.Hint, err = p.parseIndexHints(); err != nil {
+			return Query{}, err
+		}
+		if p.acceptKeyword("TABLESAMPLE") {
+			if result.Sample, err = p.parseTableSample(); err != nil {
+				return Query{}, err
+			}
+		}
+	}
+	if err := applyIndexHints(&result, hints); err != nil {
+		return Query{}, err
+	}
+	if p.isKeyword("PIVOT") {
+		if result.Pivot, err = p.parsePivot(); err != nil {
+			return Query{}, err
+		}
+	}
+
+	for p.isKeyword("JOIN", "INNER", "LEFT", "RIGHT", "FULL", "CROSS") {
+		join, err := p.parseJoin()
+		if err != nil {
+			return Query{}, err
+		}
+		result.Joins = append(result.Joins, join)
+	}
+
+	if p.acceptKeyword("WHERE") {
+		start := p.pos
+		result.Where, err = p.parseExpr()
+		if err != nil {
+			return Query{}, err
+		}
+		result.Filter = compactFilter(p.tokens[start:p.pos])
+	}
+
+	if p.acceptKeyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return Query{}, err
+		}
+		if result.GroupBy, result.GroupingSets, err = p.parseGroupBy(); err != nil {
+			return Query{}, err
+		}
+	}
+
+	if p.acceptKeyword("HAVING") {
+		if result.Having, err = p.parseExpr(); err != nil {
+			return Query{}, err
+		}
+	}
+	return result, nil
+}
+
+// parseTableSample parses the sampling method and percentage of a TABLESAMPLE clause
+func (p *queryParser) parseTableSample() (*TableSample, error) {
+	if !p.isKeyword("SYSTEM", "BERNOULLI") {
+		return nil, fmt.Errorf("expected SYSTEM or BERNOULLI after TABLESAMPLE but found %s", p.describe())
+	}
+	sample := &TableSample{Method: strings.ToUpper(p.next().Value)}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	token := p.peek()
+	percent, err := strconv.ParseFloat(token.Value, 64)
+	if token.Type != parser.TokenNumber || err != nil || percent <= 0 || percent > 100 {
+		return nil, fmt.Errorf("TABLESAMPLE expects a percentage between 0 and 100 but found %s", p.describe())
+	}
+	p.pos++
+	sample.Percent = percent
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	if p.isKeyword("REPEATABLE") {
+		return nil, fmt.Errorf("TABLESAMPLE REPEATABLE is not supported, as the rows sampled can not be seeded")
+	}
+	return sample, nil
+}
+
+// maxGroupingSets is the most grouping sets a GROUP BY may expand to, as in PostgreSQL, which keeps a
+// CUBE from growing a $facet stage per subset of many columns
+const maxGroupingSets = 4096
+
+// parseGroupBy parses the elements of a GROUP BY clause, returning the expressions it groups by
+// and, when it uses GROUPING SETS, ROLLUP or CUBE, the grouping sets it expands to
+func (p *queryParser) parseGroupBy() ([]Expr, [][]Expr, error) {
+	sets := [][]Expr{nil}
+	advanced := false
+	for {
+		if p.isKeyword("GROUPING", "ROLLUP", "CUBE") {
+			advanced = true
+		}
+		element, err := p.parseGroupingElement()
+		if err != nil {
+			return nil, nil, err
+		}
+		var product [][]Expr
+		for _, set := range sets {
+			for _, other := range element {
+				product = append(product, append(append([]Expr{}, set...), other...))
+			}
+		}
+		if len(product) > maxGroupingSets {
+			return nil, nil, fmt.Errorf("GROUP BY expands to more than %d grouping sets", maxGroupingSets)
+		}
+		sets = product
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+
+	var exprs []Expr
+	seen := map[string]bool{}
+	for _, set := range sets {
+		for _, expr := range set {
+			if !seen[expr.String()] {
+				seen[expr.String()] = true
+				exprs = append(exprs, expr)
+			}
+		}
+	}
+	if !advanced {
+		return exprs, nil, nil
+	}
+	return exprs, sets, nil
+}
+
+// parseGroupingElement parses an element of a GROUP BY clause and returns the grouping sets it stands
+// for: ROLLUP(a, b) for (a, b), (a) and (), CUBE(a, b) for every subset of a and b, GROUPING SETS for
+// the sets it lists, and any other expression for the set made of itself
+func (p *queryParser) parseGroupingElement() ([][]Expr, error) {
+	switch {
+	case p.peek().IsKeyword("GROUPING") && p.peekAt(1).IsKeyword("SETS"):
+		p.pos += 2
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		var sets [][]Expr
+		for {
+			element, err := p.parseGroupingElement()
+			if err != nil {
+				return nil, err
+			}
+			sets = append(sets, element...)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return sets, nil
+	case (p.isKeyword("ROLLUP") || p.isKeyword("CUBE")) && p.peekAt(1).IsSymbol("("):
+		cube := p.next().IsKeyword("CUBE")
+		p.pos++
+		var columns [][]Expr
+		for {
+			column, err := p.parseGroupingColumns()
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, column)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		if cube {
+			return cubeSets(columns)
+		}
+		sets := make([][]Expr, 0, len(columns)+1)
+		for i := len(columns); i >= 0; i-- {
+			var set []Expr
+			for _, column := range columns[:i] {
+				set = append(set, column...)
+			}
+			sets = append(sets, set)
+		}
+		return sets, nil
+	}
+	column, err := p.parseGroupingColumns()
+	if err != nil {
+		return nil, err
+	}
+	return [][]Expr{column}, nil
+}
+
+// parseGroupingColumns parses an expression or a parenthesized list of expressions grouped together,
+// which is empty for the () grand total
+func (p *queryParser) parseGroupingColumns() ([]Expr, error) {
+	start := p.pos
+	expr, err := p.parseExpr()
+	if err == nil || start >= len(p.tokens) || !p.tokens[start].IsSymbol("(") {
+		if err != nil {
+			return nil, err
+		}
+		return []Expr{expr}, nil
+	}
+	p.pos = start + 1
+	if p.acceptSymbol(")") {
+		return []Expr{}, nil
+	}
+	exprs, err := p.parseExprs()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return exprs, nil
+}
+
+// cubeSets returns every subset of the given column groups, from all of them down to none
+func cubeSets(columns [][]Expr) ([][]Expr, error) {
+	n := len(columns)
+	if n >= 63 || 1<<n > maxGroupingSets {
+		return nil, fmt.Errorf("CUBE of %d columns expands to more than %d grouping sets", n, maxGroupingSets)
+	}
+	sets := make([][]Expr, 0, 1<<n)
+	for mask := 1<<n - 1; mask >= 0; mask-- {
+		set := []Expr{}
+		for i, column := range columns {
+			if mask&(1<<(n-1-i)) != 0 {
+				set = append(set, column...)
+			}
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// parsePivot parses PIVOT (aggregate FOR column IN (value [AS name], ...)) [AS alias], where a value
+// may be a bracketed or quoted identifier as in T-SQL, or a string or number literal
+func (p *queryParser) parsePivot() (*Pivot, error) {
+	if err := p.expectKeyword("PIVOT"); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	aggregate, ok := expr.(*FuncCall)
+	if !ok || !IsAggregate(aggregate) {
+		return nil, fmt.Errorf("PIVOT expects an aggregate function but found %s", expr)
+	}
+	pivot := &Pivot{Aggregate: aggregate}
+	if err := p.expectKeyword("FOR"); err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.Type != parser.TokenIdentifier && token.Type != parser.TokenQuotedIdentifier {
+		return nil, fmt.Errorf("expected a PIVOT column but found %s", p.describe())
+	}
+	expr, err = p.parseColumnRef()
+	if err != nil {
+		return nil, err
+	}
+	if pivot.Column, ok = expr.(*ColumnRef); !ok {
+		return nil, fmt.Errorf("expected a PIVOT column but found %s", expr)
+	}
+	if err := p.expectKeyword("IN"); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	for {
+		value, err := p.parsePivotValue()
+		if err != nil {
+			return nil, err
+		}
+		pivot.Values = append(pivot.Values, value)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	if pivot.Alias, err = p.parseAlias(); err != nil {
+		return nil, err
+	}
+	return pivot, nil
+}
+
+// parsePivotValue parses a value of a PIVOT IN list, a quoted identifier standing for the string
+// or number it spells
+func (p *queryParser) parsePivotValue() (PivotValue, error) {
+	var value PivotValue
+	token := p.peek()
+	switch token.Type {
+	case parser.TokenQuotedIdentifier:
+		p.pos++
+		value.Value = &Literal{Kind: StringLiteral, Value: token.Value}
+		if _, err := strconv.ParseFloat(token.Value, 64); err == nil {
+			value.Value.Kind = NumberLiteral
+		}
+	default:
+		expr, err := p.parseUnary()
+		if err != nil {
+			return PivotValue{}, err
+		}
+		literal, ok := expr.(*Literal)
+		if !ok || (literal.Kind != StringLiteral && literal.Kind != NumberLiteral) {
+			return PivotValue{}, fmt.Errorf("PIVOT values must be constants but found %s", expr)
+		}
+		value.Value = literal
+	}
+	value.Name = value.Value.Value
+	if p.acceptKeyword("AS") {
+		name, err := p.parseName()
+		if err != nil {
+			return PivotValue{}, err
+		}
+		value.Name = name
+	}
+	return value, nil
+}
+
+// parseJoin parses a JOIN clause
+func (p *queryParser) parseJoin() (Join, error) {
+	join := Join{Type: InnerJoin}
+	if p.acceptKeyword("LEFT") {
+		join.Type = LeftJoin
+		p.acceptKeyword("OUTER")
+	} else if p.acceptKeyword("RIGHT") {
+		join.Type = RightJoin
+		p.acceptKeyword("OUTER")
+	} else if p.acceptKeyword("FULL") {
+		join.Type = FullJoin
+		p.acceptKeyword("OUTER")
+	} else if p.acceptKeyword("CROSS") {
+		join.Type = CrossJoin
+	} else {
+		p.acceptKeyword("INNER")
+	}
+	if err := p.expectKeyword("JOIN"); err != nil {
+		return Join{}, err
+	}
+
+	var err error
+	lateral := p.acceptKeyword("LATERAL")
+	if p.isKeyword("UNNEST") && p.peekAt(1).IsSymbol("(") {
+		if join.Unnest, join.Alias, err = p.parseUnnest(); err != nil {
+			return Join{}, err
+		}
+	} else if lateral {
+		return Join{}, fmt.Errorf("LATERAL is only supported with UNNEST but found %s", p.describe())
+	} else if p.peek().IsSymbol("(") {
+		subquery, err := p.parseSubquery()
+		if err != nil {
+			return Join{}, err
+		}
+		join.Subquery = &subquery
+	} else if join.Table, err = p.parseIdentifier(); err != nil {
+		return Join{}, err
+	}
+	if join.Unnest == nil {
+		if join.Alias, err = p.parseAlias(); err != nil {
+			return Join{}, err
+		}
+	}
+	if join.Subquery != nil && join.Alias == "" {
+		return Join{}, fmt.Errorf("a subquery in JOIN must have an alias")
+	}
+	hint, err := p.parseIndexHints()
+	if err != nil {
+		return Join{}, err
+	}
+	if hint != "" {
+		return Join{}, fmt.Errorf("index hints are only supported for the table read FROM, not %s", join.Name())
+	}
+	if join.Type == CrossJoin {
+		return join, nil
+	}
+	if err := p.expectKeyword("ON"); err != nil {
+		return Join{}, err
+	}
+	if join.On, err = p.parseExpr(); err != nil {
+		return Join{}, err
+	}
+	return join, nil
+}
+
+// parseUnnest parses the UNNEST of an array in a JOIN, with an optional WITH ORDINALITY, and the
+// alias it must be given, which can be followed by the names of its element and ordinality columns
+func (p *queryParser) parseUnnest() (*Unnest, string, error) {
+	if err := p.expectKeyword("UNNEST"); err != nil {
+		return nil, "", err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, "", err
+	}
+	array, err := p.parseExpr()
+	if err != nil {
+		return nil, "", err
+	}
+	if p.peek().IsSymbol(",") {
+		return nil, "", fmt.Errorf("UNNEST of several arrays is not supported")
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, "", err
+	}
+	unnest := &Unnest{Array: array}
+	if p.isKeyword("WITH") && p.peekAt(1).IsKeyword("ORDINALITY") {
+		p.pos += 2
+		unnest.Ordinality = true
+	}
+	alias, err := p.parseAlias()
+	if err != nil {
+		return nil, "", err
+	}
+	if alias == "" {
+		return nil, "", fmt.Errorf("UNNEST in JOIN must have an alias")
+	}
+	if p.acceptSymbol("(") {
+		for {
+			column, err := p.parseIdentifier()
+			if err != nil {
+				return nil, "", err
+			}
+			unnest.Columns = append(unnest.Columns, column)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, "", err
+		}
+		returned := 1
+		if unnest.Ordinality {
+			returned = 2
+		}
+		if len(unnest.Columns) > returned {
+			return nil, "", fmt.Errorf("UNNEST %s names %d columns but only returns %d", alias, len(unnest.Columns), returned)
+		}
+	}
+	return unnest, alias, nil
+}
+
+// parseProjection parses a single entry of a SELECT list
+func (p *queryParser) parseProjection() (Projection, error) {
+	expr, err := p.parseExpr()
+	if err != nil {
+		return Projection{}, err
+	}
+	if star, ok := expr.(*StarExpr); ok && p.isKeyword("EXCEPT", "EXCLUDE") {
+		if star.Except, err = p.parseExcept(); err != nil {
+			return Projection{}, err
+		}
+	}
+	alias, err := p.parseAlias()
+	if err != nil {
+		return Projection{}, err
+	}
+	return Projection{Expr: expr, Alias: alias}, nil
+}
+
+// parseExcept parses the columns left out of a wildcard by EXCEPT (...) or EXCLUDE, which DuckDB
+// also accepts with a single column and no parentheses
+func (p *queryParser) parseExcept() ([]string, error) {
+	keyword := strings.ToUpper(p.next().Value)
+	if keyword == "EXCLUDE" && !p.peek().IsSymbol("(") {
+		column, err := p.parseIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		return []string{column}, nil
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var columns []string
+	for {
+		column, err := p.parseIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// compactFilter joins the tokens of a WHERE clause into the compact filter form used by Query.Filter
+func compactFilter(tokens []parser.Token) string {
+	var filter string
+	for _, token := range tokens {
+		if token.IsSymbol(",") {
+			continue
+		}
+		filter += token.Value
+	}
+	return filter
+}
+
+// parseExpr parses an expression
+func (p *queryParser) parseExpr() (Expr, error) {
+	return p.parseOr()
+}
+
+// parseOr parses a chain of OR operations
+func (p *queryParser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses a chain of AND operations
+func (p *queryParser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseNot parses an optional NOT prefix
+func (p *queryParser) parseNot() (Expr, error) {
+	if p.acceptKeyword("NOT") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "NOT", Expr: expr}, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses a comparison, null test, IN list, BETWEEN range, LIKE pattern or
+// regular expression match
+func (p *queryParser) parseComparison() (Expr, error) {
+	left, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+
+	token := p.peek()
+	if token.Type == parser.TokenOperator {
+		switch token.Value {
+		case "=", "<>", "!=", "<", "<=", ">", ">=", "~", "~*", "!~", "!~*":
+			p.pos++
+			right, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			return &BinaryExpr{Op: token.Value, Left: left, Right: right}, nil
+		}
+	}
+
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &IsNullExpr{Expr: left, Not: not}, nil
+	}
+
+	not := false
+	if p.isKeyword("NOT") && (p.peekAt(1).IsKeyword("IN") || p.peekAt(1).IsKeyword("BETWEEN") || p.peekAt(1).IsKeyword("LIKE") ||
+		p.peekAt(1).IsKeyword("REGEXP") || p.peekAt(1).IsKeyword("RLIKE")) {
+		p.pos++
+		not = true
+	}
+	switch {
+	case p.acceptKeyword("IN"):
+		values, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		return &InExpr{Expr: left, Values: values, Not: not}, nil
+	case p.acceptKeyword("BETWEEN"):
+		lower, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		upper, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{Expr: left, Lower: lower, Upper: upper, Not: not}, nil
+	case p.acceptKeyword("LIKE"):
+		pattern, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		op := "LIKE"
+		if not {
+			op = "NOT LIKE"
+		}
+		return &BinaryExpr{Op: op, Left: left, Right: pattern}, nil
+	case p.isKeyword("REGEXP", "RLIKE"):
+		// MySQL spells its regular expression match REGEXP or RLIKE
+		p.pos++
+		pattern, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		op := "REGEXP"
+		if not {
+			op = "NOT REGEXP"
+		}
+		return &BinaryExpr{Op: op, Left: left, Right: pattern}, nil
+	}
+	return left, nil
+}
+
+// parseExprList parses a parenthesized, comma separated list of expressions
+func (p *queryParser) parseExprList() ([]Expr, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	exprs, err := p.parseExprs()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return exprs, nil
+}
+
+// parseExprs parses a comma separated list of expressions
+func (p *queryParser) parseExprs() ([]Expr, error) {
+	var exprs []Expr
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if !p.acceptSymbol(",") {
+			return exprs, nil
+		}
+	}
+}
+
+// parseConcat parses a chain of || string concatenations
+func (p *queryParser) parseConcat() (Expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptSymbol("||") {
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "||", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseAdditive parses a chain of addition and subtraction
+func (p *queryParser) parseAdditive() (Expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().IsSymbol("+") || p.peek().IsSymbol("-") {
+		op := p.next().Value
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseMultiplicative parses a chain of multiplication, division and modulo
+func (p *queryParser) parseMultiplicative() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().IsSymbol("*") || p.peek().IsSymbol("/") || p.peek().IsSymbol("%") {
+		op := p.next().Value
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses an optional sign prefix, folding it into numeric literals
+func (p *queryParser) parseUnary() (Expr, error) {
+	if p.acceptSymbol("+") {
+		return p.parseUnary()
+	}
+	if p.acceptSymbol("-") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if literal, ok := expr.(*Literal); ok && literal.Kind == NumberLiteral && !strings.HasPrefix(literal.Value, "-") {
+			return &Literal{Kind: NumberLiteral, Value: "-" + literal.Value}, nil
+		}
+		return &UnaryExpr{Op: "-", Expr: expr}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses a primary expression followed by any number of PostgreSQL ::type conversions,
+// -> or ->> JSON field accesses and COLLATE clauses, which apply from left to right
+func (p *queryParser) parsePostfix() (Expr, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token := p.peek()
+		switch {
+		case token.IsSymbol("::"):
+			p.pos++
+			typ, err := p.parseTypeName()
+			if err != nil {
+				return nil, err
+			}
+			expr = &CastExpr{Expr: expr, Type: typ}
+		case token.IsSymbol("->") || token.IsSymbol("->>"):
+			p.pos++
+			negative := p.acceptSymbol("-")
+			step, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			if literal, ok := step.(*Literal); negative && ok && literal.Kind == NumberLiteral {
+				step = &Literal{Kind: NumberLiteral, Value: "-" + literal.Value}
+			} else if negative {
+				step = &UnaryExpr{Op: "-", Expr: step}
+			}
+			expr = &BinaryExpr{Op: token.Value, Left: expr, Right: step}
+		case token.IsKeyword("COLLATE"):
+			p.pos++
+			if err := p.parseCollation(); err != nil {
+				return nil, err
+			}
+		default:
+			return expr, nil
+		}
+	}
+}
+
+// parseCollation parses the collation name of a COLLATE clause, which is recorded for the whole
+// statement since MongoDB applies a single collation to every comparison of a command
+func (p *queryParser) parseCollation() error {
+	token := p.peek()
+	if token.Type != parser.TokenIdentifier && token.Type != parser.TokenQuotedIdentifier && token.Type != parser.TokenString {
+		return fmt.Errorf("expected a collation name after COLLATE but found %s", p.describe())
+	}
+	p.pos++
+	for _, collation := range p.collations {
+		if collation == token.Value {
+			return nil
+		}
+	}
+	p.collations = append(p.collations, token.Value)
+	return nil
+}
+
+// parsePrimary parses a literal, column reference, function call, wildcard or parenthesized expression
+func (p *queryParser) parsePrimary() (Expr, error) {
+	token := p.peek()
+	switch token.Type {
+	case parser.TokenNumber:
+		p.pos++
+		return &Literal{Kind: NumberLiteral, Value: token.Value}, nil
+	case parser.TokenString:
+		p.pos++
+		return &Literal{Kind: StringLiteral, Value: token.Value}, nil
+	case parser.TokenPunctuation:
+		if p.acceptSymbol("(") {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			return expr, nil
+		}
+	case parser.TokenOperator:
+		if p.acceptSymbol("*") {
+			return &StarExpr{}, nil
+		}
+	case parser.TokenIdentifier, parser.TokenQuotedIdentifier:
+		if token.Type == parser.TokenIdentifier {
+			switch strings.ToUpper(token.Value) {
+			case "TRUE", "FALSE":
+				p.pos++
+				return &Literal{Kind: BooleanLiteral, Value: strings.ToLower(token.Value)}, nil
+			case "NULL":
+				p.pos++
+				return &Literal{Kind: NullLiteral, Value: "null"}, nil
+			case "CASE":
+				return p.parseCase()
+			case "CAST", "CONVERT":
+				if p.peekAt(1).IsSymbol("(") {
+					return p.parseCast()
+				}
+			case "DATE", "TIMESTAMP":
+				if next := p.peekAt(1); next.Type == parser.TokenString {
+					p.pos += 2
+					return &Literal{Kind: LiteralKind(strings.ToLower(token.Value)), Value: next.Value}, nil
+				}
+			case "INTERVAL":
+				if next := p.peekAt(1); next.Type == parser.TokenString || next.Type == parser.TokenNumber || next.IsSymbol("-") ||
+					(next.Type == parser.TokenIdentifier && p.isIntervalUnit(p.peekAt(2))) {
+					return p.parseInterval()
+				}
+			}
+			if niladicFunctions[strings.ToUpper(token.Value)] && !p.peekAt(1).IsSymbol("(") {
+				p.pos++
+				return &FuncCall{Name: token.Value}, nil
+			}
+			if p.peekAt(1).IsSymbol("(") {
+				return p.parseFuncCall()
+			}
+			if reservedWords[strings.ToUpper(token.Value)] {
+				break
+			}
+		}
+		return p.parseColumnRef()
+	}
+	return nil, fmt.Errorf("unexpected %s", p.describe())
+}
+
+// parseCase parses a simple or searched CASE expression
+func (p *queryParser) parseCase() (Expr, error) {
+	if err := p.expectKeyword("CASE"); err != nil {
+		return nil, err
+	}
+	expr := &CaseExpr{}
+	var err error
+	if !p.isKeyword("WHEN") {
+		if expr.Operand, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	for p.acceptKeyword("WHEN") {
+		var when WhenClause
+		if when.Cond, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("THEN"); err != nil {
+			return nil, err
+		}
+		if when.Result, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		expr.Whens = append(expr.Whens, when)
+	}
+	if len(expr.Whens) == 0 {
+		return nil, fmt.Errorf("expected WHEN but found %s", p.describe())
+	}
+	if p.acceptKeyword("ELSE") {
+		if expr.Else, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKeyword("END"); err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+// parseCast parses CAST(x AS type) and the CONVERT(type, x) of SQL Server
+func (p *queryParser) parseCast() (Expr, error) {
+	convert := strings.EqualFold(p.next().Value, "CONVERT")
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	cast := &CastExpr{}
+	var err error
+	if convert {
+		if cast.Type, err = p.parseTypeName(); err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+	if cast.Expr, err = p.parseExpr(); err != nil {
+		return nil, err
+	}
+	if convert && p.peek().IsSymbol(",") {
+		return nil, fmt.Errorf("CONVERT styles are not supported")
+	}
+	if !convert {
+		if err := p.expectKeyword("AS"); err != nil {
+			return nil, err
+		}
+		if cast.Type, err = p.parseTypeName(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return cast, nil
+}
+
+// parseTypeName parses the name of a type, such as INTEGER, DOUBLE PRECISION or NUMERIC(10, 2)
+func (p *queryParser) parseTypeName() (string, error) {
+	token := p.peek()
+	if token.Type != parser.TokenIdentifier {
+		return "", fmt.Errorf("expected a type but found %s", p.describe())
+	}
+	p.pos++
+	typ := strings.ToUpper(token.Value)
+	switch {
+	case typ == "DOUBLE" && p.acceptKeyword("PRECISION"):
+		typ += " PRECISION"
+	case typ == "CHARACTER" && p.acceptKeyword("VARYING"):
+		typ += " VARYING"
+	case typ == "TIMESTAMP" && (p.isKeyword("WITH") || p.isKeyword("WITHOUT")) && p.peekAt(1).IsKeyword("TIME"):
+		typ += " " + strings.ToUpper(p.next().Value)
+		p.pos++
+		if err := p.expectKeyword("ZONE"); err != nil {
+			return "", err
+		}
+		typ += " TIME ZONE"
+	}
+	if p.acceptSymbol("(") {
+		var sizes []string
+		for {
+			size := p.peek()
+			if size.Type != parser.TokenNumber {
+				return "", fmt.Errorf("expected a type size but found %s", p.describe())
+			}
+			p.pos++
+			sizes = append(sizes, size.Value)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return "", err
+		}
+		typ += "(" + strings.Join(sizes, ", ") + ")"
+	}
+	return typ, nil
+}
+
+// parseInterval parses an INTERVAL given as a string or as an amount followed by a unit
+func (p *queryParser) parseInterval() (Expr, error) {
+	if err := p.expectKeyword("INTERVAL"); err != nil {
+		return nil, err
+	}
+	value, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	interval := &IntervalExpr{Value: value}
+	if p.isIntervalUnit(p.peek()) {
+		interval.Unit = strings.ToUpper(p.next().Value)
+	} else if literal, ok := value.(*Literal); !ok || literal.Kind != StringLiteral {
+		return nil, fmt.Errorf("expected an INTERVAL unit but found %s", p.describe())
+	}
+	return interval, nil
+}
+
+// isIntervalUnit checks if a token names an INTERVAL unit, in the singular or the plural
+func (p *queryParser) isIntervalUnit(token parser.Token) bool {
+	if token.Type != parser.TokenIdentifier {
+		return false
+	}
+	unit := strings.ToUpper(token.Value)
+	return intervalUnits[unit] || intervalUnits[strings.TrimSuffix(unit, "S")]
+}
+
+// parseFuncCall parses a function call and its arguments
+func (p *queryParser) parseFuncCall() (Expr, error) {
+	call := &FuncCall{Name: p.next().Value}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	if !p.acceptSymbol(")") {
+		call.Distinct = p.acceptKeyword("DISTINCT")
+		if err := p.parseArgs(call); err != nil {
+			return nil, err
+		}
+		if err := p.parseAggregateOrder(call); err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().IsKeyword("WITHIN") && p.peekAt(1).IsKeyword("GROUP") {
+		if !aggregateFunctions[strings.ToUpper(call.Name)] {
+			return nil, fmt.Errorf("WITHIN GROUP is only allowed in aggregate functions: %s", call)
+		}
+		if len(call.OrderBy) > 0 {
+			return nil, fmt.Errorf("%s can not have both ORDER BY and WITHIN GROUP", call.Name)
+		}
+		p.pos += 2
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("ORDER"); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		items, err := p.parseOrderItems()
+		if err != nil {
+			return nil, err
+		}
+		call.OrderBy = items
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().IsKeyword("FILTER") && p.peekAt(1).IsSymbol("(") {
+		if !aggregateFunctions[strings.ToUpper(call.Name)] {
+			return nil, fmt.Errorf("FILTER is only allowed in aggregate functions: %s", call)
+		}
+		p.pos += 2
+		if err := p.expectKeyword("WHERE"); err != nil {
+			return nil, err
+		}
+		filter, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Filter = filter
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("OVER") {
+		window, err := p.parseWindow()
+		if err != nil {
+			return nil, err
+		}
+		call.Over = window
+	}
+	return call, nil
+}
+
+// parseAggregateOrder parses the ORDER BY that follows the arguments of an aggregate call, as in
+// STRING_AGG(name, ', ' ORDER BY name), and the SEPARATOR of a MySQL GROUP_CONCAT. GROUP_CONCAT
+// concatenates its arguments, so its arguments become the concatenated value and the separator,
+// which is a comma by default
+func (p *queryParser) parseAggregateOrder(call *FuncCall) error {
+	name := strings.ToUpper(call.Name)
+	if p.isKeyword("ORDER") {
+		if !aggregateFunctions[name] {
+			return fmt.Errorf("ORDER BY is only allowed in aggregate functions: %s", call)
+		}
+		p.pos++
+		if err := p.expectKeyword("BY"); err != nil {
+			return err
+		}
+		items, err := p.parseOrderItems()
+		if err != nil {
+			return err
+		}
+		call.OrderBy = items
+	}
+	if name != "GROUP_CONCAT" {
+		return nil
+	}
+	var separator Expr = &Literal{Kind: StringLiteral, Value: ","}
+	if p.acceptKeyword("SEPARATOR") {
+		if p.peek().Type != parser.TokenString {
+			return fmt.Errorf("expected a separator string but found %s", p.describe())
+		}
+		separator = &Literal{Kind: StringLiteral, Value: p.next().Value}
+	}
+	value := call.Args[0]
+	if len(call.Args) > 1 {
+		value = &FuncCall{Name: "CONCAT", Args: call.Args}
+	}
+	call.Args = []Expr{value, separator}
+	return nil
+}
+
+// parseArgs parses the arguments of a function call, turning the keyword forms of EXTRACT,
+// POSITION, SUBSTRING and TRIM into the equivalent positional arguments
+func (p *queryParser) parseArgs(call *FuncCall) error {
+	var first Expr
+	var err error
+	switch strings.ToUpper(call.Name) {
+	case "EXTRACT":
+		if p.peek().Type != parser.TokenIdentifier {
+			return fmt.Errorf("expected a date part but found %s", p.describe())
+		}
+		token := p.next()
+		if err := p.expectKeyword("FROM"); err != nil {
+			return err
+		}
+		input, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		call.Args = []Expr{&Literal{Kind: StringLiteral, Value: strings.ToUpper(token.Value)}, input}
+		return nil
+	case "POSITION":
+		if first, err = p.parseConcat(); err != nil {
+			return err
+		}
+		if err := p.expectKeyword("IN"); err != nil {
+			return err
+		}
+		input, err := p.parseConcat()
+		if err != nil {
+			return err
+		}
+		call.Args = []Expr{first, input}
+		return nil
+	case "SUBSTRING":
+		if first, err = p.parseExpr(); err != nil {
+			return err
+		}
+		if !p.isKeyword("FROM", "FOR") {
+			break
+		}
+		var start Expr = &Literal{Kind: NumberLiteral, Value: "1"}
+		if p.acceptKeyword("FROM") {
+			if start, err = p.parseExpr(); err != nil {
+				return err
+			}
+		}
+		call.Args = []Expr{first, start}
+		if p.acceptKeyword("FOR") {
+			length, err := p.parseExpr()
+			if err != nil {
+				return err
+			}
+			call.Args = append(call.Args, length)
+		}
+		return nil
+	case "TRIM":
+		if p.acceptKeyword("LEADING") {
+			call.Name = "LTRIM"
+		} else if p.acceptKeyword("TRAILING") {
+			call.Name = "RTRIM"
+		} else {
+			p.acceptKeyword("BOTH")
+		}
+		if !p.isKeyword("FROM") {
+			if first, err = p.parseExpr(); err != nil {
+				return err
+			}
+		}
+		if !p.acceptKeyword("FROM") {
+			break
+		}
+		input, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		call.Args = []Expr{input}
+		if first != nil {
+			call.Args = append(call.Args, first)
+		}
+		return nil
+	default:
+		call.Args, err = p.parseExprs()
+		return err
+	}
+
+	call.Args = []Expr{first}
+	if p.acceptSymbol(",") {
+		rest, err := p.parseExprs()
+		if err != nil {
+			return err
+		}
+		call.Args = append(call.Args, rest...)
+	}
+	return nil
+}
+
+// parseWindow parses the parenthesized window of a window function call
+func (p *queryParser) parseWindow() (*Window, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	window := &Window{}
+	var err error
+	if p.acceptKeyword("PARTITION") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		if window.PartitionBy, err = p.parseExprs(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		if window.OrderBy, err = p.parseOrderItems(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isKeyword("ROWS", "RANGE") {
+		frame := &WindowFrame{Unit: strings.ToUpper(p.next().Value), End: FrameBound{Kind: CurrentRow}}
+		if p.acceptKeyword("BETWEEN") {
+			if frame.Start, err = p.parseFrameBound(); err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("AND"); err != nil {
+				return nil, err
+			}
+			if frame.End, err = p.parseFrameBound(); err != nil {
+				return nil, err
+			}
+		} else if frame.Start, err = p.parseFrameBound(); err != nil {
+			return nil, err
+		}
+		window.Frame = frame
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// parseFrameBound parses a boundary of a window frame
+func (p *queryParser) parseFrameBound() (FrameBound, error) {
+	if p.acceptKeyword("UNBOUNDED") {
+		if p.acceptKeyword("PRECEDING") {
+			return FrameBound{Kind: UnboundedPreceding}, nil
+		}
+		if err := p.expectKeyword("FOLLOWING"); err != nil {
+			return FrameBound{}, err
+		}
+		return FrameBound{Kind: UnboundedFollowing}, nil
+	}
+	if p.acceptKeyword("CURRENT") {
+		if err := p.expectKeyword("ROW"); err != nil {
+			return FrameBound{}, err
+		}
+		return FrameBound{Kind: CurrentRow}, nil
+	}
+	offset, err := p.parseAdditive()
+	if err != nil {
+		return FrameBound{}, err
+	}
+	if p.acceptKeyword("PRECEDING") {
+		return FrameBound{Kind: Preceding, Offset: offset}, nil
+	}
+	if err := p.expectKeyword("FOLLOWING"); err != nil {
+		return FrameBound{}, err
+	}
+	return FrameBound{Kind: Following, Offset: offset}, nil
+}
+
+// parseOrderItems parses the comma separated expressions of an ORDER BY clause
+func (p *queryParser) parseOrderItems() ([]OrderItem, error) {
+	var items []OrderItem
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		item := OrderItem{Expr: expr}
+		if p.acceptKeyword("DESC") {
+			item.Desc = true
+		} else {
+			p.acceptKeyword("ASC")
+		}
+		items = append(items, item)
+		if !p.acceptSymbol(",") {
+			return items, nil
+		}
+	}
+}
+
+// parseColumnRef parses a possibly qualified column name or table wildcard
+func (p *queryParser) parseColumnRef() (Expr, error) {
+	parts := []string{p.next().Value}
+	for p.acceptSymbol(".") {
+		if p.acceptSymbol("*") {
+			return &StarExpr{Table: strings.Join(parts, ".")}, nil
+		}
+		part, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 1 {
+		return &ColumnRef{Name: parts[0]}, nil
+	}
+	return &ColumnRef{Table: parts[0], Name: strings.Join(parts[1:], ".")}, nil
+}
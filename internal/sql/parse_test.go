@@ -0,0 +1,940 @@
+package sql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Expr
+		wantErr bool
+	}{
+		{
+			name:  "comparison",
+			input: "active = true",
+			want:  &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "active"}, Right: &Literal{Kind: BooleanLiteral, Value: "true"}},
+		},
+		{
+			name:  "and binds tighter than or",
+			input: "a = 1 OR b = 2 AND c = 3",
+			want: &BinaryExpr{
+				Op:   "OR",
+				Left: &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "a"}, Right: &Literal{Kind: NumberLiteral, Value: "1"}},
+				Right: &BinaryExpr{
+					Op:    "AND",
+					Left:  &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "b"}, Right: &Literal{Kind: NumberLiteral, Value: "2"}},
+					Right: &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "c"}, Right: &Literal{Kind: NumberLiteral, Value: "3"}},
+				},
+			},
+		},
+		{
+			name:  "negative number",
+			input: "balance < -10.5",
+			want:  &BinaryExpr{Op: "<", Left: &ColumnRef{Name: "balance"}, Right: &Literal{Kind: NumberLiteral, Value: "-10.5"}},
+		},
+		{
+			name:  "not between",
+			input: "age NOT BETWEEN 18 AND 65",
+			want:  &BetweenExpr{Expr: &ColumnRef{Name: "age"}, Lower: &Literal{Kind: NumberLiteral, Value: "18"}, Upper: &Literal{Kind: NumberLiteral, Value: "65"}, Not: true},
+		},
+		{
+			name:  "qualified column",
+			input: "u.address.city IS NULL",
+			want:  &IsNullExpr{Expr: &ColumnRef{Table: "u", Name: "address.city"}},
+		},
+		{
+			name:  "count distinct",
+			input: "COUNT(DISTINCT country)",
+			want:  &FuncCall{Name: "COUNT", Args: []Expr{&ColumnRef{Name: "country"}}, Distinct: true},
+		},
+		{
+			name:  "window function",
+			input: "ROW_NUMBER() OVER (PARTITION BY dept ORDER BY salary DESC, name)",
+			want: &FuncCall{Name: "ROW_NUMBER", Over: &Window{
+				PartitionBy: []Expr{&ColumnRef{Name: "dept"}},
+				OrderBy:     []OrderItem{{Expr: &ColumnRef{Name: "salary"}, Desc: true}, {Expr: &ColumnRef{Name: "name"}}},
+			}},
+		},
+		{
+			name:  "window frame",
+			input: "AVG(price) OVER (ORDER BY day RANGE 3 PRECEDING)",
+			want: &FuncCall{Name: "AVG", Args: []Expr{&ColumnRef{Name: "price"}}, Over: &Window{
+				OrderBy: []OrderItem{{Expr: &ColumnRef{Name: "day"}}},
+				Frame:   &WindowFrame{Unit: "RANGE", Start: FrameBound{Kind: Preceding, Offset: &Literal{Kind: NumberLiteral, Value: "3"}}, End: FrameBound{Kind: CurrentRow}},
+			}},
+		},
+		{
+			name:    "window frame without bound",
+			input:   "SUM(x) OVER (ORDER BY y ROWS BETWEEN UNBOUNDED PRECEDING)",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:  "searched case",
+			input: "CASE WHEN age < 18 THEN 'minor' ELSE 'adult' END",
+			want: &CaseExpr{
+				Whens: []WhenClause{{Cond: &BinaryExpr{Op: "<", Left: &ColumnRef{Name: "age"}, Right: &Literal{Kind: NumberLiteral, Value: "18"}}, Result: &Literal{Kind: StringLiteral, Value: "minor"}}},
+				Else:  &Literal{Kind: StringLiteral, Value: "adult"},
+			},
+		},
+		{
+			name:  "simple case without else",
+			input: "CASE status WHEN 'A' THEN 1 WHEN 'B' THEN 2 END",
+			want: &CaseExpr{
+				Operand: &ColumnRef{Name: "status"},
+				Whens: []WhenClause{
+					{Cond: &Literal{Kind: StringLiteral, Value: "A"}, Result: &Literal{Kind: NumberLiteral, Value: "1"}},
+					{Cond: &Literal{Kind: StringLiteral, Value: "B"}, Result: &Literal{Kind: NumberLiteral, Value: "2"}},
+				},
+			},
+		},
+		{
+			name:  "concatenation binds looser than addition",
+			input: "a || b + 1 = c",
+			want: &BinaryExpr{
+				Op:    "=",
+				Left:  &BinaryExpr{Op: "||", Left: &ColumnRef{Name: "a"}, Right: &BinaryExpr{Op: "+", Left: &ColumnRef{Name: "b"}, Right: &Literal{Kind: NumberLiteral, Value: "1"}}},
+				Right: &ColumnRef{Name: "c"},
+			},
+		},
+		{
+			name:  "substring from for",
+			input: "SUBSTRING(name FROM 2 FOR 3)",
+			want:  &FuncCall{Name: "SUBSTRING", Args: []Expr{&ColumnRef{Name: "name"}, &Literal{Kind: NumberLiteral, Value: "2"}, &Literal{Kind: NumberLiteral, Value: "3"}}},
+		},
+		{
+			name:  "position in",
+			input: "POSITION('@' IN email)",
+			want:  &FuncCall{Name: "POSITION", Args: []Expr{&Literal{Kind: StringLiteral, Value: "@"}, &ColumnRef{Name: "email"}}},
+		},
+		{
+			name:  "trim leading",
+			input: "TRIM(LEADING '0' FROM code)",
+			want:  &FuncCall{Name: "LTRIM", Args: []Expr{&ColumnRef{Name: "code"}, &Literal{Kind: StringLiteral, Value: "0"}}},
+		},
+		{
+			name:  "trim with arguments",
+			input: "TRIM(code, 'x')",
+			want:  &FuncCall{Name: "TRIM", Args: []Expr{&ColumnRef{Name: "code"}, &Literal{Kind: StringLiteral, Value: "x"}}},
+		},
+		{
+			name:    "position without in",
+			input:   "POSITION('@', email)",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "case without when",
+			input:   "CASE ELSE 1 END",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "case without end",
+			input:   "CASE WHEN a THEN 1",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:  "date literal",
+			input: "created >= DATE '2024-01-01'",
+			want:  &BinaryExpr{Op: ">=", Left: &ColumnRef{Name: "created"}, Right: &Literal{Kind: DateLiteral, Value: "2024-01-01"}},
+		},
+		{
+			name:  "interval string",
+			input: "NOW() - INTERVAL '7 days'",
+			want: &BinaryExpr{
+				Op:    "-",
+				Left:  &FuncCall{Name: "NOW"},
+				Right: &IntervalExpr{Value: &Literal{Kind: StringLiteral, Value: "7 days"}},
+			},
+		},
+		{
+			name:  "interval with unit",
+			input: "CURRENT_TIMESTAMP + INTERVAL 2 hours",
+			want: &BinaryExpr{
+				Op:    "+",
+				Left:  &FuncCall{Name: "CURRENT_TIMESTAMP"},
+				Right: &IntervalExpr{Value: &Literal{Kind: NumberLiteral, Value: "2"}, Unit: "HOURS"},
+			},
+		},
+		{
+			name:  "extract",
+			input: "EXTRACT(month FROM created)",
+			want:  &FuncCall{Name: "EXTRACT", Args: []Expr{&Literal{Kind: StringLiteral, Value: "MONTH"}, &ColumnRef{Name: "created"}}},
+		},
+		{
+			name:  "cast",
+			input: "CAST(price AS NUMERIC(10, 2))",
+			want:  &CastExpr{Expr: &ColumnRef{Name: "price"}, Type: "NUMERIC(10, 2)"},
+		},
+		{
+			name:  "convert",
+			input: "CONVERT(varchar, id)",
+			want:  &CastExpr{Expr: &ColumnRef{Name: "id"}, Type: "VARCHAR"},
+		},
+		{
+			name:  "postfix cast binds tighter than minus",
+			input: "-amount::double precision",
+			want:  &UnaryExpr{Op: "-", Expr: &CastExpr{Expr: &ColumnRef{Name: "amount"}, Type: "DOUBLE PRECISION"}},
+		},
+		{
+			name:  "chained postfix casts",
+			input: "'2024-01-01'::timestamp with time zone::date",
+			want: &CastExpr{
+				Expr: &CastExpr{Expr: &Literal{Kind: StringLiteral, Value: "2024-01-01"}, Type: "TIMESTAMP WITH TIME ZONE"},
+				Type: "DATE",
+			},
+		},
+		{
+			name:  "json operators",
+			input: "data->'tags'->>-1 = 'vip'",
+			want: &BinaryExpr{
+				Op: "=",
+				Left: &BinaryExpr{
+					Op:    "->>",
+					Left:  &BinaryExpr{Op: "->", Left: &ColumnRef{Name: "data"}, Right: &Literal{Kind: StringLiteral, Value: "tags"}},
+					Right: &Literal{Kind: NumberLiteral, Value: "-1"},
+				},
+				Right: &Literal{Kind: StringLiteral, Value: "vip"},
+			},
+		},
+		{
+			name:  "json operator followed by a cast",
+			input: "data->>'age'::int",
+			want: &CastExpr{
+				Expr: &BinaryExpr{Op: "->>", Left: &ColumnRef{Name: "data"}, Right: &Literal{Kind: StringLiteral, Value: "age"}},
+				Type: "INT",
+			},
+		},
+		{
+			name:  "filtered aggregate",
+			input: "COUNT(*) FILTER (WHERE open)",
+			want:  &FuncCall{Name: "COUNT", Args: []Expr{&StarExpr{}}, Filter: &ColumnRef{Name: "open"}},
+		},
+		{
+			name:  "ordered-set aggregate",
+			input: "PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY ms DESC)",
+			want: &FuncCall{
+				Name:    "PERCENTILE_CONT",
+				Args:    []Expr{&Literal{Kind: NumberLiteral, Value: "0.95"}},
+				OrderBy: []OrderItem{{Expr: &ColumnRef{Name: "ms"}, Desc: true}},
+			},
+		},
+		{
+			name:  "case-insensitive regular expression match",
+			input: "email !~* '@example'",
+			want:  &BinaryExpr{Op: "!~*", Left: &ColumnRef{Name: "email"}, Right: &Literal{Kind: StringLiteral, Value: "@example"}},
+		},
+		{
+			name:  "negated rlike",
+			input: "name NOT RLIKE '^a' AND active",
+			want: &BinaryExpr{
+				Op:    "AND",
+				Left:  &BinaryExpr{Op: "NOT REGEXP", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "^a"}},
+				Right: &ColumnRef{Name: "active"},
+			},
+		},
+		{
+			name:  "aggregate with an order",
+			input: "STRING_AGG(name, ', ' ORDER BY age DESC)",
+			want: &FuncCall{
+				Name:    "STRING_AGG",
+				Args:    []Expr{&ColumnRef{Name: "name"}, &Literal{Kind: StringLiteral, Value: ", "}},
+				OrderBy: []OrderItem{{Expr: &ColumnRef{Name: "age"}, Desc: true}},
+			},
+		},
+		{
+			name:  "group concat with a separator",
+			input: "GROUP_CONCAT(DISTINCT first, last ORDER BY last SEPARATOR ';')",
+			want: &FuncCall{
+				Name: "GROUP_CONCAT",
+				Args: []Expr{
+					&FuncCall{Name: "CONCAT", Args: []Expr{&ColumnRef{Name: "first"}, &ColumnRef{Name: "last"}}},
+					&Literal{Kind: StringLiteral, Value: ";"},
+				},
+				Distinct: true,
+				OrderBy:  []OrderItem{{Expr: &ColumnRef{Name: "last"}}},
+			},
+		},
+		{
+			name:  "group concat with the default separator",
+			input: "GROUP_CONCAT(name)",
+			want:  &FuncCall{Name: "GROUP_CONCAT", Args: []Expr{&ColumnRef{Name: "name"}, &Literal{Kind: StringLiteral, Value: ","}}},
+		},
+		{
+			name:    "order on a scalar function",
+			input:   "UPPER(name ORDER BY name)",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "order and within group",
+			input:   "LISTAGG(name, ',' ORDER BY name) WITHIN GROUP (ORDER BY name)",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "within group on a scalar function",
+			input:   "ROUND(ms) WITHIN GROUP (ORDER BY ms)",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "filter on a scalar function",
+			input:   "UPPER(name) FILTER (WHERE open)",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "cast without type",
+			input:   "CAST(price AS)",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "interval without unit",
+			input:   "INTERVAL 7",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "missing operand",
+			input:   "age >",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newQueryParser(tt.input)
+			require.NoError(t, err)
+			got, err := p.parseExpr()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseSelect(t *testing.T) {
+	limit := int64(100)
+	tests := []struct {
+		name    string
+		input   string
+		want    Query
+		wantErr bool
+	}{
+		{
+			name:  "count with filter",
+			input: "SELECT COUNT(*) FROM users WHERE active = true",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Columns:     []string{"COUNT(*)"},
+				Filter:      "active=true",
+				Projections: []Projection{{Expr: &FuncCall{Name: "COUNT", Args: []Expr{&StarExpr{}}}}},
+				Where:       &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "active"}, Right: &Literal{Kind: BooleanLiteral, Value: "true"}},
+			},
+		},
+		{
+			name:  "distinct",
+			input: "SELECT DISTINCT city FROM users",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Columns:     []string{"city"},
+				Projections: []Projection{{Expr: &ColumnRef{Name: "city"}}},
+				Distinct:    true,
+			},
+		},
+		{
+			name:  "all",
+			input: "SELECT ALL city FROM users",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Columns:     []string{"city"},
+				Projections: []Projection{{Expr: &ColumnRef{Name: "city"}}},
+			},
+		},
+		{
+			name:  "aggregates with aliases",
+			input: "SELECT MAX(age) AS oldest, AVG(score) average FROM app.t;",
+			want: Query{
+				Command:  SQLSelect,
+				Database: "app",
+				Table:    "t",
+				Columns:  []string{"oldest", "average"},
+				Projections: []Projection{
+					{Expr: &FuncCall{Name: "MAX", Args: []Expr{&ColumnRef{Name: "age"}}}, Alias: "oldest"},
+					{Expr: &FuncCall{Name: "AVG", Args: []Expr{&ColumnRef{Name: "score"}}}, Alias: "average"},
+				},
+			},
+		},
+		{
+			name:  "keywords as names",
+			input: "SELECT u.limit AS full FROM app.order u",
+			want: Query{
+				Command:     SQLSelect,
+				Database:    "app",
+				Table:       "order",
+				Alias:       "u",
+				Columns:     []string{"full"},
+				Projections: []Projection{{Expr: &ColumnRef{Table: "u", Name: "limit"}, Alias: "full"}},
+			},
+		},
+		{
+			name:  "group by",
+			input: "SELECT country, COUNT(*) FROM orders WHERE total > 10 GROUP BY country, 2",
+			want: Query{
+				Command: SQLSelect,
+				Table:   "orders",
+				Columns: []string{"country", "COUNT(*)"},
+				Filter:  "total>10",
+				Projections: []Projection{
+					{Expr: &ColumnRef{Name: "country"}},
+					{Expr: &FuncCall{Name: "COUNT", Args: []Expr{&StarExpr{}}}},
+				},
+				Where:   &BinaryExpr{Op: ">", Left: &ColumnRef{Name: "total"}, Right: &Literal{Kind: NumberLiteral, Value: "10"}},
+				GroupBy: []Expr{&ColumnRef{Name: "country"}, &Literal{Kind: NumberLiteral, Value: "2"}},
+			},
+		},
+		{
+			name:  "having",
+			input: "SELECT country FROM orders GROUP BY country HAVING SUM(total) >= 100",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "orders",
+				Columns:     []string{"country"},
+				Projections: []Projection{{Expr: &ColumnRef{Name: "country"}}},
+				GroupBy:     []Expr{&ColumnRef{Name: "country"}},
+				Having:      &BinaryExpr{Op: ">=", Left: &FuncCall{Name: "SUM", Args: []Expr{&ColumnRef{Name: "total"}}}, Right: &Literal{Kind: NumberLiteral, Value: "100"}},
+			},
+		},
+		{
+			name:  "joins",
+			input: "SELECT u.name, o.total FROM users AS u JOIN orders o ON o.user_id = u._id LEFT OUTER JOIN items ON items.order_id = o._id",
+			want: Query{
+				Command: SQLSelect,
+				Table:   "users",
+				Alias:   "u",
+				Columns: []string{"name", "total"},
+				Projections: []Projection{
+					{Expr: &ColumnRef{Table: "u", Name: "name"}},
+					{Expr: &ColumnRef{Table: "o", Name: "total"}},
+				},
+				Joins: []Join{
+					{Type: InnerJoin, Table: "orders", Alias: "o", On: &BinaryExpr{Op: "=", Left: &ColumnRef{Table: "o", Name: "user_id"}, Right: &ColumnRef{Table: "u", Name: "_id"}}},
+					{Type: LeftJoin, Table: "items", On: &BinaryExpr{Op: "=", Left: &ColumnRef{Table: "items", Name: "order_id"}, Right: &ColumnRef{Table: "o", Name: "_id"}}},
+				},
+			},
+		},
+		{
+			name:  "right and full joins",
+			input: "SELECT * FROM users u RIGHT OUTER JOIN orders o ON o.user_id = u._id FULL JOIN items i ON i.order_id = o._id",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Alias:       "u",
+				Columns:     []string{"*"},
+				Projections: []Projection{{Expr: &StarExpr{}}},
+				Joins: []Join{
+					{Type: RightJoin, Table: "orders", Alias: "o", On: &BinaryExpr{Op: "=", Left: &ColumnRef{Table: "o", Name: "user_id"}, Right: &ColumnRef{Table: "u", Name: "_id"}}},
+					{Type: FullJoin, Table: "items", Alias: "i", On: &BinaryExpr{Op: "=", Left: &ColumnRef{Table: "i", Name: "order_id"}, Right: &ColumnRef{Table: "o", Name: "_id"}}},
+				},
+			},
+		},
+		{
+			name:  "set operations",
+			input: "SELECT name FROM users UNION ALL SELECT name FROM admins EXCEPT DISTINCT SELECT name FROM banned",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Columns:     []string{"name"},
+				Projections: []Projection{{Expr: &ColumnRef{Name: "name"}}},
+				SetOperations: []SetOperation{
+					{Operator: Union, All: true, Query: Query{Command: SQLSelect, Table: "admins", Columns: []string{"name"}, Projections: []Projection{{Expr: &ColumnRef{Name: "name"}}}}},
+					{Operator: Except, Query: Query{Command: SQLSelect, Table: "banned", Columns: []string{"name"}, Projections: []Projection{{Expr: &ColumnRef{Name: "name"}}}}},
+				},
+			},
+		},
+		{
+			name:  "intersect after a union",
+			input: "SELECT a FROM t UNION SELECT a FROM u INTERSECT SELECT a FROM v",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "t",
+				Columns:     []string{"a"},
+				Projections: []Projection{{Expr: &ColumnRef{Name: "a"}}},
+				SetOperations: []SetOperation{
+					{Operator: Union, Query: Query{
+						Command:     SQLSelect,
+						Table:       "u",
+						Columns:     []string{"a"},
+						Projections: []Projection{{Expr: &ColumnRef{Name: "a"}}},
+						SetOperations: []SetOperation{
+							{Operator: Intersect, Query: Query{Command: SQLSelect, Table: "v", Columns: []string{"a"}, Projections: []Projection{{Expr: &ColumnRef{Name: "a"}}}}},
+						},
+					}},
+				},
+			},
+		},
+		{
+			name:  "intersect before a union",
+			input: "SELECT a FROM t INTERSECT SELECT a FROM u UNION SELECT a FROM v",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "t",
+				Columns:     []string{"a"},
+				Projections: []Projection{{Expr: &ColumnRef{Name: "a"}}},
+				SetOperations: []SetOperation{
+					{Operator: Intersect, Query: Query{Command: SQLSelect, Table: "u", Columns: []string{"a"}, Projections: []Projection{{Expr: &ColumnRef{Name: "a"}}}}},
+					{Operator: Union, Query: Query{Command: SQLSelect, Table: "v", Columns: []string{"a"}, Projections: []Projection{{Expr: &ColumnRef{Name: "a"}}}}},
+				},
+			},
+		},
+		{
+			name:    "set operation without query",
+			input:   "SELECT name FROM users UNION",
+			want:    Query{},
+			wantErr: true,
+		},
+		{
+			name:  "derived table",
+			input: "SELECT * FROM (SELECT user_id, SUM(total) s FROM orders GROUP BY user_id) t WHERE t.s > 100",
+			want: Query{
+				Command:     SQLSelect,
+				Alias:       "t",
+				Columns:     []string{"*"},
+				Filter:      "t.s>100",
+				Projections: []Projection{{Expr: &StarExpr{}}},
+				Where:       &BinaryExpr{Op: ">", Left: &ColumnRef{Table: "t", Name: "s"}, Right: &Literal{Kind: NumberLiteral, Value: "100"}},
+				From: &Query{
+					Command: SQLSelect,
+					Table:   "orders",
+					Columns: []string{"user_id", "s"},
+					Projections: []Projection{
+						{Expr: &ColumnRef{Name: "user_id"}},
+						{Expr: &FuncCall{Name: "SUM", Args: []Expr{&ColumnRef{Name: "total"}}}, Alias: "s"},
+					},
+					GroupBy: []Expr{&ColumnRef{Name: "user_id"}},
+				},
+			},
+		},
+		{
+			name:    "derived table without alias",
+			input:   "SELECT * FROM (SELECT name FROM users)",
+			want:    Query{},
+			wantErr: true,
+		},
+		{
+			name:  "common table expressions",
+			input: "WITH RECURSIVE a (n) AS (SELECT name FROM users), b AS (SELECT n FROM a) SELECT n FROM b JOIN (SELECT n FROM a) c ON c.n = b.n",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "b",
+				Columns:     []string{"n"},
+				Projections: []Projection{{Expr: &ColumnRef{Name: "n"}}},
+				Joins: []Join{{
+					Type:     InnerJoin,
+					Alias:    "c",
+					On:       &BinaryExpr{Op: "=", Left: &ColumnRef{Table: "c", Name: "n"}, Right: &ColumnRef{Table: "b", Name: "n"}},
+					Subquery: &Query{Command: SQLSelect, Table: "a", Columns: []string{"n"}, Projections: []Projection{{Expr: &ColumnRef{Name: "n"}}}},
+				}},
+				With: []CommonTableExpression{
+					{
+						Name:      "a",
+						Columns:   []string{"n"},
+						Query:     Query{Command: SQLSelect, Table: "users", Columns: []string{"name"}, Projections: []Projection{{Expr: &ColumnRef{Name: "name"}}}},
+						Recursive: true,
+					},
+					{
+						Name:      "b",
+						Query:     Query{Command: SQLSelect, Table: "a", Columns: []string{"n"}, Projections: []Projection{{Expr: &ColumnRef{Name: "n"}}}},
+						Recursive: true,
+					},
+				},
+			},
+		},
+		{
+			name:    "common table expression without query",
+			input:   "WITH a AS SELECT name FROM users SELECT * FROM a",
+			want:    Query{},
+			wantErr: true,
+		},
+		{
+			name:    "join without condition",
+			input:   "SELECT * FROM users JOIN orders",
+			want:    Query{},
+			wantErr: true,
+		},
+		{
+			name:    "missing table",
+			input:   "SELECT name FROM",
+			want:    Query{},
+			wantErr: true,
+		},
+		{
+			name:    "trailing tokens",
+			input:   "SELECT name FROM users users2 extra",
+			want:    Query{},
+			wantErr: true,
+		},
+		{
+			name:  "pivot",
+			input: "SELECT * FROM sales PIVOT (SUM(amount) FOR quarter IN ([Q1], 'Q2' AS second, [2024])) AS p",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "sales",
+				Columns:     []string{"*"},
+				Projections: []Projection{{Expr: &StarExpr{}}},
+				Pivot: &Pivot{
+					Aggregate: &FuncCall{Name: "SUM", Args: []Expr{&ColumnRef{Name: "amount"}}},
+					Column:    &ColumnRef{Name: "quarter"},
+					Values: []PivotValue{
+						{Value: &Literal{Kind: StringLiteral, Value: "Q1"}, Name: "Q1"},
+						{Value: &Literal{Kind: StringLiteral, Value: "Q2"}, Name: "second"},
+						{Value: &Literal{Kind: NumberLiteral, Value: "2024"}, Name: "2024"},
+					},
+					Alias: "p",
+				},
+			},
+		},
+		{
+			name:    "pivot without aggregate",
+			input:   "SELECT * FROM sales PIVOT (amount FOR quarter IN ([Q1]))",
+			wantErr: true,
+		},
+		{
+			name:  "order by",
+			input: "SELECT name FROM users ORDER BY CASE WHEN vip THEN 0 ELSE 1 END, name DESC",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Columns:     []string{"name"},
+				Projections: []Projection{{Expr: &ColumnRef{Name: "name"}}},
+				OrderBy: []OrderItem{
+					{Expr: &CaseExpr{
+						Whens: []WhenClause{{Cond: &ColumnRef{Name: "vip"}, Result: &Literal{Kind: NumberLiteral, Value: "0"}}},
+						Else:  &Literal{Kind: NumberLiteral, Value: "1"},
+					}},
+					{Expr: &ColumnRef{Name: "name"}, Desc: true},
+				},
+			},
+		},
+		{
+			name:  "random order with limit",
+			input: "SELECT * FROM events ORDER BY RANDOM() LIMIT 100",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "events",
+				Columns:     []string{"*"},
+				Projections: []Projection{{Expr: &StarExpr{}}},
+				OrderBy:     []OrderItem{{Expr: &FuncCall{Name: "RANDOM"}}},
+				Limit:       &limit,
+			},
+		},
+		{
+			name:    "negative limit",
+			input:   "SELECT * FROM events LIMIT -1",
+			wantErr: true,
+		},
+		{
+			name:  "tablesample",
+			input: "SELECT * FROM events e TABLESAMPLE system (0.5) WHERE e.type = 'click'",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "events",
+				Alias:       "e",
+				Columns:     []string{"*"},
+				Filter:      "e.type=click",
+				Projections: []Projection{{Expr: &StarExpr{}}},
+				Where:       &BinaryExpr{Op: "=", Left: &ColumnRef{Table: "e", Name: "type"}, Right: &Literal{Kind: StringLiteral, Value: "click"}},
+				Sample:      &TableSample{Method: "SYSTEM", Percent: 0.5},
+			},
+		},
+		{
+			name:  "cross join unnest with ordinality",
+			input: "SELECT o._id, i.sku FROM orders o CROSS JOIN UNNEST(o.items) WITH ORDINALITY AS i(item, n)",
+			want: Query{
+				Command: SQLSelect,
+				Table:   "orders",
+				Alias:   "o",
+				Columns: []string{"_id", "sku"},
+				Projections: []Projection{
+					{Expr: &ColumnRef{Table: "o", Name: "_id"}},
+					{Expr: &ColumnRef{Table: "i", Name: "sku"}},
+				},
+				Joins: []Join{{
+					Type:   CrossJoin,
+					Alias:  "i",
+					Unnest: &Unnest{Array: &ColumnRef{Table: "o", Name: "items"}, Ordinality: true, Columns: []string{"item", "n"}},
+				}},
+			},
+		},
+		{
+			name:  "left join lateral unnest",
+			input: "SELECT i.sku FROM orders LEFT JOIN LATERAL unnest(items) i ON TRUE",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "orders",
+				Columns:     []string{"sku"},
+				Projections: []Projection{{Expr: &ColumnRef{Table: "i", Name: "sku"}}},
+				Joins: []Join{{
+					Type:   LeftJoin,
+					Alias:  "i",
+					On:     &Literal{Kind: BooleanLiteral, Value: "true"},
+					Unnest: &Unnest{Array: &ColumnRef{Name: "items"}},
+				}},
+			},
+		},
+		{
+			name:  "select star except",
+			input: "SELECT * EXCEPT (password, ssn) FROM users",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Columns:     []string{"* EXCEPT (password, ssn)"},
+				Projections: []Projection{{Expr: &StarExpr{Except: []string{"password", "ssn"}}}},
+			},
+		},
+		{
+			name:  "select star exclude without parentheses",
+			input: "SELECT * EXCLUDE password, price * 2 AS dbl FROM users",
+			want: Query{
+				Command: SQLSelect,
+				Table:   "users",
+				Columns: []string{"* EXCEPT (password)", "dbl"},
+				Projections: []Projection{
+					{Expr: &StarExpr{Except: []string{"password"}}},
+					{Expr: &BinaryExpr{Op: "*", Left: &ColumnRef{Name: "price"}, Right: &Literal{Kind: NumberLiteral, Value: "2"}}, Alias: "dbl"},
+				},
+			},
+		},
+		{
+			name:    "select star except without parentheses",
+			input:   "SELECT * EXCEPT password FROM users",
+			wantErr: true,
+		},
+		{
+			name:  "use index",
+			input: "SELECT name FROM users u USE INDEX FOR ORDER BY (idx_age) ORDER BY age",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Alias:       "u",
+				Columns:     []string{"name"},
+				Projections: []Projection{{Expr: &ColumnRef{Name: "name"}}},
+				OrderBy:     []OrderItem{{Expr: &ColumnRef{Name: "age"}}},
+				Hint:        "idx_age",
+			},
+		},
+		{
+			name:  "table hints",
+			input: "SELECT * FROM users WITH (NOLOCK, INDEX = idx_email)",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Columns:     []string{"*"},
+				Projections: []Projection{{Expr: &StarExpr{}}},
+				Hint:        "idx_email",
+			},
+		},
+		{
+			name:  "optimizer hint comment",
+			input: "SELECT /*+ NO_ICP(u) INDEX(u idx_email) */ * FROM users u",
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Alias:       "u",
+				Columns:     []string{"*"},
+				Projections: []Projection{{Expr: &StarExpr{}}},
+				Hint:        "idx_email",
+			},
+		},
+		{
+			name:  "collate",
+			input: `SELECT name FROM users WHERE name = 'muller' COLLATE "de-u-ks-level1" ORDER BY name COLLATE utf8mb4_0900_ai_ci, age`,
+			want: Query{
+				Command:     SQLSelect,
+				Table:       "users",
+				Columns:     []string{"name"},
+				Filter:      `name=mullerCOLLATEde-u-ks-level1`,
+				Projections: []Projection{{Expr: &ColumnRef{Name: "name"}}},
+				Where:       &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "muller"}},
+				OrderBy:     []OrderItem{{Expr: &ColumnRef{Name: "name"}}, {Expr: &ColumnRef{Name: "age"}}},
+				Collations:  []string{"de-u-ks-level1", "utf8mb4_0900_ai_ci"},
+			},
+		},
+		{
+			name:    "collate without a collation",
+			input:   "SELECT name FROM users ORDER BY name COLLATE 1",
+			wantErr: true,
+		},
+		{
+			name:    "optimizer hint for another table",
+			input:   "SELECT /*+ INDEX(orders idx_total) */ * FROM users",
+			wantErr: true,
+		},
+		{
+			name:    "force index naming two indexes",
+			input:   "SELECT * FROM users FORCE INDEX (idx_email, idx_age)",
+			wantErr: true,
+		},
+		{
+			name:    "conflicting index hints",
+			input:   "SELECT /*+ INDEX(users idx_age) */ * FROM users USE INDEX (idx_email)",
+			wantErr: true,
+		},
+		{
+			name:    "ignore index",
+			input:   "SELECT * FROM users IGNORE INDEX (idx_email)",
+			wantErr: true,
+		},
+		{
+			name:    "index hint on a joined table",
+			input:   "SELECT * FROM users u JOIN orders o USE INDEX (idx_user) ON u._id = o.user_id",
+			wantErr: true,
+		},
+		{
+			name:    "unnest without alias",
+			input:   "SELECT * FROM orders CROSS JOIN UNNEST(items)",
+			wantErr: true,
+		},
+		{
+			name:    "unnest naming too many columns",
+			input:   "SELECT * FROM orders CROSS JOIN UNNEST(items) AS i(item, n)",
+			wantErr: true,
+		},
+		{
+			name:    "lateral subquery",
+			input:   "SELECT * FROM orders o JOIN LATERAL (SELECT * FROM items) AS i ON TRUE",
+			wantErr: true,
+		},
+		{
+			name:    "tablesample with seed",
+			input:   "SELECT * FROM events TABLESAMPLE BERNOULLI (10) REPEATABLE (42)",
+			wantErr: true,
+		},
+		{
+			name:    "tablesample over 100 percent",
+			input:   "SELECT * FROM events TABLESAMPLE BERNOULLI (150)",
+			wantErr: true,
+		},
+		{
+			name:    "offset after the table",
+			input:   "SELECT * FROM users OFFSET 3",
+			wantErr: true,
+		},
+		{
+			name:    "natural join",
+			input:   "SELECT * FROM users NATURAL JOIN orders",
+			wantErr: true,
+		},
+		{
+			name:    "group by without columns",
+			input:   "SELECT a FROM t GROUP BY",
+			wantErr: true,
+		},
+		{
+			name:    "aggregate with a group by without columns",
+			input:   "SELECT COUNT(*) FROM t GROUP BY",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HandleSelectUserInput(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseGroupBy(t *testing.T) {
+	a, b, c := &ColumnRef{Name: "a"}, &ColumnRef{Name: "b"}, &ColumnRef{Name: "c"}
+	tests := []struct {
+		name     string
+		input    string
+		wantKeys []Expr
+		wantSets [][]Expr
+		wantErr  bool
+	}{
+		{
+			name:     "plain",
+			input:    "a, (b + c) * 2",
+			wantKeys: []Expr{a, &BinaryExpr{Op: "*", Left: &BinaryExpr{Op: "+", Left: b, Right: c}, Right: &Literal{Kind: NumberLiteral, Value: "2"}}},
+		},
+		{
+			name:     "rollup",
+			input:    "ROLLUP(a, b)",
+			wantKeys: []Expr{a, b},
+			wantSets: [][]Expr{{a, b}, {a}, {}},
+		},
+		{
+			name:     "cube",
+			input:    "CUBE(a, b)",
+			wantKeys: []Expr{a, b},
+			wantSets: [][]Expr{{a, b}, {a}, {b}, {}},
+		},
+		{
+			name:     "grouping sets",
+			input:    "GROUPING SETS ((a, b), c, ())",
+			wantKeys: []Expr{a, b, c},
+			wantSets: [][]Expr{{a, b}, {c}, {}},
+		},
+		{
+			name:     "column with a rollup",
+			input:    "c, ROLLUP((a, b))",
+			wantKeys: []Expr{c, a, b},
+			wantSets: [][]Expr{{c, a, b}, {c}},
+		},
+		{
+			name:    "unterminated rollup",
+			input:   "ROLLUP(a, b",
+			wantErr: true,
+		},
+		{
+			name:    "cube of too many columns",
+			input:   "CUBE(a, b, c, d, e, f, g, h, i, j, k, l, m)",
+			wantErr: true,
+		},
+		{
+			name:    "cube of more columns than a shift holds",
+			input:   "CUBE(" + strings.Repeat("a, ", 70) + "b)",
+			wantErr: true,
+		},
+		{
+			name:    "cubes expanding to too many grouping sets",
+			input:   "CUBE(a, b, c, d, e, f), CUBE(g, h, i, j, k, l, m)",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newQueryParser(tt.input)
+			require.NoError(t, err)
+			keys, sets, err := p.parseGroupBy()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantKeys, keys)
+			require.Equal(t, tt.wantSets, sets)
+		})
+	}
+}
@@ -19,18 +19,167 @@ const (
 
 // Query is a SQL query
 type Query struct {
-	Command  Command
-	Database string
-	Table    string
-	Columns  []string
-	Filter   string
-	Values   []interface{}
+	Command     Command
+	Database    string
+	Table       string
+	Columns     []string
+	Filter      string
+	Values      []interface{}
+	Projections []Projection
+	// Distinct removes the duplicate rows of a SELECT DISTINCT
+	Distinct bool
+	Where    Expr
+	GroupBy  []Expr
+	Having   Expr
+	Alias    string
+	Joins    []Join
+	// From is the derived table the query reads from instead of Table
+	From *Query
+	// With defines the common table expressions the query can refer to by name
+	With []CommonTableExpression
+	// SetOperations combine the rows of the query with the rows of other queries
+	SetOperations []SetOperation
+	// GroupingSets lists the sets of GroupBy expressions that rows are grouped by separately, as
+	// expanded from GROUPING SETS, ROLLUP and CUBE, and is nil for a plain GROUP BY
+	GroupingSets [][]Expr
+	// Pivot turns the values of a column of the rows read FROM into columns
+	Pivot *Pivot
+	// OrderBy sorts the rows of the query, after any set operations
+	OrderBy []OrderItem
+	// Limit caps the number of rows of the query once they are sorted, and is nil without LIMIT
+	Limit *int64
+	// Sample reads a random sample of the rows of Table instead of all of them
+	Sample *TableSample
+	// Hint names the index of Table the query must use, as given by an index hint
+	Hint string
+	// Collations lists the collations named by the COLLATE clauses of the statement, which MongoDB
+	// applies to the whole command rather than to a single comparison
+	Collations []string
+	// Set holds the assignments of an UPDATE that sets a column to an expression
+	// rather than a literal value
+	Set []Assignment
+}
+
+// Pivot is a T-SQL PIVOT, which groups rows by their remaining columns and turns each listed value
+// of a column into a column that aggregates the rows holding it
+type Pivot struct {
+	Aggregate *FuncCall
+	// Column is the column whose values become columns
+	Column *ColumnRef
+	Values []PivotValue
+	// Alias names the pivoted rows
+	Alias string
+}
+
+// PivotValue is a value of the pivot column and the name of the column it becomes
+type PivotValue struct {
+	Value *Literal
+	Name  string
+}
+
+// TableSample is a TABLESAMPLE clause, which reads about Percent percent of the rows of a table
+type TableSample struct {
+	// Method is SYSTEM or BERNOULLI
+	Method  string
+	Percent float64
+}
+
+// Assignment is a column = expression pair of an UPDATE SET clause
+type Assignment struct {
+	Column string
+	Value  Expr
+}
+
+// SetOperator is the operator of a set operation
+type SetOperator string
+
+// SQL set operators
+const (
+	Union     SetOperator = "UNION"
+	Intersect SetOperator = "INTERSECT"
+	Except    SetOperator = "EXCEPT"
+)
+
+// SetOperation is a UNION, INTERSECT or EXCEPT of the rows of another query
+type SetOperation struct {
+	Operator SetOperator
+	// All keeps duplicate rows
+	All   bool
+	Query Query
+}
+
+// CommonTableExpression is a named query defined by a WITH clause
+type CommonTableExpression struct {
+	Name string
+	// Columns renames the columns of the query when given
+	Columns []string
+	Query   Query
+	// Recursive is set for the queries of a WITH RECURSIVE clause, which may refer to themselves
+	Recursive bool
+}
+
+// JoinType is the kind of a JOIN clause
+type JoinType string
+
+// SQL join types
+const (
+	InnerJoin JoinType = "INNER"
+	LeftJoin  JoinType = "LEFT"
+	RightJoin JoinType = "RIGHT"
+	FullJoin  JoinType = "FULL"
+	CrossJoin JoinType = "CROSS"
+)
+
+// Join is a table joined to the query by a JOIN clause
+type Join struct {
+	Type  JoinType
+	Table string
+	Alias string
+	On    Expr
+	// Subquery is the derived table joined instead of Table
+	Subquery *Query
+	// Unnest is the array expanded into a row per element instead of Table
+	Unnest *Unnest
+}
+
+// Unnest is an UNNEST in a JOIN, which returns a row for each element of an array
+type Unnest struct {
+	Array Expr
+	// Ordinality adds a column numbering the elements from 1, as WITH ORDINALITY asks
+	Ordinality bool
+	// Columns renames the element and ordinality columns, as listed after the alias
+	Columns []string
+}
+
+// Name returns the alias of a joined table, or its name if it has no alias
+func (j Join) Name() string {
+	if j.Alias != "" {
+		return j.Alias
+	}
+	return j.Table
+}
+
+// Projection is a single entry of a SELECT list
+type Projection struct {
+	Expr  Expr
+	Alias string
+}
+
+// Name returns the name of the result column produced by a projection
+func (p Projection) Name() string {
+	if p.Alias != "" {
+		return p.Alias
+	}
+	if column, ok := p.Expr.(*ColumnRef); ok {
+		return column.Name
+	}
+	return p.Expr.String()
 }
 
 // ParseSQLCommand parses a SQL command
 func ParseSQLCommand(command string) (Command, error) {
 	switch command {
-	case "SELECT":
+	case "SELECT", "WITH":
 		return SQLSelect, nil
 	case "INSERT":
 		return SQLInsert, nil
@@ -54,55 +203,11 @@ func GetCommandFromUserInput(input string) (Command, error) {
 
 // HandleSelectUserInput handles user input for a SELECT command
 func HandleSelectUserInput(input string) (Query, error) {
-	var result Query
-
-	result.Command = SQLSelect
-	column, err := parser.FindBetween(input, "SELECT", "FROM")
-	if err != nil {
-		return Query{}, err
-	}
-	columns, err := parser.SplitInputByDelimiters(column, []string{" ", ","})
+	p, err := newQueryParser(input)
 	if err != nil {
 		return Query{}, err
 	}
-	result.Columns = columns
-
-	has := parser.ContainsCommand(input, "WHERE")
-	if has {
-		tables, err := parser.FindBetween(input, "FROM", "WHERE")
-		if err != nil {
-			return Query{}, err
-		}
-		table, err := parser.SplitInputByDelimiters(tables, []string{" ", ","})
-		if err != nil {
-			return Query{}, err
-		}
-		result.Table = table[0]
-
-		filters, err := parser.FindAfter(input, "WHERE")
-		if err != nil {
-			return Query{}, err
-		}
-		filter, err := parser.SplitInputByDelimiters(filters, []string{" ", ",", "'"})
-		if err != nil {
-			return Query{}, err
-		}
-		for _, f := range filter {
-			result.Filter = result.Filter + f
-		}
-	} else {
-		tables, err := parser.FindAfter(input, "FROM")
-		if err != nil {
-			return Query{}, err
-		}
-		table, err := parser.SplitInputByDelimiters(tables, []string{" ", ","})
-		if err != nil {
-			return Query{}, err
-		}
-		result.Table = table[0]
-		result.Filter = ""
-	}
-	return result, nil
+	return p.parseSelect()
 }
 
 // HandleInsertUserInput handles user input for an INSERT command
@@ -167,108 +272,20 @@ func HandleInsertUserInput(input string) (Query, error) {
 
 // HandleUpdateUserInput handles user input for an UPDATE command
 func HandleUpdateUserInput(input string) (Query, error) {
-	var result Query
-	result.Command = SQLUpdate
-
-	temp, err := parser.FindBetween(input, "UPDATE", "SET")
+	p, err := newQueryParser(input)
 	if err != nil {
 		return Query{}, err
 	}
-	table, err := parser.SplitInputByDelimiters(temp, []string{" ", ","})
-	if err != nil {
-		return Query{}, err
-	}
-	result.Table = table[0]
-
-	if parser.ContainsCommand(input, "WHERE") {
-		temp, err := parser.FindAfter(input, "WHERE")
-		if err != nil {
-			return Query{}, err
-		}
-		line, err := parser.SplitInputByDelimiters(temp, []string{" ", ",", "'"})
-		if err != nil {
-			return Query{}, err
-		}
-		for _, l := range line {
-			result.Filter = result.Filter + l
-		}
-	} else {
-		result.Filter = ""
-	}
-
-	var lines string
-	if parser.ContainsCommand(input, "WHERE") {
-		lines, err = parser.FindBetween(input, "SET", "WHERE")
-		if err != nil {
-			return Query{}, err
-		}
-	} else {
-		lines, err = parser.FindAfter(input, "SET")
-		if err != nil {
-			return Query{}, err
-		}
-	}
-
-	line, err := parser.SplitInputByDelimiters(lines, []string{","})
-	if err != nil {
-		return Query{}, err
-	}
-	for _, l := range line {
-		column, err := parser.SplitInputByDelimiters(l, []string{"=", " ", "'"})
-		if err != nil {
-			return Query{}, err
-		}
-		result.Columns = append(result.Columns, column[0])
-		result.Values = append(result.Values, column[1])
-	}
-	return result, nil
+	return p.parseUpdate()
 }
 
 // HandleDeleteUserInput handles user input for a DELETE command
 func HandleDeleteUserInput(input string) (Query, error) {
-	var result Query
-
-	if !parser.ContainsCommand(input, "DELETE") {
-		return Query{}, fmt.Errorf("invalid command")
-	}
-	result.Command = SQLDelete
-
-	if parser.ContainsCommand(input, "WHERE") {
-		temp, err := parser.FindBetween(input, "FROM", "WHERE")
-		if err != nil {
-			return Query{}, err
-		}
-		table, err := parser.SplitInputByDelimiters(temp, []string{" ", ","})
-		if err != nil {
-			return Query{}, err
-		}
-		result.Table = table[0]
-
-		temp, err = parser.FindAfter(input, "WHERE")
-		if err != nil {
-			return Query{}, err
-		}
-		line, err := parser.SplitInputByDelimiters(temp, []string{" ", ",", "'"})
-		if err != nil {
-			return Query{}, err
-		}
-		for _, l := range line {
-			result.Filter = result.Filter + l
-		}
-	} else {
-		temp, err := parser.FindAfter(input, "FROM")
-		if err != nil {
-			return Query{}, err
-		}
-		table, err := parser.SplitInputByDelimiters(temp, []string{" ", ","})
-		if err != nil {
-			return Query{}, err
-		}
-		result.Table = table[0]
-		result.Filter = ""
+	p, err := newQueryParser(input)
+	if err != nil {
+		return Query{}, err
 	}
-
-	return result, nil
+	return p.parseDelete()
 }
 
 // ConvertUserInputToSQLQuery converts user input to a SQL query
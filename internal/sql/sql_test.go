@@ -18,6 +18,12 @@ func TestParseSQLCommand(t *testing.T) {
 			want:    SQLSelect,
 			wantErr: false,
 		},
+		{
+			name:    "with",
+			command: "WITH",
+			want:    SQLSelect,
+			wantErr: false,
+		},
 		{
 			name:    "insert",
 			command: "INSERT",
@@ -117,25 +123,25 @@ func TestHandleSelectUserInput(t *testing.T) {
 		{
 			name:    "select all",
 			input:   "SELECT * FROM users",
-			want:    Query{Command: SQLSelect, Database: "", Table: "users", Columns: []string{"*"}, Filter: ""},
+			want:    Query{Command: SQLSelect, Database: "", Table: "users", Columns: []string{"*"}, Filter: "", Projections: []Projection{{Expr: &StarExpr{}}}},
 			wantErr: false,
 		},
 		{
 			name:    "select with filter",
 			input:   "SELECT * FROM users WHERE name = 'Bob'",
-			want:    Query{Command: SQLSelect, Database: "", Table: "users", Columns: []string{"*"}, Filter: "name=Bob"},
+			want:    Query{Command: SQLSelect, Database: "", Table: "users", Columns: []string{"*"}, Filter: "name=Bob", Projections: []Projection{{Expr: &StarExpr{}}}, Where: &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "Bob"}}},
 			wantErr: false,
 		},
 		{
 			name:    "select with one column",
 			input:   "SELECT name FROM users",
-			want:    Query{Command: SQLSelect, Database: "", Table: "users", Columns: []string{"name"}, Filter: ""},
+			want:    Query{Command: SQLSelect, Database: "", Table: "users", Columns: []string{"name"}, Filter: "", Projections: []Projection{{Expr: &ColumnRef{Name: "name"}}}},
 			wantErr: false,
 		},
 		{
 			name:    "select with columns",
 			input:   "SELECT name, age FROM users",
-			want:    Query{Command: SQLSelect, Database: "", Table: "users", Columns: []string{"name", "age"}, Filter: ""},
+			want:    Query{Command: SQLSelect, Database: "", Table: "users", Columns: []string{"name", "age"}, Filter: "", Projections: []Projection{{Expr: &ColumnRef{Name: "name"}}, {Expr: &ColumnRef{Name: "age"}}}},
 			wantErr: false,
 		},
 		{
@@ -219,19 +225,85 @@ func TestHandleUpdateUserInput(t *testing.T) {
 		{
 			name:    "update one column",
 			input:   "UPDATE users SET age = 21 WHERE name = 'Bob'",
-			want:    Query{Command: SQLUpdate, Database: "", Table: "users", Columns: []string{"age"}, Values: []interface{}{"21"}, Filter: "name=Bob"},
+			want:    Query{Command: SQLUpdate, Table: "users", Columns: []string{"age"}, Filter: "name=Bob", Where: &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "Bob"}}, Set: []Assignment{{Column: "age", Value: &Literal{Kind: NumberLiteral, Value: "21"}}}},
 			wantErr: false,
 		},
 		{
 			name:    "update two columns",
 			input:   "UPDATE users SET age = 21, name = 'Bob' WHERE name = 'Bob'",
-			want:    Query{Command: SQLUpdate, Database: "", Table: "users", Columns: []string{"age", "name"}, Values: []interface{}{"21", "Bob"}, Filter: "name=Bob"},
+			want:    Query{Command: SQLUpdate, Table: "users", Columns: []string{"age", "name"}, Filter: "name=Bob", Where: &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "Bob"}}, Set: []Assignment{{Column: "age", Value: &Literal{Kind: NumberLiteral, Value: "21"}}, {Column: "name", Value: &Literal{Kind: StringLiteral, Value: "Bob"}}}},
+			wantErr: false,
+		},
+		{
+			name:  "update with an expression",
+			input: "UPDATE users SET tier = CASE WHEN points > 100 THEN 'gold' END, age = 21 WHERE active = true",
+			want: Query{
+				Command: SQLUpdate,
+				Table:   "users",
+				Columns: []string{"tier", "age"},
+				Filter:  "active=true",
+				Where:   &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "active"}, Right: &Literal{Kind: BooleanLiteral, Value: "true"}},
+				Set: []Assignment{
+					{Column: "tier", Value: &CaseExpr{Whens: []WhenClause{{
+						Cond:   &BinaryExpr{Op: ">", Left: &ColumnRef{Name: "points"}, Right: &Literal{Kind: NumberLiteral, Value: "100"}},
+						Result: &Literal{Kind: StringLiteral, Value: "gold"},
+					}}}},
+					{Column: "age", Value: &Literal{Kind: NumberLiteral, Value: "21"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "update with an index hint",
+			input: "UPDATE users WITH (INDEX(idx_email)) SET age = 21 WHERE email = 'bob@example.com'",
+			want: Query{
+				Command: SQLUpdate,
+				Table:   "users",
+				Columns: []string{"age"},
+				Filter:  "email=bob@example.com",
+				Where:   &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "email"}, Right: &Literal{Kind: StringLiteral, Value: "bob@example.com"}},
+				Set:     []Assignment{{Column: "age", Value: &Literal{Kind: NumberLiteral, Value: "21"}}},
+				Hint:    "idx_email",
+			},
 			wantErr: false,
 		},
+		{
+			name:  "update with a regular expression filter",
+			input: "UPDATE users SET age = 21 WHERE name ~ '^B'",
+			want: Query{
+				Command: SQLUpdate,
+				Table:   "users",
+				Columns: []string{"age"},
+				Filter:  "name~^B",
+				Where:   &BinaryExpr{Op: "~", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "^B"}},
+				Set:     []Assignment{{Column: "age", Value: &Literal{Kind: NumberLiteral, Value: "21"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "update an aliased table",
+			input: "UPDATE users u SET age = 21 WHERE u.name = 'Bob'",
+			want: Query{
+				Command: SQLUpdate,
+				Table:   "users",
+				Alias:   "u",
+				Columns: []string{"age"},
+				Filter:  "u.name=Bob",
+				Where:   &BinaryExpr{Op: "=", Left: &ColumnRef{Table: "u", Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "Bob"}},
+				Set:     []Assignment{{Column: "age", Value: &Literal{Kind: NumberLiteral, Value: "21"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "update with a subquery filter",
+			input:   "UPDATE users SET a = 1 WHERE b IN (SELECT x FROM y)",
+			want:    Query{},
+			wantErr: true,
+		},
 		{
 			name:    "update with no filter",
 			input:   "UPDATE users SET age = 21, name = 'Bob'",
-			want:    Query{Command: SQLUpdate, Database: "", Table: "users", Columns: []string{"age", "name"}, Values: []interface{}{"21", "Bob"}, Filter: ""},
+			want:    Query{Command: SQLUpdate, Table: "users", Columns: []string{"age", "name"}, Set: []Assignment{{Column: "age", Value: &Literal{Kind: NumberLiteral, Value: "21"}}, {Column: "name", Value: &Literal{Kind: StringLiteral, Value: "Bob"}}}},
 			wantErr: false,
 		},
 		{
@@ -264,9 +336,50 @@ func TestHandleDeleteUserInput(t *testing.T) {
 		{
 			name:    "delete",
 			input:   "DELETE FROM users WHERE name = 'Bob'",
-			want:    Query{Command: SQLDelete, Database: "", Table: "users", Filter: "name=Bob"},
+			want:    Query{Command: SQLDelete, Table: "users", Filter: "name=Bob", Where: &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "Bob"}}},
+			wantErr: false,
+		},
+		{
+			name:  "delete with an optimizer hint",
+			input: "DELETE /*+ INDEX(users idx_name) */ FROM users WHERE name = 'Bob'",
+			want: Query{
+				Command: SQLDelete,
+				Table:   "users",
+				Filter:  "name=Bob",
+				Where:   &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "Bob"}},
+				Hint:    "idx_name",
+			},
+			wantErr: false,
+		},
+		{
+			name:  "delete with a LIKE filter",
+			input: "DELETE FROM users WHERE name LIKE 'B%'",
+			want: Query{
+				Command: SQLDelete,
+				Table:   "users",
+				Filter:  "nameLIKEB%",
+				Where:   &BinaryExpr{Op: "LIKE", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "B%"}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "delete from an aliased table",
+			input:   "DELETE FROM users u WHERE u.name = 'Bob'",
+			want:    Query{Command: SQLDelete, Table: "users", Alias: "u", Filter: "u.name=Bob", Where: &BinaryExpr{Op: "=", Left: &ColumnRef{Table: "u", Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "Bob"}}},
 			wantErr: false,
 		},
+		{
+			name:    "delete with a LIMIT",
+			input:   "DELETE FROM users WHERE a = 1 LIMIT 1",
+			want:    Query{},
+			wantErr: true,
+		},
+		{
+			name:    "delete with an incomplete filter",
+			input:   "DELETE FROM users WHERE a = 1 OR",
+			want:    Query{},
+			wantErr: true,
+		},
 		{
 			name:    "delete with no filter",
 			input:   "DELETE FROM users",
@@ -303,7 +416,7 @@ func TestConvertUserInputToSQLQuery(t *testing.T) {
 		{
 			name:    "select",
 			input:   "SELECT * FROM users WHERE name = 'Bob'",
-			want:    Query{Command: SQLSelect, Database: "", Table: "users", Columns: []string{"*"}, Filter: "name=Bob"},
+			want:    Query{Command: SQLSelect, Database: "", Table: "users", Columns: []string{"*"}, Filter: "name=Bob", Projections: []Projection{{Expr: &StarExpr{}}}, Where: &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "Bob"}}},
 			wantErr: false,
 		},
 		{
@@ -315,13 +428,13 @@ func TestConvertUserInputToSQLQuery(t *testing.T) {
 		{
 			name:    "update",
 			input:   "UPDATE users SET age = 21 WHERE name = 'Bob'",
-			want:    Query{Command: SQLUpdate, Database: "", Table: "users", Columns: []string{"age"}, Values: []interface{}{"21"}, Filter: "name=Bob"},
+			want:    Query{Command: SQLUpdate, Table: "users", Columns: []string{"age"}, Filter: "name=Bob", Where: &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "Bob"}}, Set: []Assignment{{Column: "age", Value: &Literal{Kind: NumberLiteral, Value: "21"}}}},
 			wantErr: false,
 		},
 		{
 			name:    "delete",
 			input:   "DELETE FROM users WHERE name = 'Bob'",
-			want:    Query{Command: SQLDelete, Database: "", Table: "users", Filter: "name=Bob"},
+			want:    Query{Command: SQLDelete, Table: "users", Filter: "name=Bob", Where: &BinaryExpr{Op: "=", Left: &ColumnRef{Name: "name"}, Right: &Literal{Kind: StringLiteral, Value: "Bob"}}},
 			wantErr: false,
 		},
 		{